@@ -0,0 +1,105 @@
+package ch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Credentials are the user name and the password sent in the handshake.
+type Credentials struct {
+	User     string
+	Password string
+}
+
+// Authenticator provides credentials for new connections. It is consulted
+// on each handshake so rotated credentials are picked up by new connections.
+type Authenticator interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// AuthenticatorFunc is an Authenticator that calls the function,
+// for example, to fetch credentials from a secret manager.
+type AuthenticatorFunc func(ctx context.Context) (Credentials, error)
+
+var _ Authenticator = (AuthenticatorFunc)(nil)
+
+func (fn AuthenticatorFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return fn(ctx)
+}
+
+// StaticPassword returns an Authenticator that always uses the same credentials.
+func StaticPassword(user, password string) Authenticator {
+	return AuthenticatorFunc(func(context.Context) (Credentials, error) {
+		return Credentials{User: user, Password: password}, nil
+	})
+}
+
+// EnvPassword returns an Authenticator that reads the password from
+// the environment variable on each handshake.
+func EnvPassword(user, envVar string) Authenticator {
+	return AuthenticatorFunc(func(context.Context) (Credentials, error) {
+		password, ok := os.LookupEnv(envVar)
+		if !ok {
+			return Credentials{}, fmt.Errorf("ch: environment variable %s is not set", envVar)
+		}
+		return Credentials{User: user, Password: password}, nil
+	})
+}
+
+//------------------------------------------------------------------------------
+
+// FilePassword returns an Authenticator that reads the password from the file,
+// for example, a mounted Kubernetes secret. The file is read again when its
+// modification time changes. Trailing newlines are removed.
+func FilePassword(user, path string) Authenticator {
+	return &fileAuthenticator{
+		user: user,
+		path: path,
+	}
+}
+
+type fileAuthenticator struct {
+	user string
+	path string
+
+	mu       sync.Mutex
+	password string
+	modTime  time.Time
+}
+
+var _ Authenticator = (*fileAuthenticator)(nil)
+
+func (a *fileAuthenticator) Credentials(ctx context.Context) (Credentials, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("ch: can't read password file: %w", err)
+	}
+
+	if !fi.ModTime().Equal(a.modTime) {
+		b, err := os.ReadFile(a.path)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("ch: can't read password file: %w", err)
+		}
+		a.password = strings.TrimRight(string(b), "\r\n")
+		a.modTime = fi.ModTime()
+	}
+
+	return Credentials{User: a.user, Password: a.password}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// credentials returns the credentials for a new connection.
+func (db *DB) credentials(ctx context.Context) (Credentials, error) {
+	if db.cfg.Authenticator == nil {
+		return Credentials{User: db.cfg.User, Password: db.cfg.Password}, nil
+	}
+	return db.cfg.Authenticator.Credentials(ctx)
+}
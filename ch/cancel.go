@@ -0,0 +1,97 @@
+package ch
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/uptrace/go-clickhouse/ch/chpool"
+)
+
+// queryTracker tracks ids of the queries that are executed by a DB and
+// its clones.
+type queryTracker struct {
+	mu      sync.Mutex
+	queries map[*chpool.Conn]trackedQuery
+}
+
+type trackedQuery struct {
+	id   string
+	host string // remote address of the connection
+}
+
+func newQueryTracker() *queryTracker {
+	return &queryTracker{
+		queries: make(map[*chpool.Conn]trackedQuery),
+	}
+}
+
+func (t *queryTracker) add(cn *chpool.Conn, queryID, host string) {
+	if queryID == "" {
+		return
+	}
+	t.mu.Lock()
+	t.queries[cn] = trackedQuery{id: queryID, host: host}
+	t.mu.Unlock()
+}
+
+func (t *queryTracker) remove(cn *chpool.Conn) {
+	t.mu.Lock()
+	delete(t.queries, cn)
+	t.mu.Unlock()
+}
+
+// byHost returns the query ids grouped by the host that executes the queries.
+func (t *queryTracker) byHost() map[string][]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hosts := make(map[string][]string)
+	for _, q := range t.queries {
+		hosts[q.host] = append(hosts[q.host], q.id)
+	}
+	return hosts
+}
+
+// CancelAll kills the in-flight queries started by the DB and its clones,
+// including sessions, using KILL QUERY. Because KILL QUERY only stops queries
+// on the server that receives it, the queries are grouped by the address of
+// the connection and KILL QUERY is sent to each address on a new connection.
+// It does not wait for the queries to stop. Queries executed on other servers,
+// for example, by a Distributed table, are stopped by the server that received
+// them. It returns the first error after trying all addresses.
+func (db *DB) CancelAll(ctx context.Context) error {
+	var firstErr error
+	for host, ids := range db.queries.byHost() {
+		if err := db.killQueries(ctx, host, ids); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (db *DB) killQueries(ctx context.Context, host string, ids []string) error {
+	// KILL QUERY must not wait for the session connection or a query slot.
+	hostDB := db.Admin()
+	hostDB.pool = newHostConnPool(db, host)
+	defer hostDB.pool.Close()
+
+	_, err := hostDB.ExecContext(ctx, "KILL QUERY WHERE query_id IN (?) ASYNC", In(ids))
+	return err
+}
+
+// newHostConnPool returns a pool with a single connection to the host, which is
+// the remote address of a connection dialed by the DB.
+func newHostConnPool(db *DB, host string) *chpool.ConnPool {
+	cfg := db.cfg
+	poolcfg := cfg.Config
+	poolcfg.PoolSize = 1
+	poolcfg.ReservedConns = 0
+	poolcfg.MinIdleConns = 0
+	poolcfg.HealthCheckInterval = 0
+	poolcfg.Dialer = func(ctx context.Context) (net.Conn, error) {
+		return cfg.dial(ctx, host)
+	}
+	poolcfg.OnConnect = db.warmConn
+	return chpool.New(&poolcfg)
+}
@@ -4,28 +4,99 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
+	"syscall"
+	"time"
 
+	"github.com/uptrace/go-clickhouse/ch/chpool"
+	"github.com/uptrace/go-clickhouse/ch/chproto"
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 )
 
 type (
-	Safe             = chschema.Safe
-	Ident            = chschema.Ident
-	CHModel          = chschema.CHModel
-	AfterScanRowHook = chschema.AfterScanRowHook
+	Safe                  = chschema.Safe
+	Ident                 = chschema.Ident
+	CHModel               = chschema.CHModel
+	AfterScanRowHook      = chschema.AfterScanRowHook
+	BeforeAppendModelHook = chschema.BeforeAppendModelHook
+	Compression           = chproto.Compression
+	ChecksumError         = chproto.ChecksumError
+	CompressionError      = chproto.CompressionError
+	Decimal               = chschema.Decimal
+	UUID                  = chschema.UUID
+	Point                 = chschema.Point
+	Ring                  = chschema.Ring
+	Polygon               = chschema.Polygon
+	MultiPolygon          = chschema.MultiPolygon
+)
+
+const (
+	CompressionNone = chproto.CompressionNone
+	CompressionLZ4  = chproto.CompressionLZ4
+	CompressionZSTD = chproto.CompressionZSTD
 )
 
 func SafeQuery(query string, args ...any) chschema.QueryWithArgs {
 	return chschema.SafeQuery(query, args)
 }
 
+// NewDecimal returns a decimal with the unscaled value and scale.
+func NewDecimal(value int64, scale int) Decimal {
+	return chschema.NewDecimal(value, scale)
+}
+
+// ParseDecimal parses a decimal number such as "-123.45".
+func ParseDecimal(s string) (Decimal, error) {
+	return chschema.ParseDecimal(s)
+}
+
+// DumpSchema returns the schema of the models. Without arguments, it returns
+// the schema of all models used so far by the process.
+func DumpSchema(models ...any) (*chschema.Schema, error) {
+	if len(models) == 0 {
+		return chschema.NewSchema(chschema.RegisteredTables()), nil
+	}
+
+	tables, err := modelTables("DumpSchema", models)
+	if err != nil {
+		return nil, err
+	}
+	return chschema.NewSchema(tables), nil
+}
+
+// RegisterModels registers the tables of the models, so they are returned by
+// chschema.RegisteredTables, chschema.TableByName, and DumpSchema before
+// the models are used in queries. Models are pointers to structs, for example,
+// (*Span)(nil).
+func RegisterModels(models ...any) ([]*chschema.Table, error) {
+	return modelTables("RegisterModels", models)
+}
+
+func modelTables(funcName string, models []any) ([]*chschema.Table, error) {
+	tables := make([]*chschema.Table, 0, len(models))
+	for _, model := range models {
+		typ := reflect.TypeOf(model)
+		for typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("ch: %s(unsupported %T)", funcName, model)
+		}
+		tables = append(tables, chschema.TableForType(typ))
+	}
+	return tables, nil
+}
+
 //------------------------------------------------------------------------------
 
 type result struct {
 	model    Model
 	affected int
+
+	totals   *chschema.Block // WITH TOTALS row, if any
+	extremes *chschema.Block // min and max rows when extremes=1
 }
 
 var _ sql.Result = (*result)(nil)
@@ -49,13 +120,102 @@ type Error struct {
 	Name       string
 	Message    string
 	StackTrace string
-	nested     error // TODO: wrap/unwrap
+	nested     error
 }
 
 func (exc *Error) Error() string {
 	return exc.Name + ": " + exc.Message
 }
 
+// Unwrap returns the nested exception, for example, the error from the remote
+// shard that failed a Distributed query.
+func (exc *Error) Unwrap() error {
+	return exc.nested
+}
+
+// ErrUnexpectedPacket is matched by errors.Is for every *UnexpectedPacketError.
+var ErrUnexpectedPacket = errors.New("ch: unexpected packet")
+
+// UnexpectedPacketError is returned when the server sends a packet the client
+// does not know how to handle in the current state. The connection is discarded.
+type UnexpectedPacketError struct {
+	Op       string // operation that was reading the packet, e.g. readDataBlocks
+	Packet   uint64 // packet code, see chproto.Server* constants
+	Revision uint64 // negotiated protocol revision
+	Addr     string // server address
+}
+
+func newUnexpectedPacketError(op string, cn *chpool.Conn, packet uint64) *UnexpectedPacketError {
+	return &UnexpectedPacketError{
+		Op:       op,
+		Packet:   packet,
+		Revision: cn.ServerInfo.Revision,
+		Addr:     cn.RemoteAddr().String(),
+	}
+}
+
+func (err *UnexpectedPacketError) Error() string {
+	return fmt.Sprintf("ch: %s: unexpected packet: %d (revision=%d addr=%s)",
+		err.Op, err.Packet, err.Revision, err.Addr)
+}
+
+func (err *UnexpectedPacketError) Unwrap() error {
+	return ErrUnexpectedPacket
+}
+
+// ErrConcurrencyLimit is matched by errors.Is for every *ConcurrencyLimitError.
+var ErrConcurrencyLimit = errors.New("ch: too many concurrent queries")
+
+// ConcurrencyLimitError is returned when a query waits for a free slot longer
+// than QueueTimeout. See WithMaxConcurrentQueries.
+type ConcurrencyLimitError struct {
+	Limit  int           // MaxConcurrentQueries
+	Waited time.Duration // how long the query waited in the queue
+}
+
+func (err *ConcurrencyLimitError) Error() string {
+	return fmt.Sprintf("ch: too many concurrent queries (limit=%d, waited %s)",
+		err.Limit, err.Waited)
+}
+
+func (err *ConcurrencyLimitError) Unwrap() error {
+	return ErrConcurrencyLimit
+}
+
+// RowError is returned when a row is rejected before it is appended to
+// the insert block, for example, by a BeforeAppendModel hook.
+type RowError struct {
+	Row int // index of the row in the model slice
+	Err error
+}
+
+func (err *RowError) Error() string {
+	return fmt.Sprintf("ch: row %d: %s", err.Row, err.Err)
+}
+
+func (err *RowError) Unwrap() error {
+	return err.Err
+}
+
+// InsertError is returned when an insert split into several blocks fails.
+// The blocks before Block were inserted. See InsertQuery.BlockSize.
+// With InsertQuery.DeadLetter, AcceptedRows also counts the rows of the failed
+// block that were inserted or passed to the dead-letter func.
+type InsertError struct {
+	Block        int // index of the failed block
+	AcceptedRows int // number of rows in the blocks that were inserted
+	Err          error
+}
+
+func (err *InsertError) Error() string {
+	return fmt.Sprintf("ch: insert block %d failed (%d rows inserted): %s",
+		err.Block, err.AcceptedRows, err.Err)
+}
+
+func (err *InsertError) Unwrap() error {
+	return err.Err
+}
+
 func isBadConn(err error, allowTimeout bool) bool {
 	if err == nil {
 		return false
@@ -68,6 +228,16 @@ func isBadConn(err error, allowTimeout bool) bool {
 	return true
 }
 
+// isStaleConnError reports whether err is returned when the server has closed
+// the connection.
+func isStaleConnError(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
 //------------------------------------------------------------------------------
 
 type InValues struct {
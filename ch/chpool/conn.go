@@ -20,18 +20,27 @@ type Conn struct {
 	ServerInfo chproto.ServerInfo
 
 	Inited    bool
+	reused    bool
+	reserved  bool // uses a reserved pool slot, see GetReserved
+	received  int64
+	written   int64
 	createdAt time.Time
 	usedAt    int64  // atomic
 	closed    uint32 // atomic
 }
 
 func NewConn(netConn net.Conn) *Conn {
+	return NewConnSize(netConn, 0, 0)
+}
+
+// NewConnSize is like NewConn, but uses the specified read and write buffer sizes.
+func NewConnSize(netConn net.Conn, readBufSize, writeBufSize int) *Conn {
 	cn := &Conn{
 		netConn:   netConn,
-		rd:        chproto.NewReader(netConn),
-		wr:        chproto.NewWriter(netConn),
 		createdAt: time.Now(),
 	}
+	cn.rd = chproto.NewReaderSize(connReader{cn}, readBufSize)
+	cn.wr = chproto.NewWriterSize(connWriter{cn}, writeBufSize)
 	cn.SetUsedAt(time.Now())
 	return cn
 }
@@ -45,6 +54,22 @@ func (cn *Conn) SetUsedAt(tm time.Time) {
 	atomic.StoreInt64(&cn.usedAt, tm.Unix())
 }
 
+// Reused reports whether the connection was taken from the idle connections
+// and so may have been closed by the server while it was idle.
+func (cn *Conn) Reused() bool {
+	return cn.reused
+}
+
+// Received returns the number of bytes read from the network connection.
+func (cn *Conn) Received() int64 {
+	return cn.received
+}
+
+// Written returns the number of bytes written to the network connection.
+func (cn *Conn) Written() int64 {
+	return cn.written
+}
+
 func (cn *Conn) LocalAddr() net.Addr {
 	return cn.netConn.LocalAddr()
 }
@@ -130,3 +155,23 @@ func (cn *Conn) deadline(ctx context.Context, timeout time.Duration) time.Time {
 
 	return noDeadline
 }
+
+type connReader struct {
+	cn *Conn
+}
+
+func (r connReader) Read(b []byte) (int, error) {
+	n, err := r.cn.netConn.Read(b)
+	r.cn.received += int64(n)
+	return n, err
+}
+
+type connWriter struct {
+	cn *Conn
+}
+
+func (w connWriter) Write(b []byte) (int, error) {
+	n, err := w.cn.netConn.Write(b)
+	w.cn.written += int64(n)
+	return n, err
+}
@@ -73,14 +73,31 @@ type Pooler interface {
 }
 
 type Config struct {
-	Dialer  func(context.Context) (net.Conn, error)
-	OnClose func(*Conn) error
-
-	PoolSize        int
+	Dialer func(context.Context) (net.Conn, error)
+	// OnConnect is called for connections that are dialed in the background
+	// to maintain MinIdleConns, before they are added to the idle list.
+	OnConnect func(context.Context, *Conn) error
+	OnClose   func(*Conn) error
+	// HealthCheck is called every HealthCheckInterval for each idle connection.
+	// Connections that fail the check are closed.
+	HealthCheck         func(context.Context, *Conn) error
+	HealthCheckInterval time.Duration
+
+	PoolSize int
+	// ReservedConns is the number of PoolSize slots that are used only by
+	// GetReserved when the other slots are taken. At least one slot is
+	// always left for Get.
+	ReservedConns   int
 	PoolTimeout     time.Duration
+	MinIdleConns    int
 	MaxIdleConns    int
 	ConnMaxIdleTime time.Duration
 	ConnMaxLifetime time.Duration
+
+	// ReadBufferSize and WriteBufferSize are sizes of the buffered
+	// reader and writer used by each connection. Zero means 4KiB.
+	ReadBufferSize  int
+	WriteBufferSize int
 }
 
 type ConnPool struct {
@@ -88,34 +105,151 @@ type ConnPool struct {
 
 	dialErrorsNum uint32 // atomic
 
-	_closed uint32 // atomic
+	_closed  uint32 // atomic
+	closedCh chan struct{}
 
 	lastDialErrorMu sync.RWMutex
 	lastDialError   error
 
-	queue chan struct{}
+	queue         chan struct{}
+	reservedQueue chan struct{} // nil unless ReservedConns is set
 
 	stats Stats
 
-	connsMu   sync.Mutex
-	conns     []*Conn
-	idleConns []*Conn
+	connsMu      sync.Mutex
+	conns        []*Conn
+	idleConns    []*Conn
+	dialingConns int
+	drained      chan struct{} // closed when all conns are removed after Shutdown
 }
 
 var _ Pooler = (*ConnPool)(nil)
 
 func New(cfg *Config) *ConnPool {
+	reserved := cfg.ReservedConns
+	if reserved >= cfg.PoolSize {
+		reserved = cfg.PoolSize - 1
+	}
+	if reserved < 0 {
+		reserved = 0
+	}
+
 	p := &ConnPool{
 		cfg: cfg,
 
-		queue:     make(chan struct{}, cfg.PoolSize),
+		closedCh:  make(chan struct{}),
+		queue:     make(chan struct{}, cfg.PoolSize-reserved),
 		conns:     make([]*Conn, 0, cfg.PoolSize),
 		idleConns: make([]*Conn, 0, cfg.PoolSize),
 	}
+	if reserved > 0 {
+		p.reservedQueue = make(chan struct{}, reserved)
+	}
+
+	p.connsMu.Lock()
+	p.checkMinIdleConns()
+	p.connsMu.Unlock()
+
+	if cfg.HealthCheck != nil && cfg.HealthCheckInterval > 0 {
+		go p.healthCheckLoop()
+	}
 
 	return p
 }
 
+// checkMinIdleConns dials connections in the background until there are
+// at least MinIdleConns idle connections. connsMu must be held.
+func (p *ConnPool) checkMinIdleConns() {
+	if p.cfg.MinIdleConns == 0 || p.closed() {
+		return
+	}
+	for len(p.conns)+p.dialingConns < p.cfg.PoolSize &&
+		len(p.idleConns)+p.dialingConns < p.cfg.MinIdleConns {
+		p.dialingConns++
+		go func() {
+			if err := p.addIdleConn(); err != nil && err != ErrClosed {
+				internal.Logger.Printf("addIdleConn failed: %s", err)
+			}
+		}()
+	}
+}
+
+func (p *ConnPool) addIdleConn() error {
+	ctx := context.Background()
+
+	cn, err := p.dialConn(ctx)
+	if err == nil && p.cfg.OnConnect != nil {
+		if err = p.cfg.OnConnect(ctx, cn); err != nil {
+			_ = cn.Close()
+		}
+	}
+
+	p.connsMu.Lock()
+	defer p.connsMu.Unlock()
+
+	p.dialingConns--
+	if err != nil {
+		return err
+	}
+
+	if p.closed() {
+		_ = cn.Close()
+		return ErrClosed
+	}
+
+	p.conns = append(p.conns, cn)
+	p.idleConns = append(p.idleConns, cn)
+	return nil
+}
+
+func (p *ConnPool) healthCheckLoop() {
+	ticker := time.NewTicker(p.cfg.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.checkIdleConns()
+		case <-p.closedCh:
+			return
+		}
+	}
+}
+
+// checkIdleConns checks idle connections one by one starting with the
+// least recently used so the rest of the connections stay available.
+func (p *ConnPool) checkIdleConns() {
+	p.connsMu.Lock()
+	n := len(p.idleConns)
+	p.connsMu.Unlock()
+
+	for i := 0; i < n; i++ {
+		p.connsMu.Lock()
+		if len(p.idleConns) == 0 {
+			p.connsMu.Unlock()
+			return
+		}
+		cn := p.idleConns[0]
+		p.idleConns = append(p.idleConns[:0], p.idleConns[1:]...)
+		p.connsMu.Unlock()
+
+		if !p.isHealthyConn(cn) || p.cfg.HealthCheck(context.Background(), cn) != nil {
+			atomic.AddUint32(&p.stats.StaleConns, 1)
+			_ = p.CloseConn(cn)
+			continue
+		}
+
+		p.connsMu.Lock()
+		if p.closed() {
+			p.connsMu.Unlock()
+			_ = p.CloseConn(cn)
+			return
+		}
+		p.idleConns = append(p.idleConns, cn)
+		p.connsMu.Unlock()
+	}
+}
+
 func (p *ConnPool) NewConn(ctx context.Context) (*Conn, error) {
 	cn, err := p.dialConn(ctx)
 	if err != nil {
@@ -146,7 +280,7 @@ func (p *ConnPool) dialConn(ctx context.Context) (*Conn, error) {
 		return nil, err
 	}
 
-	cn := NewConn(netConn)
+	cn := NewConnSize(netConn, p.cfg.ReadBufferSize, p.cfg.WriteBufferSize)
 	return cn, nil
 }
 
@@ -184,11 +318,22 @@ func (p *ConnPool) getLastDialError() error {
 
 // Get returns an existing connection from the pool or creates a new one.
 func (p *ConnPool) Get(ctx context.Context) (*Conn, error) {
+	return p.get(ctx, false)
+}
+
+// GetReserved is like Get, but uses a reserved slot when the other slots
+// are taken, for example, for health checks and KILL QUERY while the pool
+// is saturated by long queries. See Config.ReservedConns.
+func (p *ConnPool) GetReserved(ctx context.Context) (*Conn, error) {
+	return p.get(ctx, true)
+}
+
+func (p *ConnPool) get(ctx context.Context, reserved bool) (*Conn, error) {
 	if p.closed() {
 		return nil, ErrClosed
 	}
 
-	err := p.waitTurn(ctx)
+	reserved, err := p.waitTurn(ctx, reserved)
 	if err != nil {
 		return nil, err
 	}
@@ -196,22 +341,22 @@ func (p *ConnPool) Get(ctx context.Context) (*Conn, error) {
 	for {
 		p.connsMu.Lock()
 		cn := p.popIdle()
+		p.checkMinIdleConns()
 		p.connsMu.Unlock()
 
 		if cn == nil {
 			break
 		}
 
-		if p.cfg.ConnMaxLifetime > 0 && time.Since(cn.createdAt) >= p.cfg.ConnMaxLifetime {
-			_ = p.CloseConn(cn)
-			continue
-		}
-		if p.cfg.ConnMaxIdleTime > 0 && time.Since(cn.UsedAt()) >= p.cfg.ConnMaxIdleTime {
+		if !p.isHealthyConn(cn) {
+			atomic.AddUint32(&p.stats.StaleConns, 1)
 			_ = p.CloseConn(cn)
 			continue
 		}
 
 		atomic.AddUint32(&p.stats.Hits, 1)
+		cn.reused = true
+		cn.reserved = reserved
 		return cn, nil
 	}
 
@@ -219,27 +364,56 @@ func (p *ConnPool) Get(ctx context.Context) (*Conn, error) {
 
 	newcn, err := p.NewConn(ctx)
 	if err != nil {
-		p.freeTurn()
+		p.freeTurn(reserved)
 		return nil, err
 	}
 
+	newcn.reserved = reserved
 	return newcn, nil
 }
 
+// isHealthyConn reports whether the connection is still within
+// ConnMaxLifetime and ConnMaxIdleTime.
+func (p *ConnPool) isHealthyConn(cn *Conn) bool {
+	now := time.Now()
+
+	if p.cfg.ConnMaxLifetime > 0 && now.Sub(cn.createdAt) >= p.cfg.ConnMaxLifetime {
+		return false
+	}
+	if p.cfg.ConnMaxIdleTime > 0 && now.Sub(cn.UsedAt()) >= p.cfg.ConnMaxIdleTime {
+		return false
+	}
+
+	return true
+}
+
 func (p *ConnPool) getTurn() {
 	p.queue <- struct{}{}
 }
 
-func (p *ConnPool) waitTurn(ctx context.Context) error {
+// waitTurn waits for a free slot. With reserved, it also waits for a reserved
+// slot and reports whether the reserved slot was taken.
+func (p *ConnPool) waitTurn(ctx context.Context, reserved bool) (bool, error) {
+	var reservedQueue chan struct{}
+	if reserved {
+		reservedQueue = p.reservedQueue
+	}
+
 	select {
 	case <-ctx.Done():
-		return ctx.Err()
+		return false, ctx.Err()
 	default:
 	}
 
 	select {
 	case p.queue <- struct{}{}:
-		return nil
+		return false, nil
+	default:
+	}
+
+	select {
+	case reservedQueue <- struct{}{}:
+		return true, nil
 	default:
 	}
 
@@ -252,21 +426,31 @@ func (p *ConnPool) waitTurn(ctx context.Context) error {
 			<-timer.C
 		}
 		timers.Put(timer)
-		return ctx.Err()
+		return false, ctx.Err()
 	case p.queue <- struct{}{}:
 		if !timer.Stop() {
 			<-timer.C
 		}
 		timers.Put(timer)
-		return nil
+		return false, nil
+	case reservedQueue <- struct{}{}:
+		if !timer.Stop() {
+			<-timer.C
+		}
+		timers.Put(timer)
+		return true, nil
 	case <-timer.C:
 		timers.Put(timer)
 		atomic.AddUint32(&p.stats.Timeouts, 1)
-		return ErrPoolTimeout
+		return false, ErrPoolTimeout
 	}
 }
 
-func (p *ConnPool) freeTurn() {
+func (p *ConnPool) freeTurn(reserved bool) {
+	if reserved {
+		<-p.reservedQueue
+		return
+	}
 	<-p.queue
 }
 
@@ -282,12 +466,23 @@ func (p *ConnPool) popIdle() *Conn {
 }
 
 func (p *ConnPool) Put(cn *Conn) {
+	if p.closed() {
+		p.Remove(cn, nil)
+		return
+	}
+
 	if cn.rd.Buffered() > 0 {
 		internal.Logger.Printf("Conn has unread data")
 		p.Remove(cn, BadConnError{})
 		return
 	}
 
+	if p.cfg.ConnMaxLifetime > 0 && time.Since(cn.createdAt) >= p.cfg.ConnMaxLifetime {
+		atomic.AddUint32(&p.stats.StaleConns, 1)
+		p.Remove(cn, nil)
+		return
+	}
+
 	var atMaxCap bool
 
 	p.connsMu.Lock()
@@ -302,14 +497,15 @@ func (p *ConnPool) Put(cn *Conn) {
 
 	if atMaxCap {
 		p.Remove(cn, nil)
+		return
 	}
 
-	p.freeTurn()
+	p.freeTurn(cn.reserved)
 }
 
 func (p *ConnPool) Remove(cn *Conn, reason error) {
 	p.removeConnWithLock(cn)
-	p.freeTurn()
+	p.freeTurn(cn.reserved)
 	_ = p.closeConn(cn)
 }
 
@@ -321,6 +517,7 @@ func (p *ConnPool) CloseConn(cn *Conn) error {
 func (p *ConnPool) removeConnWithLock(cn *Conn) {
 	p.connsMu.Lock()
 	p.removeConn(cn)
+	p.checkMinIdleConns()
 	p.connsMu.Unlock()
 }
 
@@ -328,9 +525,13 @@ func (p *ConnPool) removeConn(cn *Conn) {
 	for i, c := range p.conns {
 		if c == cn {
 			p.conns = append(p.conns[:i], p.conns[i+1:]...)
-			return
+			break
 		}
 	}
+	if p.drained != nil && len(p.conns) == 0 {
+		close(p.drained)
+		p.drained = nil
+	}
 }
 
 func (p *ConnPool) closeConn(cn *Conn) error {
@@ -376,6 +577,7 @@ func (p *ConnPool) Close() error {
 	if !atomic.CompareAndSwapUint32(&p._closed, 0, 1) {
 		return ErrClosed
 	}
+	close(p.closedCh)
 
 	var firstErr error
 	p.connsMu.Lock()
@@ -390,3 +592,54 @@ func (p *ConnPool) Close() error {
 
 	return firstErr
 }
+
+// Shutdown closes the pool gracefully. It stops handing out connections,
+// closes idle connections, and waits until checked-out connections are
+// returned to the pool. If ctx is done before that, the remaining connections
+// are closed and ctx.Err() is returned.
+func (p *ConnPool) Shutdown(ctx context.Context) error {
+	if !atomic.CompareAndSwapUint32(&p._closed, 0, 1) {
+		return ErrClosed
+	}
+	close(p.closedCh)
+
+	p.connsMu.Lock()
+	idleConns := p.idleConns
+	p.idleConns = nil
+	for _, cn := range idleConns {
+		p.removeConn(cn)
+	}
+	var drained chan struct{}
+	if len(p.conns) > 0 {
+		drained = make(chan struct{})
+		p.drained = drained
+	}
+	p.connsMu.Unlock()
+
+	var firstErr error
+	for _, cn := range idleConns {
+		if err := p.closeConn(cn); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	if drained == nil {
+		return firstErr
+	}
+
+	select {
+	case <-drained:
+		return firstErr
+	case <-ctx.Done():
+	}
+
+	p.connsMu.Lock()
+	for _, cn := range p.conns {
+		_ = p.closeConn(cn)
+	}
+	p.conns = nil
+	p.drained = nil
+	p.connsMu.Unlock()
+
+	return ctx.Err()
+}
@@ -0,0 +1,90 @@
+package chpool_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chpool"
+)
+
+func TestReservedConns(t *testing.T) {
+	pool := chpool.New(&chpool.Config{
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			client, server := net.Pipe()
+			t.Cleanup(func() { server.Close() })
+			return client, nil
+		},
+		PoolSize:      2,
+		ReservedConns: 1,
+		PoolTimeout:   10 * time.Millisecond,
+		MaxIdleConns:  2,
+	})
+	defer pool.Close()
+
+	ctx := context.Background()
+
+	cn1, err := pool.Get(ctx)
+	require.NoError(t, err)
+
+	// The second slot is reserved.
+	_, err = pool.Get(ctx)
+	require.ErrorIs(t, err, chpool.ErrPoolTimeout)
+
+	cn2, err := pool.GetReserved(ctx)
+	require.NoError(t, err)
+
+	_, err = pool.GetReserved(ctx)
+	require.ErrorIs(t, err, chpool.ErrPoolTimeout)
+
+	// Releasing the reserved conn frees the reserved slot.
+	pool.Put(cn2)
+	cn2, err = pool.GetReserved(ctx)
+	require.NoError(t, err)
+
+	// Releasing the regular conn frees the regular slot.
+	pool.Put(cn1)
+	_, err = pool.Get(ctx)
+	require.NoError(t, err)
+
+	pool.Remove(cn2, nil)
+	_, err = pool.GetReserved(ctx)
+	require.NoError(t, err)
+}
+
+func TestPutMaxIdleConns(t *testing.T) {
+	pool := chpool.New(&chpool.Config{
+		Dialer: func(ctx context.Context) (net.Conn, error) {
+			client, server := net.Pipe()
+			t.Cleanup(func() { server.Close() })
+			return client, nil
+		},
+		PoolSize:     2,
+		PoolTimeout:  10 * time.Millisecond,
+		MaxIdleConns: 1,
+	})
+	defer pool.Close()
+
+	ctx := context.Background()
+
+	cn1, err := pool.Get(ctx)
+	require.NoError(t, err)
+	cn2, err := pool.Get(ctx)
+	require.NoError(t, err)
+
+	pool.Put(cn1)
+	// The idle list is full so the conn is closed and its slot is freed once.
+	pool.Put(cn2)
+	require.Equal(t, 1, pool.Len())
+	require.Equal(t, 1, pool.IdleLen())
+
+	_, err = pool.Get(ctx)
+	require.NoError(t, err)
+	_, err = pool.Get(ctx)
+	require.NoError(t, err)
+	_, err = pool.Get(ctx)
+	require.ErrorIs(t, err, chpool.ErrPoolTimeout)
+}
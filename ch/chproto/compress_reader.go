@@ -0,0 +1,233 @@
+package chproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/uptrace/go-clickhouse/ch/internal/cityhash102"
+)
+
+var errUnreadData = errors.New("ch: compressed reader was closed with unread data")
+
+// Checksum is a CityHash128 checksum of a compressed block.
+type Checksum struct {
+	Low, High uint64
+}
+
+func (c Checksum) String() string {
+	return fmt.Sprintf("%016x%016x", c.High, c.Low)
+}
+
+// ChecksumError is returned when the checksum of a compressed block
+// does not match its contents, i.e. the block was corrupted in transit.
+type ChecksumError struct {
+	Expected Checksum // checksum from the block header
+	Actual   Checksum // checksum of the received data
+	Block    int      // index of the block in the compressed stream
+	Offset   int64    // offset of the block in the compressed stream
+	Size     int      // compressed size of the block including the header
+}
+
+func (err *ChecksumError) Error() string {
+	return fmt.Sprintf("ch: checksum mismatch in compressed block %d at offset %d "+
+		"(size=%d): expected %s, got %s",
+		err.Block, err.Offset, err.Size, err.Expected, err.Actual)
+}
+
+// compressReader reads compressed blocks. The compression method is read
+// from the block header so it does not depend on the configured Compression.
+type compressReader struct {
+	rd *bufio.Reader
+
+	header   []byte
+	zstd     *zstd.Decoder
+	noVerify bool
+	offset   int64 // number of compressed bytes read so far
+	block    int   // number of compressed blocks read so far
+
+	zdata []byte
+	data  []byte
+	pos   int
+}
+
+func newCompressReader(r *bufio.Reader) *compressReader {
+	return &compressReader{
+		rd: r,
+
+		header: make([]byte, headerSize),
+	}
+}
+
+func (r *compressReader) Release() error {
+	var err error
+	if r.Buffered() > 0 {
+		err = errUnreadData
+	}
+
+	r.data = r.data[:0]
+	r.pos = 0
+	r.zdata = r.zdata[:0]
+
+	return err
+}
+
+func (r *compressReader) Buffered() int {
+	return len(r.data) - r.pos
+}
+
+func (r *compressReader) Read(buf []byte) (int, error) {
+	var nread int
+
+	if r.pos < len(r.data) {
+		n := copy(buf, r.data[r.pos:])
+		nread += n
+		r.pos += n
+	}
+
+	for nread < len(buf) {
+		if err := r.readData(); err != nil {
+			return nread, err
+		}
+
+		n := copy(buf[nread:], r.data)
+		nread += n
+		r.pos = n
+	}
+
+	return nread, nil
+}
+
+func (r *compressReader) ReadByte() (byte, error) {
+	if r.pos == len(r.data) {
+		if err := r.readData(); err != nil {
+			return 0, err
+		}
+	}
+
+	if r.pos < len(r.data) {
+		c := r.data[r.pos]
+		r.pos++
+		return c, nil
+	}
+
+	return 0, io.EOF
+}
+
+func (r *compressReader) readData() error {
+	if r.pos != len(r.data) {
+		panic("not reached")
+	}
+
+	_, err := io.ReadFull(r.rd, r.header)
+	if err != nil {
+		return err
+	}
+
+	method := r.header[16]
+	compressedSize := int(binary.LittleEndian.Uint32(r.header[17:])) - compressionHeaderSize
+	uncompressedSize := int(binary.LittleEndian.Uint32(r.header[21:]))
+	if compressedSize < 0 {
+		return fmt.Errorf("ch: invalid compressed block size at offset %d: %d",
+			r.offset, compressedSize+compressionHeaderSize)
+	}
+
+	// Keep the compression header in front of the data, because it is covered
+	// by the checksum.
+	r.zdata = grow(r.zdata, compressionHeaderSize+compressedSize)
+	copy(r.zdata, r.header[checksumSize:])
+	zdata := r.zdata[compressionHeaderSize:]
+	r.data = grow(r.data, uncompressedSize)
+
+	if _, err := io.ReadFull(r.rd, zdata); err != nil {
+		return err
+	}
+
+	offset, block := r.offset, r.block
+	r.offset += int64(headerSize + compressedSize)
+	r.block++
+
+	if !r.noVerify {
+		if err := verifyChecksum(r.header, r.zdata, block, offset); err != nil {
+			return err
+		}
+	}
+
+	r.data, err = decompress(&r.zstd, method, zdata, r.data)
+	if err != nil {
+		return err
+	}
+
+	r.pos = 0
+	return nil
+}
+
+// decompress decompresses zdata into data, which must have the uncompressed size.
+func decompress(dec **zstd.Decoder, method byte, zdata, data []byte) ([]byte, error) {
+	switch method {
+	case lz4Compression:
+		if _, err := lz4.UncompressBlock(zdata, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	case zstdCompression:
+		if *dec == nil {
+			d, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			*dec = d
+		}
+		b, err := (*dec).DecodeAll(zdata, data[:0])
+		if err != nil {
+			return nil, err
+		}
+		if len(b) != len(data) {
+			return nil, fmt.Errorf("ch: zstd block has %d bytes, wanted %d", len(b), len(data))
+		}
+		return b, nil
+	case noCompression:
+		if len(zdata) != len(data) {
+			return nil, fmt.Errorf("ch: uncompressed block has %d bytes, wanted %d",
+				len(zdata), len(data))
+		}
+		copy(data, zdata)
+		return data, nil
+	default:
+		return nil, fmt.Errorf("ch: unsupported compression method: 0x%02x", method)
+	}
+}
+
+func verifyChecksum(header, data []byte, block int, offset int64) error {
+	expected := Checksum{
+		Low:  binary.LittleEndian.Uint64(header[0:]),
+		High: binary.LittleEndian.Uint64(header[8:]),
+	}
+	sum := cityhash102.CityHash128(data, uint32(len(data)))
+	actual := Checksum{
+		Low:  sum.Lower64(),
+		High: sum.Higher64(),
+	}
+	if actual != expected {
+		return &ChecksumError{
+			Expected: expected,
+			Actual:   actual,
+			Block:    block,
+			Offset:   offset,
+			Size:     checksumSize + len(data),
+		}
+	}
+	return nil
+}
+
+func grow(b []byte, n int) []byte {
+	if cap(b) < n {
+		return make([]byte, n)
+	}
+	return b[:n]
+}
@@ -0,0 +1,93 @@
+package chproto_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+func TestCompression(t *testing.T) {
+	methods := []chproto.Compression{
+		chproto.CompressionLZ4,
+		chproto.CompressionZSTD,
+	}
+	for _, method := range methods {
+		for _, level := range []int{0, 3} {
+			testCompression(t, method, level)
+		}
+	}
+}
+
+func testCompression(t *testing.T, method chproto.Compression, level int) {
+	t.Run(fmt.Sprintf("%s-%d", method, level), func(t *testing.T) {
+		var buf bytes.Buffer
+		long := strings.Repeat("hello world ", 200000)
+
+		wr := chproto.NewWriter(&buf)
+		wr.SetCompressionLevel(level)
+		wr.SetCompressionBlockSize(64 << 10)
+		wr.SetStrictChecksums(true)
+		wr.WithCompression(method, func() error {
+			wr.String("foo")
+			wr.String(long)
+			return nil
+		})
+		require.NoError(t, wr.Flush())
+		require.Less(t, buf.Len(), len(long))
+
+		rd := chproto.NewReader(&buf)
+		err := rd.WithCompression(true, func() error {
+			s, err := rd.String()
+			require.NoError(t, err)
+			require.Equal(t, "foo", s)
+
+			s, err = rd.String()
+			require.NoError(t, err)
+			require.Equal(t, long, s)
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	var buf bytes.Buffer
+
+	wr := chproto.NewWriter(&buf)
+	wr.WithCompression(chproto.CompressionLZ4, func() error {
+		wr.String("foo")
+		return nil
+	})
+	require.NoError(t, wr.Flush())
+
+	data := buf.Bytes()
+	data[len(data)-1] ^= 0xff // corrupt the payload
+
+	rd := chproto.NewReader(bytes.NewReader(data))
+	err := rd.WithCompression(true, func() error {
+		_, err := rd.String()
+		return err
+	})
+
+	var checksumErr *chproto.ChecksumError
+	require.True(t, errors.As(err, &checksumErr), "got %v", err)
+	require.Equal(t, 0, checksumErr.Block)
+	require.Equal(t, int64(0), checksumErr.Offset)
+	require.Equal(t, len(data), checksumErr.Size)
+	require.NotEqual(t, checksumErr.Expected, checksumErr.Actual)
+
+	rd = chproto.NewReader(bytes.NewReader(data))
+	rd.SetChecksumVerification(false)
+	err = rd.WithCompression(true, func() error {
+		_, err := rd.String()
+		return err
+	})
+	var skipErr *chproto.ChecksumError
+	require.False(t, errors.As(err, &skipErr))
+}
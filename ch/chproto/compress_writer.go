@@ -0,0 +1,265 @@
+package chproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+
+	"github.com/uptrace/go-clickhouse/ch/internal"
+	"github.com/uptrace/go-clickhouse/ch/internal/cityhash102"
+)
+
+// Compression is a method used to compress data blocks.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionLZ4  Compression = "lz4"
+	CompressionZSTD Compression = "zstd"
+)
+
+// Enabled reports whether blocks are compressed.
+func (c Compression) Enabled() bool {
+	return c != "" && c != CompressionNone
+}
+
+const (
+	noCompression   = 0x02
+	lz4Compression  = 0x82
+	zstdCompression = 0x90
+)
+
+const (
+	checksumSize          = 16        // city hash 128
+	compressionHeaderSize = 1 + 4 + 4 // method + compressed + uncompressed
+
+	headerSize = checksumSize + compressionHeaderSize
+
+	defaultBlockSize = 1 << 20 // 1 MB
+	maxLZ4Level      = 9
+)
+
+// CompressionError is returned by writers with strict checksums when
+// a compressed block does not decompress back to the written data.
+type CompressionError struct {
+	Method Compression
+	Block  int   // index of the block in the compressed stream
+	Offset int64 // offset of the block in the compressed stream
+	Err    error // decompression error, if any
+}
+
+func (err *CompressionError) Error() string {
+	msg := "data mismatch after decompression"
+	if err.Err != nil {
+		msg = err.Err.Error()
+	}
+	return fmt.Sprintf("ch: verifying %s block %d at offset %d: %s",
+		err.Method, err.Block, err.Offset, msg)
+}
+
+func (err *CompressionError) Unwrap() error {
+	return err.Err
+}
+
+//------------------------------------------------------------------------------
+
+type compressWriter struct {
+	wr *bufio.Writer
+
+	method Compression
+	level  int
+	zstd   *zstd.Encoder
+
+	strict bool
+	dec    *zstd.Decoder
+	verify []byte
+	block  int   // number of compressed blocks written so far
+	offset int64 // number of compressed bytes written so far
+
+	data  []byte
+	pos   int
+	zdata []byte
+}
+
+func newCompressWriter(w *bufio.Writer) *compressWriter {
+	return &compressWriter{
+		wr:   w,
+		data: make([]byte, defaultBlockSize),
+	}
+}
+
+func (w *compressWriter) setLevel(level int) {
+	if level != w.level {
+		w.level = level
+		w.zstd = nil
+	}
+}
+
+// setBlockSize must be called when the writer does not have buffered data.
+func (w *compressWriter) setBlockSize(size int) {
+	if size <= 0 {
+		size = defaultBlockSize
+	}
+	if size != len(w.data) {
+		w.data = make([]byte, size)
+	}
+}
+
+func (w *compressWriter) Close() error {
+	err := w.flush()
+	w.pos = 0
+	return err
+}
+
+func (w *compressWriter) Flush() error {
+	return w.Close()
+}
+
+func (w *compressWriter) WriteByte(c byte) error {
+	w.data[w.pos] = c
+	w.pos++
+	return w.checkFlush()
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write(internal.Bytes(s))
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	var written int
+	for len(data) > 0 {
+		n := copy(w.data[w.pos:], data)
+		data = data[n:]
+		w.pos += n
+		if err := w.checkFlush(); err != nil {
+			return written, err
+		}
+		written += n
+	}
+	return written, nil
+}
+
+func (w *compressWriter) checkFlush() error {
+	if w.pos < len(w.data) {
+		return nil
+	}
+	return w.flush()
+}
+
+func (w *compressWriter) flush() error {
+	if w.pos == 0 {
+		return nil
+	}
+
+	if err := w.compress(); err != nil {
+		return err
+	}
+	compressedSize := len(w.zdata) - checksumSize
+
+	binary.LittleEndian.PutUint32(w.zdata[17:], uint32(compressedSize))
+	binary.LittleEndian.PutUint32(w.zdata[21:], uint32(w.pos))
+
+	checkSum := cityhash102.CityHash128(w.zdata[16:], uint32(compressedSize))
+	binary.LittleEndian.PutUint64(w.zdata[0:], checkSum.Lower64())
+	binary.LittleEndian.PutUint64(w.zdata[8:], checkSum.Higher64())
+
+	if w.strict {
+		if err := w.verifyBlock(); err != nil {
+			return err
+		}
+	}
+
+	w.block++
+	w.offset += int64(len(w.zdata))
+
+	w.wr.Write(w.zdata)
+	w.pos = 0
+
+	return nil
+}
+
+// verifyBlock checks that the compressed block in w.zdata has a valid checksum
+// and decompresses to w.data.
+func (w *compressWriter) verifyBlock() error {
+	if err := verifyChecksum(w.zdata[:checksumSize], w.zdata[checksumSize:],
+		w.block, w.offset); err != nil {
+		return err
+	}
+
+	w.verify = grow(w.verify, w.pos)
+	data, err := decompress(&w.dec, w.zdata[16], w.zdata[headerSize:], w.verify)
+	if err != nil || !bytes.Equal(data, w.data[:w.pos]) {
+		return &CompressionError{
+			Method: w.method,
+			Block:  w.block,
+			Offset: w.offset,
+			Err:    err,
+		}
+	}
+	return nil
+}
+
+// compress compresses w.data into w.zdata leaving space for the header.
+func (w *compressWriter) compress() error {
+	src := w.data[:w.pos]
+
+	switch w.method {
+	case CompressionLZ4:
+		w.zdata = grow(w.zdata, headerSize+lz4.CompressBlockBound(len(src)))
+		n, err := compressLZ4(w.zdata[headerSize:], src, w.level)
+		if err != nil {
+			return err
+		}
+		w.zdata = w.zdata[:headerSize+n]
+		w.zdata[16] = lz4Compression
+	case CompressionZSTD:
+		if w.zstd == nil {
+			opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+			if w.level > 0 {
+				opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(w.level)))
+			}
+			enc, err := zstd.NewWriter(nil, opts...)
+			if err != nil {
+				return err
+			}
+			w.zstd = enc
+		}
+		w.zdata = w.zstd.EncodeAll(src, grow(w.zdata, headerSize))
+		w.zdata[16] = zstdCompression
+	case CompressionNone:
+		w.zdata = append(grow(w.zdata, headerSize), src...)
+		w.zdata[16] = noCompression
+	default:
+		return fmt.Errorf("ch: unsupported compression method: %q", w.method)
+	}
+
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// compressLZ4 uses LZ4HC when level is positive.
+func compressLZ4(dest, src []byte, level int) (int, error) {
+	if len(src) < 16 {
+		return uncompressable(dest, src), nil
+	}
+	if level > 0 {
+		if level > maxLZ4Level {
+			level = maxLZ4Level
+		}
+		c := lz4.CompressorHC{Level: lz4.CompressionLevel(1 << (8 + level))}
+		return c.CompressBlock(src, dest)
+	}
+	var c lz4.Compressor
+	return c.CompressBlock(src, dest)
+}
+
+func uncompressable(dest, src []byte) int {
+	dest[0] = byte(len(src)) << 4
+	copy(dest[1:], src)
+	return len(src) + 1
+}
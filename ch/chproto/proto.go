@@ -52,3 +52,9 @@ const (
 	DBMS_MIN_REVISION_WITH_PARALLEL_REPLICAS                  = 54453
 	DBMS_TCP_PROTOCOL_VERSION                                 = DBMS_MIN_REVISION_WITH_PARALLEL_REPLICAS
 )
+
+// Setting flags that are sent along with each setting when settings are serialized as strings.
+const (
+	SettingFlagImportant = 0x01
+	SettingFlagCustom    = 0x02
+)
@@ -19,23 +19,40 @@ type reader interface {
 
 type Reader struct {
 	br *bufio.Reader
-	zr *lz4Reader
+	zr *compressReader
 	rd reader // points to br or zr
 
 	buf []byte
 }
 
 func NewReader(r io.Reader) *Reader {
-	br := bufio.NewReader(r)
+	return NewReaderSize(r, 0)
+}
+
+// NewReaderSize returns a new Reader whose buffer has at least the specified size.
+// Zero size means the default bufio buffer size.
+func NewReaderSize(r io.Reader, size int) *Reader {
+	var br *bufio.Reader
+	if size > 0 {
+		br = bufio.NewReaderSize(r, size)
+	} else {
+		br = bufio.NewReader(r)
+	}
 	return &Reader{
 		br: br,
-		zr: newLZ4Reader(br),
+		zr: newCompressReader(br),
 		rd: br,
 
 		buf: make([]byte, uuidLen),
 	}
 }
 
+// SetChecksumVerification enables or disables verification of compressed block
+// checksums. Verification is enabled by default.
+func (r *Reader) SetChecksumVerification(on bool) {
+	r.zr.noVerify = !on
+}
+
 func (r *Reader) WithCompression(enabled bool, fn func() error) error {
 	if enabled {
 		r.rd = r.zr
@@ -61,6 +78,11 @@ func (r *Reader) Buffered() int {
 	return r.rd.Buffered()
 }
 
+// Peek returns the next n bytes without advancing the reader.
+func (r *Reader) Peek(n int) ([]byte, error) {
+	return r.br.Peek(n)
+}
+
 func (r *Reader) Bool() (bool, error) {
 	c, err := r.rd.ReadByte()
 	if err != nil {
@@ -203,3 +225,14 @@ func (r *Reader) Date() (time.Time, error) {
 	}
 	return time.Unix(int64(days)*secsInDay, 0), nil
 }
+
+func (r *Reader) Date32() (time.Time, error) {
+	days, err := r.Int32()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if days == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(int64(days)*secsInDay, 0), nil
+}
@@ -4,7 +4,15 @@ type ServerInfo struct {
 	Name         string
 	MinorVersion uint64
 	MajorVersion uint64
-	Revision     uint64
+	VersionPatch uint64
+	Timezone     string
+	DisplayName  string
+
+	// Revision is the negotiated protocol revision, i.e. the minimum of the client
+	// and the server revisions. Both sides use it to decide which fields to send.
+	Revision uint64
+	// ServerRevision is the protocol revision reported by the server.
+	ServerRevision uint64
 }
 
 func (srv *ServerInfo) ReadFrom(rd *Reader) (err error) {
@@ -17,25 +25,37 @@ func (srv *ServerInfo) ReadFrom(rd *Reader) (err error) {
 	if srv.MinorVersion, err = rd.Uvarint(); err != nil {
 		return err
 	}
-	if srv.Revision, err = rd.Uvarint(); err != nil {
+	if srv.ServerRevision, err = rd.Uvarint(); err != nil {
 		return err
 	}
+	srv.Revision = NegotiateRevision(srv.ServerRevision)
 
 	if srv.Revision >= DBMS_MIN_REVISION_WITH_SERVER_TIMEZONE {
-		if _, err := rd.String(); err != nil { // timezone
+		if srv.Timezone, err = rd.String(); err != nil {
 			return err
 		}
 	}
 	if srv.Revision >= DBMS_MIN_REVISION_WITH_SERVER_DISPLAY_NAME {
-		if _, err := rd.String(); err != nil { // display name
+		if srv.DisplayName, err = rd.String(); err != nil {
 			return err
 		}
 	}
 	if srv.Revision >= DBMS_MIN_REVISION_WITH_VERSION_PATCH {
-		if _, err := rd.Uvarint(); err != nil { // server version patch
+		if srv.VersionPatch, err = rd.Uvarint(); err != nil {
 			return err
 		}
+	} else {
+		srv.VersionPatch = srv.Revision
 	}
 
 	return nil
 }
+
+// NegotiateRevision returns the protocol revision used to talk to a server
+// that reports the given revision.
+func NegotiateRevision(serverRevision uint64) uint64 {
+	if serverRevision < DBMS_TCP_PROTOCOL_VERSION {
+		return serverRevision
+	}
+	return DBMS_TCP_PROTOCOL_VERSION
+}
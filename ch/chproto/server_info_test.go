@@ -0,0 +1,46 @@
+package chproto_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+func TestServerInfoNegotiation(t *testing.T) {
+	tests := []struct {
+		serverRevision uint64
+		revision       uint64
+		patch          uint64
+	}{
+		{54460, chproto.DBMS_TCP_PROTOCOL_VERSION, 7},
+		{chproto.DBMS_TCP_PROTOCOL_VERSION, chproto.DBMS_TCP_PROTOCOL_VERSION, 7},
+		{54400, 54400, 54400},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+
+		wr := chproto.NewWriter(&buf)
+		wr.String("ClickHouse")
+		wr.Uvarint(22)
+		wr.Uvarint(8)
+		wr.Uvarint(test.serverRevision)
+		wr.String("UTC")
+		wr.String("ch1")
+		if test.revision >= chproto.DBMS_MIN_REVISION_WITH_VERSION_PATCH {
+			wr.Uvarint(7)
+		}
+		require.NoError(t, wr.Flush())
+
+		var srv chproto.ServerInfo
+		require.NoError(t, srv.ReadFrom(chproto.NewReader(&buf)))
+		require.Equal(t, test.serverRevision, srv.ServerRevision)
+		require.Equal(t, test.revision, srv.Revision)
+		require.Equal(t, test.patch, srv.VersionPatch)
+		require.Equal(t, "UTC", srv.Timezone)
+		require.Equal(t, "ch1", srv.DisplayName)
+		require.Zero(t, buf.Len())
+	}
+}
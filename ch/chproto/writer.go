@@ -23,31 +23,64 @@ type writer interface {
 
 type Writer struct {
 	bw *bufio.Writer
-	zw *lz4Writer
+	zw *compressWriter
 	wr writer // points to bw or zw
 
-	err error
+	err     error
+	written int64 // bytes written before compression
 
 	buf []byte
 }
 
 func NewWriter(w io.Writer) *Writer {
-	bw := bufio.NewWriter(w)
+	return NewWriterSize(w, 0)
+}
+
+// NewWriterSize returns a new Writer whose buffer has at least the specified size.
+// Zero size means the default bufio buffer size.
+func NewWriterSize(w io.Writer, size int) *Writer {
+	var bw *bufio.Writer
+	if size > 0 {
+		bw = bufio.NewWriterSize(w, size)
+	} else {
+		bw = bufio.NewWriter(w)
+	}
 	return &Writer{
 		bw: bw,
-		zw: newLZ4Writer(bw),
+		zw: newCompressWriter(bw),
 		wr: bw,
 
 		buf: make([]byte, uuidLen),
 	}
 }
 
-func (w *Writer) WithCompression(enabled bool, fn func() error) {
+// SetCompressionLevel sets the compression level. Zero means the fastest
+// LZ4 compression and the default ZSTD level. Positive LZ4 levels use LZ4HC.
+func (w *Writer) SetCompressionLevel(level int) {
+	w.zw.setLevel(level)
+}
+
+// SetCompressionBlockSize sets the maximum size of uncompressed data
+// in a compressed block. Zero means 1MB.
+func (w *Writer) SetCompressionBlockSize(size int) {
+	w.zw.setBlockSize(size)
+}
+
+// SetStrictChecksums enables verification of compressed blocks before they are
+// written: each block is decompressed and compared with the original data.
+// Corrupted blocks are reported with *CompressionError.
+func (w *Writer) SetStrictChecksums(on bool) {
+	w.zw.strict = on
+}
+
+func (w *Writer) WithCompression(method Compression, fn func() error) {
 	if w.err != nil {
 		return
 	}
 
+	enabled := method.Enabled()
 	if enabled {
+		w.zw.method = method
 		w.wr = w.zw
 	}
 
@@ -70,12 +103,18 @@ func (w *Writer) Flush() (err error) {
 	return w.wr.Flush()
 }
 
+// Written returns the number of bytes written so far before compression.
+func (w *Writer) Written() int64 {
+	return w.written
+}
+
 func (w *Writer) Write(b []byte) {
 	if w.err != nil {
 		return
 	}
 
-	_, err := w.wr.Write(b)
+	n, err := w.wr.Write(b)
+	w.written += int64(n)
 	w.err = err
 }
 
@@ -84,6 +123,9 @@ func (w *Writer) WriteByte(c byte) {
 		return
 	}
 	w.err = w.wr.WriteByte(c)
+	if w.err == nil {
+		w.written++
+	}
 }
 
 func (w *Writer) Bool(flag bool) {
@@ -185,6 +227,21 @@ func (w *Writer) Date(tm time.Time) {
 	w.UInt16(uint16(unixTime(tm) / secsInDay))
 }
 
+func (w *Writer) Date32(tm time.Time) {
+	w.Int32(int32(date32Days(tm)))
+}
+
+// date32Days returns the number of days since 1970-01-01 rounding down
+// dates before 1970.
+func date32Days(tm time.Time) int64 {
+	sec := unixTime(tm)
+	days := sec / secsInDay
+	if sec%secsInDay < 0 {
+		days--
+	}
+	return days
+}
+
 func unixTime(tm time.Time) int64 {
 	if tm.IsZero() {
 		return 0
@@ -5,11 +5,19 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math"
+	"math/big"
+	"net/netip"
 	"strconv"
 	"time"
 )
 
 func Append(fmter Formatter, b []byte, v any) []byte {
+	if fmter.redact {
+		if _, ok := v.(QueryAppender); !ok {
+			return appendRedacted(b)
+		}
+	}
+
 	switch v := v.(type) {
 	case nil:
 		return AppendNull(b)
@@ -45,6 +53,13 @@ func Append(fmter Formatter, b []byte, v any) []byte {
 		return AppendTime(b, v)
 	case []byte:
 		return AppendBytes(b, v)
+	case netip.Addr:
+		return AppendString(b, v.String())
+	case *big.Int:
+		if v == nil {
+			return AppendNull(b)
+		}
+		return v.Append(b, 10)
 	case QueryAppender:
 		return AppendQueryAppender(fmter, b, v)
 	case driver.Valuer:
@@ -54,6 +69,10 @@ func Append(fmter Formatter, b []byte, v any) []byte {
 	}
 }
 
+func appendRedacted(b []byte) []byte {
+	return append(b, '?')
+}
+
 func AppendError(b []byte, err error) []byte {
 	b = append(b, "?!("...)
 	b = append(b, err.Error()...)
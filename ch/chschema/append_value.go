@@ -3,7 +3,9 @@ package chschema
 import (
 	"database/sql/driver"
 	"fmt"
+	"math/big"
 	"net"
+	"net/netip"
 	"reflect"
 	"strconv"
 	"time"
@@ -60,6 +62,10 @@ func Appender(typ reflect.Type) AppenderFunc {
 		return appendIPValue
 	case ipNetType:
 		return appendIPNetValue
+	case netipAddrType:
+		return appendNetipAddrValue
+	case bigIntType:
+		return appendBigIntValue
 	}
 
 	if typ.Implements(queryAppenderType) {
@@ -110,10 +116,18 @@ func AppendValue(fmter Formatter, b []byte, v reflect.Value) []byte {
 	if v.Kind() == reflect.Ptr && v.IsNil() {
 		return AppendNull(b)
 	}
+	if fmter.redact && !isQueryAppender(v.Type()) {
+		return appendRedacted(b)
+	}
 	appender := Appender(v.Type())
 	return appender(fmter, b, v)
 }
 
+func isQueryAppender(typ reflect.Type) bool {
+	return typ.Implements(queryAppenderType) ||
+		(typ.Kind() != reflect.Ptr && reflect.PtrTo(typ).Implements(queryAppenderType))
+}
+
 func appendIfaceValue(fmter Formatter, b []byte, v reflect.Value) []byte {
 	return Append(fmter, b, v.Interface())
 }
@@ -165,6 +179,18 @@ func appendIPNetValue(fmter Formatter, b []byte, v reflect.Value) []byte {
 	return AppendString(b, ipnet.String())
 }
 
+func appendNetipAddrValue(fmter Formatter, b []byte, v reflect.Value) []byte {
+	addr := v.Interface().(netip.Addr)
+	return AppendString(b, addr.String())
+}
+
+func appendBigIntValue(fmter Formatter, b []byte, v reflect.Value) []byte {
+	if v.IsNil() {
+		return AppendNull(b)
+	}
+	return v.Interface().(*big.Int).Append(b, 10)
+}
+
 func appendJSONRawMessageValue(fmter Formatter, b []byte, v reflect.Value) []byte {
 	return AppendString(b, internal.String(v.Bytes()))
 }
@@ -0,0 +1,286 @@
+package chschema
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chtype"
+)
+
+// bigIntSize returns the number of bytes used by Int128, UInt128, Int256,
+// and UInt256 types.
+func bigIntSize(chType string) (size int, signed bool, ok bool) {
+	switch chType {
+	case chtype.Int128:
+		return 16, true, true
+	case chtype.UInt128:
+		return 16, false, true
+	case chtype.Int256:
+		return 32, true, true
+	case chtype.UInt256:
+		return 32, false, true
+	}
+	return 0, false, false
+}
+
+func isBigIntType(chType string) bool {
+	_, _, ok := bigIntSize(chType)
+	return ok
+}
+
+//------------------------------------------------------------------------------
+
+// BigIntColumn stores Int128, UInt128, Int256, and UInt256 values.
+// Values are scanned into *big.Int or, when they fit, into integers.
+// A nil *big.Int is NULL for Nullable types.
+type BigIntColumn struct {
+	ColumnOf[*big.Int]
+	typ      reflect.Type
+	size     int
+	signed   bool
+	nullable bool
+
+	err error // first value that can't be converted
+}
+
+var _ Columnar = (*BigIntColumn)(nil)
+
+func NewBigIntColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	var nullable bool
+	if s := nullableType(chType); s != "" {
+		chType = s
+		nullable = true
+	}
+
+	size, signed, ok := bigIntSize(chType)
+	if !ok {
+		panic(fmt.Errorf("ch: invalid big integer type: %q", chType))
+	}
+	if typ.Kind() == reflect.Interface {
+		typ = bigIntType
+	}
+	return &BigIntColumn{
+		ColumnOf: NewColumnOf[*big.Int](numRow),
+		typ:      typ,
+		size:     size,
+		signed:   signed,
+		nullable: nullable,
+	}
+}
+
+func (c *BigIntColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *BigIntColumn) Set(v any) {
+	if column, ok := v.([]*big.Int); ok {
+		c.Column = column
+		return
+	}
+
+	c.Reset(0)
+	slice := reflect.ValueOf(v)
+	for i := 0; i < slice.Len(); i++ {
+		c.AppendValue(slice.Index(i))
+	}
+}
+
+func (c *BigIntColumn) Value() any {
+	return c.Slice(0, c.Len())
+}
+
+func (c *BigIntColumn) Nullable(nulls UInt8Column) any {
+	nullable := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(c.typ)), c.Len(), c.Len())
+	for i := range c.Column {
+		if nulls.Column[i] == 0 {
+			ptr := reflect.New(c.typ)
+			_ = c.ConvertAssign(i, ptr.Elem())
+			nullable.Index(i).Set(ptr)
+		}
+	}
+	return nullable.Interface()
+}
+
+func (c *BigIntColumn) Index(idx int) any {
+	if c.typ == bigIntType {
+		return c.Column[idx]
+	}
+	v := reflect.New(c.typ).Elem()
+	_ = c.ConvertAssign(idx, v)
+	return v.Interface()
+}
+
+func (c *BigIntColumn) Slice(s, e int) any {
+	if c.typ == bigIntType {
+		return c.Column[s:e]
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(c.typ), e-s, e-s)
+	for i := s; i < e; i++ {
+		_ = c.ConvertAssign(i, slice.Index(i-s))
+	}
+	return slice.Interface()
+}
+
+func (c *BigIntColumn) ConvertAssign(idx int, v reflect.Value) error {
+	n := c.Column[idx]
+
+	switch v.Type() {
+	case bigIntType:
+		v.Set(reflect.ValueOf(n))
+		return nil
+	case bigIntType.Elem():
+		v.Addr().Interface().(*big.Int).Set(c.value(idx))
+		return nil
+	}
+
+	n = c.value(idx)
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		if !n.IsInt64() || v.OverflowInt(n.Int64()) {
+			return fmt.Errorf("ch: %s overflows %s", n, v.Type())
+		}
+		v.SetInt(n.Int64())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+			return fmt.Errorf("ch: %s overflows %s", n, v.Type())
+		}
+		v.SetUint(n.Uint64())
+	case reflect.Float32, reflect.Float64:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		v.SetFloat(f)
+	case reflect.String:
+		v.SetString(n.String())
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(n))
+	default:
+		return fmt.Errorf("ch: can't scan big integer into %s", v.Type())
+	}
+	return nil
+}
+
+func (c *BigIntColumn) value(idx int) *big.Int {
+	if n := c.Column[idx]; n != nil {
+		return n
+	}
+	return new(big.Int)
+}
+
+func (c *BigIntColumn) AppendValue(v reflect.Value) {
+	var n *big.Int
+
+	switch v.Type() {
+	case bigIntType:
+		n = v.Interface().(*big.Int)
+	case bigIntType.Elem():
+		x := v.Interface().(big.Int)
+		n = new(big.Int).Set(&x)
+	default:
+		switch v.Kind() {
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+			n = big.NewInt(v.Int())
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+			n = new(big.Int).SetUint64(v.Uint())
+		case reflect.String:
+			var ok bool
+			n, ok = new(big.Int).SetString(v.String(), 10)
+			if !ok && c.err == nil {
+				c.err = fmt.Errorf("ch: can't parse big integer %q", v.String())
+			}
+		}
+	}
+
+	c.Column = append(c.Column, n)
+}
+
+func (c *BigIntColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+
+	var nulls []byte
+	if c.nullable {
+		nulls = make([]byte, numRow)
+		if _, err := io.ReadFull(rd, nulls); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, c.size)
+	for i := range c.Column {
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return err
+		}
+		if c.signed {
+			c.Column[i] = bigIntFromLE(buf)
+		} else {
+			c.Column[i] = bigUintFromLE(buf)
+		}
+	}
+
+	for i, null := range nulls {
+		if null == 1 {
+			c.Column[i] = nil
+		}
+	}
+
+	// Report values that don't fit here, because Slice can't return errors.
+	if c.typ != bigIntType {
+		v := reflect.New(c.typ).Elem()
+		for i := range c.Column {
+			if err := c.ConvertAssign(i, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *BigIntColumn) WriteTo(wr *chproto.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	if c.nullable {
+		for _, n := range c.Column {
+			if n == nil {
+				wr.UInt8(1)
+			} else {
+				wr.UInt8(0)
+			}
+		}
+	}
+
+	bits := 8 * c.size
+	buf := make([]byte, c.size)
+	for i := range c.Column {
+		n := c.value(i)
+		if !bigIntFits(n, bits, c.signed) {
+			return fmt.Errorf("ch: %s overflows %d-bit integer", n, bits)
+		}
+		bigIntToLE(buf, n)
+		wr.Write(buf)
+	}
+	return nil
+}
+
+// bigUintFromLE decodes a little endian unsigned integer.
+func bigUintFromLE(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+	return new(big.Int).SetBytes(be)
+}
+
+func bigIntFits(n *big.Int, bits int, signed bool) bool {
+	if !signed {
+		return n.Sign() >= 0 && n.BitLen() <= bits
+	}
+	if n.Sign() < 0 {
+		// -n-1 must fit into bits-1 bits.
+		return new(big.Int).Not(n).BitLen() < bits
+	}
+	return n.BitLen() < bits
+}
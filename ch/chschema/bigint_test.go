@@ -0,0 +1,68 @@
+package chschema_test
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestBigIntColumn(t *testing.T) {
+	for _, test := range []struct {
+		chType string
+		values []string
+	}{
+		{"Int128", []string{"0", "-1", "-170141183460469231731687303715884105728"}},
+		{"UInt128", []string{"0", "1", "340282366920938463463374607431768211455"}},
+		{"Int256", []string{"-57896044618658097711785492504343953926634992332820282019728792003956564819968"}},
+		{"UInt256", []string{"115792089237316195423570985008687907853269984665640564039457584007913129639935"}},
+		{"Nullable(UInt128)", []string{"1", "", "2"}},
+	} {
+		col := chschema.NewColumnFromCHType(test.chType, 0)
+		for _, s := range test.values {
+			var n *big.Int
+			if s != "" {
+				n, _ = new(big.Int).SetString(s, 10)
+			}
+			col.AppendValue(reflect.ValueOf(n))
+		}
+
+		var buf bytes.Buffer
+		wr := chproto.NewWriter(&buf)
+		require.NoError(t, col.WriteTo(wr))
+		require.NoError(t, wr.Flush())
+
+		got := chschema.NewColumnFromCHType(test.chType, 0)
+		require.NoError(t, got.ReadFrom(chproto.NewReader(&buf), len(test.values)))
+		for i, s := range test.values {
+			n := got.Index(i).(*big.Int)
+			if s == "" {
+				require.Nil(t, n, test.chType)
+			} else {
+				require.Equal(t, s, n.String(), test.chType)
+			}
+		}
+	}
+}
+
+func TestBigIntOverflow(t *testing.T) {
+	col := chschema.NewColumn(reflect.TypeOf(uint64(0)), "UInt128", 0)
+	col.AppendValue(reflect.ValueOf(uint64(42)))
+	require.Equal(t, uint64(42), col.Index(0))
+
+	n := new(big.Int).Lsh(big.NewInt(1), 64)
+	col = chschema.NewColumnFromCHType("UInt128", 0)
+	col.AppendValue(reflect.ValueOf(n))
+
+	var u uint64
+	require.Error(t, col.ConvertAssign(0, reflect.ValueOf(&u).Elem()))
+
+	col = chschema.NewColumnFromCHType("Int128", 0)
+	col.AppendValue(reflect.ValueOf(new(big.Int).Lsh(big.NewInt(1), 127)))
+	require.Error(t, col.WriteTo(chproto.NewWriter(new(bytes.Buffer))))
+}
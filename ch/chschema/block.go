@@ -2,6 +2,7 @@ package chschema
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/uptrace/go-clickhouse/ch/chproto"
 )
@@ -57,6 +58,115 @@ func (b *Block) Column(colName, colType string) *Column {
 	return col
 }
 
+// Reorder returns a block with the columns in the order of the sample
+// block received from the server. Columns that are not present in the sample
+// block are skipped. It returns *ColumnMismatchError when the block does not
+// have some of the sample block columns.
+//
+// Sample blocks without columns, for example, when inserting into some views,
+// do not describe the expected structure so the block is returned as is
+// and the server validates the columns listed in the INSERT query.
+func (b *Block) Reorder(sample *Block) (*Block, error) {
+	if sample == nil || len(sample.Columns) == 0 || b.hasColumnOrder(sample) {
+		return b, nil
+	}
+
+	cols := make([]*Column, 0, len(sample.Columns))
+	var missing []string
+	for _, sampleCol := range sample.Columns {
+		col, ok := b.lookupColumn(sampleCol.Name)
+		if !ok {
+			missing = append(missing, sampleCol.Name)
+			continue
+		}
+		// The server looks up columns by the names of the sample block,
+		// which differ in case when the query is formatted with WithIdentFolder.
+		col.Name = sampleCol.Name
+		cols = append(cols, col)
+	}
+
+	if len(missing) > 0 {
+		err := &ColumnMismatchError{
+			Missing: missing,
+		}
+		for _, col := range b.Columns {
+			if _, ok := sample.lookupColumn(col.Name); !ok {
+				err.Extra = append(err.Extra, col.Name)
+			}
+		}
+		return nil, err
+	}
+
+	return &Block{
+		Table:     b.Table,
+		NumColumn: len(cols),
+		NumRow:    b.NumRow,
+		Columns:   cols,
+		columnMap: b.columnMap,
+	}, nil
+}
+
+// CheckTypes compares the types of the model fields with the type option,
+// for example, `ch:"type:DateTime64(3)"`, with the types of the sample block
+// columns. It returns *ColumnTypeError on the first mismatch.
+func (b *Block) CheckTypes(sample *Block) error {
+	if b.Table == nil || sample == nil {
+		return nil
+	}
+
+	for _, col := range b.Columns {
+		field, _ := b.Table.LookupField(col.Name)
+		if field == nil || !field.hasFlag(customTypeFlag) || field.hasFlag(splitFlag) {
+			continue
+		}
+
+		sampleCol, ok := sample.lookupColumn(col.Name)
+		if !ok {
+			continue
+		}
+		if normalizeType(field.CHType) != normalizeType(sampleCol.Type) {
+			return &ColumnTypeError{
+				Table:      b.Table.Type.Name(),
+				Field:      field.GoName,
+				Type:       field.CHType,
+				ServerType: sampleCol.Type,
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeType removes spaces so that "Decimal(18,4)" matches "Decimal(18, 4)".
+func normalizeType(chType string) string {
+	return strings.ReplaceAll(chType, " ", "")
+}
+
+// lookupColumn returns the column with the name, ignoring the case
+// when there is no exact match.
+func (b *Block) lookupColumn(name string) (*Column, bool) {
+	if col, ok := b.columnMap[name]; ok {
+		return col, true
+	}
+	for _, col := range b.Columns {
+		if strings.EqualFold(col.Name, name) {
+			return col, true
+		}
+	}
+	return nil, false
+}
+
+func (b *Block) hasColumnOrder(sample *Block) bool {
+	if len(b.Columns) != len(sample.Columns) {
+		return false
+	}
+	for i, col := range b.Columns {
+		if col.Name != sample.Columns[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
 func (b *Block) WriteTo(wr *chproto.Writer) error {
 	// Can't use b.NumRow for column oriented struct.
 	var numRow int
@@ -76,9 +186,47 @@ func (b *Block) WriteTo(wr *chproto.Writer) error {
 		wr.String(col.Name)
 		wr.String(col.Type)
 		if err := col.WriteTo(wr); err != nil {
+			switch err := err.(type) {
+			case *EnumValueError:
+				err.Column = col.Name
+			case *FixedStringSizeError:
+				err.Column = col.Name
+			}
 			return err
 		}
 	}
 
 	return nil
 }
+
+//------------------------------------------------------------------------------
+
+// ColumnMismatchError is returned when an insert block does not match
+// the columns expected by the server.
+type ColumnMismatchError struct {
+	Missing []string // columns expected by the server, but not provided by the model
+	Extra   []string // columns provided by the model, but not expected by the server
+}
+
+func (err *ColumnMismatchError) Error() string {
+	s := "ch: model does not have columns expected by the server: " +
+		strings.Join(err.Missing, ", ")
+	if len(err.Extra) > 0 {
+		s += " (extra model columns: " + strings.Join(err.Extra, ", ") + ")"
+	}
+	return s
+}
+
+// ColumnTypeError is returned when the type option of a model field does not
+// match the column type reported by the server.
+type ColumnTypeError struct {
+	Table      string
+	Field      string
+	Type       string // type from the field tag
+	ServerType string
+}
+
+func (err *ColumnTypeError) Error() string {
+	return fmt.Sprintf("ch: %s.%s has type %s, but the server column has type %s",
+		err.Table, err.Field, err.Type, err.ServerType)
+}
@@ -0,0 +1,69 @@
+package chschema_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestBlockReorder(t *testing.T) {
+	block := chschema.NewBlock(nil, 3, 0)
+	block.Column("a", "String")
+	block.Column("b", "String")
+	block.Column("c", "UInt8")
+
+	sample := chschema.NewBlock(nil, 2, 0)
+	sample.Column("c", "UInt8")
+	sample.Column("a", "String")
+
+	reordered, err := block.Reorder(sample)
+	require.NoError(t, err)
+	require.Len(t, reordered.Columns, 2)
+	require.Equal(t, "c", reordered.Columns[0].Name)
+	require.Equal(t, "a", reordered.Columns[1].Name)
+
+	sample.Column("d", "String")
+	_, err = block.Reorder(sample)
+	var mismatchErr *chschema.ColumnMismatchError
+	require.True(t, errors.As(err, &mismatchErr))
+	require.Equal(t, []string{"d"}, mismatchErr.Missing)
+	require.Equal(t, []string{"b"}, mismatchErr.Extra)
+
+	// Views can return a sample block without columns.
+	reordered, err = block.Reorder(chschema.NewBlock(nil, 0, 0))
+	require.NoError(t, err)
+	require.Equal(t, block, reordered)
+}
+
+func TestBlockCheckTypes(t *testing.T) {
+	type Model struct {
+		Time  time.Time `ch:"type:DateTime64(3)"`
+		Price float64   `ch:"type:Decimal(18,4)"`
+		Name  string
+	}
+
+	table := chschema.TableForType(reflect.TypeOf(Model{}))
+	block := chschema.NewBlock(table, 3, 0)
+	block.Column("time", "DateTime64(3)")
+	block.Column("price", "Decimal(18,4)")
+	block.Column("name", "String")
+
+	sample := chschema.NewBlock(nil, 3, 0)
+	sample.Column("time", "DateTime64(3)")
+	sample.Column("price", "Decimal(18, 4)")
+	sample.Column("name", "LowCardinality(String)")
+	require.NoError(t, block.CheckTypes(sample))
+
+	sample = chschema.NewBlock(nil, 1, 0)
+	sample.Column("time", "DateTime64(6)")
+	err := block.CheckTypes(sample)
+	var typeErr *chschema.ColumnTypeError
+	require.True(t, errors.As(err, &typeErr))
+	require.Equal(t, "Time", typeErr.Field)
+	require.Equal(t, "DateTime64(6)", typeErr.ServerType)
+}
@@ -2,10 +2,11 @@ package chschema
 
 import (
 	"bytes"
+	"encoding"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"math"
 	"net"
 	"reflect"
@@ -483,31 +484,189 @@ func (c StringColumn) WriteTo(wr *chproto.Writer) error {
 
 type UUID [16]byte
 
-// TODO: rework to use []byte
+// ParseUUID parses UUID in the canonical form, for example,
+// "123e4567-e89b-12d3-a456-426614174000", or without hyphens.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+
+	if len(s) == 36 {
+		if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+			return u, fmt.Errorf("ch: can't parse UUID %q", s)
+		}
+		s = s[:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	}
+	if len(s) != 32 {
+		return u, fmt.Errorf("ch: can't parse UUID %q", s)
+	}
+	if _, err := hex.Decode(u[:], internal.Bytes(s)); err != nil {
+		return u, fmt.Errorf("ch: can't parse UUID %q", s)
+	}
+	return u, nil
+}
+
+// String returns UUID in the canonical form.
+func (u UUID) String() string {
+	b := make([]byte, 36)
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b)
+}
+
+// UUIDColumn stores UUID values in [16]byte arrays, []byte, strings, and
+// types that implement encoding.TextMarshaler and encoding.TextUnmarshaler.
 type UUIDColumn struct {
 	ColumnOf[UUID]
+	typ reflect.Type
+
+	err error // first value that can't be converted
 }
 
 var _ Columnar = (*UUIDColumn)(nil)
 
 func NewUUIDColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	if typ.Kind() == reflect.Interface {
+		typ = uuidType
+	}
 	return &UUIDColumn{
 		ColumnOf: NewColumnOf[UUID](numRow),
+		typ:      typ,
 	}
 }
 
-func (c UUIDColumn) Type() reflect.Type {
-	return uuidType
+func (c *UUIDColumn) Type() reflect.Type {
+	return c.typ
 }
 
-func (c UUIDColumn) ConvertAssign(idx int, v reflect.Value) error {
-	b := v.Slice(0, v.Len()).Bytes()
-	copy(b, c.Column[idx][:])
-	return nil
+func (c *UUIDColumn) Set(v any) {
+	if column, ok := v.([]UUID); ok {
+		c.Column = column
+		return
+	}
+
+	c.Reset(0)
+	slice := reflect.ValueOf(v)
+	for i := 0; i < slice.Len(); i++ {
+		c.AppendValue(slice.Index(i))
+	}
+}
+
+func (c *UUIDColumn) Value() any {
+	return c.Slice(0, c.Len())
+}
+
+func (c *UUIDColumn) Nullable(nulls UInt8Column) any {
+	nullable := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(c.typ)), c.Len(), c.Len())
+	for i := range c.Column {
+		if nulls.Column[i] == 0 {
+			ptr := reflect.New(c.typ)
+			_ = c.ConvertAssign(i, ptr.Elem())
+			nullable.Index(i).Set(ptr)
+		}
+	}
+	return nullable.Interface()
+}
+
+func (c *UUIDColumn) Index(idx int) any {
+	if c.typ == uuidType {
+		return c.Column[idx]
+	}
+	v := reflect.New(c.typ).Elem()
+	_ = c.ConvertAssign(idx, v)
+	return v.Interface()
+}
+
+func (c *UUIDColumn) Slice(s, e int) any {
+	if c.typ == uuidType {
+		return c.Column[s:e]
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(c.typ), e-s, e-s)
+	for i := s; i < e; i++ {
+		_ = c.ConvertAssign(i, slice.Index(i-s))
+	}
+	return slice.Interface()
+}
+
+func (c *UUIDColumn) ConvertAssign(idx int, v reflect.Value) error {
+	u := c.Column[idx]
+
+	if v.CanAddr() {
+		if text, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && !isUUID(v.Type()) {
+			return text.UnmarshalText([]byte(u.String()))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Array:
+		if !isUUID(v.Type()) {
+			break
+		}
+		reflect.Copy(v, reflect.ValueOf(u[:]))
+		return nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		v.SetBytes(append([]byte(nil), u[:]...))
+		return nil
+	case reflect.String:
+		v.SetString(u.String())
+		return nil
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(u))
+		return nil
+	}
+	return fmt.Errorf("ch: can't scan UUID into %s", v.Type())
 }
 
 func (c *UUIDColumn) AppendValue(v reflect.Value) {
-	c.Column = append(c.Column, v.Convert(uuidType).Interface().(UUID))
+	u, err := uuidFromValue(v)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	c.Column = append(c.Column, u)
+}
+
+func uuidFromValue(v reflect.Value) (UUID, error) {
+	switch v.Kind() {
+	case reflect.Array:
+		if isUUID(v.Type()) {
+			return v.Convert(uuidType).Interface().(UUID), nil
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			var u UUID
+			switch b := v.Bytes(); len(b) {
+			case 0:
+				return u, nil
+			case len(u):
+				copy(u[:], b)
+				return u, nil
+			default:
+				return ParseUUID(string(b))
+			}
+		}
+	case reflect.String:
+		if v.Len() == 0 {
+			return UUID{}, nil
+		}
+		return ParseUUID(v.String())
+	}
+
+	if text, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := text.MarshalText()
+		if err != nil {
+			return UUID{}, err
+		}
+		return ParseUUID(string(b))
+	}
+	return UUID{}, fmt.Errorf("ch: can't use %s as UUID", v.Type())
 }
 
 func (c *UUIDColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
@@ -523,7 +682,10 @@ func (c *UUIDColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 	return nil
 }
 
-func (c UUIDColumn) WriteTo(wr *chproto.Writer) error {
+func (c *UUIDColumn) WriteTo(wr *chproto.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
 	for i := range c.Column {
 		wr.UUID(c.Column[i][:])
 	}
@@ -587,6 +749,9 @@ func (c IPColumn) WriteTo(wr *chproto.Writer) error {
 			continue
 		}
 
+		if len(b) == net.IPv4len {
+			b = b.To16()
+		}
 		if len(b) != ipSize {
 			return fmt.Errorf("got %d bytes, wanted %d", len(b), ipSize)
 		}
@@ -767,6 +932,57 @@ func (c DateColumn) WriteTo(wr *chproto.Writer) error {
 
 //------------------------------------------------------------------------------
 
+// Date32 range supported by ClickHouse. The server silently clamps dates
+// outside of the range.
+var (
+	minDate32 = time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxDate32 = time.Date(2299, time.December, 31, 23, 59, 59, 0, time.UTC)
+)
+
+// Date32Column stores Date32 values, which are signed days since 1970-01-01.
+// The zero time is stored as 1970-01-01 like with Date.
+type Date32Column struct {
+	DateTimeColumn
+}
+
+var _ Columnar = (*Date32Column)(nil)
+
+func NewDate32Column(typ reflect.Type, chType string, numRow int) Columnar {
+	return &Date32Column{
+		DateTimeColumn: DateTimeColumn{
+			ColumnOf: NewColumnOf[time.Time](numRow),
+		},
+	}
+}
+
+func (c *Date32Column) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+
+	for i := range c.Column {
+		n, err := rd.Date32()
+		if err != nil {
+			return err
+		}
+		c.Column[i] = n
+	}
+
+	return nil
+}
+
+func (c Date32Column) WriteTo(wr *chproto.Writer) error {
+	for _, tm := range c.Column {
+		if !tm.IsZero() && (tm.Before(minDate32) || tm.After(maxDate32)) {
+			return fmt.Errorf("ch: %s is out of Date32 range", tm.Format("2006-01-02"))
+		}
+	}
+	for i := range c.Column {
+		wr.Date32(c.Column[i])
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
 const timePrecision = int64(time.Microsecond)
 
 type TimeColumn struct {
@@ -936,6 +1152,10 @@ func NewEnumColumn(typ reflect.Type, chType string, numRow int) Columnar {
 	}
 }
 
+func (c *EnumColumn) nullValue() reflect.Value {
+	return reflect.ValueOf(c.enum.Default())
+}
+
 func (c *EnumColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 	if cap(c.Column) >= numRow {
 		c.Column = c.Column[:numRow]
@@ -944,23 +1164,50 @@ func (c *EnumColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 	}
 
 	for i := 0; i < len(c.Column); i++ {
-		n, err := rd.Int8()
-		if err != nil {
-			return err
+		var n int16
+		if c.enum.enum16 {
+			num, err := rd.Int16()
+			if err != nil {
+				return err
+			}
+			n = num
+		} else {
+			num, err := rd.Int8()
+			if err != nil {
+				return err
+			}
+			n = int16(num)
 		}
-		c.Column[i] = c.enum.Decode(int16(n))
+
+		s, ok := c.enum.Decode(n)
+		if !ok {
+			return fmt.Errorf("ch: %d is not a member of %s", n, c.enum.chType)
+		}
+		c.Column[i] = s
 	}
 
 	return nil
 }
 
 func (c *EnumColumn) WriteTo(wr *chproto.Writer) error {
+	// Check all values first so the server never receives a partial column.
+	for i, s := range c.Column {
+		if _, ok := c.enum.Encode(s); !ok {
+			return &EnumValueError{
+				Row:   i,
+				Value: s,
+				Type:  c.enum.chType,
+			}
+		}
+	}
+
 	for _, s := range c.Column {
-		n, ok := c.enum.Encode(s)
-		if !ok {
-			log.Printf("unknown enum value in %s: %s", c.enum.chType, s)
+		n, _ := c.enum.Encode(s)
+		if c.enum.enum16 {
+			wr.Int16(n)
+		} else {
+			wr.Int8(int8(n))
 		}
-		wr.Int8(int8(n))
 	}
 	return nil
 }
@@ -990,17 +1237,15 @@ func (c *LCStringColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 }
 
 func (c *LCStringColumn) readPrefix(rd *chproto.Reader, numRow int) error {
-	version, err := rd.Int64()
-	if err != nil {
-		return err
-	}
-	if version != 1 {
-		return fmt.Errorf("ch: got version=%d, wanted 1", version)
-	}
-	return nil
+	return readLCVersion(rd)
 }
 
 func (c *LCStringColumn) readData(rd *chproto.Reader, numRow int) error {
+	if numRow == 0 {
+		c.Column = c.Column[:0]
+		return nil
+	}
+
 	flags, err := rd.Int64()
 	if err != nil {
 		return err
@@ -1049,17 +1294,16 @@ func (c *LCStringColumn) readData(rd *chproto.Reader, numRow int) error {
 
 func (c *LCStringColumn) WriteTo(wr *chproto.Writer) error {
 	c.writePrefix(wr)
-	c.writeData(wr)
-	return nil
+	return c.writeData(wr)
 }
 
 func (c *LCStringColumn) writePrefix(wr *chproto.Writer) {
 	wr.Int64(1)
 }
 
-func (c *LCStringColumn) writeData(wr *chproto.Writer) {
+func (c *LCStringColumn) writeData(wr *chproto.Writer) error {
 	if len(c.Column) == 0 {
-		return
+		return nil
 	}
 
 	keys := make([]int, len(c.Column))
@@ -1086,6 +1330,7 @@ func (c *LCStringColumn) writeData(wr *chproto.Writer) {
 	for _, key := range keys {
 		lcKey.write(wr, key)
 	}
+	return nil
 }
 
 //------------------------------------------------------------------------------
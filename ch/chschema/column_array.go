@@ -13,6 +13,81 @@ type ArrayColumnar interface {
 	WriteData(wr *chproto.Writer) error
 }
 
+// prefixColumnar is implemented by columns that are encoded with a prefix,
+// for example, the LowCardinality version. Arrays encode the prefix of the
+// elements once before the offsets and the data of the elements after them,
+// so arrays have the prefix too.
+type prefixColumnar interface {
+	readPrefix(rd *chproto.Reader, numRow int) error
+	readData(rd *chproto.Reader, numRow int) error
+	writePrefix(wr *chproto.Writer)
+	writeData(wr *chproto.Writer) error
+}
+
+// readColumnPrefix reads the prefix of the element column of a composite column.
+func readColumnPrefix(rd *chproto.Reader, col Columnar, numRow int) error {
+	if col, ok := col.(prefixColumnar); ok {
+		return col.readPrefix(rd, numRow)
+	}
+	return nil
+}
+
+// readColumnData reads the data of the element column after the prefix.
+func readColumnData(rd *chproto.Reader, col Columnar, numRow int) error {
+	if col, ok := col.(prefixColumnar); ok {
+		return col.readData(rd, numRow)
+	}
+	return col.ReadFrom(rd, numRow)
+}
+
+func writeColumnPrefix(wr *chproto.Writer, col Columnar) {
+	if col, ok := col.(prefixColumnar); ok {
+		col.writePrefix(wr)
+	}
+}
+
+func writeColumnData(wr *chproto.Writer, col Columnar) error {
+	if col, ok := col.(prefixColumnar); ok {
+		return col.writeData(wr)
+	}
+	return col.WriteTo(wr)
+}
+
+func readOffsets(rd *chproto.Reader, numRow int) ([]int, error) {
+	offsets := make([]int, numRow)
+	for i := range offsets {
+		offset, err := rd.UInt64()
+		if err != nil {
+			return nil, err
+		}
+		offsets[i] = int(offset)
+	}
+	return offsets, nil
+}
+
+// arrayRowError sets the row of a value error returned by the element column
+// to the row of the array column.
+func arrayRowError(err error, arrayRow func(elem int) int) error {
+	switch err := err.(type) {
+	case *EnumValueError:
+		err.Row = arrayRow(err.Row)
+	case *FixedStringSizeError:
+		err.Row = arrayRow(err.Row)
+	}
+	return err
+}
+
+// flatElemRow returns the row of the array column that contains the element
+// with the index in the flattened elements of all rows.
+func flatElemRow(elem, numRow int, rowLen func(row int) int) int {
+	for row := 0; row < numRow; row++ {
+		if elem -= rowLen(row); elem < 0 {
+			return row
+		}
+	}
+	return numRow - 1
+}
+
 //------------------------------------------------------------------------------
 
 type ArrayColumnOf[T any] struct {
@@ -286,27 +361,35 @@ func (c *StringArrayColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 	if numRow == 0 {
 		return nil
 	}
+	if err := c.readPrefix(rd, numRow); err != nil {
+		return err
+	}
+	return c.readData(rd, numRow)
+}
+
+var _ prefixColumnar = (*StringArrayColumn)(nil)
 
+func (c *StringArrayColumn) readPrefix(rd *chproto.Reader, numRow int) error {
+	if c.lcElem != nil {
+		return c.lcElem.readPrefix(rd, numRow)
+	}
+	return nil
+}
+
+func (c *StringArrayColumn) readData(rd *chproto.Reader, numRow int) error {
 	if cap(c.Column) >= numRow {
 		c.Column = c.Column[:numRow]
 	} else {
 		c.Column = make([][]string, numRow)
 	}
 
-	if c.lcElem != nil {
-		if err := c.lcElem.readPrefix(rd, numRow); err != nil {
-			return err
-		}
+	if numRow == 0 {
+		return nil
 	}
 
-	offsets := make([]int, numRow)
-
-	for i := 0; i < len(offsets); i++ {
-		offset, err := rd.UInt64()
-		if err != nil {
-			return err
-		}
-		offsets[i] = int(offset)
+	offsets, err := readOffsets(rd, numRow)
+	if err != nil {
+		return err
 	}
 
 	if err := c.elem.ReadFrom(rd, offsets[len(offsets)-1]); err != nil {
@@ -323,10 +406,17 @@ func (c *StringArrayColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 }
 
 func (c *StringArrayColumn) WriteTo(wr *chproto.Writer) error {
+	c.writePrefix(wr)
+	return c.writeData(wr)
+}
+
+func (c *StringArrayColumn) writePrefix(wr *chproto.Writer) {
 	if c.lcElem != nil {
 		c.lcElem.writePrefix(wr)
 	}
+}
 
+func (c *StringArrayColumn) writeData(wr *chproto.Writer) error {
 	_ = c.WriteOffset(wr, 0)
 	return c.WriteData(wr)
 }
@@ -342,11 +432,24 @@ func (c *StringArrayColumn) WriteOffset(wr *chproto.Writer, offset int) int {
 }
 
 func (c *StringArrayColumn) WriteData(wr *chproto.Writer) error {
+	// Write elements of all rows at once so that LowCardinality elements
+	// are encoded using a single dictionary.
+	var n int
 	for _, ss := range c.Column {
-		c.stringElem.Column = ss
-		if err := c.elem.WriteTo(wr); err != nil {
-			return err
-		}
+		n += len(ss)
+	}
+	elems := make([]string, 0, n)
+	for _, ss := range c.Column {
+		elems = append(elems, ss...)
+	}
+
+	c.stringElem.Column = elems
+	if err := c.elem.WriteTo(wr); err != nil {
+		return arrayRowError(err, func(elem int) int {
+			return flatElemRow(elem, len(c.Column), func(row int) int {
+				return len(c.Column[row])
+			})
+		})
 	}
 	return nil
 }
@@ -362,8 +465,7 @@ func (c ArrayLCStringColumn) Type() reflect.Type {
 }
 
 func (c *ArrayLCStringColumn) WriteTo(wr *chproto.Writer) error {
-	c.writeData(wr)
-	return nil
+	return c.writeData(wr)
 }
 
 func (c *ArrayLCStringColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
@@ -378,13 +480,17 @@ func (c *ArrayLCStringColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 type GenericArrayColumn struct {
 	Column reflect.Value
 
-	typ       reflect.Type
-	elem      Columnar
-	arrayElem ArrayColumnar
+	typ        reflect.Type
+	elem       Columnar
+	arrayElem  ArrayColumnar
+	prefixElem prefixColumnar
 }
 
 var _ Columnar = (*GenericArrayColumn)(nil)
 
+// NewGenericArrayColumn returns a column of Go slices for Array(T) values.
+// T can be an array too, so nested slices can be used with any depth of arrays,
+// for example, [][][]int64 for Array(Array(Array(Int64))).
 func NewGenericArrayColumn(typ reflect.Type, chType string, numRow int) Columnar {
 	elemType := chArrayElemType(chType)
 	if elemType == "" {
@@ -398,12 +504,18 @@ func NewGenericArrayColumn(typ reflect.Type, chType string, numRow int) Columnar
 	if _, ok := elem.(*LCStringColumn); ok {
 		panic("not reached")
 	}
-	arrayElem, _ = elem.(ArrayColumnar)
+
+	// Elements with a prefix are written at once using the flattened elements.
+	prefixElem, _ := elem.(prefixColumnar)
+	if prefixElem == nil {
+		arrayElem, _ = elem.(ArrayColumnar)
+	}
 
 	c := &GenericArrayColumn{
-		typ:       reflect.SliceOf(typ),
-		elem:      elem,
-		arrayElem: arrayElem,
+		typ:        reflect.SliceOf(typ),
+		elem:       elem,
+		arrayElem:  arrayElem,
+		prefixElem: prefixElem,
 	}
 
 	c.Column = reflect.MakeSlice(c.typ, 0, numRow)
@@ -461,6 +573,24 @@ func (c *GenericArrayColumn) AppendPointer(typ reflect.Type, ptr unsafe.Pointer)
 }
 
 func (c *GenericArrayColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if numRow > 0 {
+		if err := c.readPrefix(rd, numRow); err != nil {
+			return err
+		}
+	}
+	return c.readData(rd, numRow)
+}
+
+var _ prefixColumnar = (*GenericArrayColumn)(nil)
+
+func (c *GenericArrayColumn) readPrefix(rd *chproto.Reader, numRow int) error {
+	if c.prefixElem != nil {
+		return c.prefixElem.readPrefix(rd, numRow)
+	}
+	return nil
+}
+
+func (c *GenericArrayColumn) readData(rd *chproto.Reader, numRow int) error {
 	if c.Column.Cap() >= numRow {
 		c.Column = c.Column.Slice(0, numRow)
 	} else {
@@ -471,16 +601,18 @@ func (c *GenericArrayColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 		return nil
 	}
 
-	offsets := make([]int, numRow)
-	for i := 0; i < len(offsets); i++ {
-		offset, err := rd.UInt64()
-		if err != nil {
-			return err
-		}
-		offsets[i] = int(offset)
+	offsets, err := readOffsets(rd, numRow)
+	if err != nil {
+		return err
 	}
 
-	if err := c.elem.ReadFrom(rd, offsets[len(offsets)-1]); err != nil {
+	numElem := offsets[len(offsets)-1]
+	if c.prefixElem != nil {
+		err = c.prefixElem.readData(rd, numElem)
+	} else {
+		err = c.elem.ReadFrom(rd, numElem)
+	}
+	if err != nil {
 		return err
 	}
 
@@ -494,21 +626,52 @@ func (c *GenericArrayColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 }
 
 func (c *GenericArrayColumn) WriteTo(wr *chproto.Writer) error {
+	c.writePrefix(wr)
+	return c.writeData(wr)
+}
+
+func (c *GenericArrayColumn) writePrefix(wr *chproto.Writer) {
+	if c.prefixElem == nil {
+		return
+	}
+	// The prefix does not depend on the values.
+	c.prefixElem.writePrefix(wr)
+}
+
+func (c *GenericArrayColumn) writeData(wr *chproto.Writer) error {
 	_ = c.WriteOffset(wr, 0)
 
 	colLen := c.Column.Len()
-	for i := 0; i < colLen; i++ {
-		// TODO: add SetValue or SetPointer
-		c.elem.Set(c.Column.Index(i).Interface())
-
+	if c.arrayElem == nil {
+		// Write elements of all rows at once so that columns with a prefix,
+		// for example, a nulls map, are encoded only once.
+		elems := reflect.MakeSlice(c.typ.Elem(), 0, colLen)
+		for i := 0; i < colLen; i++ {
+			elems = reflect.AppendSlice(elems, c.Column.Index(i))
+		}
+		c.elem.Set(elems.Interface())
 		var err error
-		if c.arrayElem != nil {
-			err = c.arrayElem.WriteData(wr)
+		if c.prefixElem != nil {
+			err = c.prefixElem.writeData(wr)
 		} else {
 			err = c.elem.WriteTo(wr)
 		}
 		if err != nil {
-			return err
+			return arrayRowError(err, func(elem int) int {
+				return flatElemRow(elem, colLen, func(row int) int {
+					return c.Column.Index(row).Len()
+				})
+			})
+		}
+		return nil
+	}
+
+	for i := 0; i < colLen; i++ {
+		// TODO: add SetValue or SetPointer
+		c.elem.Set(c.Column.Index(i).Interface())
+		if err := c.arrayElem.WriteData(wr); err != nil {
+			// The elements of each row are written separately.
+			return arrayRowError(err, func(int) int { return i })
 		}
 	}
 
@@ -0,0 +1,87 @@
+package chschema_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestNestedArrayColumn(t *testing.T) {
+	str := func(s string) *string { return &s }
+	date := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC).Local()
+
+	tests := []struct {
+		chType string
+		values any
+	}{
+		{"Array(Array(Int64))", [][][]int64{{{1, 2}, {3}}, {}}},
+		{"Array(Array(Array(Int64)))", [][][][]int64{{{{1, 2}, {3}}, {}}, {{{4}}}}},
+		{"Array(Array(Array(Array(UInt8))))", [][][][][]uint8{{{{{1}, {2, 3}}}}, {}}},
+		{"Array(Array(Array(Float32)))", [][][][]float32{{{{1.5}}}, {{{}, {2}}}}},
+		{"Array(Array(Array(String)))", [][][][]string{{{{"a", "b"}, {"c"}}}, {{{"d"}}}}},
+		{"Array(Array(Array(DateTime)))", [][][][]time.Time{{{{date}}}, {{{date, date}}}}},
+		{"Array(Array(Enum8('a' = 1, 'b' = 2)))", [][][]string{{{"a"}}, {{"b", "a"}}}},
+		{"Array(Array(LowCardinality(String)))", [][][]string{{{"a", "b"}, {"a"}}, {{}}}},
+		{"Array(Array(Array(LowCardinality(String))))", [][][][]string{{{{"a"}}}, {{{"a", "b"}}}}},
+		{"Array(Array(LowCardinality(UInt32)))", [][][]uint32{{{1, 2}}, {{2}, {}}}},
+		{"Array(Array(Nullable(String)))", [][][]*string{{{str("a"), nil}, {nil}}}},
+		{"Array(Array(Array(Nullable(String))))", [][][][]*string{{{{str("a"), nil}}}, {{{}}}}},
+		{
+			"Array(Array(LowCardinality(Nullable(String))))",
+			[][][]*string{{{str("a"), nil}}, {{nil, str("a")}}},
+		},
+	}
+
+	for _, test := range tests {
+		values := reflect.ValueOf(test.values)
+		typ := values.Type().Elem()
+
+		col := chschema.NewColumn(typ, test.chType, 0)
+		for i := 0; i < values.Len(); i++ {
+			elem := reflect.New(typ).Elem()
+			elem.Set(values.Index(i))
+			col.AppendValue(elem)
+		}
+
+		got := chschema.NewColumn(typ, test.chType, 0)
+		roundTrip(t, col, got)
+		require.Equal(t, test.values, got.Value(), test.chType)
+	}
+}
+
+func TestNestedArrayEncoding(t *testing.T) {
+	chType := "Array(Array(LowCardinality(String)))"
+	values := [][][]string{{{"a", "b"}}, {{"a"}, {}}}
+
+	typ := reflect.TypeOf(values).Elem()
+	col := chschema.NewColumn(typ, chType, 0)
+	col.Set(values)
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+
+	// The LowCardinality version precedes the offsets of all levels.
+	var uints []uint64
+	for i := 0; i < 6; i++ {
+		uints = append(uints, binary.LittleEndian.Uint64(buf.Next(8)))
+	}
+	require.Equal(t, []uint64{1, 1, 3, 2, 3, 3}, uints)
+}
+
+func TestNestedArrayDepthMismatch(t *testing.T) {
+	require.Panics(t, func() {
+		chschema.NewColumn(reflect.TypeOf([]string(nil)), "Array(Array(String))", 0)
+	})
+	require.Panics(t, func() {
+		chschema.NewColumn(reflect.TypeOf([]int64(nil)), "Array(Array(Int64))", 0)
+	})
+}
@@ -0,0 +1,276 @@
+package chschema
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/internal"
+)
+
+// FixedStringSizeError is returned when an inserted value is longer than
+// the FixedString(N) column and values are not truncated.
+type FixedStringSizeError struct {
+	Column string
+	Row    int
+	Len    int // length of the value in bytes
+	Size   int // N
+}
+
+func (err *FixedStringSizeError) Error() string {
+	return fmt.Sprintf("ch: column %s row %d: %d bytes are longer than FixedString(%d)",
+		err.Column, err.Row, err.Len, err.Size)
+}
+
+// TruncateFixedStrings truncates the values of FixedString(N) columns,
+// including Nullable and LowCardinality columns, that are longer than N bytes.
+// Strings are truncated to N bytes even if that splits a multi-byte character.
+func (b *Block) TruncateFixedStrings() {
+	for _, col := range b.Columns {
+		truncateFixedStrings(col.Columnar)
+	}
+}
+
+func truncateFixedStrings(col Columnar) {
+	switch col := col.(type) {
+	case *FixedStringColumn:
+		for i, s := range col.Column {
+			if len(s) > col.size {
+				col.Column[i] = s[:col.size]
+			}
+		}
+	case *FixedBytesColumn:
+		for i, b := range col.Column {
+			if len(b) > col.size {
+				col.Column[i] = b[:col.size]
+			}
+		}
+	case *NullableColumn:
+		truncateFixedStrings(col.Values)
+	case *LCColumn:
+		truncateFixedStrings(col.Columnar)
+	case *LCNullableColumn:
+		truncateFixedStrings(col.Values)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// FixedStringColumn stores FixedString(N) values in strings. Values are padded
+// with zero bytes when written and trailing zero bytes are removed when read.
+// Use FixedBytesColumn or FixedArrayColumn to keep the zero bytes.
+type FixedStringColumn struct {
+	StringColumn
+	size int
+}
+
+var _ Columnar = (*FixedStringColumn)(nil)
+
+func NewFixedStringColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	return &FixedStringColumn{
+		StringColumn: StringColumn{
+			ColumnOf: NewColumnOf[string](numRow),
+		},
+		size: fixedStringSize(chType),
+	}
+}
+
+func (c *FixedStringColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+
+	b := make([]byte, c.size)
+	for i := range c.Column {
+		if _, err := io.ReadFull(rd, b); err != nil {
+			return err
+		}
+		c.Column[i] = string(bytes.TrimRight(b, "\x00"))
+	}
+	return nil
+}
+
+func (c *FixedStringColumn) WriteTo(wr *chproto.Writer) error {
+	for i, s := range c.Column {
+		if len(s) > c.size {
+			return &FixedStringSizeError{Row: i, Len: len(s), Size: c.size}
+		}
+	}
+
+	zeros := make([]byte, c.size)
+	for _, s := range c.Column {
+		wr.Write(internal.Bytes(s))
+		wr.Write(zeros[len(s):])
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// FixedBytesColumn stores FixedString(N) values in byte slices. Shorter values
+// are padded with zero bytes when written and values are read as is, i.e.
+// every value has N bytes including the padding.
+type FixedBytesColumn struct {
+	BytesColumn
+	size int
+}
+
+var _ Columnar = (*FixedBytesColumn)(nil)
+
+func NewFixedBytesColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	return &FixedBytesColumn{
+		BytesColumn: BytesColumn{
+			ColumnOf: NewColumnOf[[]byte](numRow),
+		},
+		size: fixedStringSize(chType),
+	}
+}
+
+func (c *FixedBytesColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+
+	buf := make([]byte, numRow*c.size)
+	if _, err := io.ReadFull(rd, buf); err != nil {
+		return err
+	}
+	for i := range c.Column {
+		c.Column[i] = buf[i*c.size : (i+1)*c.size : (i+1)*c.size]
+	}
+	return nil
+}
+
+func (c *FixedBytesColumn) WriteTo(wr *chproto.Writer) error {
+	for i, b := range c.Column {
+		if len(b) > c.size {
+			return &FixedStringSizeError{Row: i, Len: len(b), Size: c.size}
+		}
+	}
+
+	zeros := make([]byte, c.size)
+	for _, b := range c.Column {
+		wr.Write(b)
+		wr.Write(zeros[len(b):])
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+// FixedArrayColumn stores FixedString(N) values in byte arrays of N bytes,
+// for example, [32]byte for FixedString(32).
+type FixedArrayColumn struct {
+	typ  reflect.Type
+	size int
+	data []byte
+}
+
+var _ Columnar = (*FixedArrayColumn)(nil)
+
+func NewFixedArrayColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	size := fixedStringSize(chType)
+	if typ.Len() != size {
+		panic(fmt.Errorf("ch: %s can't be used with %s", typ, chType))
+	}
+	return &FixedArrayColumn{
+		typ:  typ,
+		size: size,
+		data: make([]byte, 0, numRow*size),
+	}
+}
+
+func (c *FixedArrayColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *FixedArrayColumn) Set(v any) {
+	slice := reflect.ValueOf(v)
+	c.data = c.data[:0]
+	for i := 0; i < slice.Len(); i++ {
+		c.AppendValue(slice.Index(i))
+	}
+}
+
+func (c *FixedArrayColumn) AppendValue(v reflect.Value) {
+	start := len(c.data)
+	c.data = append(c.data, make([]byte, c.size)...)
+	reflect.Copy(reflect.ValueOf(c.data[start:]), v)
+}
+
+func (c *FixedArrayColumn) Value() any {
+	return c.Slice(0, c.Len())
+}
+
+func (c *FixedArrayColumn) Nullable(nulls UInt8Column) any {
+	slice := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(c.typ)), c.Len(), c.Len())
+	for i := 0; i < c.Len(); i++ {
+		if nulls.Column[i] == 0 {
+			elem := reflect.New(c.typ)
+			c.copyTo(i, elem.Elem())
+			slice.Index(i).Set(elem)
+		}
+	}
+	return slice.Interface()
+}
+
+func (c *FixedArrayColumn) Len() int {
+	return len(c.data) / c.size
+}
+
+func (c *FixedArrayColumn) Index(idx int) any {
+	elem := reflect.New(c.typ).Elem()
+	c.copyTo(idx, elem)
+	return elem.Interface()
+}
+
+func (c *FixedArrayColumn) Slice(s, e int) any {
+	slice := reflect.MakeSlice(reflect.SliceOf(c.typ), e-s, e-s)
+	for i := s; i < e; i++ {
+		c.copyTo(i, slice.Index(i-s))
+	}
+	return slice.Interface()
+}
+
+func (c *FixedArrayColumn) ConvertAssign(idx int, dest reflect.Value) error {
+	switch dest.Kind() {
+	case reflect.Array:
+		if dest.Type() != c.typ {
+			return fmt.Errorf("ch: can't scan %s into %s", c.typ, dest.Type())
+		}
+		c.copyTo(idx, dest)
+	case reflect.Slice:
+		if dest.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("ch: can't scan %s into %s", c.typ, dest.Type())
+		}
+		b := make([]byte, c.size)
+		copy(b, c.bytes(idx))
+		dest.SetBytes(b)
+	case reflect.String:
+		dest.SetString(string(c.bytes(idx)))
+	default:
+		dest.Set(reflect.ValueOf(c.Index(idx)))
+	}
+	return nil
+}
+
+func (c *FixedArrayColumn) bytes(idx int) []byte {
+	return c.data[idx*c.size : (idx+1)*c.size]
+}
+
+func (c *FixedArrayColumn) copyTo(idx int, dest reflect.Value) {
+	reflect.Copy(dest, reflect.ValueOf(c.bytes(idx)))
+}
+
+func (c *FixedArrayColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if n := numRow * c.size; cap(c.data) >= n {
+		c.data = c.data[:n]
+	} else {
+		c.data = make([]byte, n)
+	}
+	_, err := io.ReadFull(rd, c.data)
+	return err
+}
+
+func (c *FixedArrayColumn) WriteTo(wr *chproto.Writer) error {
+	wr.Write(c.data)
+	return nil
+}
@@ -0,0 +1,83 @@
+package chschema_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestFixedStringColumns(t *testing.T) {
+	const chType = "FixedString(4)"
+
+	str := chschema.NewColumn(reflect.TypeOf(""), chType, 0)
+	str.AppendValue(reflect.ValueOf("ab"))
+	got := chschema.NewColumn(reflect.TypeOf(""), chType, 0)
+	roundTrip(t, str, got)
+	require.Equal(t, []string{"ab"}, got.Value())
+
+	bs := chschema.NewColumn(reflect.TypeOf([]byte(nil)), chType, 0)
+	bs.AppendValue(reflect.ValueOf([]byte("ab")))
+	got = chschema.NewColumn(reflect.TypeOf([]byte(nil)), chType, 0)
+	roundTrip(t, bs, got)
+	require.Equal(t, [][]byte{[]byte("ab\x00\x00")}, got.Value())
+
+	arr := chschema.NewColumn(reflect.TypeOf([4]byte{}), chType, 0)
+	arr.AppendValue(reflect.ValueOf([4]byte{1, 2, 3, 4}))
+	arr.AppendValue(reflect.ValueOf([4]byte{5}))
+	got = chschema.NewColumn(reflect.TypeOf([4]byte{}), chType, 0)
+	roundTrip(t, arr, got)
+	require.Equal(t, [][4]byte{{1, 2, 3, 4}, {5}}, got.Value())
+
+	var dest []byte
+	require.NoError(t, got.ConvertAssign(1, reflect.ValueOf(&dest).Elem()))
+	require.Equal(t, []byte{5, 0, 0, 0}, dest)
+
+	require.Panics(t, func() {
+		chschema.NewColumn(reflect.TypeOf([3]byte{}), chType, 0)
+	})
+}
+
+func TestNullableFixedArrayColumn(t *testing.T) {
+	const chType = "Nullable(FixedString(2))"
+
+	value := [2]byte{'h', 'i'}
+	typ := reflect.TypeOf(&value)
+	col := chschema.NewColumn(typ, chType, 0)
+	col.AppendValue(reflect.ValueOf(&value))
+	col.AppendValue(reflect.Zero(typ))
+
+	got := chschema.NewColumn(typ, chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []*[2]byte{&value, nil}, got.Value())
+}
+
+func TestFixedStringSize(t *testing.T) {
+	block := chschema.NewBlock(nil, 2, 0)
+	str := block.Column("code", "FixedString(2)")
+	str.AppendValue(reflect.ValueOf("ab"))
+	str.AppendValue(reflect.ValueOf("abc"))
+	code, name := "abc", "a"
+	nullable := block.Column("name", "Nullable(FixedString(2))")
+	nullable.AppendValue(reflect.ValueOf(&code))
+	nullable.AppendValue(reflect.ValueOf(&name))
+
+	err := block.WriteTo(chproto.NewWriter(new(bytes.Buffer)))
+	require.Equal(t, &chschema.FixedStringSizeError{
+		Column: "code",
+		Row:    1,
+		Len:    3,
+		Size:   2,
+	}, err)
+	require.EqualError(t, err, "ch: column code row 1: 3 bytes are longer than FixedString(2)")
+
+	block.TruncateFixedStrings()
+	require.NoError(t, block.WriteTo(chproto.NewWriter(new(bytes.Buffer))))
+	require.Equal(t, []string{"ab", "ab"}, str.Value())
+	require.Equal(t, []string{"ab", "a"},
+		nullable.Columnar.(*chschema.NullableColumn).Values.Value())
+}
@@ -0,0 +1,155 @@
+package chschema
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chtype"
+)
+
+// NonFiniteFloatError is returned when an inserted float is NaN or ±Inf
+// and non-finite floats are rejected.
+type NonFiniteFloatError struct {
+	Column string
+	Row    int
+	Value  float64
+}
+
+func (err *NonFiniteFloatError) Error() string {
+	return fmt.Sprintf("ch: column %s row %d: %v is not a finite number",
+		err.Column, err.Row, err.Value)
+}
+
+// CheckFinite returns *NonFiniteFloatError for the first NaN or ±Inf value
+// in the Float32 and Float64 columns, including Nullable, LowCardinality, and
+// arrays of them, for example, Array(Array(Nullable(Float32))). The elements
+// of Tuple and Map columns are not checked.
+func (b *Block) CheckFinite() error {
+	for _, col := range b.Columns {
+		if row, value, ok := findNonFinite(col.Columnar); ok {
+			return &NonFiniteFloatError{
+				Column: col.Name,
+				Row:    row,
+				Value:  value,
+			}
+		}
+	}
+	return nil
+}
+
+func findNonFinite(col Columnar) (int, float64, bool) {
+	switch col := col.(type) {
+	case *Float64Column:
+		for i, f := range col.Column {
+			if !isFinite(f) {
+				return i, f, true
+			}
+		}
+	case *Float32Column:
+		for i, f := range col.Column {
+			if !isFinite(float64(f)) {
+				return i, float64(f), true
+			}
+		}
+	case *NullableColumn:
+		// NULL values are stored as zeros.
+		return findNonFinite(col.Values)
+	case *LCNullableColumn:
+		return findNonFinite(col.Values)
+	case *NullScannerColumn:
+		return findNonFinite(col.Values)
+	case *LCColumn:
+		return findNonFinite(col.Columnar)
+	case *NonFiniteNullColumn:
+		return findNonFinite(col.Columnar)
+	case *Float64ArrayColumn:
+		for i, elems := range col.Column {
+			for _, f := range elems {
+				if !isFinite(f) {
+					return i, f, true
+				}
+			}
+		}
+	case *GenericArrayColumn:
+		for i := 0; i < col.Column.Len(); i++ {
+			if f, ok := findNonFiniteValue(col.Column.Index(i)); ok {
+				return i, f, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// findNonFiniteValue returns the first NaN or ±Inf float in the array elements.
+func findNonFiniteValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); !isFinite(f) {
+			return f, true
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return findNonFiniteValue(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if f, ok := findNonFiniteValue(v.Index(i)); ok {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func isFinite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+//------------------------------------------------------------------------------
+
+// NonFiniteNullColumn wraps a Float32 or Float64 column, including Nullable,
+// so NaN and ±Inf are scanned as nil into interface and pointer destinations,
+// for example, to encode the values as JSON.
+type NonFiniteNullColumn struct {
+	Columnar
+}
+
+var _ Columnar = (*NonFiniteNullColumn)(nil)
+
+// IsFloatType reports whether the type is a Float32 or Float64 type, including Nullable.
+func IsFloatType(chType string) bool {
+	if s := nullableType(chType); s != "" {
+		chType = s
+	}
+	return chType == chtype.Float32 || chType == chtype.Float64
+}
+
+func (c *NonFiniteNullColumn) Index(idx int) any {
+	v := c.Columnar.Index(idx)
+	if isNonFiniteValue(v) {
+		return nil
+	}
+	return v
+}
+
+func (c *NonFiniteNullColumn) ConvertAssign(idx int, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if isNonFiniteValue(c.Columnar.Index(idx)) {
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+	return c.Columnar.ConvertAssign(idx, v)
+}
+
+func isNonFiniteValue(v any) bool {
+	switch v := v.(type) {
+	case float64:
+		return !isFinite(v)
+	case float32:
+		return !isFinite(float64(v))
+	}
+	return false
+}
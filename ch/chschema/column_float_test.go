@@ -0,0 +1,79 @@
+package chschema_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestBlockCheckFinite(t *testing.T) {
+	one := 1.0
+	nan := math.NaN()
+
+	block := chschema.NewBlock(nil, 2, 0)
+	block.Column("count", "UInt64").AppendValue(reflect.ValueOf(uint64(1)))
+	block.Column("value", "Float64").AppendValue(reflect.ValueOf(1.5))
+	require.NoError(t, block.CheckFinite())
+
+	nullable := block.Column("nullable", "Nullable(Float64)")
+	nullable.AppendValue(reflect.ValueOf(&one))
+	require.NoError(t, block.CheckFinite())
+
+	nullable.AppendValue(reflect.ValueOf((*float64)(nil)))
+	nullable.AppendValue(reflect.ValueOf(&nan))
+	err := block.CheckFinite()
+	require.IsType(t, &chschema.NonFiniteFloatError{}, err)
+	require.EqualError(t, err, "ch: column nullable row 2: NaN is not a finite number")
+
+	block = chschema.NewBlock(nil, 1, 0)
+	values := []float64{1, math.Inf(-1)}
+	block.Column("values", "Array(Float64)").AppendValue(reflect.ValueOf(&values).Elem())
+	require.EqualError(t, block.CheckFinite(), "ch: column values row 0: -Inf is not a finite number")
+
+	nan32 := float32(math.NaN())
+	tests := []struct {
+		chType string
+		values any
+	}{
+		{"Array(Float32)", [][]float32{{1}, {2, nan32}}},
+		{"Array(Nullable(Float64))", [][]*float64{{&one, nil}, {&nan}}},
+		{"Array(Array(Float32))", [][][]float32{{}, {{1}, {nan32}}}},
+		{"Array(LowCardinality(Float64))", [][]float64{{1}, {math.Inf(1)}}},
+		{"LowCardinality(Float64)", []float64{1, nan}},
+		{"LowCardinality(Nullable(Float64))", []*float64{nil, &nan}},
+	}
+	for _, test := range tests {
+		block = chschema.NewBlock(nil, 2, 0)
+		col := block.Column("values", test.chType)
+		col.Set(test.values)
+
+		err := block.CheckFinite()
+		var floatErr *chschema.NonFiniteFloatError
+		require.ErrorAs(t, err, &floatErr, test.chType)
+		require.Equal(t, 1, floatErr.Row, test.chType)
+	}
+}
+
+func TestNonFiniteNullColumn(t *testing.T) {
+	col := chschema.NewColumnFromCHType("Float64", 0)
+	col.AppendValue(reflect.ValueOf(1.5))
+	col.AppendValue(reflect.ValueOf(math.Inf(1)))
+
+	got := &chschema.NonFiniteNullColumn{Columnar: chschema.NewColumnFromCHType("Float64", 0)}
+	roundTrip(t, col, got)
+
+	require.Equal(t, 1.5, got.Index(0))
+	require.Nil(t, got.Index(1))
+
+	var ptr *float64
+	require.NoError(t, got.ConvertAssign(1, reflect.ValueOf(&ptr).Elem()))
+	require.Nil(t, ptr)
+
+	var f float64
+	require.NoError(t, got.ConvertAssign(1, reflect.ValueOf(&f).Elem()))
+	require.True(t, math.IsInf(f, 1))
+}
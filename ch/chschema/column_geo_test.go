@@ -0,0 +1,41 @@
+package chschema_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestGeoColumns(t *testing.T) {
+	type Test struct {
+		Value  any
+		CHType string
+	}
+
+	ring := chschema.Ring{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}}
+	polygon := chschema.Polygon{ring, {{X: 1, Y: 1}, {X: 2, Y: 2}}}
+	tests := []Test{
+		{chschema.Point{X: 1.5, Y: -2}, "Point"},
+		{ring, "Ring"},
+		{polygon, "Polygon"},
+		{chschema.MultiPolygon{polygon, {ring}}, "MultiPolygon"},
+	}
+
+	for _, test := range tests {
+		typ := reflect.TypeOf(test.Value)
+		col := chschema.NewColumn(typ, test.CHType, 0)
+		col.AppendValue(reflect.ValueOf(test.Value))
+		col.AppendValue(reflect.Zero(typ))
+
+		got := chschema.NewColumn(typ, test.CHType, 0)
+		roundTrip(t, col, got)
+		require.Equal(t, test.Value, got.Index(0), test.CHType)
+
+		got = chschema.NewColumnFromCHType(test.CHType, 0)
+		roundTrip(t, col, got)
+		require.Equal(t, test.Value, got.Index(0), test.CHType)
+	}
+}
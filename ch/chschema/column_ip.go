@@ -0,0 +1,206 @@
+package chschema
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chtype"
+)
+
+// IPAddrColumn stores IPv4 and IPv6 values in netip.Addr or net.IP.
+// IPv4 values are encoded as UInt32 and IPv6 values as 16 bytes in network
+// order. IPv4 addresses are stored in IPv6 columns as IPv4-mapped addresses
+// and are read back as is, for example, ::ffff:1.2.3.4, like ClickHouse
+// returns them. Use netip.Addr.Unmap to get the IPv4 address.
+type IPAddrColumn struct {
+	ColumnOf[netip.Addr]
+	typ reflect.Type
+	v4  bool
+
+	err error // first value that can't be converted
+}
+
+var _ Columnar = (*IPAddrColumn)(nil)
+
+func NewIPAddrColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	if typ.Kind() == reflect.Interface {
+		typ = netipAddrType
+	}
+	return &IPAddrColumn{
+		ColumnOf: NewColumnOf[netip.Addr](numRow),
+		typ:      typ,
+		v4:       chType == chtype.IPv4,
+	}
+}
+
+func (c *IPAddrColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *IPAddrColumn) Set(v any) {
+	if column, ok := v.([]netip.Addr); ok {
+		c.Column = column
+		return
+	}
+
+	c.Reset(0)
+	slice := reflect.ValueOf(v)
+	for i := 0; i < slice.Len(); i++ {
+		c.AppendValue(slice.Index(i))
+	}
+}
+
+func (c *IPAddrColumn) Value() any {
+	return c.Slice(0, c.Len())
+}
+
+func (c *IPAddrColumn) Nullable(nulls UInt8Column) any {
+	nullable := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(c.typ)), c.Len(), c.Len())
+	for i := range c.Column {
+		if nulls.Column[i] == 0 {
+			ptr := reflect.New(c.typ)
+			_ = c.ConvertAssign(i, ptr.Elem())
+			nullable.Index(i).Set(ptr)
+		}
+	}
+	return nullable.Interface()
+}
+
+func (c *IPAddrColumn) Index(idx int) any {
+	if c.typ == netipAddrType {
+		return c.Column[idx]
+	}
+	v := reflect.New(c.typ).Elem()
+	_ = c.ConvertAssign(idx, v)
+	return v.Interface()
+}
+
+func (c *IPAddrColumn) Slice(s, e int) any {
+	if c.typ == netipAddrType {
+		return c.Column[s:e]
+	}
+	slice := reflect.MakeSlice(reflect.SliceOf(c.typ), e-s, e-s)
+	for i := s; i < e; i++ {
+		_ = c.ConvertAssign(i, slice.Index(i-s))
+	}
+	return slice.Interface()
+}
+
+func (c *IPAddrColumn) ConvertAssign(idx int, v reflect.Value) error {
+	addr := c.Column[idx]
+
+	switch v.Type() {
+	case netipAddrType:
+		v.Set(reflect.ValueOf(addr))
+		return nil
+	case ipType:
+		v.Set(reflect.ValueOf(net.IP(addr.AsSlice())))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(addr.String())
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(addr))
+	default:
+		return fmt.Errorf("ch: can't scan IP address into %s", v.Type())
+	}
+	return nil
+}
+
+func (c *IPAddrColumn) AppendValue(v reflect.Value) {
+	var addr netip.Addr
+	var err error
+
+	switch v.Type() {
+	case netipAddrType:
+		addr = v.Interface().(netip.Addr)
+	case ipType:
+		ip := v.Interface().(net.IP)
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		if len(ip) > 0 {
+			var ok bool
+			if addr, ok = netip.AddrFromSlice(ip); !ok {
+				err = fmt.Errorf("ch: invalid IP address %v", []byte(ip))
+			}
+		}
+	default:
+		if v.Kind() != reflect.String {
+			err = fmt.Errorf("ch: can't use %s as IP address", v.Type())
+		} else if v.Len() > 0 {
+			if addr, err = netip.ParseAddr(v.String()); err != nil {
+				err = fmt.Errorf("ch: can't parse IP address %q", v.String())
+			}
+		}
+	}
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+
+	c.Column = append(c.Column, addr)
+}
+
+func (c *IPAddrColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+
+	if c.v4 {
+		for i := range c.Column {
+			n, err := rd.UInt32()
+			if err != nil {
+				return err
+			}
+			var b [4]byte
+			binary.BigEndian.PutUint32(b[:], n)
+			c.Column[i] = netip.AddrFrom4(b)
+		}
+		return nil
+	}
+
+	var b [ipSize]byte
+	for i := range c.Column {
+		if _, err := io.ReadFull(rd, b[:]); err != nil {
+			return err
+		}
+		c.Column[i] = netip.AddrFrom16(b)
+	}
+	return nil
+}
+
+func (c *IPAddrColumn) WriteTo(wr *chproto.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	if c.v4 {
+		for _, addr := range c.Column {
+			if !addr.IsValid() {
+				wr.UInt32(0)
+				continue
+			}
+			if !addr.Is4() {
+				return fmt.Errorf("ch: can't insert %s into IPv4 column", addr)
+			}
+			b := addr.As4()
+			wr.UInt32(binary.BigEndian.Uint32(b[:]))
+		}
+		return nil
+	}
+
+	for _, addr := range c.Column {
+		if !addr.IsValid() {
+			wr.Write(zeroIP)
+			continue
+		}
+		b := addr.As16()
+		wr.Write(b[:])
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package chschema_test
+
+import (
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestIPAddrColumn(t *testing.T) {
+	addrType := reflect.TypeOf(netip.Addr{})
+	addrs := []netip.Addr{
+		netip.MustParseAddr("1.2.3.4"),
+		netip.MustParseAddr("192.168.0.1"),
+	}
+
+	for _, chType := range []string{"IPv4", "IPv6"} {
+		col := chschema.NewColumn(addrType, chType, 0)
+		col.Set(addrs)
+
+		got := chschema.NewColumn(addrType, chType, 0)
+		roundTrip(t, col, got)
+		if chType == "IPv6" {
+			// IPv4 addresses are read as IPv4-mapped IPv6 addresses.
+			require.Equal(t, netip.MustParseAddr("::ffff:1.2.3.4"), got.Index(0))
+			require.Equal(t, addrs[1], got.Index(1).(netip.Addr).Unmap())
+		} else {
+			require.Equal(t, addrs, got.Value(), chType)
+		}
+
+		ips := chschema.NewColumn(reflect.TypeOf(net.IP{}), chType, 0)
+		roundTrip(t, col, ips)
+		require.Equal(t, "1.2.3.4", ips.Index(0).(net.IP).String(), chType)
+	}
+
+	col := chschema.NewColumnFromCHType("IPv6", 0)
+	col.AppendValue(reflect.ValueOf(net.ParseIP("2001:db8::1")))
+	got := chschema.NewColumn(addrType, "IPv6", 0)
+	roundTrip(t, col, got)
+	require.Equal(t, netip.MustParseAddr("2001:db8::1"), got.Index(0))
+
+	col = chschema.NewColumnFromCHType("IPv4", 0)
+	col.AppendValue(reflect.ValueOf(netip.MustParseAddr("2001:db8::1")))
+	err := col.WriteTo(chproto.NewWriter(nil))
+	require.EqualError(t, err, "ch: can't insert 2001:db8::1 into IPv4 column")
+}
+
+func TestIPAddrAppendError(t *testing.T) {
+	col := chschema.NewColumn(reflect.TypeOf(netip.Addr{}), "IPv6", 0)
+	col.AppendValue(reflect.ValueOf("2001:db8::1"))
+	col.AppendValue(reflect.ValueOf(""))
+	require.Equal(t, netip.MustParseAddr("2001:db8::1"), col.Index(0))
+	require.NoError(t, col.WriteTo(chproto.NewWriter(nil)))
+
+	col.AppendValue(reflect.ValueOf("foo"))
+	col.AppendValue(reflect.ValueOf(42))
+	err := col.WriteTo(chproto.NewWriter(nil))
+	require.EqualError(t, err, `ch: can't parse IP address "foo"`)
+
+	col = chschema.NewColumnFromCHType("IPv4", 0)
+	col.AppendValue(reflect.ValueOf(42))
+	err = col.WriteTo(chproto.NewWriter(nil))
+	require.EqualError(t, err, "ch: can't use int as IP address")
+}
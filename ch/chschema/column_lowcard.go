@@ -0,0 +1,312 @@
+package chschema
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+// LCColumn stores LowCardinality(T) values in a column of T. The dictionary
+// is encoded using another column of T. String values use LCStringColumn.
+//
+// LowCardinality(Nullable(T)) values can be stored in a column of T too.
+// NULL values are read as zero values then. Use LCNullableColumn to keep NULLs.
+type LCColumn struct {
+	Columnar // values
+
+	typ      reflect.Type
+	elemType string
+	nullable bool
+}
+
+var _ Columnar = (*LCColumn)(nil)
+
+func NewLCColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	elemType := lowCardinalityType(chType)
+	nullable := false
+	if s := nullableType(elemType); s != "" {
+		elemType = s
+		nullable = true
+	}
+	return &LCColumn{
+		Columnar: NewColumn(typ, elemType, numRow),
+		typ:      typ,
+		elemType: elemType,
+		nullable: nullable,
+	}
+}
+
+func (c *LCColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if numRow == 0 {
+		c.Columnar = NewColumn(c.typ, c.elemType, numRow)
+		return nil
+	}
+	if err := c.readPrefix(rd, numRow); err != nil {
+		return err
+	}
+	return c.readData(rd, numRow)
+}
+
+func (c *LCColumn) readPrefix(rd *chproto.Reader, numRow int) error {
+	return readLCVersion(rd)
+}
+
+func (c *LCColumn) readData(rd *chproto.Reader, numRow int) error {
+	c.Columnar = NewColumn(c.typ, c.elemType, numRow)
+	if numRow == 0 {
+		return nil
+	}
+
+	dict, keys, err := readLCDict(rd, numRow, func(dictSize int) Columnar {
+		return NewColumn(c.typ, c.elemType, dictSize)
+	})
+	if err != nil {
+		return err
+	}
+
+	value := reflect.New(c.Columnar.Type()).Elem()
+	for _, key := range keys {
+		// The nullable dictionary stores the zero value for NULL.
+		if err := dict.ConvertAssign(key, value); err != nil {
+			return err
+		}
+		c.Columnar.AppendValue(value)
+	}
+	return nil
+}
+
+func (c *LCColumn) WriteTo(wr *chproto.Writer) error {
+	c.writePrefix(wr)
+	return c.writeData(wr)
+}
+
+func (c *LCColumn) writePrefix(wr *chproto.Writer) {
+	wr.Int64(1)
+}
+
+func (c *LCColumn) writeData(wr *chproto.Writer) error {
+	if c.Len() == 0 {
+		return nil
+	}
+
+	b := newLCDictBuilder(NewColumn(c.typ, c.elemType, 0), c.Columnar.Type(), c.nullable)
+	keys := make([]int, c.Len())
+	for i := range keys {
+		keys[i] = b.key(c.Index(i))
+	}
+	return writeLCDict(wr, b.dict, keys)
+}
+
+//------------------------------------------------------------------------------
+
+// LCNullableColumn stores LowCardinality(Nullable(T)) values in a column of *T.
+// NULL is encoded as the dictionary key 0.
+type LCNullableColumn struct {
+	NullableColumn
+
+	typ      reflect.Type
+	elemType string
+}
+
+var _ Columnar = (*LCNullableColumn)(nil)
+
+func NewLCNullableColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	elemType := nullableType(lowCardinalityType(chType))
+	typ = typ.Elem()
+	return &LCNullableColumn{
+		NullableColumn: NullableColumn{
+			Values: NewColumn(typ, elemType, numRow),
+		},
+		typ:      typ,
+		elemType: elemType,
+	}
+}
+
+func (c *LCNullableColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if numRow > 0 {
+		if err := c.readPrefix(rd, numRow); err != nil {
+			return err
+		}
+	}
+	return c.readData(rd, numRow)
+}
+
+func (c *LCNullableColumn) readPrefix(rd *chproto.Reader, numRow int) error {
+	return readLCVersion(rd)
+}
+
+func (c *LCNullableColumn) readData(rd *chproto.Reader, numRow int) error {
+	c.Nulls.Reset(numRow)
+	c.Values = NewColumn(c.typ, c.elemType, numRow)
+	if numRow == 0 {
+		c.nullable = reflect.ValueOf(c.Values.Nullable(c.Nulls))
+		return nil
+	}
+
+	dict, keys, err := readLCDict(rd, numRow, func(dictSize int) Columnar {
+		return NewColumn(c.typ, c.elemType, dictSize)
+	})
+	if err != nil {
+		return err
+	}
+
+	value := reflect.New(c.Values.Type()).Elem()
+	for _, key := range keys {
+		if key == 0 {
+			c.Nulls.Column = append(c.Nulls.Column, 1)
+			c.Values.AppendValue(reflect.Zero(value.Type()))
+			continue
+		}
+
+		if err := dict.ConvertAssign(key, value); err != nil {
+			return err
+		}
+		c.Nulls.Column = append(c.Nulls.Column, 0)
+		c.Values.AppendValue(value)
+	}
+
+	c.nullable = reflect.ValueOf(c.Values.Nullable(c.Nulls))
+	return nil
+}
+
+func (c *LCNullableColumn) WriteTo(wr *chproto.Writer) error {
+	c.writePrefix(wr)
+	return c.writeData(wr)
+}
+
+func (c *LCNullableColumn) writePrefix(wr *chproto.Writer) {
+	wr.Int64(1)
+}
+
+func (c *LCNullableColumn) writeData(wr *chproto.Writer) error {
+	if c.Len() == 0 {
+		return nil
+	}
+
+	b := newLCDictBuilder(NewColumn(c.typ, c.elemType, 0), c.Values.Type(), true)
+	keys := make([]int, c.Len())
+	for i := range keys {
+		if c.Nulls.Column[i] == 1 {
+			continue // key 0 is NULL
+		}
+		keys[i] = b.key(c.Values.Index(i))
+	}
+	return writeLCDict(wr, b.dict, keys)
+}
+
+//------------------------------------------------------------------------------
+
+// lcDictBuilder assigns dictionary keys to values.
+type lcDictBuilder struct {
+	dict  Columnar
+	index map[any]int
+	value reflect.Value // some columns require addressable values
+}
+
+// newLCDictBuilder returns a builder for the dict column. Nullable dictionaries
+// reserve the key 0 for NULL.
+func newLCDictBuilder(dict Columnar, typ reflect.Type, nullable bool) *lcDictBuilder {
+	b := &lcDictBuilder{
+		dict:  dict,
+		index: make(map[any]int),
+		value: reflect.New(typ).Elem(),
+	}
+	if nullable {
+		b.dict.AppendValue(b.value)
+	}
+	return b
+}
+
+func (b *lcDictBuilder) key(v any) int {
+	mapKey := v
+	// Byte slices aren't hashable.
+	if rv := reflect.ValueOf(v); rv.IsValid() && isBytesType(rv.Type()) {
+		mapKey = string(rv.Bytes())
+	}
+
+	if key, ok := b.index[mapKey]; ok {
+		return key
+	}
+	key := b.dict.Len()
+	b.index[mapKey] = key
+	b.value.Set(reflect.ValueOf(v))
+	b.dict.AppendValue(b.value)
+	return key
+}
+
+// readLCVersion reads the LowCardinality serialization version that precedes
+// the dictionary. Arrays write the version once before the offsets.
+func readLCVersion(rd *chproto.Reader) error {
+	version, err := rd.Int64()
+	if err != nil {
+		return err
+	}
+	if version != 1 {
+		return fmt.Errorf("ch: got version=%d, wanted 1", version)
+	}
+	return nil
+}
+
+// readLCDict reads the LowCardinality dictionary and the keys of numRow values.
+// The version must be read by the caller.
+func readLCDict(
+	rd *chproto.Reader, numRow int, newDict func(dictSize int) Columnar,
+) (Columnar, []int, error) {
+	flags, err := rd.Int64()
+	if err != nil {
+		return nil, nil, err
+	}
+	lcKey := newLCKeyType(flags & 0xf)
+
+	dictSize, err := rd.UInt64()
+	if err != nil {
+		return nil, nil, err
+	}
+	dict := newDict(int(dictSize))
+	if err := dict.ReadFrom(rd, int(dictSize)); err != nil {
+		return nil, nil, err
+	}
+
+	numKey, err := rd.UInt64()
+	if err != nil {
+		return nil, nil, err
+	}
+	if int(numKey) != numRow {
+		return nil, nil, fmt.Errorf("%d != %d", numKey, numRow)
+	}
+
+	keys := make([]int, numRow)
+	for i := range keys {
+		key, err := lcKey.read(rd)
+		if err != nil {
+			return nil, nil, err
+		}
+		if key >= int(dictSize) {
+			return nil, nil, fmt.Errorf("ch: LowCardinality key=%d is out of range", key)
+		}
+		keys[i] = key
+	}
+	return dict, keys, nil
+}
+
+// writeLCDict writes the dictionary and the keys. The version must be written
+// by the caller.
+func writeLCDict(wr *chproto.Writer, dict Columnar, keys []int) error {
+	const hasAdditionalKeys = 1 << 9
+	const needUpdateDict = 1 << 10
+
+	lcKey := newLCKey(int64(dict.Len()))
+	wr.Int64(int64(lcKey.typ) | hasAdditionalKeys | needUpdateDict)
+
+	wr.Int64(int64(dict.Len()))
+	if err := dict.WriteTo(wr); err != nil {
+		return err
+	}
+
+	wr.Int64(int64(len(keys)))
+	for _, key := range keys {
+		lcKey.write(wr, key)
+	}
+	return nil
+}
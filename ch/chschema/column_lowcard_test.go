@@ -0,0 +1,103 @@
+package chschema_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestLCColumn(t *testing.T) {
+	chType := "LowCardinality(Bool)"
+	col := chschema.NewColumn(reflect.TypeOf(false), chType, 0)
+	for _, flag := range []bool{true, false, true, true} {
+		col.AppendValue(reflect.ValueOf(flag))
+	}
+
+	got := chschema.NewColumn(reflect.TypeOf(false), chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []bool{true, false, true, true}, got.Value())
+
+	anyCol := chschema.NewColumnFromCHType(chType, 0)
+	roundTrip(t, col, anyCol)
+	require.Equal(t, false, anyCol.Index(1))
+}
+
+func TestLCColumnTypes(t *testing.T) {
+	str := func(s string) *string { return &s }
+	date := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC).Local()
+
+	tests := []struct {
+		chType string
+		values any
+	}{
+		{"LowCardinality(UInt32)", []uint32{3, 1, 3, 0}},
+		{"LowCardinality(Float64)", []float64{1.5, 1.5, -2}},
+		{"LowCardinality(FixedString(3))", []string{"foo", "ba", "foo"}},
+		{"LowCardinality(Date)", []time.Time{date, date.AddDate(0, 0, 1), date}},
+		{"LowCardinality(DateTime)", []time.Time{date.Add(time.Hour), date}},
+		{"LowCardinality(Nullable(String))", []*string{str("foo"), nil, str(""), str("foo")}},
+		{"LowCardinality(Nullable(Int64))", []*int64{nil, nil}},
+	}
+
+	for _, test := range tests {
+		values := reflect.ValueOf(test.values)
+		typ := values.Type().Elem()
+
+		col := chschema.NewColumn(typ, test.chType, 0)
+		for i := 0; i < values.Len(); i++ {
+			col.AppendValue(values.Index(i))
+		}
+
+		got := chschema.NewColumn(typ, test.chType, 0)
+		roundTrip(t, col, got)
+		require.Equal(t, test.values, got.Value(), test.chType)
+
+		anyCol := chschema.NewColumnFromCHType(test.chType, 0)
+		roundTrip(t, col, anyCol)
+		require.Equal(t, test.values, anyCol.Value(), test.chType)
+	}
+}
+
+func TestLCColumnBytes(t *testing.T) {
+	for _, chType := range []string{"LowCardinality(FixedString(2))", "LowCardinality(String)"} {
+		values := [][]byte{[]byte("ab"), []byte("cd"), []byte("ab")}
+		typ := reflect.TypeOf(values).Elem()
+
+		col := chschema.NewColumn(typ, chType, 0)
+		for _, v := range values {
+			col.AppendValue(reflect.ValueOf(v))
+		}
+
+		got := chschema.NewColumn(typ, chType, 0)
+		roundTrip(t, col, got)
+		require.Equal(t, values, got.Value(), chType)
+
+		anyCol := chschema.NewColumnFromCHType(chType, 0)
+		roundTrip(t, col, anyCol)
+		require.Equal(t, []string{"ab", "cd", "ab"}, anyCol.Value(), chType)
+	}
+}
+
+func TestLCNullableIntoValue(t *testing.T) {
+	chType := "LowCardinality(Nullable(String))"
+	col := chschema.NewColumn(reflect.TypeOf((*string)(nil)), chType, 0)
+	foo := "foo"
+	col.AppendValue(reflect.ValueOf(&foo))
+	col.AppendValue(reflect.ValueOf((*string)(nil)))
+
+	// NULL is scanned as the zero value.
+	got := chschema.NewColumn(reflect.TypeOf(""), chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []string{"foo", ""}, got.Value())
+
+	// The empty string is not NULL.
+	strCol := chschema.NewColumn(reflect.TypeOf(""), chType, 0)
+	strCol.AppendValue(reflect.ValueOf(""))
+	nullable := chschema.NewColumn(reflect.TypeOf((*string)(nil)), chType, 0)
+	roundTrip(t, strCol, nullable)
+	require.NotNil(t, nullable.Index(0))
+}
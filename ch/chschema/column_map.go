@@ -0,0 +1,183 @@
+package chschema
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+// mapKeyValueTypes returns the key and the value types of Map(K, V).
+func mapKeyValueTypes(chType string) (keyType, valueType string) {
+	types := splitTypes(chSubType(chType, "Map("))
+	if len(types) != 2 {
+		return "", ""
+	}
+	return types[0], types[1]
+}
+
+func isMapType(chType string) bool {
+	keyType, _ := mapKeyValueTypes(chType)
+	return keyType != ""
+}
+
+//------------------------------------------------------------------------------
+
+// MapColumn stores Map(K, V) values in Go maps. Maps are encoded like
+// Array(Tuple(K, V)): offsets followed by the keys and the values of all rows.
+// The prefixes of the keys and the values, for example, the LowCardinality
+// version, precede the offsets.
+type MapColumn struct {
+	Column reflect.Value // reflect.Slice of maps
+
+	typ    reflect.Type
+	keys   Columnar
+	values Columnar
+}
+
+var _ Columnar = (*MapColumn)(nil)
+
+func NewMapColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	keyType, valueType := mapKeyValueTypes(chType)
+	if keyType == "" {
+		panic(fmt.Errorf("ch: invalid Map type: %q", chType))
+	}
+	if typ.Kind() != reflect.Map {
+		typ = reflect.MapOf(goType(keyType), goType(valueType))
+	}
+	return &MapColumn{
+		Column: reflect.MakeSlice(reflect.SliceOf(typ), 0, numRow),
+		typ:    typ,
+		keys:   NewColumn(typ.Key(), keyType, 0),
+		values: NewColumn(typ.Elem(), valueType, 0),
+	}
+}
+
+func (c *MapColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *MapColumn) Set(v any) {
+	c.Column = reflect.ValueOf(v)
+}
+
+func (c *MapColumn) AppendValue(v reflect.Value) {
+	c.Column = reflect.Append(c.Column, v)
+}
+
+func (c *MapColumn) Value() any {
+	return c.Column.Interface()
+}
+
+func (c *MapColumn) Nullable(nulls UInt8Column) any {
+	panic("not implemented")
+}
+
+func (c *MapColumn) Len() int {
+	return c.Column.Len()
+}
+
+func (c *MapColumn) Index(idx int) any {
+	return c.Column.Index(idx).Interface()
+}
+
+func (c *MapColumn) Slice(s, e int) any {
+	return c.Column.Slice(s, e).Interface()
+}
+
+func (c *MapColumn) ConvertAssign(idx int, v reflect.Value) error {
+	v.Set(c.Column.Index(idx))
+	return nil
+}
+
+func (c *MapColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if numRow > 0 {
+		if err := c.readPrefix(rd, numRow); err != nil {
+			return err
+		}
+	}
+	return c.readData(rd, numRow)
+}
+
+func (c *MapColumn) WriteTo(wr *chproto.Writer) error {
+	c.writePrefix(wr)
+	return c.writeData(wr)
+}
+
+var _ prefixColumnar = (*MapColumn)(nil)
+
+func (c *MapColumn) readPrefix(rd *chproto.Reader, numRow int) error {
+	if err := readColumnPrefix(rd, c.keys, numRow); err != nil {
+		return err
+	}
+	return readColumnPrefix(rd, c.values, numRow)
+}
+
+func (c *MapColumn) readData(rd *chproto.Reader, numRow int) error {
+	c.Column = reflect.MakeSlice(reflect.SliceOf(c.typ), numRow, numRow)
+	if numRow == 0 {
+		return nil
+	}
+
+	offsets, err := readOffsets(rd, numRow)
+	if err != nil {
+		return err
+	}
+	numElem := offsets[len(offsets)-1]
+
+	if err := readColumnData(rd, c.keys, numElem); err != nil {
+		return err
+	}
+	if err := readColumnData(rd, c.values, numElem); err != nil {
+		return err
+	}
+
+	var prev int
+	for i, offset := range offsets {
+		m := reflect.MakeMapWithSize(c.typ, offset-prev)
+		for j := prev; j < offset; j++ {
+			key := reflect.New(c.typ.Key()).Elem()
+			if err := c.keys.ConvertAssign(j, key); err != nil {
+				return err
+			}
+			value := reflect.New(c.typ.Elem()).Elem()
+			if err := c.values.ConvertAssign(j, value); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, value)
+		}
+		c.Column.Index(i).Set(m)
+		prev = offset
+	}
+
+	return nil
+}
+
+func (c *MapColumn) writePrefix(wr *chproto.Writer) {
+	writeColumnPrefix(wr, c.keys)
+	writeColumnPrefix(wr, c.values)
+}
+
+func (c *MapColumn) writeData(wr *chproto.Writer) error {
+	keys := reflect.MakeSlice(reflect.SliceOf(c.typ.Key()), 0, c.Column.Len())
+	values := reflect.MakeSlice(reflect.SliceOf(c.typ.Elem()), 0, c.Column.Len())
+
+	var offset int
+	for i := 0; i < c.Column.Len(); i++ {
+		iter := c.Column.Index(i).MapRange()
+		for iter.Next() {
+			keys = reflect.Append(keys, iter.Key())
+			values = reflect.Append(values, iter.Value())
+			offset++
+		}
+		wr.UInt64(uint64(offset))
+	}
+
+	c.keys.Set(keys.Interface())
+	c.values.Set(values.Interface())
+
+	if err := writeColumnData(wr, c.keys); err != nil {
+		return err
+	}
+	return writeColumnData(wr, c.values)
+}
@@ -0,0 +1,88 @@
+package chschema_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestMapColumn(t *testing.T) {
+	values := []map[string][]uint64{
+		{"a": {1, 2}, "b": nil},
+		nil,
+		{"c": {3}},
+	}
+
+	chType := "Map(String, Array(UInt64))"
+	col := chschema.NewColumn(reflect.TypeOf(values[0]), chType, 0)
+	for _, m := range values {
+		col.AppendValue(reflect.ValueOf(m))
+	}
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+
+	got := chschema.NewColumnFromCHType(chType, 0)
+	require.NoError(t, got.ReadFrom(chproto.NewReader(&buf), len(values)))
+	require.Equal(t, map[string][]uint64{"a": {1, 2}, "b": {}}, got.Index(0))
+	require.Equal(t, map[string][]uint64{}, got.Index(1))
+	require.Equal(t, map[string][]uint64{"c": {3}}, got.Index(2))
+}
+
+func TestMapColumnLowCardinality(t *testing.T) {
+	values := []map[string]uint64{
+		{"a": 1},
+		{"a": 2, "b": 3},
+	}
+
+	chType := "Map(LowCardinality(String), UInt64)"
+	col := chschema.NewColumn(reflect.TypeOf(values[0]), chType, 0)
+	col.Set(values)
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+
+	// The LowCardinality version of the keys precedes the offsets.
+	require.Equal(t, uint64(1), binary.LittleEndian.Uint64(buf.Bytes()[0:8]))
+	require.Equal(t, uint64(1), binary.LittleEndian.Uint64(buf.Bytes()[8:16]))
+	require.Equal(t, uint64(3), binary.LittleEndian.Uint64(buf.Bytes()[16:24]))
+
+	got := chschema.NewColumn(reflect.TypeOf(values[0]), chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, values, got.Value())
+
+	// Maps in arrays use a single prefix too.
+	arr := chschema.NewColumnFromCHType("Array("+chType+")", 0)
+	arr.Set([][]map[string]uint64{values, {}})
+	gotArr := chschema.NewColumnFromCHType("Array("+chType+")", 0)
+	roundTrip(t, arr, gotArr)
+	require.Equal(t, [][]map[string]uint64{values, {}}, gotArr.Value())
+}
+
+func TestMapGoType(t *testing.T) {
+	type Model struct {
+		Counters map[string]uint64
+		Labels   map[string]string
+		Tags     map[uint32][]string
+		Attrs    map[string]any
+		Nested   map[string]struct{ Name string }
+	}
+
+	table := chschema.TableForType(reflect.TypeOf(Model{}))
+	require.Equal(t, "Map(String, UInt64)", table.Fields[0].CHType)
+	require.Equal(t, "Map(String, String)", table.Fields[1].CHType)
+	require.Equal(t, "Map(UInt32, Array(String))", table.Fields[2].CHType)
+	// Maps that can't be stored as Map columns are stored as JSON.
+	require.Equal(t, "String", table.Fields[3].CHType)
+	require.Equal(t, "String", table.Fields[4].CHType)
+}
@@ -0,0 +1,99 @@
+package chschema
+
+import (
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+// NestedColumn stores a field of a Nested column, for example, items.name,
+// using slices of structs: the field values of the slice elements are
+// encoded as an array.
+type NestedColumn struct {
+	typ   reflect.Type // slice of structs
+	elem  *Field       // struct field
+	array Columnar
+}
+
+var _ Columnar = (*NestedColumn)(nil)
+
+// NewNestedColumnFunc returns a NewColumnFunc for the struct field of a Nested column.
+func NewNestedColumnFunc(elem *Field) NewColumnFunc {
+	return func(typ reflect.Type, chType string, numRow int) Columnar {
+		return &NestedColumn{
+			typ:   typ,
+			elem:  elem,
+			array: NewColumn(reflect.SliceOf(elem.Type), chType, numRow),
+		}
+	}
+}
+
+func (c *NestedColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *NestedColumn) Set(v any) {
+	slice := reflect.ValueOf(v)
+	for i := 0; i < slice.Len(); i++ {
+		c.AppendValue(slice.Index(i))
+	}
+}
+
+func (c *NestedColumn) AppendValue(v reflect.Value) {
+	// Array columns require addressable values.
+	values := reflect.New(reflect.SliceOf(c.elem.Type)).Elem()
+	values.Set(reflect.MakeSlice(values.Type(), v.Len(), v.Len()))
+
+	for i := 0; i < v.Len(); i++ {
+		if fv, ok := fieldByIndex(v.Index(i), c.elem.Index); ok {
+			values.Index(i).Set(fv)
+		}
+	}
+	c.array.AppendValue(values)
+}
+
+func (c *NestedColumn) Value() any {
+	return c.array.Value()
+}
+
+func (c *NestedColumn) Nullable(nulls UInt8Column) any {
+	panic("not implemented")
+}
+
+func (c *NestedColumn) Len() int {
+	return c.array.Len()
+}
+
+func (c *NestedColumn) Index(idx int) any {
+	return c.array.Index(idx)
+}
+
+func (c *NestedColumn) Slice(s, e int) any {
+	return c.array.Slice(s, e)
+}
+
+// ConvertAssign sets the field of each struct in the slice v, resizing v
+// to the number of array elements. Other fields of the structs are kept
+// so each column of the Nested column can be assigned separately.
+func (c *NestedColumn) ConvertAssign(idx int, v reflect.Value) error {
+	values := reflect.ValueOf(c.array.Index(idx))
+
+	if n := values.Len(); v.Len() != n {
+		slice := reflect.MakeSlice(v.Type(), n, n)
+		reflect.Copy(slice, v)
+		v.Set(slice)
+	}
+
+	for i := 0; i < values.Len(); i++ {
+		c.elem.Value(v.Index(i)).Set(values.Index(i))
+	}
+	return nil
+}
+
+func (c *NestedColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	return c.array.ReadFrom(rd, numRow)
+}
+
+func (c *NestedColumn) WriteTo(wr *chproto.Writer) error {
+	return c.array.WriteTo(wr)
+}
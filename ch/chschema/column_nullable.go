@@ -1,6 +1,9 @@
 package chschema
 
 import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
 	"reflect"
 
 	"github.com/uptrace/go-clickhouse/ch/chproto"
@@ -12,8 +15,16 @@ type NullableColumn struct {
 	nullable reflect.Value // reflect.Slice
 }
 
+// NullableNewColumnFunc returns a func that creates Nullable(T) columns of *T.
+// The column of T is created by fn using the element type and T.
 func NullableNewColumnFunc(fn NewColumnFunc) NewColumnFunc {
 	return func(typ reflect.Type, chType string, numRow int) Columnar {
+		if typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if s := nullableType(chType); s != "" {
+			chType = s
+		}
 		return &NullableColumn{
 			Values: fn(typ, chType, numRow),
 		}
@@ -27,13 +38,28 @@ func (c *NullableColumn) Type() reflect.Type {
 }
 
 func (c *NullableColumn) Set(v any) {
-	panic("not reached")
+	slice := reflect.ValueOf(v)
+	values := reflect.MakeSlice(reflect.SliceOf(c.Values.Type()), slice.Len(), slice.Len())
+
+	c.Nulls.Reset(slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		if elem := slice.Index(i); elem.IsNil() {
+			c.Nulls.Column = append(c.Nulls.Column, 1)
+			values.Index(i).Set(nullValue(c.Values))
+		} else {
+			c.Nulls.Column = append(c.Nulls.Column, 0)
+			values.Index(i).Set(elem.Elem())
+		}
+	}
+
+	c.Values.Set(values.Interface())
+	c.nullable = slice
 }
 
 func (c *NullableColumn) AppendValue(v reflect.Value) {
 	if v.IsNil() {
 		c.Nulls.Column = append(c.Nulls.Column, 1)
-		c.Values.AppendValue(reflect.New(c.Values.Type()).Elem())
+		c.Values.AppendValue(nullValue(c.Values))
 	} else {
 		c.Nulls.Column = append(c.Nulls.Column, 0)
 		c.Values.AppendValue(v.Elem())
@@ -61,11 +87,36 @@ func (c *NullableColumn) Index(idx int) any {
 }
 
 func (c *NullableColumn) Slice(s, e int) any {
-	panic("not implemented")
+	return c.nullable.Slice(s, e).Interface()
 }
 
 func (c *NullableColumn) ConvertAssign(idx int, dest reflect.Value) error {
-	if idx < len(c.Nulls.Column) && c.Nulls.Column[idx] == 1 {
+	null := idx < len(c.Nulls.Column) && c.Nulls.Column[idx] == 1
+
+	switch dest.Kind() {
+	case reflect.Ptr:
+	case reflect.Interface:
+		if null {
+			dest.Set(reflect.Zero(dest.Type()))
+			return nil
+		}
+		return c.Values.ConvertAssign(idx, dest)
+	default:
+		if scanner, ok := dest.Addr().Interface().(sql.Scanner); ok {
+			if null {
+				return scanner.Scan(nil)
+			}
+			return scanner.Scan(c.Values.Index(idx))
+		}
+		if null {
+			dest.Set(reflect.Zero(dest.Type()))
+			return nil
+		}
+		return c.Values.ConvertAssign(idx, dest)
+	}
+
+	if null {
+		dest.Set(reflect.Zero(dest.Type()))
 		return nil
 	}
 	if dest.IsNil() {
@@ -76,6 +127,7 @@ func (c *NullableColumn) ConvertAssign(idx int, dest reflect.Value) error {
 
 func (c *NullableColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
 	if numRow == 0 {
+		c.nullable = reflect.MakeSlice(reflect.SliceOf(c.Type()), 0, 0)
 		return nil
 	}
 	if err := c.Nulls.ReadFrom(rd, numRow); err != nil {
@@ -95,6 +147,159 @@ func (c *NullableColumn) WriteTo(wr *chproto.Writer) error {
 	return c.Values.WriteTo(wr)
 }
 
+// nullValue returns the value stored in the column for NULL. It is the zero value
+// unless the zero value is not valid for the column, for example, for enums.
+func nullValue(col Columnar) reflect.Value {
+	if col, ok := col.(interface{ nullValue() reflect.Value }); ok {
+		return col.nullValue()
+	}
+	return reflect.New(col.Type()).Elem()
+}
+
 func isNilValue(v reflect.Value) bool {
 	return false
 }
+
+//------------------------------------------------------------------------------
+
+var sqlScannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// isNullScannerType reports whether typ is a struct like sql.NullString
+// that implements sql.Scanner and driver.Valuer.
+func isNullScannerType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Struct &&
+		typ.Implements(driverValuerType) &&
+		reflect.PtrTo(typ).Implements(sqlScannerType)
+}
+
+// NullScannerColumn stores Nullable(T) values in a column of types like
+// sql.NullString, sql.NullInt64, and sql.NullTime. Values are written using
+// driver.Valuer and scanned using sql.Scanner.
+type NullScannerColumn struct {
+	Nulls  UInt8Column
+	Values Columnar // T values
+
+	typ reflect.Type
+	err error // first value that can't be converted
+}
+
+var _ Columnar = (*NullScannerColumn)(nil)
+
+func NewNullScannerColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	elemType := nullableType(chType)
+	return &NullScannerColumn{
+		Values: NewColumn(goType(elemType), elemType, numRow),
+		typ:    typ,
+	}
+}
+
+func (c *NullScannerColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *NullScannerColumn) Set(v any) {
+	slice := reflect.ValueOf(v)
+
+	c.Nulls.Reset(slice.Len())
+	c.Values.Set(reflect.MakeSlice(reflect.SliceOf(c.Values.Type()), 0, slice.Len()).Interface())
+	c.err = nil
+
+	for i := 0; i < slice.Len(); i++ {
+		c.AppendValue(slice.Index(i))
+	}
+}
+
+func (c *NullScannerColumn) AppendValue(v reflect.Value) {
+	value, err := v.Interface().(driver.Valuer).Value()
+	if err == nil && value != nil {
+		elem := reflect.ValueOf(value)
+		if elem.Type().ConvertibleTo(c.Values.Type()) {
+			c.Nulls.Column = append(c.Nulls.Column, 0)
+			c.Values.AppendValue(elem.Convert(c.Values.Type()))
+			return
+		}
+		err = fmt.Errorf("ch: can't convert %T to %s", value, c.Values.Type())
+	}
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+
+	c.Nulls.Column = append(c.Nulls.Column, 1)
+	c.Values.AppendValue(nullValue(c.Values))
+}
+
+func (c *NullScannerColumn) Value() any {
+	return c.Slice(0, c.Len())
+}
+
+func (c *NullScannerColumn) Nullable(nulls UInt8Column) any {
+	nullable := reflect.MakeSlice(reflect.SliceOf(reflect.PtrTo(c.typ)), c.Len(), c.Len())
+	for i := 0; i < c.Len(); i++ {
+		if nulls.Column[i] == 0 {
+			ptr := reflect.New(c.typ)
+			_ = c.ConvertAssign(i, ptr.Elem())
+			nullable.Index(i).Set(ptr)
+		}
+	}
+	return nullable.Interface()
+}
+
+func (c *NullScannerColumn) Len() int {
+	return c.Values.Len()
+}
+
+func (c *NullScannerColumn) Index(idx int) any {
+	v := reflect.New(c.typ).Elem()
+	_ = c.ConvertAssign(idx, v)
+	return v.Interface()
+}
+
+func (c *NullScannerColumn) Slice(s, e int) any {
+	slice := reflect.MakeSlice(reflect.SliceOf(c.typ), e-s, e-s)
+	for i := s; i < e; i++ {
+		_ = c.ConvertAssign(i, slice.Index(i-s))
+	}
+	return slice.Interface()
+}
+
+func (c *NullScannerColumn) ConvertAssign(idx int, dest reflect.Value) error {
+	var src any
+	if c.Nulls.Column[idx] == 0 {
+		src = c.Values.Index(idx)
+	}
+
+	if dest.Kind() == reflect.Interface {
+		if src == nil {
+			dest.Set(reflect.Zero(dest.Type()))
+		} else {
+			dest.Set(reflect.ValueOf(src))
+		}
+		return nil
+	}
+
+	scanner, ok := dest.Addr().Interface().(sql.Scanner)
+	if !ok {
+		return fmt.Errorf("ch: can't scan %s into %s", c.typ, dest.Type())
+	}
+	return scanner.Scan(src)
+}
+
+func (c *NullScannerColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if numRow == 0 {
+		return nil
+	}
+	if err := c.Nulls.ReadFrom(rd, numRow); err != nil {
+		return err
+	}
+	return c.Values.ReadFrom(rd, numRow)
+}
+
+func (c *NullScannerColumn) WriteTo(wr *chproto.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
+	if err := c.Nulls.WriteTo(wr); err != nil {
+		return err
+	}
+	return c.Values.WriteTo(wr)
+}
@@ -0,0 +1,154 @@
+package chschema_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestNullableColumnTypes(t *testing.T) {
+	str := "x"
+	tm := time.Unix(1e6, 0).Local()
+	dec := chschema.NewDecimal(1234, 2)
+	addr := netip.MustParseAddr("1.2.3.4")
+	uuid := chschema.UUID{1, 2, 3}
+	uuidStr := uuid.String()
+
+	tests := []struct {
+		value  any
+		chType string
+	}{
+		{&str, "Nullable(String)"},
+		{&str, "Nullable(Enum8('x' = 1))"},
+		{&str, "Nullable(FixedString(1))"},
+		{&uuidStr, "Nullable(UUID)"},
+		{&tm, "Nullable(DateTime)"},
+		{&tm, "Nullable(DateTime64(3))"},
+		{&dec, "Nullable(Decimal(18, 2))"},
+		{&addr, "Nullable(IPv4)"},
+		{&uuid, "Nullable(UUID)"},
+	}
+
+	for _, test := range tests {
+		typ := reflect.TypeOf(test.value)
+		col := chschema.NewColumn(typ, test.chType, 0)
+		col.AppendValue(reflect.ValueOf(test.value))
+		col.AppendValue(reflect.Zero(typ))
+
+		got := chschema.NewColumn(typ, test.chType, 0)
+		roundTrip(t, col, got)
+
+		dest := reflect.New(typ).Elem()
+		require.NoError(t, got.ConvertAssign(0, dest), test.chType)
+		require.Equal(t, test.value, dest.Interface(), test.chType)
+
+		require.NoError(t, got.ConvertAssign(1, dest), test.chType)
+		require.Nil(t, got.Index(1), test.chType)
+	}
+}
+
+func TestNullScannerColumn(t *testing.T) {
+	tm := time.Unix(1e6, 0).Local()
+	tests := []struct {
+		value  any
+		chType string
+	}{
+		{sql.NullString{String: "hello", Valid: true}, "Nullable(String)"},
+		{sql.NullInt64{Int64: 64, Valid: true}, "Nullable(Int64)"},
+		{sql.NullInt32{Int32: 32, Valid: true}, "Nullable(Int32)"},
+		{sql.NullInt16{Int16: 16, Valid: true}, "Nullable(Int16)"},
+		{sql.NullByte{Byte: 8, Valid: true}, "Nullable(UInt8)"},
+		{sql.NullFloat64{Float64: 1.5, Valid: true}, "Nullable(Float64)"},
+		{sql.NullBool{Bool: true, Valid: true}, "Nullable(Bool)"},
+		{sql.NullTime{Time: tm, Valid: true}, "Nullable(DateTime)"},
+	}
+
+	for _, test := range tests {
+		typ := reflect.TypeOf(test.value)
+		col := chschema.NewColumn(typ, test.chType, 0)
+		col.AppendValue(reflect.ValueOf(test.value))
+		col.AppendValue(reflect.Zero(typ))
+
+		got := chschema.NewColumn(typ, test.chType, 0)
+		roundTrip(t, col, got)
+
+		values := reflect.MakeSlice(reflect.SliceOf(typ), 0, 2)
+		values = reflect.Append(values, reflect.ValueOf(test.value), reflect.Zero(typ))
+		require.Equal(t, values.Interface(), got.Value(), test.chType)
+	}
+}
+
+type badValuer struct {
+	V   any
+	Err error
+}
+
+func (v badValuer) Value() (driver.Value, error) {
+	return v.V, v.Err
+}
+
+func (v *badValuer) Scan(src any) error {
+	v.V = src
+	return nil
+}
+
+func TestNullScannerColumnSet(t *testing.T) {
+	values := []sql.NullInt64{{Int64: 1, Valid: true}, {}, {Int64: 3, Valid: true}}
+	typ := reflect.TypeOf(sql.NullInt64{})
+
+	col := chschema.NewColumn(typ, "Nullable(Int64)", 0)
+	col.AppendValue(reflect.ValueOf(sql.NullInt64{Int64: 42, Valid: true}))
+	col.Set(values)
+	require.Equal(t, values, col.Value())
+	require.Equal(t, values[1:], col.Slice(1, 3))
+
+	got := chschema.NewColumn(typ, "Nullable(Int64)", 0)
+	roundTrip(t, col, got)
+	require.Equal(t, values, got.Value())
+}
+
+func TestNullScannerColumnAppendError(t *testing.T) {
+	typ := reflect.TypeOf(badValuer{})
+
+	col := chschema.NewColumn(typ, "Nullable(Int64)", 0)
+	col.AppendValue(reflect.ValueOf(badValuer{V: int64(1)}))
+	col.AppendValue(reflect.ValueOf(badValuer{Err: errors.New("valuer failed")}))
+	col.AppendValue(reflect.ValueOf(badValuer{V: "foo"}))
+	require.Equal(t, 3, col.Len())
+	err := col.WriteTo(chproto.NewWriter(nil))
+	require.EqualError(t, err, "valuer failed")
+
+	col = chschema.NewColumn(typ, "Nullable(Int64)", 0)
+	col.AppendValue(reflect.ValueOf(badValuer{V: "foo"}))
+	err = col.WriteTo(chproto.NewWriter(nil))
+	require.EqualError(t, err, "ch: can't convert string to int64")
+}
+
+func TestNullableIntoScanner(t *testing.T) {
+	s := "hello"
+	col := chschema.NewColumn(reflect.TypeOf(&s), "Nullable(String)", 0)
+	col.AppendValue(reflect.ValueOf(&s))
+	col.AppendValue(reflect.Zero(reflect.TypeOf(&s)))
+
+	got := chschema.NewColumn(reflect.TypeOf(&s), "Nullable(String)", 0)
+	roundTrip(t, col, got)
+
+	var ns sql.NullString
+	require.NoError(t, got.ConvertAssign(0, reflect.ValueOf(&ns).Elem()))
+	require.Equal(t, sql.NullString{String: "hello", Valid: true}, ns)
+	require.NoError(t, got.ConvertAssign(1, reflect.ValueOf(&ns).Elem()))
+	require.Equal(t, sql.NullString{}, ns)
+
+	var v any = "stale"
+	require.NoError(t, got.ConvertAssign(1, reflect.ValueOf(&v).Elem()))
+	require.Nil(t, v)
+}
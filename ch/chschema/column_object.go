@@ -0,0 +1,145 @@
+package chschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+// Object columns are prefixed with the kind of the serialization.
+const (
+	objectTupleKind  = 0 // named tuple with the dynamic subcolumns
+	objectStringKind = 1 // JSON strings
+)
+
+func isObjectType(chType string) bool {
+	return chType == "Object('json')"
+}
+
+// errNewJSONType is returned for the JSON type of servers starting with 24.8
+// that has a different serialization than Object('json').
+func errNewJSONType(chType string) error {
+	return fmt.Errorf("ch: %s is not supported, use Object('json')", chType)
+}
+
+//------------------------------------------------------------------------------
+
+// ObjectColumn stores Object('json') values in maps and structs using
+// encoding/json. Values are inserted as JSON strings and the dynamic
+// subcolumns returned by the server are converted to JSON objects.
+//
+// The JSON type of servers starting with 24.8 uses a different serialization
+// and is not supported.
+type ObjectColumn struct {
+	JSONColumn
+	typ reflect.Type
+}
+
+var _ Columnar = (*ObjectColumn)(nil)
+
+func NewObjectColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	if typ.Kind() == reflect.Interface {
+		typ = mapStringAnyType
+	}
+	return &ObjectColumn{
+		typ: typ,
+	}
+}
+
+func (c *ObjectColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *ObjectColumn) Index(idx int) any {
+	v := reflect.New(c.typ)
+	_ = c.ConvertAssign(idx, v.Elem())
+	return v.Elem().Interface()
+}
+
+func (c *ObjectColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if numRow == 0 {
+		c.Reset(0)
+		return nil
+	}
+
+	kind, err := rd.UInt8()
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case objectStringKind:
+		return c.BytesColumn.ReadFrom(rd, numRow)
+	case objectTupleKind:
+	default:
+		return fmt.Errorf("ch: unsupported Object serialization kind=%d", kind)
+	}
+
+	tupleType, err := rd.String()
+	if err != nil {
+		return err
+	}
+
+	tuples := NewColumnFromCHType(tupleType, numRow)
+	if err := tuples.ReadFrom(rd, numRow); err != nil {
+		return err
+	}
+
+	c.Reset(numRow)
+	for i := 0; i < numRow; i++ {
+		b, err := json.Marshal(objectValue(tupleType, tuples.Index(i)))
+		if err != nil {
+			return err
+		}
+		c.Column = append(c.Column, b)
+	}
+	return nil
+}
+
+func (c *ObjectColumn) WriteTo(wr *chproto.Writer) error {
+	if len(c.Values) == 0 {
+		return nil
+	}
+
+	wr.UInt8(objectStringKind)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, v := range c.Values {
+		buf.Reset()
+		if err := enc.Encode(v.Interface()); err != nil {
+			return err
+		}
+		wr.Bytes(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	}
+	return nil
+}
+
+// objectValue converts named tuples of the dynamic subcolumns to maps.
+func objectValue(chType string, v any) any {
+	if names, types := tupleElemTypes(chType); len(names) > 0 && names[0] != "" {
+		tuple, _ := v.([]any)
+		m := make(map[string]any, len(tuple))
+		for i, elem := range tuple {
+			if names[i] == "_dummy" {
+				continue // empty object
+			}
+			m[names[i]] = objectValue(types[i], elem)
+		}
+		return m
+	}
+
+	if elemType := chArrayElemType(chType); elemType != "" {
+		slice := reflect.ValueOf(v)
+		values := make([]any, slice.Len())
+		for i := range values {
+			values[i] = objectValue(elemType, slice.Index(i).Interface())
+		}
+		return values
+	}
+
+	return v
+}
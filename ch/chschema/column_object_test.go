@@ -0,0 +1,75 @@
+package chschema_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestObjectColumn(t *testing.T) {
+	type Event struct {
+		Name string `json:"name"`
+		Tags struct {
+			Env string `json:"env"`
+		} `json:"tags"`
+	}
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+
+	// The server sends the dynamic subcolumns as a named tuple.
+	tupleType := "Tuple(name String, tags Tuple(env String))"
+	wr.UInt8(0)
+	wr.String(tupleType)
+	tuples := chschema.NewColumnFromCHType(tupleType, 0)
+	tuples.AppendValue(reflect.ValueOf([]any{"click", []any{"prod"}}))
+	require.NoError(t, tuples.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+
+	col := chschema.NewColumn(reflect.TypeOf(Event{}), "Object('json')", 0)
+	require.NoError(t, col.ReadFrom(chproto.NewReader(&buf), 1))
+
+	var event Event
+	require.NoError(t, col.ConvertAssign(0, reflect.ValueOf(&event).Elem()))
+	require.Equal(t, "click", event.Name)
+	require.Equal(t, "prod", event.Tags.Env)
+
+	// The client inserts JSON strings.
+	col.AppendValue(reflect.ValueOf(event))
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+
+	got := chschema.NewColumnFromCHType("Object('json')", 0)
+	require.NoError(t, got.ReadFrom(chproto.NewReader(&buf), 1))
+	require.Equal(t, map[string]any{
+		"name": "click",
+		"tags": map[string]any{"env": "prod"},
+	}, got.Index(0))
+}
+
+func TestObjectColumnEmpty(t *testing.T) {
+	// Header blocks have no rows and no serialization kind.
+	col := chschema.NewColumnFromCHType("Object('json')", 0)
+	require.NoError(t, col.ReadFrom(chproto.NewReader(bytes.NewReader(nil)), 0))
+	require.Equal(t, 0, col.Len())
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+	require.Equal(t, 0, buf.Len())
+}
+
+func TestNewJSONType(t *testing.T) {
+	require.PanicsWithError(t, "ch: JSON is not supported, use Object('json')", func() {
+		chschema.NewColumnFromCHType("JSON", 0)
+	})
+	require.PanicsWithError(t, "ch: JSON is not supported, use Object('json')", func() {
+		chschema.NewColumn(reflect.TypeOf(map[string]any(nil)), "JSON", 0)
+	})
+}
@@ -0,0 +1,116 @@
+package chschema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var columnTypes columnTypeMap
+
+// RegisterColumnType registers fn to create columns of the ClickHouse type chType
+// for values of the Go type typ. It allows plugging codecs for ClickHouse types
+// that the driver does not support and overriding the columns of Go types.
+//
+// chType is either a complete type, for example, "IPv4", or a type name that
+// matches the type with any parameters, for example, "Variant" for
+// "Variant(String, UInt64)". Empty chType matches all ClickHouse types, so fn is
+// used for all columns of typ. Nil typ matches all Go types, so fn is used
+// for all columns of chType.
+//
+// When both chType and typ are set, typ is the Go type of chType when values
+// are scanned into interfaces, and chType is the type of typ fields
+// in CREATE TABLE queries. Arrays, Nullable, and LowCardinality types use
+// the registered codec for the elements.
+//
+// Types must be registered before they are used, for example, in init.
+func RegisterColumnType(chType string, typ reflect.Type, fn NewColumnFunc) {
+	if chType == "" && typ == nil {
+		panic(fmt.Errorf("ch: RegisterColumnType requires a ClickHouse type or a Go type"))
+	}
+	if fn == nil {
+		panic(fmt.Errorf("ch: RegisterColumnType requires a NewColumnFunc"))
+	}
+	columnTypes.register(chType, typ, fn)
+}
+
+type columnTypeKey struct {
+	chType string
+	typ    reflect.Type
+}
+
+type columnTypeMap struct {
+	mu      sync.RWMutex
+	funcs   map[columnTypeKey]NewColumnFunc
+	goTypes map[string]reflect.Type // ClickHouse type => Go type
+	chTypes map[reflect.Type]string // Go type => ClickHouse type
+}
+
+func (m *columnTypeMap) register(chType string, typ reflect.Type, fn NewColumnFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.funcs == nil {
+		m.funcs = make(map[columnTypeKey]NewColumnFunc)
+		m.goTypes = make(map[string]reflect.Type)
+		m.chTypes = make(map[reflect.Type]string)
+	}
+
+	m.funcs[columnTypeKey{chType: chType, typ: typ}] = fn
+	if chType != "" && typ != nil {
+		m.goTypes[chType] = typ
+		m.chTypes[typ] = chType
+	}
+}
+
+// columnFunc returns the registered func for the Go type and the ClickHouse type
+// preferring the most specific registration.
+func (m *columnTypeMap) columnFunc(typ reflect.Type, chType string) NewColumnFunc {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.funcs) == 0 {
+		return nil
+	}
+
+	name := chTypeName(chType)
+	for _, key := range []columnTypeKey{
+		{chType: chType, typ: typ},
+		{chType: name, typ: typ},
+		{chType: chType},
+		{chType: name},
+		{typ: typ},
+	} {
+		if fn, ok := m.funcs[key]; ok {
+			return fn
+		}
+	}
+	return nil
+}
+
+func (m *columnTypeMap) goType(chType string) reflect.Type {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if typ, ok := m.goTypes[chType]; ok {
+		return typ
+	}
+	return m.goTypes[chTypeName(chType)]
+}
+
+func (m *columnTypeMap) chType(typ reflect.Type) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.chTypes[typ]
+}
+
+// chTypeName returns the type name without parameters, for example,
+// "Variant" for "Variant(String, UInt64)".
+func chTypeName(chType string) string {
+	if i := strings.IndexByte(chType, '('); i >= 0 {
+		return chType[:i]
+	}
+	return chType
+}
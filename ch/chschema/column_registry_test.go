@@ -0,0 +1,90 @@
+package chschema_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+type celsius float64
+
+var celsiusType = reflect.TypeOf(celsius(0))
+
+// celsiusColumn encodes temperatures as tenths of a degree in Int16.
+type celsiusColumn struct {
+	chschema.ColumnOf[celsius]
+}
+
+func newCelsiusColumn(typ reflect.Type, chType string, numRow int) chschema.Columnar {
+	return &celsiusColumn{
+		ColumnOf: chschema.NewColumnOf[celsius](numRow),
+	}
+}
+
+func (c *celsiusColumn) Type() reflect.Type {
+	return celsiusType
+}
+
+func (c *celsiusColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+	for i := range c.Column {
+		n, err := rd.Int16()
+		if err != nil {
+			return err
+		}
+		c.Column[i] = celsius(n) / 10
+	}
+	return nil
+}
+
+func (c *celsiusColumn) WriteTo(wr *chproto.Writer) error {
+	for _, v := range c.Column {
+		wr.Int16(int16(v * 10))
+	}
+	return nil
+}
+
+func TestRegisterColumnType(t *testing.T) {
+	chschema.RegisterColumnType("Celsius", celsiusType, newCelsiusColumn)
+
+	col := chschema.NewColumn(celsiusType, "Celsius", 0)
+	require.IsType(t, (*celsiusColumn)(nil), col)
+	col.AppendValue(reflect.ValueOf(celsius(21.5)))
+	col.AppendValue(reflect.ValueOf(celsius(-3)))
+
+	// The registered Go type is used for interfaces and parameters are ignored.
+	got := chschema.NewColumnFromCHType("Celsius(1)", 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []celsius{21.5, -3}, got.Value())
+
+	arr := chschema.NewColumnFromCHType("Array(Nullable(Celsius))", 0)
+	require.Equal(t, reflect.TypeOf([]*celsius(nil)), arr.Type())
+
+	type Model struct {
+		Temp  celsius
+		Temps []celsius
+	}
+	table := chschema.TableForType(reflect.TypeOf(Model{}))
+	require.Equal(t, "Celsius", table.Fields[0].CHType)
+	require.Equal(t, "Array(Celsius)", table.Fields[1].CHType)
+}
+
+type kelvin float64
+
+func TestRegisterGoType(t *testing.T) {
+	typ := reflect.TypeOf(kelvin(0))
+	chschema.RegisterColumnType("", typ, func(typ reflect.Type, chType string, numRow int) chschema.Columnar {
+		return newCelsiusColumn(celsiusType, chType, numRow)
+	})
+
+	col := chschema.NewColumn(typ, "Int16", 0)
+	require.IsType(t, (*celsiusColumn)(nil), col)
+
+	// Other Go types are not affected.
+	col = chschema.NewColumn(reflect.TypeOf(float64(0)), "Float64", 0)
+	require.IsType(t, (*chschema.Float64Column)(nil), col)
+}
@@ -0,0 +1,145 @@
+package chschema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+// SplitColumn stores Array(String) and Array(Int*) values in string fields
+// that contain delimited lists, e.g. "a,b,c", for models that were created
+// before the lists were migrated to arrays.
+type SplitColumn struct {
+	sep      string
+	elemType reflect.Type
+	array    Columnar
+
+	err error // first value that can't be parsed
+}
+
+var _ Columnar = (*SplitColumn)(nil)
+
+// NewSplitColumnFunc returns a NewColumnFunc for string fields that joins
+// array elements with sep when scanning and splits values on sep when inserting.
+func NewSplitColumnFunc(sep string) NewColumnFunc {
+	return func(typ reflect.Type, chType string, numRow int) Columnar {
+		elemType := goType(chArrayElemType(chType))
+		switch elemType.Kind() {
+		case reflect.String,
+			reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			panic(fmt.Errorf("ch: split option does not support %s", chType))
+		}
+
+		sliceType := reflect.SliceOf(elemType)
+		return &SplitColumn{
+			sep:      sep,
+			elemType: elemType,
+			array:    NewColumn(sliceType, chType, numRow),
+		}
+	}
+}
+
+func (c *SplitColumn) Type() reflect.Type {
+	return stringType
+}
+
+func (c *SplitColumn) Set(v any) {
+	for _, s := range v.([]string) {
+		c.AppendValue(reflect.ValueOf(s))
+	}
+}
+
+func (c *SplitColumn) AppendValue(v reflect.Value) {
+	slice, err := c.split(v.String())
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	c.array.AppendValue(slice)
+}
+
+func (c *SplitColumn) split(s string) (reflect.Value, error) {
+	if s == "" {
+		return reflect.New(reflect.SliceOf(c.elemType)).Elem(), nil
+	}
+
+	items := strings.Split(s, c.sep)
+	// Array columns require addressable values.
+	slice := reflect.New(reflect.SliceOf(c.elemType)).Elem()
+	slice.Set(reflect.MakeSlice(slice.Type(), len(items), len(items)))
+	for i, item := range items {
+		item = strings.TrimSpace(item)
+		elem := slice.Index(i)
+
+		switch c.elemType.Kind() {
+		case reflect.String:
+			elem.SetString(item)
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n, err := strconv.ParseInt(item, 10, c.elemType.Bits())
+			if err != nil {
+				return slice, fmt.Errorf("ch: can't split %q: %w", s, err)
+			}
+			elem.SetInt(n)
+		default:
+			n, err := strconv.ParseUint(item, 10, c.elemType.Bits())
+			if err != nil {
+				return slice, fmt.Errorf("ch: can't split %q: %w", s, err)
+			}
+			elem.SetUint(n)
+		}
+	}
+	return slice, nil
+}
+
+func (c *SplitColumn) join(idx int) string {
+	slice := reflect.ValueOf(c.array.Index(idx))
+	items := make([]string, slice.Len())
+	for i := range items {
+		items[i] = fmt.Sprint(slice.Index(i).Interface())
+	}
+	return strings.Join(items, c.sep)
+}
+
+func (c *SplitColumn) Value() any {
+	values := make([]string, c.Len())
+	for i := range values {
+		values[i] = c.join(i)
+	}
+	return values
+}
+
+func (c *SplitColumn) Nullable(nulls UInt8Column) any {
+	panic("not implemented")
+}
+
+func (c *SplitColumn) Len() int {
+	return c.array.Len()
+}
+
+func (c *SplitColumn) Index(idx int) any {
+	return c.join(idx)
+}
+
+func (c *SplitColumn) Slice(s, e int) any {
+	return c.Value().([]string)[s:e]
+}
+
+func (c *SplitColumn) ConvertAssign(idx int, v reflect.Value) error {
+	v.SetString(c.join(idx))
+	return nil
+}
+
+func (c *SplitColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	return c.array.ReadFrom(rd, numRow)
+}
+
+func (c *SplitColumn) WriteTo(wr *chproto.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.array.WriteTo(wr)
+}
@@ -0,0 +1,49 @@
+package chschema_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestSplitColumn(t *testing.T) {
+	type Model struct {
+		Tags string `ch:",split"`
+		IDs  string `ch:",split:|,type:Array(UInt32)"`
+	}
+
+	table := chschema.TableForType(reflect.TypeOf(Model{}))
+	require.Equal(t, "Array(String)", table.FieldMap["tags"].CHType)
+
+	col := table.NewColumn("ids", "Array(UInt32)", 0)
+	for _, s := range []string{"1|2|3", "", "42"} {
+		col.AppendValue(reflect.ValueOf(s))
+	}
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+
+	got := table.NewColumn("ids", "Array(UInt32)", 0)
+	require.NoError(t, got.ReadFrom(chproto.NewReader(&buf), 3))
+	require.Equal(t, []string{"1|2|3", "", "42"}, got.Value())
+
+	var s string
+	require.NoError(t, got.ConvertAssign(0, reflect.ValueOf(&s).Elem()))
+	require.Equal(t, "1|2|3", s)
+
+	col = table.NewColumn("tags", "Array(Int64)", 0)
+	require.Equal(t, "Array(Int64)", col.Type)
+	col.AppendValue(reflect.ValueOf("-1, 2"))
+	require.Equal(t, []string{"-1,2"}, col.Value())
+
+	bad := table.NewColumn("ids", "Array(UInt32)", 0)
+	bad.AppendValue(reflect.ValueOf("1|x"))
+	require.Error(t, bad.WriteTo(chproto.NewWriter(&buf)))
+}
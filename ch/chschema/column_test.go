@@ -0,0 +1,94 @@
+package chschema_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestDate32Column(t *testing.T) {
+	timeType := reflect.TypeOf(time.Time{})
+	dates := []time.Time{
+		time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(1969, time.December, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2299, time.December, 31, 0, 0, 0, 0, time.UTC),
+	}
+
+	col := chschema.NewColumn(timeType, "Date32", 0)
+	for _, date := range dates {
+		col.AppendValue(reflect.ValueOf(date))
+	}
+
+	got := chschema.NewColumn(timeType, "Date32", 0)
+	roundTrip(t, col, got)
+	for i, date := range dates {
+		require.True(t, date.Equal(got.Index(i).(time.Time)), "got %s", got.Index(i))
+	}
+
+	col = chschema.NewColumn(timeType, "Date32", 0)
+	col.AppendValue(reflect.ValueOf(time.Date(1899, time.December, 31, 0, 0, 0, 0, time.UTC)))
+	err := col.WriteTo(nil)
+	require.EqualError(t, err, "ch: 1899-12-31 is out of Date32 range")
+}
+
+type textUUID struct {
+	s string
+}
+
+func (u textUUID) MarshalText() ([]byte, error) {
+	return []byte(u.s), nil
+}
+
+func (u *textUUID) UnmarshalText(b []byte) error {
+	u.s = string(b)
+	return nil
+}
+
+func TestUUIDColumn(t *testing.T) {
+	const s = "00112233-4455-6677-8899-aabbccddeeff"
+	u, err := chschema.ParseUUID(s)
+	require.NoError(t, err)
+	require.Equal(t, s, u.String())
+
+	col := chschema.NewColumn(reflect.TypeOf(""), "UUID", 0)
+	col.AppendValue(reflect.ValueOf(s))
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+	// UUID is encoded as two little endian UInt64.
+	require.Equal(t, []byte{
+		0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x00,
+		0xff, 0xee, 0xdd, 0xcc, 0xbb, 0xaa, 0x99, 0x88,
+	}, buf.Bytes())
+
+	for _, typ := range []reflect.Type{
+		reflect.TypeOf(""),
+		reflect.TypeOf([]byte(nil)),
+		reflect.TypeOf([16]byte{}),
+		reflect.TypeOf(textUUID{}),
+	} {
+		got := chschema.NewColumn(typ, "UUID", 0)
+		roundTrip(t, col, got)
+
+		v := reflect.ValueOf(got.Index(0))
+		require.Equal(t, typ, v.Type())
+
+		again := chschema.NewColumn(typ, "UUID", 0)
+		again.AppendValue(v)
+		roundTrip(t, again, got)
+		require.Equal(t, v.Interface(), got.Index(0))
+	}
+
+	col = chschema.NewColumn(reflect.TypeOf(""), "UUID", 0)
+	col.AppendValue(reflect.ValueOf(strings.Repeat("x", 36)))
+	require.Error(t, col.WriteTo(chproto.NewWriter(nil)))
+}
@@ -0,0 +1,247 @@
+package chschema
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+// tupleElemTypes returns the names and the types of Tuple(T1, T2) and
+// Tuple(name1 T1, name2 T2) elements. Names are empty for unnamed tuples.
+func tupleElemTypes(chType string) (names, types []string) {
+	types = splitTypes(chSubType(chType, "Tuple("))
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	names = make([]string, len(types))
+	for i, typ := range types {
+		space := strings.IndexByte(typ, ' ')
+		if space == -1 {
+			continue
+		}
+		if paren := strings.IndexByte(typ, '('); paren >= 0 && paren < space {
+			continue
+		}
+		names[i] = strings.Trim(typ[:space], "`")
+		types[i] = strings.TrimSpace(typ[space+1:])
+	}
+	return names, types
+}
+
+func isTupleType(chType string) bool {
+	return chSubType(chType, "Tuple(") != ""
+}
+
+//------------------------------------------------------------------------------
+
+// TupleColumn stores Tuple values in structs or in []any. Elements of
+// named tuples are matched with struct fields by name and elements of unnamed
+// tuples by position. Tuples are encoded as the columns of the elements with
+// the prefixes of the elements, for example, the LowCardinality version,
+// preceding the data.
+type TupleColumn struct {
+	Column reflect.Value // reflect.Slice of tuples
+
+	typ       reflect.Type
+	elemTypes []string
+	fields    []*Field   // struct fields for the elements
+	elems     []Columnar // element columns
+}
+
+var _ Columnar = (*TupleColumn)(nil)
+
+func NewTupleColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	names, elemTypes := tupleElemTypes(chType)
+	if len(elemTypes) == 0 {
+		panic(fmt.Errorf("ch: invalid Tuple type: %q", chType))
+	}
+
+	c := &TupleColumn{
+		typ:       typ,
+		elemTypes: elemTypes,
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		table := TableForType(typ)
+		c.fields = make([]*Field, len(elemTypes))
+		for i, name := range names {
+			var field *Field
+			if name != "" {
+				field = table.FieldMap[name]
+			} else if i < len(table.Fields) {
+				field = table.Fields[i]
+			}
+			if field == nil {
+				panic(fmt.Errorf("ch: %s does not have a field for %s element #%d",
+					typ, chType, i+1))
+			}
+			c.fields[i] = field
+		}
+	case reflect.Slice:
+	default:
+		c.typ = anySliceType
+	}
+
+	c.Column = reflect.MakeSlice(reflect.SliceOf(c.typ), 0, numRow)
+	c.elems = make([]Columnar, len(elemTypes))
+	for i := range c.elems {
+		c.elems[i] = c.newElemColumn(i, 0)
+	}
+	return c
+}
+
+func (c *TupleColumn) Type() reflect.Type {
+	return c.typ
+}
+
+func (c *TupleColumn) Set(v any) {
+	c.Column = reflect.ValueOf(v)
+}
+
+func (c *TupleColumn) AppendValue(v reflect.Value) {
+	c.Column = reflect.Append(c.Column, v)
+}
+
+func (c *TupleColumn) Value() any {
+	return c.Column.Interface()
+}
+
+func (c *TupleColumn) Nullable(nulls UInt8Column) any {
+	panic("not implemented")
+}
+
+func (c *TupleColumn) Len() int {
+	return c.Column.Len()
+}
+
+func (c *TupleColumn) Index(idx int) any {
+	return c.Column.Index(idx).Interface()
+}
+
+func (c *TupleColumn) Slice(s, e int) any {
+	return c.Column.Slice(s, e).Interface()
+}
+
+func (c *TupleColumn) ConvertAssign(idx int, v reflect.Value) error {
+	v.Set(c.Column.Index(idx))
+	return nil
+}
+
+func (c *TupleColumn) newElemColumn(i, numRow int) Columnar {
+	if c.fields != nil {
+		return NewColumn(c.fields[i].Type, c.elemTypes[i], numRow)
+	}
+	return NewColumnFromCHType(c.elemTypes[i], numRow)
+}
+
+func (c *TupleColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	if numRow > 0 {
+		if err := c.readPrefix(rd, numRow); err != nil {
+			return err
+		}
+	}
+	return c.readData(rd, numRow)
+}
+
+func (c *TupleColumn) WriteTo(wr *chproto.Writer) error {
+	c.writePrefix(wr)
+	return c.writeData(wr)
+}
+
+var _ prefixColumnar = (*TupleColumn)(nil)
+
+func (c *TupleColumn) readPrefix(rd *chproto.Reader, numRow int) error {
+	for _, elem := range c.elems {
+		if err := readColumnPrefix(rd, elem, numRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *TupleColumn) readData(rd *chproto.Reader, numRow int) error {
+	c.Column = reflect.MakeSlice(reflect.SliceOf(c.typ), numRow, numRow)
+	if numRow == 0 {
+		return nil
+	}
+
+	for i, elem := range c.elems {
+		if err := readColumnData(rd, elem, numRow); err != nil {
+			return err
+		}
+
+		for row := 0; row < numRow; row++ {
+			tuple := c.Column.Index(row)
+			if c.fields == nil {
+				if tuple.IsNil() {
+					tuple.Set(reflect.MakeSlice(c.typ, len(c.elemTypes), len(c.elemTypes)))
+				}
+				tuple.Index(i).Set(reflect.ValueOf(elem.Index(row)))
+				continue
+			}
+			if err := elem.ConvertAssign(row, c.fields[i].Value(tuple)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *TupleColumn) writePrefix(wr *chproto.Writer) {
+	// The prefixes do not depend on the values.
+	for _, elem := range c.elems {
+		writeColumnPrefix(wr, elem)
+	}
+}
+
+func (c *TupleColumn) writeData(wr *chproto.Writer) error {
+	for i := range c.elemTypes {
+		elem := c.newElemColumn(i, c.Column.Len())
+
+		// Some columns require addressable values.
+		var value reflect.Value
+		if c.fields != nil {
+			value = reflect.New(c.fields[i].Type).Elem()
+		} else {
+			value = reflect.New(elem.Type()).Elem()
+		}
+
+		for row := 0; row < c.Column.Len(); row++ {
+			tuple := c.Column.Index(row)
+			if c.fields != nil {
+				value.Set(c.fields[i].Value(tuple))
+			} else if err := setAnyValue(value, tuple, i); err != nil {
+				return err
+			}
+			elem.AppendValue(value)
+		}
+
+		if err := writeColumnData(wr, elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setAnyValue sets v to the i-th element of the []any tuple.
+func setAnyValue(v, tuple reflect.Value, i int) error {
+	if i >= tuple.Len() || tuple.Index(i).IsNil() {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	elem := tuple.Index(i).Elem()
+	if elem.Type() != v.Type() {
+		if !elem.Type().ConvertibleTo(v.Type()) {
+			return fmt.Errorf("ch: can't use %s as %s in Tuple", elem.Type(), v.Type())
+		}
+		elem = elem.Convert(v.Type())
+	}
+	v.Set(elem)
+	return nil
+}
@@ -0,0 +1,89 @@
+package chschema_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+type tupleElem struct {
+	Name  string
+	Count uint64 `ch:"cnt"`
+}
+
+func roundTrip(t *testing.T, col chschema.Columnar, got chschema.Columnar) {
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+	require.NoError(t, got.ReadFrom(chproto.NewReader(&buf), col.Len()))
+}
+
+func TestTupleColumn(t *testing.T) {
+	chType := "Tuple(cnt UInt64, name String)"
+	typ := reflect.TypeOf(tupleElem{})
+	col := chschema.NewColumn(typ, chType, 0)
+	col.AppendValue(reflect.ValueOf(tupleElem{Name: "foo", Count: 1}))
+	col.AppendValue(reflect.ValueOf(tupleElem{Name: "bar", Count: 2}))
+
+	got := chschema.NewColumn(typ, chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []tupleElem{{"foo", 1}, {"bar", 2}}, got.Value())
+
+	anyCol := chschema.NewColumnFromCHType("Tuple(UInt64, String)", 0)
+	roundTrip(t, col, anyCol)
+	require.Equal(t, []any{uint64(1), "foo"}, anyCol.Index(0))
+
+	anyCol.AppendValue(reflect.ValueOf([]any{3, "baz"}))
+	got = chschema.NewColumnFromCHType("Tuple(UInt64, String)", 0)
+	roundTrip(t, anyCol, got)
+	require.Equal(t, []any{uint64(3), "baz"}, got.Index(2))
+}
+
+func TestTupleArrayColumn(t *testing.T) {
+	chType := "Array(Tuple(String, UInt64))"
+	typ := reflect.TypeOf([]tupleElem{})
+	col := chschema.NewColumn(typ, chType, 0)
+	col.AppendValue(reflect.ValueOf([]tupleElem{{"foo", 1}, {"bar", 2}}))
+	col.AppendValue(reflect.ValueOf([]tupleElem{{"baz", 3}}))
+
+	got := chschema.NewColumn(typ, chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []tupleElem{{"foo", 1}, {"bar", 2}}, got.Index(0))
+	require.Equal(t, []tupleElem{{"baz", 3}}, got.Index(1))
+}
+
+func TestTupleLowCardinality(t *testing.T) {
+	chType := "Tuple(LowCardinality(String), UInt64)"
+	typ := reflect.TypeOf(tupleElem{})
+	col := chschema.NewColumn(typ, chType, 0)
+	col.AppendValue(reflect.ValueOf(tupleElem{Name: "foo", Count: 1}))
+	col.AppendValue(reflect.ValueOf(tupleElem{Name: "foo", Count: 2}))
+
+	var buf bytes.Buffer
+	wr := chproto.NewWriter(&buf)
+	require.NoError(t, col.WriteTo(wr))
+	require.NoError(t, wr.Flush())
+	// The LowCardinality version precedes the data of the elements.
+	require.Equal(t, uint64(1), binary.LittleEndian.Uint64(buf.Bytes()[:8]))
+
+	got := chschema.NewColumn(typ, chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []tupleElem{{"foo", 1}, {"foo", 2}}, got.Value())
+
+	arrType := reflect.TypeOf([]tupleElem{})
+	arr := chschema.NewColumn(arrType, "Array("+chType+")", 0)
+	arr.AppendValue(reflect.ValueOf([]tupleElem{{"foo", 1}, {"bar", 2}}))
+	arr.AppendValue(reflect.ValueOf([]tupleElem{{"baz", 3}}))
+
+	gotArr := chschema.NewColumn(arrType, "Array("+chType+")", 0)
+	roundTrip(t, arr, gotArr)
+	require.Equal(t, []tupleElem{{"foo", 1}, {"bar", 2}}, gotArr.Index(0))
+	require.Equal(t, []tupleElem{{"baz", 3}}, gotArr.Index(1))
+}
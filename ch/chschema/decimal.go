@@ -0,0 +1,320 @@
+package chschema
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+)
+
+// Decimal is a value of the Decimal32, Decimal64, Decimal128, and Decimal256
+// types: an unscaled integer value and the number of digits after the decimal point.
+// For example, 123.45 is Decimal{Value: big.NewInt(12345), Scale: 2}.
+type Decimal struct {
+	Value *big.Int
+	Scale int
+}
+
+var _ QueryAppender = Decimal{}
+
+// NewDecimal returns a decimal with the unscaled value and scale.
+func NewDecimal(value int64, scale int) Decimal {
+	return Decimal{Value: big.NewInt(value), Scale: scale}
+}
+
+// ParseDecimal parses a decimal number such as "-123.45".
+func ParseDecimal(s string) (Decimal, error) {
+	digits := s
+	var scale int
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		digits = s[:i] + s[i+1:]
+		scale = len(s) - i - 1
+	}
+
+	value, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, fmt.Errorf("ch: can't parse Decimal %q", s)
+	}
+	return Decimal{Value: value, Scale: scale}, nil
+}
+
+func (d Decimal) value() *big.Int {
+	if d.Value == nil {
+		return new(big.Int)
+	}
+	return d.Value
+}
+
+// Rescale returns the decimal with the scale. Extra digits are truncated.
+func (d Decimal) Rescale(scale int) Decimal {
+	value := new(big.Int).Set(d.value())
+	switch {
+	case scale > d.Scale:
+		value.Mul(value, pow10(scale-d.Scale))
+	case scale < d.Scale:
+		value.Quo(value, pow10(d.Scale-scale))
+	}
+	return Decimal{Value: value, Scale: scale}
+}
+
+// Int64 returns the integer part of the decimal.
+func (d Decimal) Int64() int64 {
+	return d.Rescale(0).Value.Int64()
+}
+
+// Float64 returns the nearest float64 value of the decimal.
+func (d Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(d.String(), 64)
+	return f
+}
+
+func (d Decimal) String() string {
+	s := d.value().String()
+	if d.Scale <= 0 {
+		return s
+	}
+
+	var sign string
+	if s[0] == '-' {
+		sign, s = "-", s[1:]
+	}
+	if len(s) <= d.Scale {
+		s = strings.Repeat("0", d.Scale-len(s)+1) + s
+	}
+	i := len(s) - d.Scale
+	return sign + s[:i] + "." + s[i:]
+}
+
+func (d Decimal) AppendQuery(fmter Formatter, b []byte) ([]byte, error) {
+	return append(b, d.String()...), nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+//------------------------------------------------------------------------------
+
+// parseDecimalType returns the precision and the scale of Decimal(P, S)
+// and DecimalN(S) types.
+func parseDecimalType(chType string) (prec, scale int, ok bool) {
+	if s := chSubType(chType, "Decimal("); s != "" {
+		i := strings.IndexByte(s, ',')
+		if i == -1 {
+			return 0, 0, false
+		}
+		prec, err1 := strconv.Atoi(strings.TrimSpace(s[:i]))
+		scale, err2 := strconv.Atoi(strings.TrimSpace(s[i+1:]))
+		return prec, scale, err1 == nil && err2 == nil
+	}
+
+	for _, t := range [...]struct {
+		prefix string
+		prec   int
+	}{
+		{"Decimal32(", 9},
+		{"Decimal64(", 18},
+		{"Decimal128(", 38},
+		{"Decimal256(", 76},
+	} {
+		if s := chSubType(chType, t.prefix); s != "" {
+			scale, err := strconv.Atoi(strings.TrimSpace(s))
+			return t.prec, scale, err == nil
+		}
+	}
+
+	return 0, 0, false
+}
+
+func isDecimalType(chType string) bool {
+	_, _, ok := parseDecimalType(chType)
+	return ok
+}
+
+// decimalSize returns the number of bytes used to store decimals with the precision.
+func decimalSize(prec int) int {
+	switch {
+	case prec <= 9:
+		return 4
+	case prec <= 18:
+		return 8
+	case prec <= 38:
+		return 16
+	default:
+		return 32
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type DecimalColumn struct {
+	ColumnOf[Decimal]
+	size  int
+	scale int
+
+	err error // first value that can't be converted
+}
+
+var _ Columnar = (*DecimalColumn)(nil)
+
+func NewDecimalColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	prec, scale, ok := parseDecimalType(chType)
+	if !ok {
+		panic(fmt.Errorf("ch: invalid Decimal type: %q", chType))
+	}
+	return &DecimalColumn{
+		ColumnOf: NewColumnOf[Decimal](numRow),
+		size:     decimalSize(prec),
+		scale:    scale,
+	}
+}
+
+func (c *DecimalColumn) Type() reflect.Type {
+	return decimalType
+}
+
+func (c *DecimalColumn) ConvertAssign(idx int, v reflect.Value) error {
+	d := c.Column[idx]
+
+	if v.Type() == decimalType {
+		v.Set(reflect.ValueOf(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(d.Float64())
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		v.SetInt(d.Int64())
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		v.SetUint(uint64(d.Int64()))
+	case reflect.String:
+		v.SetString(d.String())
+	case reflect.Interface:
+		v.Set(reflect.ValueOf(d))
+	default:
+		return fmt.Errorf("ch: can't scan Decimal into %s", v.Type())
+	}
+	return nil
+}
+
+func (c *DecimalColumn) AppendValue(v reflect.Value) {
+	var d Decimal
+	var err error
+
+	if v.Type() == decimalType {
+		d = v.Interface().(Decimal)
+	} else {
+		switch v.Kind() {
+		case reflect.Float32, reflect.Float64:
+			s := strconv.FormatFloat(v.Float(), 'f', c.scale, 64)
+			d, err = ParseDecimal(s)
+		case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+			d = NewDecimal(v.Int(), 0)
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+			d = Decimal{Value: new(big.Int).SetUint64(v.Uint())}
+		case reflect.String:
+			d, err = ParseDecimal(v.String())
+		default:
+			err = fmt.Errorf("ch: can't use %s as Decimal", v.Type())
+		}
+	}
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+
+	c.Column = append(c.Column, d.Rescale(c.scale))
+}
+
+func (c *DecimalColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+
+	buf := make([]byte, c.size)
+	for i := range c.Column {
+		var value *big.Int
+
+		switch c.size {
+		case 4:
+			n, err := rd.Int32()
+			if err != nil {
+				return err
+			}
+			value = big.NewInt(int64(n))
+		case 8:
+			n, err := rd.Int64()
+			if err != nil {
+				return err
+			}
+			value = big.NewInt(n)
+		default:
+			if _, err := io.ReadFull(rd, buf); err != nil {
+				return err
+			}
+			value = bigIntFromLE(buf)
+		}
+
+		c.Column[i] = Decimal{Value: value, Scale: c.scale}
+	}
+
+	return nil
+}
+
+func (c *DecimalColumn) WriteTo(wr *chproto.Writer) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	buf := make([]byte, c.size)
+	for i := range c.Column {
+		d := c.Column[i]
+		if d.Scale != c.scale {
+			// Values set with Set are not rescaled by AppendValue.
+			d = d.Rescale(c.scale)
+		}
+
+		value := d.value()
+		if value.BitLen() >= 8*c.size {
+			return fmt.Errorf("ch: Decimal %s overflows %d bytes", d, c.size)
+		}
+
+		switch c.size {
+		case 4:
+			wr.Int32(int32(value.Int64()))
+		case 8:
+			wr.Int64(value.Int64())
+		default:
+			bigIntToLE(buf, value)
+			wr.Write(buf)
+		}
+	}
+	return nil
+}
+
+// bigIntFromLE decodes a little endian two's complement integer.
+func bigIntFromLE(b []byte) *big.Int {
+	be := make([]byte, len(b))
+	for i := range b {
+		be[len(b)-1-i] = b[i]
+	}
+
+	n := new(big.Int).SetBytes(be)
+	if len(be) > 0 && be[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return n
+}
+
+// bigIntToLE encodes n as a little endian two's complement integer into b.
+func bigIntToLE(b []byte, n *big.Int) {
+	if n.Sign() < 0 {
+		n = new(big.Int).Add(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	n.FillBytes(b)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -0,0 +1,62 @@
+package chschema_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestDecimalColumn(t *testing.T) {
+	values := []string{"0.00", "123.45", "-123.45", "-0.01"}
+	for _, chType := range []string{
+		"Decimal(9, 2)", "Decimal64(2)", "Decimal(38, 2)", "Decimal256(2)",
+	} {
+		col := chschema.NewColumnFromCHType(chType, 0)
+		for _, s := range values {
+			d, err := chschema.ParseDecimal(s)
+			require.NoError(t, err)
+			col.AppendValue(reflect.ValueOf(d))
+		}
+
+		var buf bytes.Buffer
+		wr := chproto.NewWriter(&buf)
+		require.NoError(t, col.WriteTo(wr))
+		require.NoError(t, wr.Flush())
+
+		got := chschema.NewColumnFromCHType(chType, 0)
+		require.NoError(t, got.ReadFrom(chproto.NewReader(&buf), len(values)))
+		for i, s := range values {
+			require.Equal(t, s, got.Index(i).(chschema.Decimal).String(), chType)
+		}
+
+		var f float64
+		require.NoError(t, got.ConvertAssign(2, reflect.ValueOf(&f).Elem()))
+		require.Equal(t, -123.45, f)
+
+		var n int64
+		require.NoError(t, got.ConvertAssign(1, reflect.ValueOf(&n).Elem()))
+		require.Equal(t, int64(123), n)
+	}
+}
+
+func TestDecimalFloat64(t *testing.T) {
+	col := chschema.NewColumn(reflect.TypeOf(float64(0)), "Decimal(18, 4)", 0)
+	col.AppendValue(reflect.ValueOf(1.23456))
+	require.Equal(t, "1.2346", col.Index(0).(chschema.Decimal).String())
+}
+
+func TestDecimalAppendError(t *testing.T) {
+	col := chschema.NewColumn(reflect.TypeOf(""), "Decimal(18, 4)", 0)
+	col.AppendValue(reflect.ValueOf("1.5"))
+	col.AppendValue(reflect.ValueOf("foo"))
+	col.AppendValue(reflect.ValueOf(true))
+	require.Equal(t, 3, col.Len())
+
+	err := col.WriteTo(chproto.NewWriter(new(bytes.Buffer)))
+	require.EqualError(t, err, `ch: can't parse Decimal "foo"`)
+}
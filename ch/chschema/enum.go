@@ -2,6 +2,7 @@ package chschema
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"sync"
@@ -9,9 +10,23 @@ import (
 
 var enumMap sync.Map
 
+// EnumValueError is returned when an inserted value is not a member of the enum.
+type EnumValueError struct {
+	Column string
+	Row    int
+	Value  string
+	Type   string
+}
+
+func (err *EnumValueError) Error() string {
+	return fmt.Sprintf("ch: column %s row %d: %q is not a member of %s",
+		err.Column, err.Row, err.Value, err.Type)
+}
+
 type enumInfo struct {
 	chType string
-	dec    []string
+	enum16 bool // values are encoded as Int16
+	dec    map[int16]string
 	enc    map[string]int16
 }
 
@@ -20,8 +35,23 @@ func (e *enumInfo) Encode(val string) (int16, bool) {
 	return i, ok
 }
 
-func (e *enumInfo) Decode(i int16) string {
-	return e.dec[i]
+// Default returns the member with the smallest value that ClickHouse uses
+// as the default value of the enum, for example, for NULL values.
+func (e *enumInfo) Default() string {
+	var def string
+	var min int16
+	found := false
+	for n, s := range e.dec {
+		if !found || n < min {
+			def, min, found = s, n, true
+		}
+	}
+	return def
+}
+
+func (e *enumInfo) Decode(i int16) (string, bool) {
+	s, ok := e.dec[i]
+	return s, ok
 }
 
 func parseEnum(s string) *enumInfo {
@@ -45,7 +75,12 @@ func _parseEnum(chType string) (*enumInfo, error) {
 		return nil, fmt.Errorf("can't parse enum type: %q", chType)
 	}
 
-	var dec []string
+	// Enum picks Enum8 or Enum16 depending on the values.
+	enum16 := strings.HasPrefix(chType, "Enum16(")
+	auto := strings.HasPrefix(chType, "Enum(")
+
+	dec := make(map[int16]string)
+	enc := make(map[string]int16)
 	for s != "" {
 		var key, val string
 		var ok bool
@@ -69,23 +104,22 @@ func _parseEnum(chType string) (*enumInfo, error) {
 		if err != nil {
 			return nil, err
 		}
-
-		ln := int(n + 1)
-		if len(dec) < ln {
-			dec = append(dec, make([]string, ln-len(dec))...)
+		if n < math.MinInt8 || n > math.MaxInt8 {
+			if !enum16 && !auto {
+				return nil, fmt.Errorf("enum value %d of %q is out of Int8 range", n, key)
+			}
+			enum16 = true
 		}
-		dec[n] = key
 
-		s, _ = scanEnumChar(s, ',')
-	}
+		dec[int16(n)] = key
+		enc[key] = int16(n)
 
-	enc := make(map[string]int16, len(dec))
-	for i, s := range dec {
-		enc[s] = int16(i)
+		s, _ = scanEnumChar(s, ',')
 	}
 
 	return &enumInfo{
 		chType: chType,
+		enum16: enum16,
 		dec:    dec,
 		enc:    enc,
 	}, nil
@@ -106,14 +140,21 @@ loop:
 		}
 	}
 
-	i := strings.IndexByte(s, '\'')
-	if i == -1 {
-		return s, "", false
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b = append(b, s[i])
+			}
+		case '\'':
+			return s[i+1:], string(b), true
+		default:
+			b = append(b, c)
+		}
 	}
-
-	key := s[:i]
-	s = s[i+1:]
-	return s, key, true
+	return s, "", false
 }
 
 func scanEnumChar(s string, ch byte) (string, bool) {
@@ -140,7 +181,7 @@ func scanEnumValue(s string) (string, string) {
 		switch {
 		case c == ' ':
 			start = i + 1
-		case c >= '0' && c <= '9':
+		case c >= '0' && c <= '9', c == '-' && i == start:
 			// continue
 		default:
 			return s[i:], s[start:i]
@@ -148,3 +189,57 @@ func scanEnumValue(s string) (string, string) {
 	}
 	return "", s[start:]
 }
+
+//------------------------------------------------------------------------------
+
+// enumFromTag builds the enum type from the enum tag option, for example,
+// `ch:",enum:hello|world=5"`. Values without a number are numbered
+// after the previous value starting with 1. The type is Enum8 when
+// all the values fit into Int8 and Enum16 otherwise.
+func enumFromTag(s string) (string, error) {
+	b := make([]byte, 0, len(s)+16)
+	next := int64(1)
+	small := true
+
+	for i, item := range strings.Split(s, "|") {
+		key, val := item, ""
+		if j := strings.LastIndexByte(item, '='); j >= 0 {
+			key, val = item[:j], item[j+1:]
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return "", fmt.Errorf("empty enum name in %q", s)
+		}
+
+		n := next
+		if val != "" {
+			var err error
+			n, err = strconv.ParseInt(strings.TrimSpace(val), 10, 16)
+			if err != nil {
+				return "", fmt.Errorf("can't parse enum value %q: %w", val, err)
+			}
+		}
+		if n < math.MinInt8 || n > math.MaxInt8 {
+			small = false
+		}
+		next = n + 1
+
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = append(b, '\'')
+		for j := 0; j < len(key); j++ {
+			if c := key[j]; c == '\'' || c == '\\' {
+				b = append(b, '\\')
+			}
+			b = append(b, key[j])
+		}
+		b = append(b, "' = "...)
+		b = strconv.AppendInt(b, n, 10)
+	}
+
+	if small {
+		return "Enum8(" + string(b) + ")", nil
+	}
+	return "Enum16(" + string(b) + ")", nil
+}
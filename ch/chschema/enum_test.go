@@ -0,0 +1,111 @@
+package chschema_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestEnumColumn(t *testing.T) {
+	chType := "Enum8('hello' = 1, 'world' = 2)"
+	col := chschema.NewColumn(reflect.TypeOf(""), chType, 0)
+	col.AppendValue(reflect.ValueOf("world"))
+	col.AppendValue(reflect.ValueOf("hello"))
+
+	got := chschema.NewColumn(reflect.TypeOf(""), chType, 0)
+	roundTrip(t, col, got)
+	require.Equal(t, []string{"world", "hello"}, got.Value())
+
+	block := chschema.NewBlock(nil, 1, 0)
+	values := block.Column("greeting", chType)
+	values.AppendValue(reflect.ValueOf("hello"))
+	values.AppendValue(reflect.ValueOf("world"))
+	values.AppendValue(reflect.ValueOf(""))
+	values.AppendValue(reflect.ValueOf("foo"))
+
+	err := block.WriteTo(chproto.NewWriter(new(bytes.Buffer)))
+	require.Equal(t, &chschema.EnumValueError{
+		Column: "greeting",
+		Row:    2,
+		Value:  "",
+		Type:   chType,
+	}, err)
+	require.EqualError(t, err,
+		`ch: column greeting row 2: "" is not a member of Enum8('hello' = 1, 'world' = 2)`)
+}
+
+func TestEnum16Column(t *testing.T) {
+	tests := []struct {
+		chType string
+		values []string
+	}{
+		{"Enum16('low' = -1000, 'high' = 1000)", []string{"high", "low"}},
+		{"Enum('a' = 1, 'b' = 300)", []string{"b", "a"}},
+		{"Enum8('neg' = -1, 'it\\'s' = 1)", []string{"it's", "neg"}},
+	}
+
+	for _, test := range tests {
+		col := chschema.NewColumn(reflect.TypeOf(""), test.chType, 0)
+		for _, v := range test.values {
+			col.AppendValue(reflect.ValueOf(v))
+		}
+
+		got := chschema.NewColumn(reflect.TypeOf(""), test.chType, 0)
+		roundTrip(t, col, got)
+		require.Equal(t, test.values, got.Value(), test.chType)
+	}
+
+	require.Panics(t, func() {
+		chschema.NewColumn(reflect.TypeOf(""), "Enum8('big' = 1000)", 0)
+	})
+}
+
+func TestArrayEnumValueError(t *testing.T) {
+	const enum = "Enum8('hello' = 1, 'world' = 2)"
+
+	tests := []struct {
+		chType string
+		values any
+		row    int
+		value  string
+	}{
+		{
+			"Array(" + enum + ")",
+			[][]string{{"hello"}, {}, {"world", "hello"}, {"world", "foo"}},
+			3, "foo",
+		},
+		{
+			"Array(Array(" + enum + "))",
+			[][][]string{{{"hello"}}, {{}, {"world"}}, {{"hello"}, {"bar"}}},
+			2, "bar",
+		},
+		{
+			"Array(Array(Array(" + enum + ")))",
+			[][][][]string{{{{"hello"}}}, {{{"world"}}, {{"hello", "baz"}}}},
+			1, "baz",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.chType, func(t *testing.T) {
+			block := chschema.NewBlock(nil, 1, 0)
+			col := block.Column("greetings", test.chType)
+			v := reflect.ValueOf(test.values)
+			for i := 0; i < v.Len(); i++ {
+				col.AppendValue(v.Index(i))
+			}
+
+			err := block.WriteTo(chproto.NewWriter(new(bytes.Buffer)))
+			require.Equal(t, &chschema.EnumValueError{
+				Column: "greetings",
+				Row:    test.row,
+				Value:  test.value,
+				Type:   enum,
+			}, err)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 const (
 	customTypeFlag = uint8(1) << iota
+	splitFlag
 )
 
 type Field struct {
@@ -23,6 +24,10 @@ type Field struct {
 	NewColumn   NewColumnFunc
 	appendValue AppenderFunc
 
+	// Nested is the Nested column of flattened fields, for example,
+	// the items column for the items.name field.
+	Nested *Field
+
 	IsPK    bool
 	NotNull bool
 
@@ -37,12 +42,24 @@ func (f *Field) Value(strct reflect.Value) reflect.Value {
 	return fieldByIndexAlloc(strct, f.Index)
 }
 
+// AppendColumn appends the quoted column name. The name is folded
+// by the formatter configured with WithIdentFolder.
+func (f *Field) AppendColumn(fmter Formatter, b []byte) []byte {
+	if fmter.foldIdent == nil {
+		return append(b, f.Column...)
+	}
+	return fmter.AppendIdent(b, f.CHName)
+}
+
 func (f *Field) AppendValue(fmter Formatter, b []byte, strct reflect.Value) []byte {
 	fv, ok := fieldByIndex(strct, f.Index)
 	if !ok {
 		return AppendNull(b)
 	}
 
+	if fmter.redact {
+		return appendRedacted(b)
+	}
 	if f.appendValue == nil {
 		return AppendError(b, fmt.Errorf("ch: AppendValue(unsupported %s)", fv.Type()))
 	}
@@ -20,7 +20,10 @@ func AppendQuery(b []byte, query string, args ...any) []byte {
 }
 
 type Formatter struct {
-	args *namedArgList
+	args      *namedArgList
+	values    *valueList
+	foldIdent func(string) string
+	redact    bool
 }
 
 func NewFormatter() Formatter {
@@ -28,19 +31,71 @@ func NewFormatter() Formatter {
 }
 
 func (f Formatter) AppendIdent(b []byte, ident string) []byte {
-	return AppendIdent(b, ident)
+	return AppendIdent(b, f.FoldIdent(ident))
 }
 
-func (f Formatter) WithArg(arg NamedArgAppender) Formatter {
-	return Formatter{
-		args: f.args.WithArg(arg),
+// AppendFQN is like AppendIdent, but quotes each part of the fully qualified
+// name, for example, db.table.
+func (f Formatter) AppendFQN(b []byte, name string) []byte {
+	return AppendFQN(b, f.FoldIdent(name))
+}
+
+// FoldIdent folds the case of the identifier using the func configured
+// with WithIdentFolder.
+func (f Formatter) FoldIdent(ident string) string {
+	if f.foldIdent != nil {
+		return f.foldIdent(ident)
 	}
+	return ident
 }
 
-func (f Formatter) WithNamedArg(name string, value any) Formatter {
-	return Formatter{
-		args: f.args.WithArg(&namedArg{name: name, value: value}),
+// WithIdentFolder returns a copy of the formatter that folds the case of
+// identifiers generated by query builders, for example, table and column
+// names of models and identifiers passed to Column and Ident.
+func (f Formatter) WithIdentFolder(fn func(string) string) Formatter {
+	f.foldIdent = fn
+	return f
+}
+
+// WithRedactedArgs returns a copy of the formatter that replaces values of
+// query arguments with ? so queries can be logged without exposing data.
+// Identifiers and SQL fragments, e.g. Ident and Safe, are formatted as usual.
+func (f Formatter) WithRedactedArgs() Formatter {
+	f.redact = true
+	return f
+}
+
+// Redacted reports whether the formatter redacts values of query arguments.
+func (f Formatter) Redacted() bool {
+	return f.redact
+}
+
+// WithValue returns a copy of the formatter that carries the value for the key,
+// like context.WithValue, so query appenders can pass state to the queries
+// nested in their args.
+func (f Formatter) WithValue(key, value any) Formatter {
+	f.values = &valueList{key: key, value: value, next: f.values}
+	return f
+}
+
+// Value returns the value for the key or nil.
+func (f Formatter) Value(key any) any {
+	for l := f.values; l != nil; l = l.next {
+		if l.key == key {
+			return l.value
+		}
 	}
+	return nil
+}
+
+func (f Formatter) WithArg(arg NamedArgAppender) Formatter {
+	f.args = f.args.WithArg(arg)
+	return f
+}
+
+func (f Formatter) WithNamedArg(name string, value any) Formatter {
+	f.args = f.args.WithArg(&namedArg{name: name, value: value})
+	return f
 }
 
 func (f Formatter) FormatQuery(query string, args ...any) string {
@@ -69,7 +124,7 @@ func (f Formatter) append(dst []byte, p *parser.Parser, args []any) []byte {
 
 	var argIndex int
 	for p.Valid() {
-		b, ok := p.ReadSep('?')
+		b, ok := p.ReadPlaceholder()
 		if !ok {
 			dst = append(dst, b...)
 			continue
@@ -170,6 +225,11 @@ func (l *namedArgList) AppendNamedArg(fmter Formatter, b []byte, name string) ([
 	return b, false
 }
 
+type valueList struct {
+	key, value any
+	next       *valueList
+}
+
 //------------------------------------------------------------------------------
 
 type namedArg struct {
@@ -0,0 +1,51 @@
+package chschema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestFormatQuery(t *testing.T) {
+	tests := []struct {
+		query  string
+		args   []any
+		wanted string
+	}{
+		{"a = ?", []any{1}, "a = 1"},
+		{`a = \? AND b = ?`, []any{1}, "a = ? AND b = 1"},
+		{"a = '?' AND b = ?", []any{1}, "a = '?' AND b = 1"},
+		{`a = 'it\'s?' AND b = ?`, []any{1}, `a = 'it\'s?' AND b = 1`},
+		{"a = 'it''s?' AND b = ?", []any{1}, "a = 'it''s?' AND b = 1"},
+		{`"a?" = ? AND b = ?`, []any{1, 2}, `"a?" = 1 AND b = 2`},
+		{"`a?` = ?", []any{1}, "`a?` = 1"},
+		{"a = ? -- why?\nAND b = ?", []any{1, 2}, "a = 1 -- why?\nAND b = 2"},
+		{"a = ? /* why? */ AND b = ?", []any{1, 2}, "a = 1 /* why? */ AND b = 2"},
+		{"a = ? - 1 AND b = ? / 2", []any{1, 2}, "a = 1 - 1 AND b = 2 / 2"},
+		{"a = 'unterminated ?", []any{1}, "a = 'unterminated ?"},
+		{"a = ? /* unterminated ?", []any{1}, "a = 1 /* unterminated ?"},
+	}
+	for _, test := range tests {
+		got := chschema.FormatQuery(test.query, test.args...)
+		require.Equal(t, test.wanted, got, test.query)
+	}
+
+	fmter := chschema.NewFormatter().WithNamedArg("id", 1)
+	require.Equal(t, "id = 1 AND name = '?id'",
+		fmter.FormatQuery("id = ?id AND name = '?id'"))
+}
+
+func TestFormatterValue(t *testing.T) {
+	type key struct{}
+
+	fmter := chschema.NewFormatter()
+	require.Nil(t, fmter.Value(key{}))
+
+	withValue := fmter.WithValue(key{}, 1).WithValue("other", 2)
+	require.Equal(t, 1, withValue.Value(key{}))
+	require.Equal(t, 2, withValue.Value("other"))
+	require.Equal(t, 3, withValue.WithValue(key{}, 3).Value(key{}))
+	require.Nil(t, fmter.Value(key{}))
+}
@@ -0,0 +1,112 @@
+package chschema
+
+import (
+	"reflect"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chtype"
+)
+
+// Point is the Point geo type, which is encoded as Tuple(Float64, Float64).
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Ring is the Ring geo type: a polygon without holes stored as Array(Point).
+type Ring []Point
+
+// Polygon is the Polygon geo type: the outer ring followed by the holes.
+type Polygon []Ring
+
+// MultiPolygon is the MultiPolygon geo type.
+type MultiPolygon []Polygon
+
+// geoArrayType returns the array type for Ring, Polygon, and MultiPolygon.
+func geoArrayType(chType string) string {
+	switch chType {
+	case chtype.Ring:
+		return "Array(Point)"
+	case chtype.Polygon:
+		return "Array(Ring)"
+	case chtype.MultiPolygon:
+		return "Array(Polygon)"
+	}
+	return ""
+}
+
+// newGeoArrayColumnFunc returns a NewColumnFunc that creates the array column
+// for the geo type.
+func newGeoArrayColumnFunc(arrayType string) NewColumnFunc {
+	return func(typ reflect.Type, chType string, numRow int) Columnar {
+		return NewColumn(typ, arrayType, numRow)
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type PointColumn struct {
+	ColumnOf[Point]
+}
+
+var _ Columnar = (*PointColumn)(nil)
+
+func NewPointColumn(typ reflect.Type, chType string, numRow int) Columnar {
+	return &PointColumn{
+		ColumnOf: NewColumnOf[Point](numRow),
+	}
+}
+
+func (c PointColumn) Type() reflect.Type {
+	return pointType
+}
+
+// Set accepts []Point and Ring, which array columns pass for Ring values.
+func (c *PointColumn) Set(v any) {
+	if column, ok := v.([]Point); ok {
+		c.Column = column
+		return
+	}
+	c.Column = reflect.ValueOf(v).Convert(pointSliceType).Interface().([]Point)
+}
+
+func (c PointColumn) ConvertAssign(idx int, v reflect.Value) error {
+	v.Set(reflect.ValueOf(c.Column[idx]))
+	return nil
+}
+
+func (c *PointColumn) AppendValue(v reflect.Value) {
+	c.Column = append(c.Column, v.Interface().(Point))
+}
+
+func (c *PointColumn) ReadFrom(rd *chproto.Reader, numRow int) error {
+	c.Alloc(numRow)
+
+	// Tuple elements are encoded one after another.
+	for i := range c.Column {
+		x, err := rd.Float64()
+		if err != nil {
+			return err
+		}
+		c.Column[i].X = x
+	}
+	for i := range c.Column {
+		y, err := rd.Float64()
+		if err != nil {
+			return err
+		}
+		c.Column[i].Y = y
+	}
+
+	return nil
+}
+
+func (c PointColumn) WriteTo(wr *chproto.Writer) error {
+	for i := range c.Column {
+		wr.Float64(c.Column[i].X)
+	}
+	for i := range c.Column {
+		wr.Float64(c.Column[i].Y)
+	}
+	return nil
+}
@@ -21,3 +21,11 @@ type AfterScanRowHook interface {
 }
 
 var afterScanBlockHookType = reflect.TypeOf((*AfterScanRowHook)(nil)).Elem()
+
+// BeforeAppendModelHook is called for each row before it is appended
+// to the insert block.
+type BeforeAppendModelHook interface {
+	BeforeAppendModel(ctx context.Context, query Query) error
+}
+
+var beforeAppendModelHookType = reflect.TypeOf((*BeforeAppendModelHook)(nil)).Elem()
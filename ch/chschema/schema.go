@@ -0,0 +1,216 @@
+package chschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+)
+
+// Schema is a serializable description of model tables that can be
+// stored, for example, in a JSON file and compared with Diff.
+type Schema struct {
+	Tables []*TableSchema `json:"tables"`
+}
+
+type TableSchema struct {
+	Name      string          `json:"name"`
+	Model     string          `json:"model"`
+	Engine    string          `json:"engine"`
+	Partition string          `json:"partition,omitempty"`
+	OrderBy   []string        `json:"order_by,omitempty"`
+	Columns   []*ColumnSchema `json:"columns"`
+}
+
+type ColumnSchema struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Default string `json:"default,omitempty"`
+	NotNull bool   `json:"not_null,omitempty"`
+	// DefaultKind is MATERIALIZED, ALIAS, or EPHEMERAL for columns read from
+	// the server. It is empty for DEFAULT, which is the only kind used by models.
+	DefaultKind string `json:"default_kind,omitempty"`
+
+	// The Go fields describe the model and are not compared by Diff.
+	GoName string `json:"go_name,omitempty"`
+	GoType string `json:"go_type,omitempty"`
+	PK     bool   `json:"pk,omitempty"`
+}
+
+// RegisteredTables returns all tables created for models so far,
+// sorted by name. Use TableForType or ch.RegisterModels to register
+// the models that are not used yet, for example, in tools that generate
+// documentation from the models.
+func RegisteredTables() []*Table {
+	var tables []*Table
+	globalTables.m.Range(func(key, value any) bool {
+		tables = append(tables, value.(*Table))
+		return true
+	})
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables
+}
+
+// TableByName returns the registered table with the table name or
+// the model name, or nil.
+func TableByName(name string) *Table {
+	return globalTables.getByName(name)
+}
+
+// NewSchema returns the schema for the tables.
+func NewSchema(tables []*Table) *Schema {
+	s := &Schema{
+		Tables: make([]*TableSchema, 0, len(tables)),
+	}
+	for _, table := range tables {
+		s.Tables = append(s.Tables, newTableSchema(table))
+	}
+	sort.Slice(s.Tables, func(i, j int) bool {
+		return s.Tables[i].Name < s.Tables[j].Name
+	})
+	return s
+}
+
+func newTableSchema(table *Table) *TableSchema {
+	ts := &TableSchema{
+		Name:      table.Name,
+		Model:     table.ModelName,
+		Engine:    table.CHEngine,
+		Partition: table.CHPartition,
+		Columns:   make([]*ColumnSchema, 0, len(table.Fields)),
+	}
+	if ts.Engine == "" {
+		ts.Engine = "MergeTree()"
+	}
+	for _, pk := range table.PKs {
+		ts.OrderBy = append(ts.OrderBy, pk.CHName)
+	}
+	for _, field := range table.Fields {
+		ts.Columns = append(ts.Columns, &ColumnSchema{
+			Name:    field.CHName,
+			Type:    field.CHType,
+			Default: string(field.CHDefault),
+			NotNull: field.NotNull,
+			GoName:  field.GoName,
+			GoType:  field.Type.String(),
+			PK:      field.IsPK,
+		})
+	}
+	return ts
+}
+
+// ReadSchema decodes a schema written with WriteTo.
+func ReadSchema(r io.Reader) (*Schema, error) {
+	s := new(Schema)
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, fmt.Errorf("ch: can't decode schema: %w", err)
+	}
+	return s, nil
+}
+
+// WriteTo writes the schema as indented JSON.
+func (s *Schema) WriteTo(w io.Writer) (int64, error) {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	b = append(b, '\n')
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (s *Schema) Table(name string) *TableSchema {
+	for _, t := range s.Tables {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// Diff returns human-readable differences between the schema s
+// and the other schema, for example, the one stored in a file.
+// An empty slice means the schemas are equal.
+func (s *Schema) Diff(other *Schema) []string {
+	var diff []string
+
+	for _, t := range s.Tables {
+		ot := other.Table(t.Name)
+		if ot == nil {
+			diff = append(diff, fmt.Sprintf("table %s: added", t.Name))
+			continue
+		}
+		diff = append(diff, t.diff(ot)...)
+	}
+	for _, ot := range other.Tables {
+		if s.Table(ot.Name) == nil {
+			diff = append(diff, fmt.Sprintf("table %s: removed", ot.Name))
+		}
+	}
+
+	return diff
+}
+
+func (t *TableSchema) column(name string) *ColumnSchema {
+	for _, col := range t.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+func (t *TableSchema) diff(other *TableSchema) []string {
+	var diff []string
+
+	if t.Engine != other.Engine {
+		diff = append(diff, fmt.Sprintf("table %s: engine changed from %s to %s",
+			t.Name, other.Engine, t.Engine))
+	}
+	if t.Partition != other.Partition {
+		diff = append(diff, fmt.Sprintf("table %s: partition changed from %q to %q",
+			t.Name, other.Partition, t.Partition))
+	}
+	if !reflect.DeepEqual(t.OrderBy, other.OrderBy) {
+		diff = append(diff, fmt.Sprintf("table %s: order by changed from %v to %v",
+			t.Name, other.OrderBy, t.OrderBy))
+	}
+
+	for _, col := range t.Columns {
+		ocol := other.column(col.Name)
+		if ocol == nil {
+			diff = append(diff, fmt.Sprintf("table %s: column %s added", t.Name, col.Name))
+			continue
+		}
+		if col.String() != ocol.String() {
+			diff = append(diff, fmt.Sprintf("table %s: column %s changed from %s to %s",
+				t.Name, col.Name, ocol, col))
+		}
+	}
+	for _, ocol := range other.Columns {
+		if t.column(ocol.Name) == nil {
+			diff = append(diff, fmt.Sprintf("table %s: column %s removed", t.Name, ocol.Name))
+		}
+	}
+
+	return diff
+}
+
+func (c *ColumnSchema) String() string {
+	s := c.Type
+	if c.NotNull {
+		s += " NOT NULL"
+	}
+	if c.DefaultKind != "" {
+		s += " " + c.DefaultKind
+	} else if c.Default != "" {
+		s += " DEFAULT"
+	}
+	if c.Default != "" {
+		s += " " + c.Default
+	}
+	return s
+}
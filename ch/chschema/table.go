@@ -3,6 +3,7 @@ package chschema
 import (
 	"fmt"
 	"reflect"
+	"strings"
 
 	"github.com/codemodus/kace"
 	"github.com/jinzhu/inflection"
@@ -16,10 +17,12 @@ const (
 	discardUnknownColumnsFlag = internal.Flag(1) << iota
 	columnarFlag
 	afterScanBlockHookFlag
+	beforeAppendModelHookFlag
 )
 
 var (
 	chModelType        = reflect.TypeOf((*CHModel)(nil)).Elem()
+	extraFieldType     = reflect.TypeOf((map[string]any)(nil))
 	tableNameInflector = inflection.Plural
 )
 
@@ -40,14 +43,21 @@ type Table struct {
 	CHName       Safe
 	CHInsertName Safe
 	CHAlias      Safe
+	aliasName    string // unquoted CHAlias
+	insertName   string // unquoted CHInsertName
 	CHEngine     string
 	CHPartition  string
+	CHSettings   []string // default SELECT settings, e.g. max_threads = 4
 
 	Fields     []*Field // PKs + DataFields
 	PKs        []*Field
 	DataFields []*Field
 	FieldMap   map[string]*Field
 
+	TenantField  *Field // field with the tenant option, if any
+	VersionField *Field // field with the version option, if any
+	ExtraField   *Field // map[string]any field with the extra option, if any
+
 	flags internal.Flag
 }
 
@@ -57,13 +67,16 @@ func newTable(typ reflect.Type) *Table {
 	t.ModelName = kace.Snake(t.Type.Name())
 	tableName := tableNameInflector(t.ModelName)
 	t.setName(tableName)
-	t.CHAlias = quoteColumnName(t.ModelName)
+	t.setAlias(t.ModelName)
 	t.initFields()
 
 	typ = reflect.PtrTo(t.Type)
 	if typ.Implements(afterScanBlockHookType) {
 		t.flags.Set(afterScanBlockHookFlag)
 	}
+	if typ.Implements(beforeAppendModelHookType) {
+		t.flags.Set(beforeAppendModelHookFlag)
+	}
 
 	return t
 }
@@ -81,13 +94,49 @@ func (t *Table) setName(name string) {
 	t.Name = name
 	t.CHName = quoted
 	t.CHInsertName = quoted
+	t.insertName = name
 	if t.CHAlias == "" {
 		t.CHAlias = quoted
+		t.aliasName = name
+	}
+}
+
+func (t *Table) setAlias(alias string) {
+	t.CHAlias = quoteColumnName(alias)
+	t.aliasName = alias
+}
+
+// AppendName appends the quoted table name. The name is folded
+// by the formatter configured with WithIdentFolder.
+func (t *Table) AppendName(fmter Formatter, b []byte) []byte {
+	if fmter.foldIdent == nil {
+		return append(b, t.CHName...)
+	}
+	return fmter.AppendFQN(b, t.Name)
+}
+
+// AppendAlias is like AppendName, but appends the table alias.
+func (t *Table) AppendAlias(fmter Formatter, b []byte) []byte {
+	if fmter.foldIdent == nil {
+		return append(b, t.CHAlias...)
+	}
+	if t.aliasName == t.Name {
+		return fmter.AppendFQN(b, t.aliasName)
+	}
+	return fmter.AppendIdent(b, t.aliasName)
+}
+
+// AppendInsertName is like AppendName, but appends the name of the table
+// that receives inserts.
+func (t *Table) AppendInsertName(fmter Formatter, b []byte) []byte {
+	if fmter.foldIdent == nil {
+		return append(b, t.CHInsertName...)
 	}
+	return fmter.AppendFQN(b, t.insertName)
 }
 
 func (t *Table) Field(name string) (*Field, error) {
-	field, ok := t.FieldMap[name]
+	field, ok := t.LookupField(name)
 	if !ok {
 		return nil, &UnknownColumnError{
 			Table:  t,
@@ -97,6 +146,20 @@ func (t *Table) Field(name string) (*Field, error) {
 	return field, nil
 }
 
+// LookupField returns the field of the column. Columns of queries formatted
+// with WithIdentFolder are matched regardless of the case.
+func (t *Table) LookupField(name string) (*Field, bool) {
+	if field, ok := t.FieldMap[name]; ok {
+		return field, true
+	}
+	for _, field := range t.Fields {
+		if strings.EqualFold(field.CHName, name) {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
 func (t *Table) initFields() {
 	t.Fields = make([]*Field, 0, t.Type.NumField())
 	t.FieldMap = make(map[string]*Field, t.Type.NumField())
@@ -133,8 +196,10 @@ func (t *Table) addFields(typ reflect.Type, baseIndex []int) {
 			if _, ok := tag.Options["inherit"]; ok {
 				embeddedTable := globalTables.Get(fieldType)
 				t.ModelName = embeddedTable.ModelName
+				t.Name = embeddedTable.Name
 				t.CHName = embeddedTable.CHName
 				t.CHAlias = embeddedTable.CHAlias
+				t.aliasName = embeddedTable.aliasName
 			}
 
 			continue
@@ -170,10 +235,11 @@ func (t *Table) processCHModelField(f reflect.StructField) {
 		t.setName(s)
 	}
 	if s, ok := tag.Option("alias"); ok {
-		t.CHAlias = quoteColumnName(s)
+		t.setAlias(s)
 	}
 	if s, ok := tag.Option("insert"); ok {
 		t.CHInsertName = quoteTableName(s)
+		t.insertName = s
 	}
 	if s, ok := tag.Option("engine"); ok {
 		t.CHEngine = s
@@ -184,6 +250,15 @@ func (t *Table) processCHModelField(f reflect.StructField) {
 	if tag.HasOption("columnar") {
 		t.flags |= columnarFlag
 	}
+	for _, s := range tag.Options["setting"] {
+		if s == "" {
+			continue
+		}
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			s = strings.TrimSpace(s[:i]) + " = " + strings.TrimSpace(s[i+1:])
+		}
+		t.CHSettings = append(t.CHSettings, s)
+	}
 }
 
 func (t *Table) newField(f reflect.StructField, index []int, tag tagparser.Tag) *Field {
@@ -207,6 +282,32 @@ func (t *Table) newField(f reflect.StructField, index []int, tag tagparser.Tag)
 	}
 	field.NotNull = tag.HasOption("notnull")
 	field.IsPK = tag.HasOption("pk")
+	if tag.HasOption("tenant") {
+		t.TenantField = field
+	}
+	if tag.HasOption("version") {
+		switch f.Type.Kind() {
+		case reflect.Int64, reflect.Uint64:
+		default:
+			if f.Type != timeType {
+				panic(fmt.Errorf("ch: %s.%s with the version option must be time.Time, int64, or uint64",
+					t.Type.Name(), f.Name))
+			}
+		}
+		t.VersionField = field
+	}
+	if tag.HasOption("nested") {
+		t.addNestedFields(field)
+		return nil
+	}
+	if tag.HasOption("extra") {
+		if f.Type != extraFieldType {
+			panic(fmt.Errorf("ch: %s.%s with the extra option must be map[string]any",
+				t.Type.Name(), f.Name))
+		}
+		t.ExtraField = field
+		return nil
+	}
 
 	if s, ok := tag.Option("type"); ok {
 		field.CHType = s
@@ -216,15 +317,47 @@ func (t *Table) newField(f reflect.StructField, index []int, tag tagparser.Tag)
 	}
 
 	if tag.HasOption("lc") {
-		if s := chSubType(field.CHType, "Array("); s != "" && s == chtype.String {
-			field.CHType = "Array(LowCardinality(String))"
-		} else if field.CHType == chtype.String {
-			field.CHType = "LowCardinality(String)"
+		if s := lcType(field.CHType); s != "" {
+			field.CHType = s
 		} else {
 			panic(fmt.Errorf("unsupported lc option on %s type", field.CHType))
 		}
 	}
 
+	if s, ok := tag.Option("enum"); ok {
+		enum, err := enumFromTag(s)
+		if err != nil {
+			panic(fmt.Errorf("ch: %s.%s: %w", t.Type.Name(), f.Name, err))
+		}
+		switch field.CHType {
+		case chtype.String:
+			field.CHType = enum
+		case "Nullable(String)":
+			field.CHType = "Nullable(" + enum + ")"
+		case "Array(String)":
+			field.CHType = "Array(" + enum + ")"
+		default:
+			panic(fmt.Errorf("ch: %s.%s with the enum option must be a string",
+				t.Type.Name(), f.Name))
+		}
+		field.setFlag(customTypeFlag)
+	}
+
+	if sep, ok := tag.Option("split"); ok {
+		if f.Type.Kind() != reflect.String {
+			panic(fmt.Errorf("ch: %s.%s with the split option must be a string",
+				t.Type.Name(), f.Name))
+		}
+		if !field.hasFlag(customTypeFlag) {
+			field.CHType = "Array(String)"
+		}
+		if sep == "" {
+			sep = ","
+		}
+		field.NewColumn = NewSplitColumnFunc(sep)
+		field.setFlag(splitFlag)
+	}
+
 	if s, ok := tag.Option("default"); ok {
 		field.CHDefault = Safe(s)
 	}
@@ -242,6 +375,39 @@ func (t *Table) newField(f reflect.StructField, index []int, tag tagparser.Tag)
 	return field
 }
 
+// addNestedFields adds the fields of the Nested column that stores a slice
+// of structs. Each struct field is stored in a separate column,
+// for example, items.name Array(String).
+func (t *Table) addNestedFields(nested *Field) {
+	typ := nested.Type
+	if typ.Kind() != reflect.Slice || typ.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("ch: %s.%s with the nested option must be a slice of structs",
+			t.Type.Name(), nested.GoName))
+	}
+
+	elemTable := globalTables.Get(typ.Elem())
+	columns := make([]string, 0, len(elemTable.Fields))
+	for _, elem := range elemTable.Fields {
+		columns = append(columns, string(elem.Column)+" "+elem.CHType)
+
+		name := nested.CHName + "." + elem.CHName
+		t.addField(&Field{
+			Field: nested.Field,
+			Type:  typ,
+			Index: nested.Index,
+
+			GoName: nested.GoName + "." + elem.GoName,
+			CHName: name,
+			Column: quoteColumnName(name),
+			CHType: "Array(" + elem.CHType + ")",
+
+			NewColumn: NewNestedColumnFunc(elem),
+			Nested:    nested,
+		})
+	}
+	nested.CHType = "Nested(" + strings.Join(columns, ", ") + ")"
+}
+
 func (t *Table) addField(field *Field) {
 	t.Fields = append(t.Fields, field)
 	if field.IsPK {
@@ -255,11 +421,18 @@ func (t *Table) addField(field *Field) {
 func (t *Table) NewColumn(colName, colType string, numRow int) *Column {
 	field, ok := t.FieldMap[colName]
 	if !ok {
-		internal.Logger.Printf("ch: %s has no column=%q", t, colName)
 		return nil
 	}
 
 	if colType != field.CHType {
+		if field.hasFlag(splitFlag) || field.Nested != nil {
+			// Split and nested fields accept arrays of any supported element type.
+			return &Column{
+				Name:     colName,
+				Type:     colType,
+				Columnar: field.NewColumn(field.Type, colType, numRow),
+			}
+		}
 		if field.CHType != chtype.Any {
 			internal.Logger.Printf("got column type %q, but %s.%s has type %q",
 				colType, t.Type.Name(), field.GoName, field.CHType)
@@ -280,6 +453,9 @@ func (t *Table) NewColumn(colName, colType string, numRow int) *Column {
 }
 
 func (t *Table) HasAfterScanRowHook() bool { return t.flags.Has(afterScanBlockHookFlag) }
+func (t *Table) HasBeforeAppendModelHook() bool {
+	return t.flags.Has(beforeAppendModelHookFlag)
+}
 
 func (t *Table) AppendNamedArg(
 	fmter Formatter, b []byte, name string, strct reflect.Value,
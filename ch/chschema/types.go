@@ -2,7 +2,9 @@ package chschema
 
 import (
 	"fmt"
+	"math/big"
 	"net"
+	"net/netip"
 	"reflect"
 	"strconv"
 	"strings"
@@ -13,7 +15,7 @@ import (
 )
 
 var chType = [...]string{
-	reflect.Bool:          chtype.UInt8,
+	reflect.Bool:          chtype.Bool,
 	reflect.Int:           chtype.Int64,
 	reflect.Int8:          chtype.Int8,
 	reflect.Int16:         chtype.Int16,
@@ -43,28 +45,50 @@ var chType = [...]string{
 
 // keep in sync with ColumnFactory
 func clickhouseType(typ reflect.Type) string {
+	if s := columnTypes.chType(typ); s != "" {
+		return s
+	}
+
 	switch typ {
 	case timeType:
 		return chtype.DateTime
-	case ipType:
+	case ipType, netipAddrType:
 		return chtype.IPv6
+	case decimalType:
+		return chtype.Decimal
+	case bigIntType:
+		return chtype.Int256
+	case pointType:
+		return chtype.Point
+	case ringType:
+		return chtype.Ring
+	case polygonType:
+		return chtype.Polygon
+	case multiPolygonType:
+		return chtype.MultiPolygon
+	}
+
+	if s := nullScannerElemType(typ); s != "" {
+		return fmt.Sprintf("Nullable(%s)", s)
 	}
 
 	kind := typ.Kind()
 	switch kind {
 	case reflect.Ptr:
-		if typ.Elem().Kind() == reflect.Struct {
+		if typ.Elem().Kind() == reflect.Struct &&
+			typ.Elem() != decimalType && typ.Elem() != netipAddrType {
 			return chtype.String
 		}
 		return fmt.Sprintf("Nullable(%s)", clickhouseType(typ.Elem()))
 	case reflect.Slice:
 		switch elem := typ.Elem(); elem.Kind() {
 		case reflect.Ptr:
-			if elem.Elem().Kind() == reflect.Struct {
+			if elem.Elem().Kind() == reflect.Struct && elem != bigIntType &&
+				elem.Elem() != netipAddrType {
 				return chtype.String // json
 			}
 		case reflect.Struct:
-			if elem != timeType {
+			if !isStructValueType(elem) {
 				return chtype.String // json
 			}
 		case reflect.Uint8:
@@ -72,10 +96,18 @@ func clickhouseType(typ reflect.Type) string {
 		}
 
 		return "Array(" + clickhouseType(typ.Elem()) + ")"
+	case reflect.Map:
+		if s := mapCHType(typ); s != "" {
+			return s
+		}
+		return chtype.String // json
 	case reflect.Array:
 		if isUUID(typ) {
 			return chtype.UUID
 		}
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return fmt.Sprintf("FixedString(%d)", typ.Len())
+		}
 	}
 
 	if s := chType[kind]; s != "" {
@@ -85,6 +117,49 @@ func clickhouseType(typ reflect.Type) string {
 	panic(fmt.Errorf("ch: unsupported Go type: %s", typ))
 }
 
+// mapCHType returns the Map(K, V) type of Go maps with keys and values that
+// are stored as ClickHouse types, e.g. map[string]uint64, or an empty string
+// for maps that are stored as JSON, e.g. map[string]any. Use
+// `ch:",type:String"` to keep storing other maps as JSON.
+func mapCHType(typ reflect.Type) string {
+	switch key := typ.Key(); key.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		if key != timeType && !isUUID(key) {
+			return ""
+		}
+	}
+
+	switch elem := indirectType(typ.Elem()); {
+	case elem.Kind() == reflect.Interface:
+		return ""
+	case elem.Kind() == reflect.Struct && !isStructValueType(elem) && elem != bigIntType.Elem():
+		return ""
+	case elem.Kind() == reflect.Map && mapCHType(elem) == "":
+		return ""
+	}
+
+	valueType := clickhouseType(typ.Elem())
+	if valueType == chtype.String && typ.Elem().Kind() != reflect.String {
+		return "" // the value is stored as JSON
+	}
+	return fmt.Sprintf("Map(%s, %s)", clickhouseType(typ.Key()), valueType)
+}
+
+// nullScannerElemType returns the ClickHouse type of the value field of structs
+// like sql.NullString that have a value field followed by the Valid field.
+func nullScannerElemType(typ reflect.Type) string {
+	if !isNullScannerType(typ) || typ.NumField() != 2 {
+		return ""
+	}
+	if f := typ.Field(1); f.Name != "Valid" || f.Type.Kind() != reflect.Bool {
+		return ""
+	}
+	return clickhouseType(typ.Field(0).Type)
+}
+
 type NewColumnFunc func(typ reflect.Type, chType string, numRow int) Columnar
 
 var kindToColumn = [...]NewColumnFunc{
@@ -121,13 +196,46 @@ func ColumnFactory(typ reflect.Type, chType string) NewColumnFunc {
 	if chType == chtype.Any {
 		return nil
 	}
+	if fn := columnTypes.columnFunc(typ, chType); fn != nil {
+		return fn
+	}
+	if chType == "JSON" {
+		panic(errNewJSONType(chType))
+	}
 
 	if s := lowCardinalityType(chType); s != "" {
-		switch s {
-		case chtype.String:
+		if s == chtype.String && !isBytesType(typ) {
 			return NewLCStringColumn
 		}
-		panic(fmt.Errorf("got %s, wanted LowCardinality(String)", chType))
+		if !isLCElemType(nullableType(s)) && !isLCElemType(s) {
+			panic(fmt.Errorf("ch: %s is not supported", chType))
+		}
+		if nullableType(s) != "" && typ.Kind() == reflect.Ptr {
+			return NewLCNullableColumn
+		}
+		return NewLCColumn
+	}
+	if s := nullableType(chType); s != "" {
+		if typ.Kind() == reflect.Ptr && typ != bigIntType {
+			return NullableNewColumnFunc(ColumnFactory(typ.Elem(), s))
+		}
+		if isNullScannerType(typ) {
+			return NewNullScannerColumn
+		}
+	}
+	if fixedStringSize(chType) > 0 {
+		switch typ.Kind() {
+		case reflect.String:
+			return NewFixedStringColumn
+		case reflect.Slice:
+			if typ.Elem().Kind() == reflect.Uint8 {
+				return NewFixedBytesColumn
+			}
+		case reflect.Array:
+			if typ.Elem().Kind() == reflect.Uint8 {
+				return NewFixedArrayColumn
+			}
+		}
 	}
 
 	if s := enumType(chType); s != "" {
@@ -136,6 +244,31 @@ func ColumnFactory(typ reflect.Type, chType string) NewColumnFunc {
 	if isDateTime64Type(chType) {
 		return NewDateTime64Column
 	}
+	if isDecimalType(chType) {
+		return NewDecimalColumn
+	}
+	if typ == bigIntType && (isBigIntType(chType) || isBigIntType(nullableType(chType))) {
+		// *big.Int columns handle NULL themselves.
+		return NewBigIntColumn
+	}
+	if isBigIntType(chType) && typ.Kind() != reflect.Slice {
+		return NewBigIntColumn
+	}
+	if isObjectType(chType) {
+		return NewObjectColumn
+	}
+	if isMapType(chType) {
+		return NewMapColumn
+	}
+	if isTupleType(chType) {
+		return NewTupleColumn
+	}
+	if chType == chtype.Point {
+		return NewPointColumn
+	}
+	if s := geoArrayType(chType); s != "" {
+		return newGeoArrayColumnFunc(s)
+	}
 
 	if strings.HasPrefix(chType, "SimpleAggregateFunction(") {
 		chType = chSubType(chType, "SimpleAggregateFunction(")
@@ -150,25 +283,44 @@ func ColumnFactory(typ reflect.Type, chType string) NewColumnFunc {
 			return NewDateTimeColumn
 		case chtype.Date:
 			return NewDateColumn
+		case chtype.Date32:
+			return NewDate32Column
 		case chtype.Int64:
 			return NewTimeColumn
 		}
 	case ipType:
+		if chType == chtype.IPv4 {
+			return NewIPAddrColumn
+		}
 		return NewIPColumn
+	case netipAddrType:
+		return NewIPAddrColumn
+	}
+
+	if chType == chtype.UUID && typ.Kind() != reflect.Ptr {
+		return NewUUIDColumn
 	}
 
 	kind := typ.Kind()
 
 	switch kind {
 	case reflect.Ptr:
-		if typ.Elem().Kind() == reflect.Struct {
+		if typ.Elem().Kind() == reflect.Struct &&
+			typ.Elem() != decimalType && typ.Elem() != netipAddrType {
 			return NewJSONColumn
 		}
 		return NullableNewColumnFunc(ColumnFactory(typ.Elem(), nullableType(chType)))
 	case reflect.Slice:
+		if s := chArrayElemType(chType); isBigIntType(s) || isBigIntType(nullableType(s)) ||
+			isTupleType(s) || s == chtype.UUID || chArrayElemType(s) != "" {
+			// Nested arrays check the depth of the Go slices in the elements.
+			return NewGenericArrayColumn
+		}
+
 		switch elem := typ.Elem(); elem.Kind() {
 		case reflect.Ptr:
-			if elem.Elem().Kind() == reflect.Struct {
+			if elem.Elem().Kind() == reflect.Struct && elem != bigIntType &&
+				elem.Elem() != netipAddrType {
 				return NewJSONColumn
 			}
 		case reflect.Int64:
@@ -184,7 +336,7 @@ func ColumnFactory(typ reflect.Type, chType string) NewColumnFunc {
 		case reflect.String:
 			return NewStringArrayColumn
 		case reflect.Struct:
-			if elem != timeType {
+			if !isStructValueType(elem) {
 				return NewJSONColumn
 			}
 		}
@@ -210,6 +362,11 @@ func ColumnFactory(typ reflect.Type, chType string) NewColumnFunc {
 		}
 	}
 
+	if kind != reflect.Struct && chArrayElemType(chType) != "" {
+		// For example, string for Array(String) or []string for Array(Array(String)).
+		panic(fmt.Errorf("ch: %s can't be used with %s", typ, chType))
+	}
+
 	fn := kindToColumn[kind]
 	if fn != nil {
 		return fn
@@ -224,6 +381,8 @@ func columnFromCHType(chType string) NewColumnFunc {
 		return NewStringColumn
 	case chtype.UUID:
 		return NewUUIDColumn
+	case chtype.Bool:
+		return NewBoolColumn
 	case chtype.Int8:
 		return NewInt8Column
 	case chtype.Int16:
@@ -250,9 +409,34 @@ func columnFromCHType(chType string) NewColumnFunc {
 		return NewDateTime64Column
 	case chtype.Date:
 		return NewDateColumn
+	case chtype.Date32:
+		return NewDate32Column
+	case chtype.IPv4:
+		return NewIPAddrColumn
 	case chtype.IPv6:
 		return NewIPColumn
 	default:
+		if isDecimalType(chType) {
+			return NewDecimalColumn
+		}
+		if isBigIntType(chType) {
+			return NewBigIntColumn
+		}
+		if isObjectType(chType) {
+			return NewObjectColumn
+		}
+		if isMapType(chType) {
+			return NewMapColumn
+		}
+		if isTupleType(chType) {
+			return NewTupleColumn
+		}
+		if chType == chtype.Point {
+			return NewPointColumn
+		}
+		if s := geoArrayType(chType); s != "" {
+			return newGeoArrayColumnFunc(s)
+		}
 		return nil
 	}
 }
@@ -277,17 +461,38 @@ var (
 	ipType     = reflect.TypeOf((*net.IP)(nil)).Elem()
 	ipNetType  = reflect.TypeOf((*net.IPNet)(nil)).Elem()
 
+	netipAddrType = reflect.TypeOf((*netip.Addr)(nil)).Elem()
+
+	pointType        = reflect.TypeOf((*Point)(nil)).Elem()
+	pointSliceType   = reflect.TypeOf((*[]Point)(nil)).Elem()
+	ringType         = reflect.TypeOf((*Ring)(nil)).Elem()
+	polygonType      = reflect.TypeOf((*Polygon)(nil)).Elem()
+	multiPolygonType = reflect.TypeOf((*MultiPolygon)(nil)).Elem()
+
+	decimalType = reflect.TypeOf((*Decimal)(nil)).Elem()
+	bigIntType  = reflect.TypeOf((*big.Int)(nil))
+
 	int64SliceType   = reflect.TypeOf((*[]int64)(nil)).Elem()
 	uint64SliceType  = reflect.TypeOf((*[]uint64)(nil)).Elem()
 	float32SliceType = reflect.TypeOf((*[]float32)(nil)).Elem()
 	float64SliceType = reflect.TypeOf((*[]float64)(nil)).Elem()
 	stringSliceType  = reflect.TypeOf((*[]string)(nil)).Elem()
+	anySliceType     = reflect.TypeOf((*[]any)(nil)).Elem()
+	mapStringAnyType = reflect.TypeOf((*map[string]any)(nil)).Elem()
 )
 
 func goType(chType string) reflect.Type {
+	if typ := columnTypes.goType(chType); typ != nil {
+		return typ
+	}
+
 	switch chType {
+	case chtype.Bool:
+		return boolType
 	case chtype.Int8:
 		return int8Type
+	case chtype.Int16:
+		return int16Type
 	case chtype.Int32:
 		return int32Type
 	case chtype.Int64:
@@ -312,6 +517,10 @@ func goType(chType string) reflect.Type {
 		return timeType
 	case chtype.Date:
 		return timeType
+	case chtype.Date32:
+		return timeType
+	case chtype.IPv4:
+		return netipAddrType
 	case chtype.IPv6:
 		return ipType
 	default:
@@ -326,13 +535,46 @@ func goType(chType string) reflect.Type {
 	if s := enumType(chType); s != "" {
 		return stringType
 	}
+	if fixedStringSize(chType) > 0 {
+		return stringType
+	}
 	if s := dateTimeType(chType); s != "" {
 		return timeType
 	}
 	if isDateTime64Type(chType) {
 		return timeType
 	}
+	if isDecimalType(chType) {
+		return decimalType
+	}
+	if isBigIntType(chType) {
+		return bigIntType
+	}
+	if keyType, valueType := mapKeyValueTypes(chType); keyType != "" {
+		return reflect.MapOf(goType(keyType), goType(valueType))
+	}
+	if isTupleType(chType) {
+		return anySliceType
+	}
+	if isObjectType(chType) {
+		return mapStringAnyType
+	}
+	switch chType {
+	case "JSON":
+		panic(errNewJSONType(chType))
+	case chtype.Point:
+		return pointType
+	case chtype.Ring:
+		return ringType
+	case chtype.Polygon:
+		return polygonType
+	case chtype.MultiPolygon:
+		return multiPolygonType
+	}
 	if s := nullableType(chType); s != "" {
+		if isBigIntType(s) {
+			return bigIntType
+		}
 		return reflect.PtrTo(goType(s))
 	}
 	if _, funcType := aggFuncNameAndType(chType); funcType != "" {
@@ -342,6 +584,21 @@ func goType(chType string) reflect.Type {
 	panic(fmt.Errorf("unsupported ClickHouse type=%q", chType))
 }
 
+// isBytesType reports whether typ is []byte or a named byte slice.
+func isBytesType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+}
+
+// isStructValueType reports whether slices of the struct type are stored
+// as arrays instead of JSON.
+func isStructValueType(typ reflect.Type) bool {
+	switch typ {
+	case timeType, decimalType, netipAddrType, pointType:
+		return true
+	}
+	return false
+}
+
 func chArrayElemType(s string) string {
 	s = chSubType(s, "Array(")
 	if s == "" {
@@ -365,8 +622,51 @@ func lowCardinalityType(s string) string {
 	return chSubType(s, "LowCardinality(")
 }
 
+// isLCElemType reports whether LowCardinality supports the type
+// besides String and Nullable types.
+func isLCElemType(s string) bool {
+	switch s {
+	case chtype.String, chtype.Bool,
+		chtype.Int8, chtype.Int16, chtype.Int32, chtype.Int64,
+		chtype.UInt8, chtype.UInt16, chtype.UInt32, chtype.UInt64,
+		chtype.Float32, chtype.Float64,
+		chtype.Date, chtype.Date32, chtype.DateTime:
+		return true
+	}
+	return fixedStringSize(s) > 0 || dateTimeType(s) != ""
+}
+
+// lcType returns the LowCardinality type for the lc tag option or an empty string.
+// Arrays of strings, including nested arrays, use LowCardinality elements.
+func lcType(chType string) string {
+	if s := chArrayElemType(chType); s != "" {
+		if s != chtype.String && chArrayElemType(s) == "" {
+			return ""
+		}
+		if s = lcType(s); s != "" {
+			return "Array(" + s + ")"
+		}
+		return ""
+	}
+	if isLCElemType(chType) || isLCElemType(nullableType(chType)) {
+		return "LowCardinality(" + chType + ")"
+	}
+	return ""
+}
+
+// fixedStringSize returns N of FixedString(N) or 0.
+func fixedStringSize(s string) int {
+	n, _ := strconv.Atoi(chSubType(s, "FixedString("))
+	return n
+}
+
 func enumType(s string) string {
-	return chSubType(s, "Enum8(")
+	for _, prefix := range []string{"Enum8(", "Enum16(", "Enum("} {
+		if s := chSubType(s, prefix); s != "" {
+			return s
+		}
+	}
+	return ""
 }
 
 func dateTimeType(s string) string {
@@ -429,6 +729,32 @@ func chSubType(s, prefix string) string {
 	return ""
 }
 
+// splitTypes splits comma-separated types, for example, arguments of Map
+// and Tuple, ignoring commas inside parentheses.
+func splitTypes(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	var types []string
+	var depth int
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				types = append(types, strings.TrimSpace(s[:i]))
+				s = s[i+1:]
+				i = -1
+			}
+		}
+	}
+	return append(types, strings.TrimSpace(s))
+}
+
 func isUUID(typ reflect.Type) bool {
-	return typ.Len() == 16 && typ.Elem().Kind() == reflect.Uint8
+	return typ.Kind() == reflect.Array && typ.Len() == 16 && typ.Elem().Kind() == reflect.Uint8
 }
@@ -3,19 +3,32 @@ package chtype
 const (
 	Any        = "_" // for decoding into interface{}
 	String     = "String"
+	Bool       = "Bool"
 	UUID       = "UUID"
 	Int8       = "Int8"
 	Int16      = "Int16"
 	Int32      = "Int32"
 	Int64      = "Int64"
+	Int128     = "Int128"
+	Int256     = "Int256"
 	UInt8      = "UInt8"
 	UInt16     = "UInt16"
 	UInt32     = "UInt32"
 	UInt64     = "UInt64"
+	UInt128    = "UInt128"
+	UInt256    = "UInt256"
 	Float32    = "Float32"
 	Float64    = "Float64"
 	DateTime   = "DateTime"
 	DateTime64 = "DateTime64"
 	Date       = "Date"
+	Date32     = "Date32"
+	IPv4       = "IPv4"
 	IPv6       = "IPv6"
+	Decimal    = "Decimal(38, 9)"
+
+	Point        = "Point"
+	Ring         = "Ring"
+	Polygon      = "Polygon"
+	MultiPolygon = "MultiPolygon"
 )
@@ -0,0 +1,59 @@
+package ch
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+// clusterCache caches the cluster name read from system.macros.
+type clusterCache struct {
+	mu       sync.Mutex
+	resolved bool
+	name     string
+}
+
+// Cluster returns the cluster name used in ON CLUSTER clauses of DDL queries.
+// It is the name set with WithCluster or the substitution of the macro set with
+// WithClusterMacro. The macro is read from system.macros once and cached.
+// Cluster returns an empty string when the macro is not defined, for example,
+// on a single node, so DDL queries are executed without ON CLUSTER.
+func (db *DB) Cluster(ctx context.Context) (string, error) {
+	if db.cfg.Cluster != "" || db.cluster == nil {
+		return db.cfg.Cluster, nil
+	}
+
+	c := db.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.resolved {
+		return c.name, nil
+	}
+
+	var name string
+	if err := db.NewSelect().
+		ColumnExpr("substitution").
+		TableExpr("system.macros").
+		Where("macro = ?", db.cfg.ClusterMacro).
+		Scan(ctx, &name); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	c.name = name
+	c.resolved = true
+	return name, nil
+}
+
+// cachedCluster is like Cluster, but does not read the macro.
+func (db *DB) cachedCluster() string {
+	if db.cfg.Cluster != "" || db.cluster == nil {
+		return db.cfg.Cluster
+	}
+
+	c := db.cluster
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.name
+}
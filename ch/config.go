@@ -1,6 +1,7 @@
 package ch
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -12,41 +13,207 @@ import (
 	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chpool"
-	"github.com/uptrace/go-clickhouse/ch/internal"
-)
-
-const (
-	discardUnknownColumnsFlag internal.Flag = 1 << iota
 )
 
 type Config struct {
 	chpool.Config
 
-	Compression bool
+	// Compression is the method used to compress data blocks: "lz4", "zstd", or "none".
+	Compression Compression
+	// CompressionLevel is the LZ4HC or ZSTD compression level.
+	// Zero means the fastest LZ4 compression and the default ZSTD level.
+	CompressionLevel int
+	// CompressionBlockSize is the maximum size of uncompressed data in a
+	// compressed block. Zero means 1MB.
+	CompressionBlockSize int
+	// SkipChecksumVerification disables verification of compressed block
+	// checksums, for example, on trusted links where the CPU cost matters.
+	SkipChecksumVerification bool
+	// StrictChecksums enables verification of compressed blocks before they
+	// are sent to the server.
+	StrictChecksums bool
 
 	Network  string
 	Addr     string
 	User     string
 	Password string
 	Database string
+	// Authenticator provides the user name and the password on each handshake.
+	// It overrides User and Password.
+	Authenticator Authenticator
+
+	// Dialer creates network connections. It shadows chpool.Config.Dialer,
+	// which is set up by the DB to use this dialer and TLSConfig.
+	// Default is net.Dialer with DialTimeout.
+	Dialer      func(ctx context.Context, network, addr string) (net.Conn, error)
+	DialTimeout time.Duration
+	TLSConfig   *tls.Config
+
+	// ConnectRetryTimeout is how long failed dials and handshakes are retried,
+	// for example, while a ClickHouse Cloud service wakes up from idle.
+	// Zero disables retries.
+	ConnectRetryTimeout time.Duration
+
+	// DNSResolveInterval enables re-resolving the Addr host every interval.
+	// New connections are spread across the resolved addresses.
+	DNSResolveInterval time.Duration
+
+	// TCPKeepAlive is the keep-alive period for TCP connections, including
+	// the ones returned by Dialer. Negative value disables keep-alives.
+	TCPKeepAlive time.Duration
+	// TCPNoDelay controls TCP_NODELAY on TCP connections.
+	TCPNoDelay bool
 
-	DialTimeout   time.Duration
-	TLSConfig     *tls.Config
 	QuerySettings map[string]any
+	// LogSettingsDiff enables logging of QuerySettings that differ from
+	// the server settings once the first connection is established.
+	LogSettingsDiff bool
 
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	// DefaultQueryTimeout is applied to queries whose context has no deadline.
+	// Zero means no timeout.
+	DefaultQueryTimeout time.Duration
+
+	// ContextDeadline enables sending the time left until the context
+	// deadline as the max_execution_time setting.
+	ContextDeadline bool
+	// TimeoutOverflowMode is sent as the timeout_overflow_mode setting along with
+	// max_execution_time derived from the context deadline. Empty string means
+	// the server default.
+	TimeoutOverflowMode TimeoutOverflowMode
 
 	MaxRetries      int
 	MinRetryBackoff time.Duration
 	MaxRetryBackoff time.Duration
+	// RetryPolicy overrides the retry options above when set.
+	RetryPolicy RetryPolicy
+
+	// UnknownColumns is the policy for result columns that are not present in the model.
+	UnknownColumns UnknownColumnPolicy
+
+	// Cluster is the cluster name used in ON CLUSTER clauses of DDL queries.
+	Cluster string
+	// ClusterMacro is the name of the macro in system.macros with the cluster
+	// name used when Cluster is empty.
+	ClusterMacro string
+
+	// RejectNonFiniteFloats makes inserts fail with *chschema.NonFiniteFloatError
+	// when a float value is NaN or ±Inf.
+	RejectNonFiniteFloats bool
+	// NonFiniteFloatsAsNull scans NaN and ±Inf into interface and pointer
+	// destinations, for example, map[string]any, as nil.
+	NonFiniteFloatsAsNull bool
+
+	// TruncateFixedStrings truncates inserted values that are longer than
+	// FixedString(N) instead of failing with *chschema.FixedStringSizeError.
+	TruncateFixedStrings bool
+
+	// InsertRowsPerSec and InsertBytesPerSec limit the rate of inserted rows
+	// and uncompressed bytes. Zero means no limit.
+	InsertRowsPerSec  int
+	InsertBytesPerSec int
+
+	// ErrorQueryLength limits the length of the query included in QueryError.
+	ErrorQueryLength int
+	// ErrorQueryRedactor removes sensitive data from the query included in QueryError.
+	ErrorQueryRedactor func(query string) string
+	// ErrorQueryArgs includes the args of raw queries in QueryError.
+	ErrorQueryArgs bool
+
+	// ShutdownTimeout is how long Close waits for in-flight queries.
+	ShutdownTimeout time.Duration
+
+	// MaxConcurrentQueries limits the number of queries executed at the same time.
+	// Zero means no limit besides PoolSize.
+	MaxConcurrentQueries int
+	// QueueTimeout is how long a query waits for a free slot
+	// when MaxConcurrentQueries is reached. Zero means wait until ctx is done.
+	QueueTimeout time.Duration
 }
 
 func (cfg *Config) netDialer() *net.Dialer {
 	return &net.Dialer{
 		Timeout:   cfg.DialTimeout,
-		KeepAlive: 5 * time.Minute,
+		KeepAlive: cfg.TCPKeepAlive,
+	}
+}
+
+// dial connects to addr, which is either cfg.Addr or one of the addresses
+// it resolves to.
+func (cfg *Config) dial(ctx context.Context, addr string) (net.Conn, error) {
+	conn, err := cfg._dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	netConn := conn
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		netConn = tlsConn.NetConn()
+	}
+	if tcpConn, ok := netConn.(*net.TCPConn); ok {
+		if err := cfg.setTCPOptions(tcpConn); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// setTCPOptions applies the TCP options to the connection, which may have
+// been created by a custom Dialer.
+func (cfg *Config) setTCPOptions(conn *net.TCPConn) error {
+	if err := conn.SetNoDelay(cfg.TCPNoDelay); err != nil {
+		return err
+	}
+	if cfg.TCPKeepAlive < 0 {
+		return conn.SetKeepAlive(false)
+	}
+	if err := conn.SetKeepAlive(true); err != nil {
+		return err
+	}
+	if cfg.TCPKeepAlive > 0 {
+		return conn.SetKeepAlivePeriod(cfg.TCPKeepAlive)
+	}
+	return nil
+}
+
+func (cfg *Config) _dial(ctx context.Context, addr string) (net.Conn, error) {
+	if cfg.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.DialTimeout)
+		defer cancel()
+	}
+
+	dialer := cfg.Dialer
+	if dialer == nil {
+		dialer = cfg.netDialer().DialContext
+	}
+
+	conn, err := dialer(ctx, cfg.Network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.TLSConfig == nil {
+		return conn, nil
 	}
+
+	// Send the configured host for SNI and verify the certificate against it
+	// even when addr is a resolved IP address. Servers behind SNI-based
+	// proxies require the name even when verification is skipped.
+	tlsConfig := cfg.TLSConfig
+	if tlsConfig.ServerName == "" {
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName, _, _ = net.SplitHostPort(cfg.Addr)
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
 }
 
 func defaultConfig() *Config {
@@ -60,7 +227,7 @@ func defaultConfig() *Config {
 			ConnMaxIdleTime: 30 * time.Minute,
 		},
 
-		Compression: true,
+		Compression: CompressionLZ4,
 
 		Network:  "tcp",
 		Addr:     "localhost:9000",
@@ -68,28 +235,109 @@ func defaultConfig() *Config {
 		Database: "default",
 
 		DialTimeout:  5 * time.Second,
+		TCPKeepAlive: 5 * time.Minute,
+		TCPNoDelay:   true,
 		ReadTimeout:  5 * time.Second,
 		WriteTimeout: 5 * time.Second,
 
 		MaxRetries:      2,
 		MinRetryBackoff: 500 * time.Millisecond,
 		MaxRetryBackoff: time.Second,
+
+		ErrorQueryLength: 4096,
 	}
 	return cfg
 }
 
 type Option func(db *DB)
 
+// TimeoutOverflowMode is what the server does when a query exceeds max_execution_time.
+type TimeoutOverflowMode string
+
+const (
+	// TimeoutOverflowThrow fails the query with an exception.
+	TimeoutOverflowThrow TimeoutOverflowMode = "throw"
+	// TimeoutOverflowBreak stops the query and returns the partial result.
+	TimeoutOverflowBreak TimeoutOverflowMode = "break"
+)
+
+// WithDiscardUnknownColumns ignores result columns that are not present in the model.
+// It is the same as WithUnknownColumns(UnknownColumnsIgnore).
 func WithDiscardUnknownColumns() Option {
+	return WithUnknownColumns(UnknownColumnsIgnore)
+}
+
+// WithUnknownColumns configures what happens with result columns that are not
+// present in the scanned model. Default is UnknownColumnsError.
+func WithUnknownColumns(policy UnknownColumnPolicy) Option {
 	return func(db *DB) {
-		db.flags.Set(discardUnknownColumnsFlag)
+		db.cfg.UnknownColumns = policy
 	}
 }
 
-// WithCompression enables/disables LZ4 compression.
+// WithCompression enables/disables LZ4 compression. Disabled compression also
+// removes the block framing, which is faster on localhost connections and easier to
+// inspect in packet captures. DSN equivalent is compress=false (or compress=none).
 func WithCompression(enabled bool) Option {
 	return func(db *DB) {
-		db.cfg.Compression = enabled
+		if enabled {
+			db.cfg.Compression = CompressionLZ4
+		} else {
+			db.cfg.Compression = CompressionNone
+		}
+	}
+}
+
+// WithCompressionMethod configures the method used to compress data blocks.
+// With CompressionZSTD the server is also asked to compress its blocks using ZSTD.
+func WithCompressionMethod(method Compression) Option {
+	return func(db *DB) {
+		db.cfg.Compression = method
+	}
+}
+
+// WithIdentFolder configures a func that folds the case of identifiers
+// generated by query builders, for example, strings.ToLower. It applies to
+// table and column names of models and to identifiers passed to Column, Ident,
+// With, and OnCluster. Use IdentFolder to fold identifiers of a single query.
+func WithIdentFolder(fn func(string) string) Option {
+	return func(db *DB) {
+		db.fmter = db.fmter.WithIdentFolder(fn)
+	}
+}
+
+// WithCompressionLevel configures the compression level. Higher levels
+// trade CPU for bandwidth. With LZ4, positive levels (1-9) enable LZ4HC.
+func WithCompressionLevel(level int) Option {
+	return func(db *DB) {
+		db.cfg.CompressionLevel = level
+	}
+}
+
+// WithCompressionBlockSize configures the maximum size of uncompressed data
+// in a compressed block. Default is 1MB.
+func WithCompressionBlockSize(size int) Option {
+	return func(db *DB) {
+		db.cfg.CompressionBlockSize = size
+	}
+}
+
+// WithChecksumVerification enables/disables verification of compressed block
+// checksums. Verification is enabled by default. When a checksum does not match,
+// queries fail with *ChecksumError.
+func WithChecksumVerification(on bool) Option {
+	return func(db *DB) {
+		db.cfg.SkipChecksumVerification = !on
+	}
+}
+
+// WithStrictChecksums enables/disables verification of compressed blocks before
+// they are sent: each block is decompressed and compared with the original data
+// so corruption in the client is reported with *CompressionError naming the block
+// instead of being detected by the server.
+func WithStrictChecksums(on bool) Option {
+	return func(db *DB) {
+		db.cfg.StrictChecksums = on
 	}
 }
 
@@ -100,6 +348,172 @@ func WithAddr(addr string) Option {
 	}
 }
 
+// WithDialer configures a custom dialer, for example, to connect through
+// a SOCKS5 proxy. DialTimeout and TLSConfig are still applied.
+func WithDialer(
+	dialer func(ctx context.Context, network, addr string) (net.Conn, error),
+) Option {
+	return func(db *DB) {
+		db.cfg.Dialer = dialer
+	}
+}
+
+// WithConnectRetry retries dials and handshakes that fail with network errors
+// or timeouts for up to timeout, for example, while a ClickHouse Cloud service
+// wakes up from idle. Retries use an exponential backoff.
+func WithConnectRetry(timeout time.Duration) Option {
+	return func(db *DB) {
+		db.cfg.ConnectRetryTimeout = timeout
+	}
+}
+
+// WithDNSResolveInterval configures the client to resolve the Addr host
+// at most once per interval and to spread new connections across all
+// returned addresses in a round-robin fashion. It is useful when the host
+// points at a rotating set of servers. Default is 0, i.e. only the first
+// reachable address is used.
+func WithDNSResolveInterval(interval time.Duration) Option {
+	return func(db *DB) {
+		db.cfg.DNSResolveInterval = interval
+	}
+}
+
+// WithTCPKeepAlive configures the keep-alive period for TCP connections.
+// Negative value disables keep-alives. Default is 5 minutes.
+func WithTCPKeepAlive(period time.Duration) Option {
+	return func(db *DB) {
+		db.cfg.TCPKeepAlive = period
+	}
+}
+
+// WithTCPNoDelay enables/disables TCP_NODELAY. Default is true.
+func WithTCPNoDelay(on bool) Option {
+	return func(db *DB) {
+		db.cfg.TCPNoDelay = on
+	}
+}
+
+// WithReadBufferSize configures the size of the buffered reader used by each connection.
+// Larger buffers reduce the number of syscalls when reading big blocks. Default is 4KiB.
+func WithReadBufferSize(size int) Option {
+	return func(db *DB) {
+		db.cfg.ReadBufferSize = size
+	}
+}
+
+// WithWriteBufferSize configures the size of the buffered writer used by each connection.
+// Larger buffers reduce the number of syscalls when writing big blocks. Default is 4KiB.
+func WithWriteBufferSize(size int) Option {
+	return func(db *DB) {
+		db.cfg.WriteBufferSize = size
+	}
+}
+
+// WithDefaultQueryTimeout configures the timeout for queries whose context
+// does not have a deadline, so a query with context.Background() can't block
+// forever. Query builders can override it using Timeout.
+func WithDefaultQueryTimeout(timeout time.Duration) Option {
+	return func(db *DB) {
+		db.cfg.DefaultQueryTimeout = timeout
+	}
+}
+
+// WithRejectNonFiniteFloats makes inserts fail with *chschema.NonFiniteFloatError
+// when Float32 or Float64 values are NaN or ±Inf instead of passing them to the
+// server, where they silently poison aggregations like sum and avg.
+func WithRejectNonFiniteFloats(on bool) Option {
+	return func(db *DB) {
+		db.cfg.RejectNonFiniteFloats = on
+	}
+}
+
+// WithNonFiniteFloatsAsNull scans NaN and ±Inf values of Float32 and Float64
+// columns into interface and pointer destinations as nil, so results scanned
+// into map[string]any or *float64 can be encoded as JSON, which does not
+// support non-finite numbers. Other destinations get the values as is.
+func WithNonFiniteFloatsAsNull(on bool) Option {
+	return func(db *DB) {
+		db.cfg.NonFiniteFloatsAsNull = on
+	}
+}
+
+// WithCluster makes CREATE TABLE, DROP TABLE, and TRUNCATE TABLE queries
+// execute on all nodes of the cluster using ON CLUSTER, unless a query sets
+// the cluster with OnCluster.
+func WithCluster(cluster string) Option {
+	return func(db *DB) {
+		db.cfg.Cluster = cluster
+	}
+}
+
+// WithClusterMacro is like WithCluster, but reads the cluster name from
+// the macro in system.macros, "cluster" by default, when the first DDL query
+// is executed. Nodes without the macro execute DDL queries without ON CLUSTER,
+// so the same code works with single nodes and clusters. See DB.Cluster.
+func WithClusterMacro(macro string) Option {
+	if macro == "" {
+		macro = "cluster"
+	}
+	return func(db *DB) {
+		db.cfg.ClusterMacro = macro
+	}
+}
+
+// WithTruncateFixedStrings truncates inserted strings and byte slices that are
+// longer than N bytes of the FixedString(N) column. By default, such inserts
+// fail with *chschema.FixedStringSizeError. Shorter values are always padded
+// with zero bytes.
+func WithTruncateFixedStrings(on bool) Option {
+	return func(db *DB) {
+		db.cfg.TruncateFixedStrings = on
+	}
+}
+
+// WithInsertRateLimit limits the rate of inserts executed by the DB and its
+// clones to rowsPerSec rows and bytesPerSec uncompressed bytes per second.
+// Inserts wait before sending a block until the limits allow it, so spiky
+// producers are smoothed without external throttling. Use InsertQuery.BlockSize
+// to split large inserts into smaller blocks. Zero means no limit.
+func WithInsertRateLimit(rowsPerSec, bytesPerSec int) Option {
+	return func(db *DB) {
+		db.cfg.InsertRowsPerSec = rowsPerSec
+		db.cfg.InsertBytesPerSec = bytesPerSec
+	}
+}
+
+// WithContextDeadline controls whether the time left until the context deadline
+// is sent as max_execution_time so the server stops executing queries
+// the client no longer waits for. It is disabled by default, because the server
+// fails queries that exceed the limit with TIMEOUT_EXCEEDED or, with
+// TimeoutOverflowBreak, returns partial results. Explicit max_execution_time
+// settings take precedence.
+func WithContextDeadline(on bool, mode TimeoutOverflowMode) Option {
+	return func(db *DB) {
+		db.cfg.ContextDeadline = on
+		db.cfg.TimeoutOverflowMode = mode
+	}
+}
+
+// WithShutdownTimeout configures how long DB.Close waits for checked-out
+// connections to be returned before closing them. Default is 0, i.e. Close
+// does not wait.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(db *DB) {
+		db.cfg.ShutdownTimeout = timeout
+	}
+}
+
+// WithMaxConcurrentQueries limits the number of queries executed at the same
+// time by this DB. Queries over the limit are queued for up to queueTimeout
+// and then fail with *ConcurrencyLimitError. Zero queueTimeout means queries
+// wait until the context is done.
+func WithMaxConcurrentQueries(n int, queueTimeout time.Duration) Option {
+	return func(db *DB) {
+		db.cfg.MaxConcurrentQueries = n
+		db.cfg.QueueTimeout = queueTimeout
+	}
+}
+
 // WithTLSConfig configures TLS config for secure connections.
 func WithTLSConfig(cfg *tls.Config) Option {
 	return func(db *DB) {
@@ -113,6 +527,16 @@ func WithQuerySettings(params map[string]any) Option {
 	}
 }
 
+// WithSettingsDiffLog enables logging of QuerySettings that differ from
+// the server settings to help understand what behavior the client changes.
+// The settings are compared once, after the first connection is established,
+// and the comparison is canceled when the DB is closed.
+func WithSettingsDiffLog(on bool) Option {
+	return func(db *DB) {
+		db.cfg.LogSettingsDiff = on
+	}
+}
+
 func WithInsecure(on bool) Option {
 	return func(db *DB) {
 		if on {
@@ -135,6 +559,14 @@ func WithPassword(password string) Option {
 	}
 }
 
+// WithAuthenticator sets the Authenticator that provides credentials
+// for new connections, overriding WithUser and WithPassword.
+func WithAuthenticator(auth Authenticator) Option {
+	return func(db *DB) {
+		db.cfg.Authenticator = auth
+	}
+}
+
 func WithDatabase(database string) Option {
 	return func(db *DB) {
 		db.cfg.Database = database
@@ -206,9 +638,43 @@ func WithPoolSize(poolSize int) Option {
 	}
 }
 
-// WithConnMaxLifetime sets the maximum amount of time a connection may be reused.
-// Expired connections may be closed lazily before reuse.
+// WithReservedAdminConn keeps one of PoolSize connections reserved for admin
+// queries, i.e. queries executed with DB.Admin and CancelAll, so
+// health checks and KILL or SYSTEM queries keep working when the pool is
+// saturated by application queries. Application queries can use at most
+// PoolSize-1 connections.
+func WithReservedAdminConn(on bool) Option {
+	return func(db *DB) {
+		if on {
+			db.cfg.ReservedConns = 1
+		} else {
+			db.cfg.ReservedConns = 0
+		}
+	}
+}
 
+// WithMinIdleConns configures minimum number of idle connections the pool
+// maintains. The connections are dialed in the background and are
+// ready to use: the handshake and a ping are performed before they are added
+// to the pool. Default is 0, i.e. connections are dialed on demand.
+func WithMinIdleConns(n int) Option {
+	return func(db *DB) {
+		db.cfg.MinIdleConns = n
+	}
+}
+
+// WithHealthCheckInterval configures how often idle connections are pinged
+// in the background. Connections that fail the ping are closed so they
+// are not handed out to queries. Default is 0, i.e. no health checks.
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(db *DB) {
+		db.cfg.HealthCheckInterval = d
+	}
+}
+
+// WithConnMaxLifetime sets the maximum amount of time a connection may be reused.
+// Expired connections are closed lazily before reuse or when returned to the pool.
+//
 // If d <= 0, connections are not closed due to a connection's age.
 func WithConnMaxLifetime(d time.Duration) Option {
 	return func(db *DB) {
@@ -216,7 +682,7 @@ func WithConnMaxLifetime(d time.Duration) Option {
 	}
 }
 
-// SetConnMaxIdleTime sets the maximum amount of time a connection may be idle.
+// WithConnMaxIdleTime sets the maximum amount of time a connection may be idle.
 // Expired connections may be closed lazily before reuse.
 //
 // If d <= 0, connections are not closed due to a connection's idle time.
@@ -310,6 +776,18 @@ func parseDSN(dsn string) ([]Option, error) {
 	if d := q.duration("write_timeout"); d != 0 {
 		opts = append(opts, WithWriteTimeout(d))
 	}
+	if d := q.duration("conn_max_lifetime"); d != 0 {
+		opts = append(opts, WithConnMaxLifetime(d))
+	}
+	if d := q.duration("conn_max_idle_time"); d != 0 {
+		opts = append(opts, WithConnMaxIdleTime(d))
+	}
+	if d := q.duration("connect_retry_timeout"); d > 0 {
+		opts = append(opts, WithConnectRetry(d))
+	}
+	if method := q.compression("compress"); method != "" {
+		opts = append(opts, WithCompressionMethod(method))
+	}
 
 	rem, err := q.remaining()
 	if err != nil {
@@ -374,6 +852,31 @@ func (o *queryOptions) duration(name string) time.Duration {
 	return 0
 }
 
+// compression parses a compression method or a bool, e.g. compress=zstd or compress=false.
+func (o *queryOptions) compression(name string) Compression {
+	s := o.string(name)
+	if s == "" {
+		return ""
+	}
+
+	switch method := Compression(strings.ToLower(s)); method {
+	case CompressionLZ4, CompressionZSTD, CompressionNone:
+		return method
+	}
+
+	if on, err := strconv.ParseBool(s); err == nil {
+		if on {
+			return CompressionLZ4
+		}
+		return CompressionNone
+	}
+
+	if o.err == nil {
+		o.err = fmt.Errorf("ch: unsupported %s method: %q", name, s)
+	}
+	return ""
+}
+
 func (o *queryOptions) remaining() (map[string]string, error) {
 	if o.err != nil {
 		return nil, o.err
@@ -0,0 +1,1038 @@
+package ch_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+func TestDSNConnLifetime(t *testing.T) {
+	db := ch.Connect(ch.WithDSN(
+		"clickhouse://localhost:9000/default?sslmode=disable" +
+			"&conn_max_lifetime=10m&conn_max_idle_time=30s",
+	))
+	defer db.Close()
+
+	cfg := db.Config()
+	require.Equal(t, 10*time.Minute, cfg.ConnMaxLifetime)
+	require.Equal(t, 30*time.Second, cfg.ConnMaxIdleTime)
+	require.Nil(t, cfg.QuerySettings)
+}
+
+func TestDSNCompression(t *testing.T) {
+	tests := []struct {
+		param  string
+		method ch.Compression
+	}{
+		{"compress=none", ch.CompressionNone},
+		{"compress=false", ch.CompressionNone},
+		{"compress=ZSTD", ch.CompressionZSTD},
+		{"compress=lz4", ch.CompressionLZ4},
+		{"", ch.CompressionLZ4},
+	}
+	for _, test := range tests {
+		db := ch.Connect(ch.WithDSN("clickhouse://localhost:9000/default?" + test.param))
+		require.Equal(t, test.method, db.Config().Compression, test.param)
+		require.Nil(t, db.Config().QuerySettings)
+		db.Close()
+	}
+}
+
+func TestCustomDialer(t *testing.T) {
+	errDial := errors.New("dial failed")
+
+	var gotNetwork, gotAddr string
+	db := ch.Connect(
+		ch.WithAddr("example.com:9000"),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			gotNetwork, gotAddr = network, addr
+			return nil, errDial
+		}),
+	)
+	defer db.Close()
+
+	err := db.Ping(context.Background())
+	require.ErrorIs(t, err, errDial)
+	require.Equal(t, "tcp", gotNetwork)
+	require.Equal(t, "example.com:9000", gotAddr)
+}
+
+func TestTLSServerName(t *testing.T) {
+	for _, insecure := range []bool{false, true} {
+		serverNames := make(chan string, 1)
+		db := ch.Connect(
+			ch.WithAddr("clickhouse.example.com:9440"),
+			ch.WithMaxRetries(0),
+			ch.WithTLSConfig(&tls.Config{InsecureSkipVerify: insecure}),
+			ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				client, server := net.Pipe()
+				go func() {
+					defer server.Close()
+					_ = tls.Server(server, &tls.Config{
+						GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+							select {
+							case serverNames <- hello.ServerName:
+							default:
+							}
+							return nil, errors.New("handshake aborted")
+						},
+					}).Handshake()
+				}()
+				return client, nil
+			}),
+		)
+
+		require.Error(t, db.Ping(context.Background()))
+		require.Equal(t, "clickhouse.example.com", <-serverNames, "insecure=%v", insecure)
+		db.Close()
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+	var dials int32
+	db := ch.Connect(
+		ch.WithConnectRetry(time.Second),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+		}),
+	)
+	defer db.Close()
+
+	err := db.Ping(context.Background())
+	require.ErrorIs(t, err, syscall.ECONNREFUSED)
+	require.Greater(t, atomic.LoadInt32(&dials), int32(1))
+}
+
+func TestAuthenticator(t *testing.T) {
+	errAuth := errors.New("secret is not available")
+
+	var calls int32
+	db := ch.Connect(
+		ch.WithAuthenticator(ch.AuthenticatorFunc(
+			func(ctx context.Context) (ch.Credentials, error) {
+				atomic.AddInt32(&calls, 1)
+				return ch.Credentials{}, errAuth
+			},
+		)),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			client, server := net.Pipe()
+			t.Cleanup(func() { server.Close() })
+			return client, nil
+		}),
+	)
+	defer db.Close()
+
+	err := db.Ping(context.Background())
+	require.ErrorIs(t, err, errAuth)
+	require.Greater(t, atomic.LoadInt32(&calls), int32(0))
+}
+
+func TestFilePassword(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "password")
+
+	require.NoError(t, os.WriteFile(path, []byte("first\n"), 0o600))
+	auth := ch.FilePassword("default", path)
+
+	creds, err := auth.Credentials(ctx)
+	require.NoError(t, err)
+	require.Equal(t, ch.Credentials{User: "default", Password: "first"}, creds)
+
+	require.NoError(t, os.WriteFile(path, []byte("second\n"), 0o600))
+	modTime := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+	creds, err = auth.Credentials(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "second", creds.Password)
+
+	require.NoError(t, os.Remove(path))
+	_, err = auth.Credentials(ctx)
+	require.Error(t, err)
+}
+
+func TestDefaultQueryTimeout(t *testing.T) {
+	db := ch.Connect(
+		ch.WithDefaultQueryTimeout(50*time.Millisecond),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			// The server reads queries, but never replies.
+			client, server := net.Pipe()
+			t.Cleanup(func() { server.Close() })
+			go func() { _, _ = io.Copy(io.Discard, server) }()
+			return client, nil
+		}),
+	)
+	defer db.Close()
+
+	start := time.Now()
+	_, err := db.ExecContext(context.Background(), "SELECT 1")
+	require.Error(t, err)
+	require.Less(t, time.Since(start), time.Second)
+
+	start = time.Now()
+	err = db.NewSelect().ColumnExpr("1").Timeout(200 * time.Millisecond).Scan(context.Background())
+	require.Error(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+}
+
+// recordingConn records the sizes of the buffers passed to Read and Write.
+type recordingConn struct {
+	net.Conn
+
+	mu     sync.Mutex
+	reads  []int
+	writes []int
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	c.reads = append(c.reads, len(b))
+	c.mu.Unlock()
+	return c.Conn.Read(b)
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	c.writes = append(c.writes, len(b))
+	c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+func TestBufferSize(t *testing.T) {
+	tests := []struct {
+		readSize, writeSize int
+		wantedRead          int
+		wantedWrite         int
+	}{
+		{0, 0, 4096, 4096},
+		{16 << 10, 8 << 10, 16 << 10, 8 << 10},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.readSize, test.writeSize), func(t *testing.T) {
+			queries := make(chan []byte, 10)
+			conn := &recordingConn{Conn: fakeExecConn(queries)}
+			db := ch.Connect(
+				ch.WithCompression(false),
+				ch.WithReadBufferSize(test.readSize),
+				ch.WithWriteBufferSize(test.writeSize),
+				ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return conn, nil
+				}),
+			)
+			defer db.Close()
+
+			// The query does not fit into the buffer.
+			_, err := db.ExecContext(context.Background(),
+				"SELECT '"+strings.Repeat("x", 20<<10)+"'")
+			require.NoError(t, err)
+
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+			require.Contains(t, conn.reads, test.wantedRead)
+			require.Contains(t, conn.writes, test.wantedWrite)
+		})
+	}
+}
+
+func TestContextDeadline(t *testing.T) {
+	maxExecutionTime := func(packet []byte) (uint64, bool) {
+		key := append([]byte{byte(len("max_execution_time"))}, "max_execution_time"...)
+		i := bytes.Index(packet, key)
+		if i == -1 {
+			return 0, false
+		}
+		n, _ := binary.Uvarint(packet[i+len(key):])
+		return n, true
+	}
+
+	for _, on := range []bool{false, true} {
+		queries := make(chan []byte, 1)
+		db := ch.Connect(
+			ch.WithCompression(false),
+			ch.WithContextDeadline(on, ch.TimeoutOverflowBreak),
+			ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return fakeExecConn(queries), nil
+			}),
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+		_, err := db.ExecContext(ctx, "SELECT 1")
+		cancel()
+		require.NoError(t, err)
+
+		packet := <-queries
+		secs, ok := maxExecutionTime(packet)
+		require.Equal(t, on, ok)
+		require.Equal(t, on, bytes.Contains(packet, []byte("timeout_overflow_mode")))
+		if on {
+			require.Equal(t, uint64(2), secs)
+		}
+
+		// Queries without a deadline are not limited.
+		_, err = db.ExecContext(context.Background(), "SELECT 1")
+		require.NoError(t, err)
+		_, ok = maxExecutionTime(<-queries)
+		require.False(t, ok)
+
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestStaleConnRetry(t *testing.T) {
+	ready := make(chan struct{})
+	var dials int32
+	db := ch.Connect(
+		ch.WithPoolSize(2),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) <= 2 {
+				return fakeServerConn(1, ready), nil
+			}
+			return fakeServerConn(1, nil), nil
+		}),
+	)
+	defer db.Close()
+
+	// Create two idle connections that are closed by the server.
+	errc := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() { errc <- db.Ping(context.Background()) }()
+	}
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&dials) == 2
+	}, time.Second, time.Millisecond)
+	close(ready)
+	require.NoError(t, <-errc)
+	require.NoError(t, <-errc)
+
+	// Both idle connections are stale so the ping is sent on a new one.
+	require.NoError(t, db.Ping(context.Background()))
+	require.Equal(t, int32(3), atomic.LoadInt32(&dials))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.Error(t, db.Ping(ctx))
+	require.Equal(t, int32(3), atomic.LoadInt32(&dials))
+}
+
+func TestStaleConnNotIdempotent(t *testing.T) {
+	var dials int32
+	db := ch.Connect(
+		ch.WithMaxRetries(0),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return fakeServerConn(1, nil), nil
+		}),
+	)
+	defer db.Close()
+
+	require.NoError(t, db.Ping(context.Background()))
+
+	// The server may have executed the query before closing the connection.
+	_, err := db.ExecContext(context.Background(), "ALTER TABLE events DELETE WHERE 1")
+	require.Error(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&dials))
+}
+
+func TestCancelAllIdle(t *testing.T) {
+	var dials int32
+	db := ch.Connect(
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errors.New("dial failed")
+		}),
+	)
+	defer db.Close()
+
+	// Without in-flight queries there is nothing to kill.
+	require.NoError(t, db.CancelAll(context.Background()))
+	require.Equal(t, int32(0), atomic.LoadInt32(&dials))
+}
+
+func TestCancelAllHosts(t *testing.T) {
+	hosts := []string{"10.0.0.1:9000", "10.0.0.2:9000"}
+	received := make(chan struct{}, len(hosts))
+	release := make(chan struct{})
+	kills := make(chan []byte, len(hosts))
+
+	var mu sync.Mutex
+	var dials int
+	killAddrs := make(map[string]bool)
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if addr != "localhost:9000" {
+				killAddrs[addr] = true
+				return fakeExecConn(kills), nil
+			}
+
+			// The queries are executed by different servers behind the same name.
+			host, _ := net.ResolveTCPAddr("tcp", hosts[dials])
+			dials++
+			return hostConn{fakeBlockingConn(received, release), host}, nil
+		}),
+	)
+	defer db.Close()
+
+	var wg sync.WaitGroup
+	for i := range hosts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := ch.ContextWithQueryID(context.Background(), fmt.Sprintf("query-%d", i))
+			_, _ = db.ExecContext(ctx, "SELECT sleep(3)")
+		}(i)
+	}
+	for range hosts {
+		<-received
+	}
+
+	require.NoError(t, db.CancelAll(context.Background()))
+	require.Equal(t, map[string]bool{hosts[0]: true, hosts[1]: true}, killAddrs)
+
+	var killed []string
+	for range hosts {
+		packet := <-kills
+		require.Contains(t, string(packet), "KILL QUERY")
+		for i := range hosts {
+			if id := fmt.Sprintf("'query-%d'", i); strings.Contains(string(packet), id) {
+				killed = append(killed, id)
+			}
+		}
+	}
+	require.ElementsMatch(t, []string{"'query-0'", "'query-1'"}, killed)
+
+	close(release)
+	wg.Wait()
+}
+
+func TestInsertCheckTypesCached(t *testing.T) {
+	type Event struct {
+		ch.CHModel `ch:"table:events"`
+
+		Name      string
+		CreatedAt time.Time `ch:"type:DateTime64(3)"`
+	}
+	type OtherEvent struct {
+		ch.CHModel `ch:"table:events"`
+
+		Name      string
+		CreatedAt time.Time `ch:"type:DateTime64(3)"`
+	}
+
+	var mu sync.Mutex
+	createdAtType := "DateTime64(3)"
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeInsertConn(func() []chfake.Column {
+				mu.Lock()
+				defer mu.Unlock()
+				return []chfake.Column{
+					{Name: "name", CHType: "String"},
+					{Name: "created_at", CHType: createdAtType},
+				}
+			}, nil), nil
+		}),
+	)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err := db.NewInsert().Model(&Event{Name: "foo"}).Exec(ctx)
+	require.NoError(t, err)
+
+	mu.Lock()
+	createdAtType = "DateTime64(6)"
+	mu.Unlock()
+
+	// The types of the model are checked only on the first insert.
+	_, err = db.NewInsert().Model(&Event{Name: "bar"}).Exec(ctx)
+	require.NoError(t, err)
+
+	_, err = db.NewInsert().Model(&OtherEvent{Name: "baz"}).Exec(ctx)
+	var typeErr *chschema.ColumnTypeError
+	require.True(t, errors.As(err, &typeErr), err)
+	require.Equal(t, "DateTime64(6)", typeErr.ServerType)
+}
+
+func TestIdentFolder(t *testing.T) {
+	db := ch.Connect(ch.WithIdentFolder(strings.ToLower))
+	defer db.Close()
+
+	query := db.NewSelect().Table("MyTable").Column("FooBar").String()
+	require.Equal(t, `SELECT "foobar" FROM "mytable"`, query)
+
+	type Model struct {
+		ch.CHModel `ch:"table:MyEvents,alias:Event"`
+
+		UserID  uint64 `ch:"UserID,pk"`
+		Version uint64 `ch:"Version"`
+	}
+
+	query = db.NewSelect().
+		With("Recent", db.NewSelect().Model((*Model)(nil)).Latest(1)).
+		Model((*Model)(nil)).
+		String()
+	require.Equal(t, `WITH "recent" AS (SELECT "event"."userid", "event"."version" `+
+		`FROM "myevents" AS "event" ORDER BY "event"."userid" DESC LIMIT 1) `+
+		`SELECT "event"."userid", "event"."version" FROM "myevents" AS "event"`, query)
+
+	query = db.NewCreateTable().Model((*Model)(nil)).OnCluster("MyCluster").String()
+	require.Equal(t, `CREATE TABLE "myevents" ON CLUSTER "mycluster" `+
+		`(userid UInt64, version UInt64) Engine = MergeTree() ORDER BY (userid)`, query)
+
+	// A per-query folder overrides the folder of the DB.
+	query = db.NewSelect().Model((*Model)(nil)).IdentFolder(strings.ToUpper).String()
+	require.Equal(t, `SELECT "EVENT"."USERID", "EVENT"."VERSION" FROM "MYEVENTS" AS "EVENT"`, query)
+
+	db2 := ch.Connect()
+	defer db2.Close()
+
+	query = db2.NewInsert().Model(&Model{}).IdentFolder(strings.ToLower).String()
+	require.Equal(t, `INSERT INTO "myevents" ("userid", "version") VALUES`, query)
+
+	query = db2.NewDropTable().Model((*Model)(nil)).IdentFolder(strings.ToLower).String()
+	require.Equal(t, `DROP TABLE "myevents"`, query)
+
+	query = db2.NewSelect().Model((*Model)(nil)).String()
+	require.Equal(t, `SELECT "Event"."UserID", "Event"."Version" FROM "MyEvents" AS "Event"`, query)
+}
+
+func TestIdentFolderScanInsert(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:MyEvents"`
+
+		UserID uint64 `ch:"UserID"`
+		Name   string `ch:"Name"`
+	}
+
+	t.Run("scan", func(t *testing.T) {
+		db := ch.Connect(
+			ch.WithCompression(false),
+			ch.WithIdentFolder(strings.ToLower),
+			ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return fakeQueryConn(
+					chfake.Column{Name: "userid", CHType: "UInt64", Values: []uint64{1}},
+					chfake.Column{Name: "name", CHType: "String", Values: []string{"foo"}},
+				), nil
+			}),
+		)
+		defer db.Close()
+
+		var models []Model
+		err := db.NewSelect().Model(&models).Scan(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, []Model{{UserID: 1, Name: "foo"}}, models)
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		db := ch.Connect(
+			ch.WithCompression(false),
+			ch.WithIdentFolder(strings.ToLower),
+			ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return fakeInsertConn(func() []chfake.Column {
+					return []chfake.Column{
+						{Name: "name", CHType: "String"},
+						{Name: "userid", CHType: "UInt64"},
+					}
+				}, nil), nil
+			}),
+		)
+		defer db.Close()
+
+		models := []Model{{UserID: 1, Name: "foo"}}
+		res, err := db.NewInsert().Model(&models).Exec(context.Background())
+		require.NoError(t, err)
+
+		n, err := res.RowsAffected()
+		require.NoError(t, err)
+		require.Equal(t, int64(1), n)
+	})
+}
+
+func TestMaxConcurrentQueries(t *testing.T) {
+	errDial := errors.New("dial failed")
+	dialing := make(chan struct{})
+	unblock := make(chan struct{})
+
+	db := ch.Connect(
+		ch.WithMaxRetries(0),
+		ch.WithMaxConcurrentQueries(1, 10*time.Millisecond),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			close(dialing)
+			<-unblock
+			return nil, errDial
+		}),
+	)
+	defer db.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- db.Ping(context.Background())
+	}()
+	<-dialing
+
+	err := db.Ping(context.Background())
+	require.ErrorIs(t, err, ch.ErrConcurrencyLimit)
+
+	var limitErr *ch.ConcurrencyLimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, 1, limitErr.Limit)
+
+	stats := db.Stats()
+	require.Equal(t, 1, stats.ActiveQueries)
+	require.Equal(t, uint64(1), stats.QueueWaits)
+	require.Equal(t, uint64(1), stats.QueueTimeouts)
+	require.GreaterOrEqual(t, stats.QueueWaitTime, 10*time.Millisecond)
+
+	close(unblock)
+	require.ErrorIs(t, <-errc, errDial)
+}
+
+func TestBackoffRetryPolicy(t *testing.T) {
+	policy := &ch.BackoffRetryPolicy{
+		MaxRetries: 2,
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 150 * time.Millisecond,
+	}
+
+	backoff, ok := policy.Retry(0, &ch.Error{Code: 209})
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond, backoff)
+
+	backoff, ok = policy.Retry(1, &ch.Error{Code: 202})
+	require.True(t, ok)
+	require.Equal(t, 150*time.Millisecond, backoff)
+
+	_, ok = policy.Retry(2, &ch.Error{Code: 202})
+	require.False(t, ok)
+
+	_, ok = policy.Retry(0, &ch.Error{Code: 62})
+	require.False(t, ok)
+
+	_, ok = policy.Retry(0, context.Canceled)
+	require.False(t, ok)
+
+	policy.RetryableCodes = []int32{62}
+	_, ok = policy.Retry(0, &ch.Error{Code: 62})
+	require.True(t, ok)
+}
+
+func TestBackoffRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		min, max time.Duration
+		attempt  int
+		wanted   time.Duration
+	}{
+		{100 * time.Millisecond, time.Second, 0, 100 * time.Millisecond},
+		{100 * time.Millisecond, time.Second, 1, 200 * time.Millisecond},
+		{100 * time.Millisecond, time.Second, 3, 800 * time.Millisecond},
+		{100 * time.Millisecond, time.Second, 4, time.Second},
+		{100 * time.Millisecond, time.Second, 70, time.Second}, // overflow
+		{0, time.Second, 3, 0},
+	}
+	for _, test := range tests {
+		policy := &ch.BackoffRetryPolicy{
+			MaxRetries: 100,
+			MinBackoff: test.min,
+			MaxBackoff: test.max,
+		}
+		backoff, ok := policy.Retry(test.attempt, driver.ErrBadConn)
+		require.True(t, ok)
+		require.Equal(t, test.wanted, backoff, "attempt %d", test.attempt)
+
+		policy.Jitter = true
+		backoff, _ = policy.Retry(test.attempt, driver.ErrBadConn)
+		require.GreaterOrEqual(t, backoff, test.min)
+		require.LessOrEqual(t, backoff, test.max)
+	}
+}
+
+// recordingRetryPolicy records the attempts and errors it is consulted with.
+type recordingRetryPolicy struct {
+	ch.BackoffRetryPolicy
+
+	attempts []int
+	errs     []error
+}
+
+func (p *recordingRetryPolicy) Retry(attempt int, err error) (time.Duration, bool) {
+	p.attempts = append(p.attempts, attempt)
+	p.errs = append(p.errs, err)
+	return p.BackoffRetryPolicy.Retry(attempt, err)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		query      string
+		code       int32
+		idempotent bool
+		retried    bool
+	}{
+		{"SELECT 1", ch.CodeTimeoutExceeded, true, true},
+		{"  (SELECT 1)", ch.CodeTimeoutExceeded, true, true},
+		{"with x AS (SELECT 1) SELECT * FROM x", ch.CodeTimeoutExceeded, true, true},
+		{"SHOW TABLES", ch.CodeTimeoutExceeded, true, true},
+		{"DESCRIBE events", ch.CodeTimeoutExceeded, true, true},
+		{"desc events", ch.CodeTimeoutExceeded, true, true},
+		{"EXISTS events", ch.CodeTimeoutExceeded, true, true},
+		{"EXPLAIN SELECT 1", ch.CodeTimeoutExceeded, true, true},
+		{"SELECT 1", ch.CodeUnknownTable, true, false},
+		{"SELECTED 1", ch.CodeTimeoutExceeded, false, false},
+		{"INSERT INTO events VALUES (1)", ch.CodeTimeoutExceeded, false, false},
+		{"ALTER TABLE events DELETE WHERE 1", ch.CodeTimeoutExceeded, false, false},
+		{"DROP TABLE events", ch.CodeTimeoutExceeded, false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			policy := &recordingRetryPolicy{
+				BackoffRetryPolicy: ch.BackoffRetryPolicy{MaxRetries: 2},
+			}
+			ids := make(chan string, 10)
+			db := ch.Connect(
+				ch.WithCompression(false),
+				ch.WithRetryPolicy(policy),
+				ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return fakeExceptionConn(test.code, ids), nil
+				}),
+			)
+			defer db.Close()
+
+			_, err := db.ExecContext(context.Background(), test.query)
+			require.True(t, ch.IsErrorCode(err, test.code), err)
+
+			switch {
+			case test.retried:
+				require.Equal(t, []int{0, 1, 2}, policy.attempts)
+				require.Len(t, ids, 3)
+			case test.idempotent:
+				require.Equal(t, []int{0}, policy.attempts)
+				require.Len(t, ids, 1)
+			default:
+				require.Empty(t, policy.attempts)
+				require.Len(t, ids, 1)
+			}
+			for _, err := range policy.errs {
+				require.True(t, ch.IsErrorCode(err, test.code), err)
+			}
+		})
+	}
+}
+
+func TestQueryRetry(t *testing.T) {
+	tests := []struct {
+		query   string
+		retried bool
+	}{
+		{"SELECT 1", true},
+		{"INSERT INTO events SELECT * FROM events_v1", false},
+		{"ALTER TABLE events ADD COLUMN name String", false},
+		{"CREATE TABLE events ON CLUSTER prod (id UInt64) ENGINE = Memory", false},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			policy := &recordingRetryPolicy{
+				BackoffRetryPolicy: ch.BackoffRetryPolicy{MaxRetries: 2},
+			}
+			db := ch.Connect(
+				ch.WithRetryPolicy(policy),
+				ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return nil, driver.ErrBadConn
+				}),
+			)
+			defer db.Close()
+
+			_, err := db.QueryContext(context.Background(), test.query)
+			require.ErrorIs(t, err, driver.ErrBadConn)
+
+			if test.retried {
+				require.Equal(t, []int{0, 1, 2}, policy.attempts)
+			} else {
+				require.Empty(t, policy.attempts)
+			}
+		})
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	ids := make(chan string, 10)
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithRetryPolicy(&ch.BackoffRetryPolicy{
+			MaxRetries: 3,
+			MinBackoff: 20 * time.Millisecond,
+			MaxBackoff: 30 * time.Millisecond,
+		}),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeExceptionConn(ch.CodeTimeoutExceeded, ids), nil
+		}),
+	)
+	defer db.Close()
+
+	start := time.Now()
+	_, err := db.ExecContext(context.Background(), "SELECT 1")
+	require.True(t, ch.IsErrorCode(err, ch.CodeTimeoutExceeded), err)
+	require.GreaterOrEqual(t, time.Since(start), 80*time.Millisecond) // 20ms + 30ms + 30ms
+	require.Len(t, ids, 4)
+
+	// The backoff is interrupted when ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = db.ExecContext(ctx, "SELECT 1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Len(t, ids, 5)
+}
+
+func TestIsErrorCode(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", &ch.Error{Code: ch.CodeTableIsReadOnly})
+	require.True(t, ch.IsReadonly(err))
+	require.False(t, ch.IsTableNotExist(err))
+	require.Equal(t, int32(0), ch.ErrorCode(errors.New("not a server error")))
+}
+
+func TestNestedError(t *testing.T) {
+	ids := make(chan string, 10)
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithMaxRetries(0),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeNestedExceptionConn(ids, ch.CodeNetworkError, ch.CodeTableIsReadOnly), nil
+		}),
+	)
+	defer db.Close()
+
+	_, err := db.ExecContext(context.Background(), "INSERT INTO events SELECT 1")
+	require.Error(t, err)
+
+	var exc *ch.Error
+	require.True(t, errors.As(err, &exc))
+	require.Equal(t, ch.CodeNetworkError, exc.Code)
+	require.Equal(t, "fake exception 210", exc.Message)
+
+	nested := errors.Unwrap(exc)
+	require.NotNil(t, nested)
+	require.True(t, errors.As(nested, &exc))
+	require.Equal(t, ch.CodeTableIsReadOnly, exc.Code)
+	require.Nil(t, errors.Unwrap(exc))
+
+	require.True(t, errors.Is(err, nested))
+	require.True(t, ch.IsErrorCode(err, ch.CodeTableIsReadOnly))
+	require.True(t, ch.IsReadonly(err))
+	require.False(t, ch.IsErrorCode(err, ch.CodeUnknownTable))
+}
+
+func TestIsValueError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&ch.Error{Code: ch.CodeCannotParseText}, true},
+		{&ch.Error{Code: ch.CodeViolatedConstraint}, true},
+		{fmt.Errorf("wrapped: %w", &ch.Error{Code: ch.CodeUnknownElementOfEnum}), true},
+		{&chschema.EnumValueError{Column: "kind", Value: "foo"}, true},
+		{&ch.Error{Code: ch.CodeTypeMismatch}, false},
+		{&ch.Error{Code: ch.CodeCannotConvertType}, false},
+		{&ch.Error{Code: ch.CodeTableIsReadOnly}, false},
+		{io.EOF, false},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.want, ch.IsValueError(test.err), test.err.Error())
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	errDial := errors.New("dial failed")
+
+	db := ch.Connect(
+		ch.WithErrorQueryLength(8),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errDial
+		}),
+	)
+	defer db.Close()
+
+	ctx := ch.ContextWithQueryID(context.Background(), "my-query")
+	_, err := db.ExecContext(ctx, "SELECT number FROM numbers(?)", 10)
+	require.ErrorIs(t, err, errDial)
+
+	var queryErr *ch.QueryError
+	require.True(t, errors.As(err, &queryErr))
+	require.Equal(t, "SELECT n...", queryErr.Query)
+	require.Nil(t, queryErr.Args)
+	require.Equal(t, "SELECT", queryErr.Operation)
+	require.Equal(t, "my-query", queryErr.QueryID)
+
+	// The query is truncated at the start of the multi-byte character.
+	_, err = db.ExecContext(ctx, "SELECT привет")
+	require.True(t, errors.As(err, &queryErr))
+	require.Equal(t, "SELECT ...", queryErr.Query)
+	require.True(t, utf8.ValidString(queryErr.Query))
+
+	tests := []struct {
+		opts   []ch.Option
+		wanted []any
+	}{
+		{[]ch.Option{ch.WithErrorQueryArgs(true)}, []any{10}},
+		{[]ch.Option{ch.WithErrorQueryArgs(false)}, nil},
+		{[]ch.Option{
+			ch.WithErrorQueryArgs(true),
+			ch.WithErrorQueryRedactor(func(query string) string { return "redacted" }),
+		}, nil},
+	}
+	for _, test := range tests {
+		db := ch.Connect(append(test.opts, ch.WithDialer(
+			func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return nil, errDial
+			}))...)
+
+		_, err := db.ExecContext(context.Background(), "SELECT number FROM numbers(?)", 10)
+		require.True(t, errors.As(err, &queryErr))
+		require.Equal(t, test.wanted, queryErr.Args)
+
+		require.NoError(t, db.Close())
+	}
+}
+
+func TestQueryIDRetry(t *testing.T) {
+	ids := make(chan string, 10)
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithMaxRetries(2),
+		ch.WithMinRetryBackoff(time.Millisecond),
+		ch.WithMaxRetryBackoff(time.Millisecond),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeExceptionConn(ch.CodeTimeoutExceeded, ids), nil
+		}),
+	)
+	defer db.Close()
+
+	ctx := ch.ContextWithQueryID(context.Background(), "my-query")
+	_, err := db.ExecContext(ctx, "SELECT 1")
+	require.True(t, ch.IsErrorCode(err, ch.CodeTimeoutExceeded))
+
+	var queryErr *ch.QueryError
+	require.True(t, errors.As(err, &queryErr))
+	require.Equal(t, "my-query-2", queryErr.QueryID)
+
+	close(ids)
+	var got []string
+	for id := range ids {
+		got = append(got, id)
+	}
+	require.Equal(t, []string{"my-query", "my-query-1", "my-query-2"}, got)
+}
+
+func TestScanAndCountQueryID(t *testing.T) {
+	ids := make(chan string, 10)
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeExceptionConn(ch.CodeUnknownTable, ids), nil
+		}),
+	)
+	defer db.Close()
+
+	ctx := ch.ContextWithQueryID(context.Background(), "my-query")
+	var names []string
+	_, err := db.NewSelect().TableExpr("events").ColumnExpr("name").ScanAndCount(ctx, &names)
+	require.True(t, ch.IsErrorCode(err, ch.CodeUnknownTable), err)
+
+	close(ids)
+	var got []string
+	for id := range ids {
+		got = append(got, id)
+	}
+	require.ElementsMatch(t, []string{"my-query", "my-query-count"}, got)
+}
+
+func TestQueryErrorRedactor(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events"`
+
+		Name string
+	}
+
+	errDial := errors.New("dial failed")
+
+	db := ch.Connect(
+		ch.WithErrorQueryRedactor(func(query string) string {
+			return strings.ReplaceAll(query, "secret", "?")
+		}),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errDial
+		}),
+	)
+	defer db.Close()
+
+	var models []Model
+	err := db.NewSelect().Model(&models).Where("name = 'secret'").Scan(context.Background())
+	require.ErrorIs(t, err, errDial)
+
+	var queryErr *ch.QueryError
+	require.True(t, errors.As(err, &queryErr))
+	require.Equal(t, `SELECT "model"."name" FROM "events" AS "model" WHERE (name = '?')`,
+		queryErr.Query)
+	require.Equal(t, "SELECT", queryErr.Operation)
+	require.Equal(t, "events", queryErr.Table)
+	require.Contains(t, err.Error(), "operation=SELECT table=events query_id=")
+}
+
+func TestSettingsDiffClose(t *testing.T) {
+	queries := make(chan []byte, 10)
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithQuerySettings(map[string]any{"max_threads": 1}),
+		ch.WithSettingsDiffLog(true),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeHangConn(queries, "system.settings"), nil
+		}),
+	)
+
+	_, err := db.ExecContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+
+	// The server never replies to the settings query.
+	timeout := time.After(time.Second)
+	for found := false; !found; {
+		select {
+		case packet := <-queries:
+			found = bytes.Contains(packet, []byte("system.settings"))
+		case <-timeout:
+			t.Fatal("settings query is not sent")
+		}
+	}
+
+	// Close cancels the query and waits for it to return.
+	closed := make(chan error, 1)
+	go func() { closed <- db.Close() }()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Close waits for the settings query")
+	}
+}
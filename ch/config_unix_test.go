@@ -0,0 +1,85 @@
+//go:build linux || darwin
+
+package ch_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+func TestTCPOptions(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	queries := make(chan []byte, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeExec(conn, queries, "")
+		}
+	}()
+
+	sockopt := func(t *testing.T, conn *net.TCPConn, level, opt int) bool {
+		raw, err := conn.SyscallConn()
+		require.NoError(t, err)
+		var value int
+		var optErr error
+		require.NoError(t, raw.Control(func(fd uintptr) {
+			value, optErr = syscall.GetsockoptInt(int(fd), level, opt)
+		}))
+		require.NoError(t, optErr)
+		return value != 0
+	}
+
+	tests := []struct {
+		dialerKeepAlive time.Duration // keep-alive set by the custom dialer
+		keepAlive       time.Duration
+		noDelay         bool
+	}{
+		{-1, time.Minute, true},
+		{time.Minute, -1, false},
+		{-1, 0, false},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.keepAlive, test.noDelay), func(t *testing.T) {
+			conns := make(chan *net.TCPConn, 1)
+			db := ch.Connect(
+				ch.WithCompression(false),
+				ch.WithAddr(ln.Addr().String()),
+				ch.WithTCPKeepAlive(test.keepAlive),
+				ch.WithTCPNoDelay(test.noDelay),
+				ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+					dialer := &net.Dialer{KeepAlive: test.dialerKeepAlive}
+					conn, err := dialer.DialContext(ctx, network, addr)
+					if err != nil {
+						return nil, err
+					}
+					conns <- conn.(*net.TCPConn)
+					return conn, nil
+				}),
+			)
+			defer db.Close()
+
+			_, err := db.ExecContext(context.Background(), "SELECT 1")
+			require.NoError(t, err)
+
+			conn := <-conns
+			require.Equal(t, test.keepAlive >= 0,
+				sockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE))
+			require.Equal(t, test.noDelay,
+				sockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+		})
+	}
+}
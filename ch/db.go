@@ -2,13 +2,13 @@ package ch
 
 import (
 	"context"
-	"crypto/tls"
 	"database/sql"
-	"database/sql/driver"
 	"errors"
 	"fmt"
 	"net"
 	"reflect"
+	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -21,6 +21,17 @@ import (
 type DBStats struct {
 	Queries uint64
 	Errors  uint64
+
+	// The following stats are only collected when MaxConcurrentQueries is set.
+
+	// QueueWaits is the number of queries that waited for a free slot.
+	QueueWaits uint64
+	// QueueTimeouts is the number of queries that failed with *ConcurrencyLimitError.
+	QueueTimeouts uint64
+	// QueueWaitTime is the total time queries waited for a free slot.
+	QueueWaitTime time.Duration
+	// ActiveQueries is the number of queries that are being executed.
+	ActiveQueries int
 }
 
 type DB struct {
@@ -30,36 +41,66 @@ type DB struct {
 	queryHooks []QueryHook
 
 	fmter chschema.Formatter
-	flags internal.Flag
 	stats DBStats
+
+	querySem chan struct{} // limits concurrent queries, nil if unlimited
+	resolver *addrResolver // nil unless DNSResolveInterval is set
+	session  *session      // nil unless the DB is a Session
+	admin    bool          // uses the reserved admin conn, see Admin
+
+	settingsDiff *settingsDiff  // nil unless LogSettingsDiff is set
+	cluster      *clusterCache  // nil unless ClusterMacro is set
+	insertLimit  *insertLimiter // nil unless the insert rate is limited
+	queries      *queryTracker
+	checkedTypes *sync.Map // typeCheckKey of inserts with matching types
 }
 
 func Connect(opts ...Option) *DB {
 	db := &DB{
-		cfg: defaultConfig(),
+		cfg:          defaultConfig(),
+		queries:      newQueryTracker(),
+		checkedTypes: new(sync.Map),
 	}
 
 	for _, opt := range opts {
 		opt(db)
 	}
-	db.pool = newConnPool(db.cfg)
+	if db.cfg.DNSResolveInterval > 0 {
+		db.resolver = newAddrResolver(db.cfg.Addr, db.cfg.DNSResolveInterval)
+	}
+	if db.cfg.MaxConcurrentQueries > 0 {
+		db.querySem = make(chan struct{}, db.cfg.MaxConcurrentQueries)
+	}
+	if db.cfg.LogSettingsDiff && len(db.cfg.QuerySettings) > 0 {
+		db.settingsDiff = newSettingsDiff()
+	}
+	if db.cfg.ClusterMacro != "" {
+		db.cluster = new(clusterCache)
+	}
+	if db.cfg.InsertRowsPerSec > 0 || db.cfg.InsertBytesPerSec > 0 {
+		db.insertLimit = newInsertLimiter(db.cfg.InsertRowsPerSec, db.cfg.InsertBytesPerSec)
+	}
+	db.pool = newConnPool(db)
 
 	return db
 }
 
-func newConnPool(cfg *Config) *chpool.ConnPool {
+func newConnPool(db *DB) *chpool.ConnPool {
+	cfg := db.cfg
 	poolcfg := cfg.Config
 	poolcfg.Dialer = func(ctx context.Context) (net.Conn, error) {
-		if cfg.TLSConfig != nil {
-			return tls.DialWithDialer(
-				cfg.netDialer(),
-				cfg.Network,
-				cfg.Addr,
-				cfg.TLSConfig,
-			)
+		addr := cfg.Addr
+		if db.resolver != nil {
+			var err error
+			addr, err = db.resolver.Addr(ctx)
+			if err != nil {
+				return nil, err
+			}
 		}
-		return cfg.netDialer().DialContext(ctx, cfg.Network, cfg.Addr)
+		return cfg.dial(ctx, addr)
 	}
+	poolcfg.OnConnect = db.warmConn
+	poolcfg.HealthCheck = db.warmConn
 	return chpool.New(&poolcfg)
 }
 
@@ -67,10 +108,34 @@ func newConnPool(cfg *Config) *chpool.ConnPool {
 //
 // It is rare to Close a DB, as the DB handle is meant to be
 // long-lived and shared between many goroutines.
+//
+// If ShutdownTimeout is set, Close waits up to that duration for
+// in-flight queries to release their connections. See Shutdown.
 func (db *DB) Close() error {
+	if db.settingsDiff != nil {
+		db.settingsDiff.stop()
+		defer db.settingsDiff.wait()
+	}
+	if db.cfg.ShutdownTimeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), db.cfg.ShutdownTimeout)
+		defer cancel()
+		return db.Shutdown(ctx)
+	}
 	return db.pool.Close()
 }
 
+// Shutdown closes the database client gracefully. New queries fail with
+// chpool.ErrClosed while in-flight queries are allowed to finish until
+// ctx is done. After that, the remaining connections are closed and
+// ctx.Err() is returned.
+func (db *DB) Shutdown(ctx context.Context) error {
+	if db.settingsDiff != nil {
+		db.settingsDiff.stop()
+		defer db.settingsDiff.wait()
+	}
+	return db.pool.Shutdown(ctx)
+}
+
 func (db *DB) String() string {
 	return fmt.Sprintf("DB<addr: %s>", db.cfg.Addr)
 }
@@ -90,6 +155,36 @@ func (db *DB) WithTimeout(d time.Duration) *DB {
 	return clone
 }
 
+// WithCompressionMethod returns a copy of the DB that compresses data blocks
+// of queries using the method, for example, to disable compression for small
+// point lookups or to use ZSTD for large exports.
+func (db *DB) WithCompressionMethod(method Compression) *DB {
+	newcfg := *db.cfg
+	newcfg.Compression = method
+
+	clone := db.clone()
+	clone.cfg = &newcfg
+
+	return clone
+}
+
+// Admin returns a copy of the DB for administrative queries, for example,
+// health checks with Ping, KILL QUERY, and SYSTEM queries. The queries are
+// not limited by MaxConcurrentQueries and use the connection reserved with
+// WithReservedAdminConn when the other connections are busy.
+func (db *DB) Admin() *DB {
+	clone := db.clone()
+	clone.session = nil
+	clone.querySem = nil
+	clone.admin = true
+	return clone
+}
+
+// InSession reports whether the DB is a Session pinned to a single connection.
+func (db *DB) InSession() bool {
+	return db.session != nil
+}
+
 func (db *DB) clone() *DB {
 	clone := *db
 
@@ -103,11 +198,39 @@ func (db *DB) Stats() DBStats {
 	return DBStats{
 		Queries: atomic.LoadUint64(&db.stats.Queries),
 		Errors:  atomic.LoadUint64(&db.stats.Errors),
+
+		QueueWaits:    atomic.LoadUint64(&db.stats.QueueWaits),
+		QueueTimeouts: atomic.LoadUint64(&db.stats.QueueTimeouts),
+		QueueWaitTime: time.Duration(atomic.LoadInt64((*int64)(&db.stats.QueueWaitTime))),
+		ActiveQueries: len(db.querySem),
 	}
 }
 
 func (db *DB) getConn(ctx context.Context) (*chpool.Conn, error) {
-	cn, err := db.pool.Get(ctx)
+	if db.session != nil {
+		return db.session.getConn(ctx)
+	}
+
+	if err := db.acquireQuerySlot(ctx); err != nil {
+		return nil, err
+	}
+
+	cn, err := db.connectRetry(ctx)
+	if err != nil {
+		db.releaseQuerySlot()
+		return nil, err
+	}
+	return cn, nil
+}
+
+func (db *DB) connect(ctx context.Context) (*chpool.Conn, error) {
+	var cn *chpool.Conn
+	var err error
+	if db.admin {
+		cn, err = db.pool.GetReserved(ctx)
+	} else {
+		cn, err = db.pool.Get(ctx)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +243,10 @@ func (db *DB) getConn(ctx context.Context) (*chpool.Conn, error) {
 		return nil, err
 	}
 
+	if db.settingsDiff != nil {
+		db.settingsDiff.start(db)
+	}
+
 	return cn, nil
 }
 
@@ -132,16 +259,87 @@ func (db *DB) initConn(ctx context.Context, cn *chpool.Conn) error {
 	return db.hello(ctx, cn)
 }
 
+// warmConn performs the handshake and a ping on connections that are
+// dialed by the pool to maintain MinIdleConns. It is also used to
+// health check idle connections.
+func (db *DB) warmConn(ctx context.Context, cn *chpool.Conn) error {
+	if err := db.initConn(ctx, cn); err != nil {
+		return err
+	}
+	return db.ping(ctx, cn)
+}
+
 func (db *DB) releaseConn(cn *chpool.Conn, err error) {
+	db.queries.remove(cn)
+
+	if db.session != nil {
+		db.session.releaseConn(cn, err)
+		return
+	}
+
 	if isBadConn(err, false) || cn.Closed() {
 		db.pool.Remove(cn, err)
 	} else {
 		db.pool.Put(cn)
 	}
+	db.releaseQuerySlot()
+}
+
+func (db *DB) acquireQuerySlot(ctx context.Context) error {
+	if db.querySem == nil {
+		return nil
+	}
+
+	select {
+	case db.querySem <- struct{}{}:
+		return nil
+	default:
+	}
+
+	var timeout <-chan time.Time
+	if db.cfg.QueueTimeout > 0 {
+		timer := time.NewTimer(db.cfg.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	atomic.AddUint64(&db.stats.QueueWaits, 1)
+	start := time.Now()
+	defer func() {
+		atomic.AddInt64((*int64)(&db.stats.QueueWaitTime), int64(time.Since(start)))
+	}()
+
+	select {
+	case db.querySem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timeout:
+		atomic.AddUint64(&db.stats.QueueTimeouts, 1)
+		return &ConcurrencyLimitError{
+			Limit:  cap(db.querySem),
+			Waited: time.Since(start),
+		}
+	}
 }
 
-func (db *DB) withConn(ctx context.Context, fn func(*chpool.Conn) error) error {
-	err := db._withConn(ctx, fn)
+func (db *DB) releaseQuerySlot() {
+	if db.querySem != nil {
+		<-db.querySem
+	}
+}
+
+func (db *DB) withConn(
+	ctx context.Context, idempotent bool, fn func(*chpool.Conn) error,
+) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		var stale bool
+		stale, err = db._withConn(ctx, idempotent, fn)
+		if !db.retryStaleConn(ctx, attempt, stale) {
+			break
+		}
+	}
 
 	atomic.AddUint64(&db.stats.Queries, 1)
 	if err != nil {
@@ -151,11 +349,22 @@ func (db *DB) withConn(ctx context.Context, fn func(*chpool.Conn) error) error {
 	return err
 }
 
-func (db *DB) _withConn(ctx context.Context, fn func(*chpool.Conn) error) error {
+// retryStaleConn reports whether to retry the query after the server has closed
+// the idle connection before receiving the query. The query is retried on
+// another connection at most PoolSize times, because all idle connections
+// may have been closed, and then a new connection is dialed.
+func (db *DB) retryStaleConn(ctx context.Context, attempt int, stale bool) bool {
+	return stale && attempt < db.cfg.PoolSize && ctx.Err() == nil
+}
+
+func (db *DB) _withConn(
+	ctx context.Context, idempotent bool, fn func(*chpool.Conn) error,
+) (stale bool, err error) {
 	cn, err := db.getConn(ctx)
 	if err != nil {
-		return err
+		return false, err
 	}
+	received, written := cn.Received(), cn.Written()
 
 	var done chan struct{}
 
@@ -190,7 +399,24 @@ func (db *DB) _withConn(ctx context.Context, fn func(*chpool.Conn) error) error
 		db.cancelConn(ctx, cn)
 	}
 
-	return err
+	return db.isStaleConn(cn, received, written, idempotent, err), err
+}
+
+// isStaleConn reports whether the query failed because the server closed
+// the idle connection, i.e. the first write or read on a reused connection
+// failed before anything was received. Queries that are not idempotent are
+// retried only when they were not written to the connection, because the
+// server may have closed the connection after executing them.
+func (db *DB) isStaleConn(
+	cn *chpool.Conn, received, written int64, idempotent bool, err error,
+) bool {
+	return err != nil &&
+		db.session == nil &&
+		cn.Reused() &&
+		!cn.Closed() &&
+		cn.Received() == received &&
+		(idempotent || cn.Written() == written) &&
+		isStaleConnError(err)
 }
 
 func (db *DB) cancelConn(ctx context.Context, cn *chpool.Conn) {
@@ -204,15 +430,19 @@ func (db *DB) cancelConn(ctx context.Context, cn *chpool.Conn) {
 }
 
 func (db *DB) Ping(ctx context.Context) error {
-	return db.withConn(ctx, func(cn *chpool.Conn) error {
-		if err := cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
-			writePing(wr)
-		}); err != nil {
-			return err
-		}
-		return cn.WithReader(ctx, db.cfg.ReadTimeout, func(rd *chproto.Reader) error {
-			return readPong(rd)
-		})
+	return db.withConn(ctx, true, func(cn *chpool.Conn) error {
+		return db.ping(ctx, cn)
+	})
+}
+
+func (db *DB) ping(ctx context.Context, cn *chpool.Conn) error {
+	if err := cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
+		writePing(wr)
+	}); err != nil {
+		return err
+	}
+	return cn.WithReader(ctx, db.cfg.ReadTimeout, func(rd *chproto.Reader) error {
+		return readPong(cn, rd)
 	})
 }
 
@@ -224,34 +454,38 @@ func (db *DB) ExecContext(
 	ctx context.Context, query string, args ...any,
 ) (sql.Result, error) {
 	query = db.FormatQuery(query, args...)
+
+	ctx, cancel := db.withQueryTimeout(ctx, 0)
+	defer cancel()
+
 	ctx, evt := db.beforeQuery(ctx, nil, query, args, nil)
 	res, err := db.exec(ctx, query)
 	db.afterQuery(ctx, evt, res, err)
-	return res, err
+	if err != nil {
+		return nil, db.queryError(ctx, nil, query, args, err)
+	}
+	return res, nil
 }
 
 func (db *DB) exec(ctx context.Context, query string) (*result, error) {
-	var res *result
-	var lastErr error
-	for attempt := 0; attempt <= db.cfg.MaxRetries; attempt++ {
-		if attempt > 0 {
-			lastErr = internal.Sleep(ctx, db.retryBackoff(attempt-1))
-			if lastErr != nil {
-				break
-			}
-		}
+	return db.execRetry(ctx, query, isIdempotentQuery(query))
+}
 
-		res, lastErr = db._exec(ctx, query)
-		if !db.shouldRetry(lastErr) {
-			break
-		}
-	}
-	return res, lastErr
+// execRetry executes the query retrying it according to the retry policy
+// when the query is idempotent.
+func (db *DB) execRetry(ctx context.Context, query string, idempotent bool) (*result, error) {
+	var res *result
+	err := db.withRetry(ctx, idempotent, func() error {
+		var err error
+		res, err = db._exec(ctx, query)
+		return err
+	})
+	return res, err
 }
 
 func (db *DB) _exec(ctx context.Context, query string) (*result, error) {
 	var res *result
-	err := db.withConn(ctx, func(cn *chpool.Conn) error {
+	err := db.withConn(ctx, isIdempotentQuery(query), func(cn *chpool.Conn) error {
 		if err := cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
 			db.writeQuery(ctx, cn, wr, query)
 			db.writeBlock(ctx, wr, nil)
@@ -276,14 +510,35 @@ func (db *DB) QueryContext(
 ) (*Rows, error) {
 	query = db.FormatQuery(query, args...)
 
+	ctx, cancel := db.withQueryTimeout(ctx, 0)
+
 	ctx, evt := db.beforeQuery(ctx, nil, query, args, nil)
 	blocks, err := db.query(ctx, query)
 	db.afterQuery(ctx, evt, nil, err)
 	if err != nil {
-		return nil, err
+		cancel()
+		return nil, db.queryError(ctx, nil, query, args, err)
 	}
 
-	return newRows(ctx, blocks), nil
+	rows := newRows(ctx, blocks)
+	rows.cancel = cancel
+	return rows, nil
+}
+
+// withQueryTimeout applies the query timeout or, when the timeout is zero and
+// ctx has no deadline, DefaultQueryTimeout.
+func (db *DB) withQueryTimeout(
+	ctx context.Context, timeout time.Duration,
+) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		if _, ok := ctx.Deadline(); !ok {
+			timeout = db.cfg.DefaultQueryTimeout
+		}
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
 }
 
 func (db *DB) QueryRow(query string, args ...any) *Row {
@@ -297,71 +552,219 @@ func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *R
 
 func (db *DB) query(ctx context.Context, query string) (*blockIter, error) {
 	var blocks *blockIter
-	var lastErr error
-
-	for attempt := 0; attempt <= db.cfg.MaxRetries; attempt++ {
-		if attempt > 0 {
-			lastErr = internal.Sleep(ctx, db.retryBackoff(attempt-1))
-			if lastErr != nil {
-				break
-			}
-		}
+	err := db.withRetry(ctx, isIdempotentQuery(query), func() error {
+		var err error
+		blocks, err = db._query(ctx, query)
+		return err
+	})
+	return blocks, err
+}
 
-		blocks, lastErr = db._query(ctx, query)
-		if !db.shouldRetry(lastErr) {
-			break
+func (db *DB) _query(ctx context.Context, query string) (*blockIter, error) {
+	idempotent := isIdempotentQuery(query)
+	for attempt := 0; ; attempt++ {
+		blocks, stale, err := db.startQuery(ctx, query, idempotent)
+		if !db.retryStaleConn(ctx, attempt, stale) {
+			return blocks, err
 		}
 	}
-
-	return blocks, lastErr
 }
 
-func (db *DB) _query(ctx context.Context, query string) (*blockIter, error) {
+func (db *DB) startQuery(
+	ctx context.Context, query string, idempotent bool,
+) (*blockIter, bool, error) {
 	cn, err := db.getConn(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	received, written := cn.Received(), cn.Written()
 
 	if err := cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
 		db.writeQuery(ctx, cn, wr, query)
 		db.writeBlock(ctx, wr, nil)
 	}); err != nil {
-		return nil, err
+		stale := db.isStaleConn(cn, received, written, idempotent, err)
+		db.releaseConn(cn, err)
+		return nil, stale, err
 	}
 
-	return newBlockIter(db, cn), nil
+	if cn.Reused() {
+		// Wait for the first packet so the query can be retried
+		// when the server has closed the idle connection.
+		if _, err := cn.Reader(ctx, db.cfg.ReadTimeout).Peek(1); err != nil {
+			stale := db.isStaleConn(cn, received, written, idempotent, err)
+			db.releaseConn(cn, err)
+			return nil, stale, err
+		}
+	}
+
+	return newBlockIter(db, cn), false, nil
+}
+
+type insertOptions struct {
+	blockSize  int
+	dedupToken string
+	deadLetter func(row int, err error)
 }
 
 func (db *DB) insert(
-	ctx context.Context, model TableModel, query string, fields []*chschema.Field,
+	ctx context.Context,
+	model TableModel,
+	query string,
+	fields []*chschema.Field,
+	opts insertOptions,
 ) (*result, error) {
-	block := model.Block(fields)
+	blockSize := opts.blockSize
+	rangeModel, ok := model.(blockRangeModel)
+	if ok && opts.deadLetter != nil && blockSize <= 0 {
+		blockSize = rangeModel.numRow()
+	}
+	if !ok || blockSize <= 0 || (rangeModel.numRow() <= blockSize && opts.deadLetter == nil) {
+		block := model.Block(fields)
+		return db.insertBlock(ctx, model, query, block, opts.dedupToken, db.insertLimit)
+	}
 
-	var res *result
-	var lastErr error
+	res := &result{model: model}
+	numRow := rangeModel.numRow()
+	for start, blockIndex := 0, 0; start < numRow; start, blockIndex = start+blockSize, blockIndex+1 {
+		end := start + blockSize
+		if end > numRow {
+			end = numRow
+		}
+
+		var token string
+		if opts.dedupToken != "" {
+			token = opts.dedupToken + "_" + strconv.Itoa(blockIndex)
+		}
 
-	for attempt := 0; attempt <= db.cfg.MaxRetries; attempt++ {
-		if attempt > 0 {
-			lastErr = internal.Sleep(ctx, db.retryBackoff(attempt-1))
-			if lastErr != nil {
-				break
+		if opts.deadLetter != nil {
+			affected, done, err := db.insertSalvage(
+				ctx, model, query, fields, start, end, token, opts.deadLetter, db.insertLimit)
+			res.affected += affected
+			if err != nil {
+				return res, &InsertError{
+					Block:        blockIndex,
+					AcceptedRows: start + done,
+					Err:          err,
+				}
 			}
+			continue
 		}
 
-		res, lastErr = db._insert(ctx, model, query, block)
-		if !db.shouldRetry(lastErr) {
-			break
+		block := rangeModel.blockRange(fields, start, end)
+		blockRes, err := db.insertBlock(ctx, model, query, block, token, db.insertLimit)
+		if err != nil {
+			return res, &InsertError{
+				Block:        blockIndex,
+				AcceptedRows: start,
+				Err:          err,
+			}
 		}
+		res.affected += blockRes.affected
+	}
+	return res, nil
+}
+
+// insertSalvage inserts the rows [start, end). When the server or the encoder
+// rejects a value, the rows are split in halves that are inserted separately
+// until the rejected rows are isolated and passed to deadLetter. It returns
+// the number of inserted rows and the number of processed rows, i.e. inserted
+// or passed to deadLetter, before the first error that is not a value error.
+// The halves are not rate limited, because the rows were limited before
+// the first attempt.
+func (db *DB) insertSalvage(
+	ctx context.Context,
+	model TableModel,
+	query string,
+	fields []*chschema.Field,
+	start, end int,
+	token string,
+	deadLetter func(row int, err error),
+	limiter *insertLimiter,
+) (affected, done int, _ error) {
+	block := model.(blockRangeModel).blockRange(fields, start, end)
+	res, err := db.insertBlock(ctx, model, query, block, token, limiter)
+	if err == nil {
+		return res.affected, end - start, nil
+	}
+	if !IsValueError(err) {
+		return 0, 0, err
+	}
+	if end-start == 1 {
+		deadLetter(start, err)
+		return 0, 1, nil
+	}
+
+	mid := start + (end-start)/2
+	var leftToken, rightToken string
+	if token != "" {
+		leftToken, rightToken = token+"_0", token+"_1"
+	}
+
+	affected, done, err = db.insertSalvage(
+		ctx, model, query, fields, start, mid, leftToken, deadLetter, nil)
+	if err != nil {
+		return affected, done, err
 	}
 
-	return res, lastErr
+	rightAffected, rightDone, err := db.insertSalvage(
+		ctx, model, query, fields, mid, end, rightToken, deadLetter, nil)
+	return affected + rightAffected, done + rightDone, err
+}
+
+// insertBlock inserts the block. Inserts with a deduplication token are
+// idempotent so they are retried according to the retry policy. The insert
+// is rate limited unless limiter is nil.
+func (db *DB) insertBlock(
+	ctx context.Context,
+	model TableModel,
+	query string,
+	block *chschema.Block,
+	token string,
+	limiter *insertLimiter,
+) (*result, error) {
+	if db.cfg.RejectNonFiniteFloats {
+		if err := block.CheckFinite(); err != nil {
+			return nil, err
+		}
+	}
+	if db.cfg.TruncateFixedStrings {
+		block.TruncateFixedStrings()
+	}
+	if limiter != nil {
+		if err := limiter.wait(ctx, block.NumRow); err != nil {
+			return nil, err
+		}
+	}
+
+	if token == "" {
+		return db._insert(ctx, model, query, block, limiter)
+	}
+
+	ctx = ContextWithQuerySettings(ctx, map[string]any{
+		"insert_deduplication_token": token,
+	})
+
+	var res *result
+	err := db.withRetry(ctx, true, func() error {
+		var err error
+		res, err = db._insert(ctx, model, query, block, limiter)
+		return err
+	})
+	return res, err
 }
 
 func (db *DB) _insert(
-	ctx context.Context, model TableModel, query string, block *chschema.Block,
+	ctx context.Context,
+	model TableModel,
+	query string,
+	block *chschema.Block,
+	limiter *insertLimiter,
 ) (*result, error) {
 	var res *result
-	err := db.withConn(ctx, func(cn *chpool.Conn) error {
+	// The block is written only after the server has replied with the table
+	// schema, so inserts that fail on a stale connection are not executed.
+	err := db.withConn(ctx, true, func(cn *chpool.Conn) error {
 		if err := cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
 			db.writeQuery(ctx, cn, wr, query)
 			db.writeBlock(ctx, wr, nil)
@@ -369,19 +772,35 @@ func (db *DB) _insert(
 			return err
 		}
 
+		var sample *chschema.Block
 		if err := cn.WithReader(ctx, db.cfg.ReadTimeout, func(rd *chproto.Reader) error {
-			_, err := db.readSampleBlock(rd)
+			var err error
+			sample, err = db.readSampleBlock(cn, rd)
 			return err
 		}); err != nil {
 			return err
 		}
 
+		if err := db.checkTypes(query, block, sample); err != nil {
+			return err
+		}
+		block, err := block.Reorder(sample)
+		if err != nil {
+			return err
+		}
+
+		var numByte int64
 		if err := cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
+			written := wr.Written()
 			db.writeBlock(ctx, wr, block)
+			numByte = wr.Written() - written
 			db.writeBlock(ctx, wr, nil)
 		}); err != nil {
 			return err
 		}
+		if limiter != nil {
+			limiter.takeBytes(numByte)
+		}
 
 		return cn.WithReader(ctx, db.cfg.ReadTimeout, func(rd *chproto.Reader) error {
 			var err error
@@ -396,6 +815,31 @@ func (db *DB) _insert(
 	return res, err
 }
 
+type typeCheckKey struct {
+	query string
+	typ   reflect.Type
+}
+
+// checkTypes checks the types of the model fields against the table schema
+// once per insert query and model type, because the schema rarely changes
+// between inserts. Types changed by ALTER TABLE after the check are rejected
+// by the server instead.
+func (db *DB) checkTypes(query string, block, sample *chschema.Block) error {
+	if block.Table == nil || sample == nil {
+		return nil
+	}
+
+	key := typeCheckKey{query: query, typ: block.Table.Type}
+	if _, ok := db.checkedTypes.Load(key); ok {
+		return nil
+	}
+	if err := block.CheckTypes(sample); err != nil {
+		return err
+	}
+	db.checkedTypes.Store(key, struct{}{})
+	return nil
+}
+
 func (db *DB) NewSelect() *SelectQuery {
 	return NewSelectQuery(db)
 }
@@ -438,36 +882,6 @@ func (db *DB) WithFormatter(fmter chschema.Formatter) *DB {
 	return clone
 }
 
-func (db *DB) shouldRetry(err error) bool {
-	switch err {
-	case driver.ErrBadConn:
-		return true
-	case nil, context.Canceled, context.DeadlineExceeded:
-		return false
-	}
-
-	if err, ok := err.(*Error); ok {
-		// https://github.com/ClickHouse/ClickHouse/blob/master/src/Common/ErrorCodes.cpp
-		const (
-			timeoutExceeded            = 159
-			tooManySimultaneousQueries = 202
-			memoryLimitExceeded        = 241
-		)
-
-		switch err.Code {
-		case timeoutExceeded, tooManySimultaneousQueries, memoryLimitExceeded:
-			return true
-		}
-	}
-
-	return false
-}
-
-func (db *DB) retryBackoff(attempt int) time.Duration {
-	return internal.RetryBackoff(
-		attempt, db.cfg.MinRetryBackoff, db.cfg.MaxRetryBackoff)
-}
-
 func (db *DB) FormatQuery(query string, args ...any) string {
 	return db.fmter.FormatQuery(query, args...)
 }
@@ -483,6 +897,7 @@ func (db *DB) makeQueryBytes() []byte {
 // Use Next to advance from row to row.
 type Rows struct {
 	ctx    context.Context
+	cancel context.CancelFunc
 	blocks *blockIter
 	block  *chschema.Block
 
@@ -511,14 +926,30 @@ func (rs *Rows) Close() error {
 func (rs *Rows) close() {
 	rs.closed = true
 	_ = rs.blocks.Close()
+	if rs.cancel != nil {
+		rs.cancel()
+	}
 }
 
 func (rs *Rows) ColumnTypes() ([]*sql.ColumnType, error) {
 	return nil, errors.New("not implemented")
 }
 
+// Columns returns the column names. Columns are known after the first
+// call to Next.
 func (rs *Rows) Columns() ([]string, error) {
-	return nil, errors.New("not implemented")
+	if len(rs.block.Columns) == 0 {
+		if err := rs.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("ch: Columns called without calling Next")
+	}
+
+	names := make([]string, len(rs.block.Columns))
+	for i, col := range rs.block.Columns {
+		names[i] = col.Name
+	}
+	return names, nil
 }
 
 func (rs *Rows) Err() error {
@@ -557,13 +988,106 @@ func (rs *Rows) Scan(dest ...any) error {
 	}
 	rs.hasNext = false
 
-	if rs.block.NumColumn != len(dest) {
-		return fmt.Errorf("ch: got %d columns, but Scan has %d values",
-			rs.block.NumColumn, len(dest))
+	return scanBlockRow(rs.block, rs.rowIndex-1, dest)
+}
+
+// Values returns the values of the current row as decoded by the column types,
+// for example, uint64 for UInt64 and time.Time for DateTime.
+func (rs *Rows) Values() ([]any, error) {
+	if err := rs.checkRow(); err != nil {
+		return nil, err
 	}
 
+	row := rs.rowIndex - 1
+	values := make([]any, len(rs.block.Columns))
 	for i, col := range rs.block.Columns {
-		if err := col.ConvertAssign(rs.rowIndex-1, reflect.ValueOf(dest[i]).Elem()); err != nil {
+		values[i] = col.Index(row)
+	}
+	return values, nil
+}
+
+// RawColumn returns the decoded value of the i-th column in the current row.
+func (rs *Rows) RawColumn(i int) (any, error) {
+	if err := rs.checkRow(); err != nil {
+		return nil, err
+	}
+	if i < 0 || i >= len(rs.block.Columns) {
+		return nil, fmt.Errorf("ch: column index %d out of range [0, %d)",
+			i, len(rs.block.Columns))
+	}
+	return rs.block.Columns[i].Index(rs.rowIndex - 1), nil
+}
+
+func (rs *Rows) checkRow() error {
+	if rs.closed {
+		if err := rs.Err(); err != nil {
+			return err
+		}
+		return errors.New("ch: rows are closed")
+	}
+	if rs.rowIndex == 0 {
+		return errors.New("ch: no current row, call Next first")
+	}
+	return nil
+}
+
+// Totals returns the totals row of a GROUP BY ... WITH TOTALS query or nil.
+// Totals are sent after the data so they are only available after Next returns false.
+func (rs *Rows) Totals() *chschema.Block {
+	return rs.blocks.totals
+}
+
+// ScanTotals copies the columns of the totals row into the values pointed at by dest.
+func (rs *Rows) ScanTotals(dest ...any) error {
+	if !rs.closed {
+		return errors.New("ch: ScanTotals called before reading all rows")
+	}
+	if err := rs.Err(); err != nil {
+		return err
+	}
+
+	totals := rs.Totals()
+	if totals == nil || totals.NumRow == 0 {
+		return sql.ErrNoRows
+	}
+	return scanBlockRow(totals, 0, dest)
+}
+
+// Extremes returns a block with the minimum (row 0) and maximum (row 1) values
+// of the result columns when the query has extremes=1 setting, or nil.
+// Like totals, extremes are only available after Next returns false.
+func (rs *Rows) Extremes() *chschema.Block {
+	return rs.blocks.extremes
+}
+
+// ScanExtremes copies the minimum and maximum values of the result columns
+// into the values pointed at by minDest and maxDest.
+func (rs *Rows) ScanExtremes(minDest, maxDest []any) error {
+	if !rs.closed {
+		return errors.New("ch: ScanExtremes called before reading all rows")
+	}
+	if err := rs.Err(); err != nil {
+		return err
+	}
+
+	extremes := rs.Extremes()
+	if extremes == nil || extremes.NumRow < 2 {
+		return sql.ErrNoRows
+	}
+	if err := scanBlockRow(extremes, 0, minDest); err != nil {
+		return err
+	}
+	return scanBlockRow(extremes, 1, maxDest)
+}
+
+func scanBlockRow(block *chschema.Block, row int, dest []any) error {
+	if block.NumColumn != len(dest) {
+		return fmt.Errorf("ch: got %d columns, but Scan has %d values",
+			block.NumColumn, len(dest))
+	}
+
+	for i, col := range block.Columns {
+		if err := col.ConvertAssign(row, reflect.ValueOf(dest[i]).Elem()); err != nil {
 			return err
 		}
 	}
@@ -1,9 +1,15 @@
 package ch_test
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
 	"os"
 	"reflect"
 	"runtime"
@@ -14,6 +20,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
 	"github.com/uptrace/go-clickhouse/chdebug"
 )
 
@@ -45,9 +52,16 @@ func TestCHError(t *testing.T) {
 	require.Error(t, err)
 	require.Nil(t, res)
 
-	exc := err.(*ch.Error)
+	var exc *ch.Error
+	require.True(t, errors.As(err, &exc))
 	require.Equal(t, int32(62), exc.Code)
 	require.Equal(t, "DB::Exception", exc.Name)
+
+	var queryErr *ch.QueryError
+	require.True(t, errors.As(err, &queryErr))
+	require.Equal(t, "hi", queryErr.Query)
+	require.NotEmpty(t, queryErr.QueryID)
+	require.NotEmpty(t, queryErr.Host)
 }
 
 func TestCHTimeout(t *testing.T) {
@@ -133,6 +147,145 @@ func TestNullable(t *testing.T) {
 	}, ms)
 }
 
+func TestNullTypes(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	type Model struct {
+		ID        uint64
+		Name      sql.NullString
+		Count     sql.NullInt64
+		Score     sql.NullFloat64
+		Done      sql.NullBool
+		DeletedAt sql.NullTime
+		Code      *string     `ch:"type:Nullable(Enum8('a' = 1, 'b' = 2))"`
+		Amount    *ch.Decimal `ch:"type:Nullable(Decimal(18, 2))"`
+		UpdatedAt *time.Time  `ch:"type:Nullable(DateTime64(3))"`
+		UUID      *ch.UUID
+	}
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Contains(t, query, "name Nullable(String), count Nullable(Int64), "+
+		"score Nullable(Float64), done Nullable(Bool), deleted_at Nullable(DateTime)")
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	tm := time.Unix(1e6, 0).Local()
+	amount := ch.NewDecimal(1234, 2)
+	uuid := ch.UUID{1, 2, 3}
+	src := []Model{{
+		ID:        1,
+		Name:      sql.NullString{String: "hello", Valid: true},
+		Count:     sql.NullInt64{Int64: 42, Valid: true},
+		Score:     sql.NullFloat64{Float64: 1.5, Valid: true},
+		Done:      sql.NullBool{Bool: true, Valid: true},
+		DeletedAt: sql.NullTime{Time: tm, Valid: true},
+		Code:      strptr("b"),
+		Amount:    &amount,
+		UpdatedAt: &tm,
+		UUID:      &uuid,
+	}, {
+		ID: 2,
+	}}
+	_, err = db.NewInsert().Model(&src).Exec(ctx)
+	require.NoError(t, err)
+
+	var dest []Model
+	err = db.NewSelect().Model(&dest).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+
+	var name sql.NullString
+	var count sql.NullInt64
+	err = db.NewSelect().Model((*Model)(nil)).Column("name", "count").
+		Where("id = 2").Scan(ctx, &name, &count)
+	require.NoError(t, err)
+	require.False(t, name.Valid)
+	require.False(t, count.Valid)
+}
+
+func TestFixedString(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB(ch.WithTruncateFixedStrings(true))
+	defer db.Close()
+
+	type Model struct {
+		ID    uint64
+		Hash  [4]byte
+		Bytes []byte `ch:"type:FixedString(4)"`
+		Code  string `ch:"type:FixedString(2)"`
+	}
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Contains(t, query, "hash FixedString(4), bytes FixedString(4), code FixedString(2)")
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	src := []Model{
+		{ID: 1, Hash: [4]byte{1, 2, 3, 4}, Bytes: []byte("ab"), Code: "xyz"},
+	}
+	_, err = db.NewInsert().Model(&src).Exec(ctx)
+	require.NoError(t, err)
+
+	var dest []Model
+	err = db.NewSelect().Model(&dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Model{
+		{ID: 1, Hash: [4]byte{1, 2, 3, 4}, Bytes: []byte("ab\x00\x00"), Code: "xy"},
+	}, dest)
+
+	strict := chDB()
+	defer strict.Close()
+
+	_, err = strict.NewInsert().Model(&src).Exec(ctx)
+	var sizeErr *chschema.FixedStringSizeError
+	require.True(t, errors.As(err, &sizeErr))
+	require.Equal(t, "code", sizeErr.Column)
+}
+
+func TestClusterMacro(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB(ch.WithClusterMacro(""))
+	defer db.Close()
+
+	var want string
+	err := db.NewSelect().
+		ColumnExpr("substitution").
+		TableExpr("system.macros").
+		Where("macro = 'cluster'").
+		Scan(ctx, &want)
+	if err != nil {
+		require.Equal(t, sql.ErrNoRows, err)
+	}
+
+	cluster, err := db.Cluster(ctx)
+	require.NoError(t, err)
+	require.Equal(t, want, cluster)
+
+	type Model struct {
+		ch.CHModel `ch:"table:cluster_macro"`
+
+		ID uint64
+	}
+
+	// Works with and without the macro.
+	err = db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	query := db.NewDropTable().Model((*Model)(nil)).String()
+	if want == "" {
+		require.Equal(t, `DROP TABLE "cluster_macro"`, query)
+	} else {
+		require.Equal(t, `DROP TABLE "cluster_macro" ON CLUSTER "`+want+`"`, query)
+	}
+}
+
 func TestPlaceholder(t *testing.T) {
 	ctx := context.Background()
 
@@ -531,3 +684,734 @@ func funcName(x interface{}) string {
 func strptr(s string) *string {
 	return &s
 }
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	sess, err := db.Session(ctx)
+	require.NoError(t, err)
+	defer sess.Close()
+
+	_, err = sess.ExecContext(ctx, "CREATE TEMPORARY TABLE session_tmp (n UInt64)")
+	require.NoError(t, err)
+
+	_, err = sess.ExecContext(ctx, "INSERT INTO session_tmp SELECT number FROM numbers(10)")
+	require.NoError(t, err)
+
+	var count int
+	err = sess.NewSelect().ColumnExpr("count()").TableExpr("session_tmp").Scan(ctx, &count)
+	require.NoError(t, err)
+	require.Equal(t, 10, count)
+
+	require.NoError(t, sess.Close())
+	require.ErrorIs(t, sess.Ping(ctx), ch.ErrSessionClosed)
+}
+
+func TestErrorCodes(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	_, err := db.ExecContext(ctx, "SELECT * FROM table_that_does_not_exist")
+	require.Error(t, err)
+	require.True(t, ch.IsTableNotExist(err))
+	require.Equal(t, ch.CodeUnknownTable, ch.ErrorCode(err))
+	require.False(t, ch.IsMemoryLimitExceeded(err))
+}
+
+func TestUnknownColumns(t *testing.T) {
+	ctx := context.Background()
+
+	type Model struct {
+		Number uint64
+		Extra  map[string]any `ch:",extra"`
+	}
+
+	query := func(db *ch.DB, dest *Model) error {
+		return db.NewSelect().
+			Model(dest).
+			ColumnExpr("number, toString(number) AS str").
+			TableExpr("numbers(1)").
+			Scan(ctx)
+	}
+
+	db := chDB()
+	defer db.Close()
+
+	var model Model
+	err := query(db, &model)
+	var unknownErr *chschema.UnknownColumnError
+	require.True(t, errors.As(err, &unknownErr))
+	require.Equal(t, "str", unknownErr.Column)
+
+	db = chDB(ch.WithUnknownColumns(ch.UnknownColumnsIgnore))
+	defer db.Close()
+
+	model = Model{}
+	require.NoError(t, query(db, &model))
+	require.Nil(t, model.Extra)
+
+	db = chDB(ch.WithUnknownColumns(ch.UnknownColumnsCollect))
+	defer db.Close()
+
+	model = Model{}
+	require.NoError(t, query(db, &model))
+	require.Equal(t, map[string]any{"str": "0"}, model.Extra)
+}
+
+func TestRowsValues(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, "SELECT number, toString(number) AS str FROM numbers(2)")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var values [][]any
+	for rows.Next() {
+		columns, err := rows.Columns()
+		require.NoError(t, err)
+		require.Equal(t, []string{"number", "str"}, columns)
+
+		row, err := rows.Values()
+		require.NoError(t, err)
+		values = append(values, row)
+
+		str, err := rows.RawColumn(1)
+		require.NoError(t, err)
+		require.Equal(t, row[1], str)
+	}
+	require.NoError(t, rows.Err())
+	require.Equal(t, [][]any{{uint64(0), "0"}, {uint64(1), "1"}}, values)
+}
+
+func TestRowsPrettyPrint(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT number, if(number = 0, NULL, toString(number * 100)) AS str
+		FROM numbers(2)
+	`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var buf bytes.Buffer
+	err = rows.PrettyPrint(&buf)
+	require.NoError(t, err)
+	require.Equal(t, `+--------+------------------+
+| number | str              |
+| UInt64 | Nullable(String) |
++--------+------------------+
+|      0 | NULL             |
+|      1 | 100              |
++--------+------------------+
+`, buf.String())
+}
+
+func TestCompressionMethodOverride(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	methods := []ch.Compression{ch.CompressionNone, ch.CompressionLZ4, ch.CompressionZSTD}
+	for _, method := range methods {
+		var sum uint64
+		err := db.WithCompressionMethod(method).NewSelect().
+			ColumnExpr("sum(number)").
+			TableExpr("numbers(100000)").
+			Scan(ctx, &sum)
+		require.NoError(t, err, method)
+		require.Equal(t, uint64(4999950000), sum, method)
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:decimals"`
+
+		Price  ch.Decimal  `ch:"type:Decimal(18, 4)"`
+		Amount float64     `ch:"type:Decimal32(2)"`
+		Hash   *ch.Decimal `ch:"type:Nullable(Decimal(76, 0))"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	hash, err := ch.ParseDecimal("-123456789012345678901234567890123456789")
+	require.NoError(t, err)
+
+	src := &Model{
+		Price:  ch.NewDecimal(-12345, 4),
+		Amount: 10.25,
+		Hash:   &hash,
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "-1.2345", dest.Price.String())
+	require.Equal(t, 10.25, dest.Amount)
+	require.Equal(t, hash.String(), dest.Hash.String())
+
+	var price float64
+	err = db.QueryRowContext(ctx, "SELECT toDecimal64('3.14', 2)").Scan(&price)
+	require.NoError(t, err)
+	require.Equal(t, 3.14, price)
+}
+
+func TestMap(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:maps"`
+
+		Counts map[string]uint64 `ch:"type:Map(String, UInt64)"`
+		Tags   map[string]string `ch:"type:Map(String, String)"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	src := &Model{
+		Counts: map[string]uint64{"foo": 1, "bar": 2},
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src.Counts, dest.Counts)
+	require.Empty(t, dest.Tags)
+}
+
+func TestTuple(t *testing.T) {
+	type Point struct {
+		X float64
+		Y float64
+	}
+	type Model struct {
+		ch.CHModel `ch:"table:tuples"`
+
+		Point  Point   `ch:"type:Tuple(x Float64, y Float64)"`
+		Path   []Point `ch:"type:Array(Tuple(Float64, Float64))"`
+		Labels []any   `ch:"type:Tuple(String, UInt64)"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	src := &Model{
+		Point:  Point{X: 1, Y: 2},
+		Path:   []Point{{X: 1, Y: 2}, {X: 3, Y: 4}},
+		Labels: []any{"foo", uint64(1)},
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+}
+
+func TestNested(t *testing.T) {
+	type Item struct {
+		Name  string
+		Count uint64
+	}
+	type Model struct {
+		ch.CHModel `ch:"table:nested"`
+
+		ID    uint64
+		Items []Item `ch:",nested"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	src := []Model{
+		{ID: 1, Items: []Item{{Name: "foo", Count: 1}, {Name: "bar", Count: 2}}},
+		{ID: 2, Items: []Item{}},
+	}
+	_, err = db.NewInsert().Model(&src).Exec(ctx)
+	require.NoError(t, err)
+
+	var dest []Model
+	err = db.NewSelect().Model(&dest).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+}
+
+func TestNestedArrays(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:nested_arrays"`
+
+		ID     uint64
+		Nums   [][][]int64
+		Names  [][]string `ch:",lc"`
+		Values [][]*string
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Contains(t, query, "nums Array(Array(Array(Int64))), "+
+		"names Array(Array(LowCardinality(String))), values Array(Array(Nullable(String)))")
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	str := "foo"
+	src := []Model{
+		{
+			ID:     1,
+			Nums:   [][][]int64{{{1, 2}, {3}}, {}},
+			Names:  [][]string{{"foo", "bar"}, {"foo"}},
+			Values: [][]*string{{&str, nil}},
+		},
+		{
+			ID:     2,
+			Nums:   [][][]int64{},
+			Names:  [][]string{{}},
+			Values: [][]*string{{nil}, {}},
+		},
+	}
+	_, err = db.NewInsert().Model(&src).Exec(ctx)
+	require.NoError(t, err)
+
+	var dest []Model
+	err = db.NewSelect().Model(&dest).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+}
+
+func TestBool(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:bools"`
+
+		Flag     bool
+		Nullable *bool
+		Flags    []bool
+		LC       bool `ch:"type:LowCardinality(Bool)"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Contains(t, query, "flag Bool, nullable Nullable(Bool), flags Array(Bool)")
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	flag := true
+	src := []Model{
+		{Flag: true, Nullable: &flag, Flags: []bool{true, false}, LC: true},
+		{Flags: []bool{}},
+	}
+	_, err = db.NewInsert().Model(&src).Exec(ctx)
+	require.NoError(t, err)
+
+	var dest []Model
+	err = db.NewSelect().Model(&dest).Order("flag DESC").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+
+	var flags []any
+	err = db.NewSelect().ColumnExpr("lc").Model((*Model)(nil)).Order("flag DESC").
+		Scan(ctx, &flags)
+	require.NoError(t, err)
+	require.Equal(t, []any{true, false}, flags)
+}
+
+func TestDate32(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:dates32"`
+
+		Date     time.Time  `ch:"type:Date32"`
+		Nullable *time.Time `ch:"type:Nullable(Date32)"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	date := time.Date(1925, time.March, 1, 0, 0, 0, 0, time.UTC)
+	src := []Model{
+		{Date: date, Nullable: &date},
+		{Date: time.Date(2200, time.January, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	_, err = db.NewInsert().Model(&src).Exec(ctx)
+	require.NoError(t, err)
+
+	var dest []Model
+	err = db.NewSelect().Model(&dest).Order("date").Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, dest, 2)
+	require.True(t, src[0].Date.Equal(dest[0].Date))
+	require.True(t, src[0].Nullable.Equal(*dest[0].Nullable))
+	require.True(t, src[1].Date.Equal(dest[1].Date))
+	require.Nil(t, dest[1].Nullable)
+}
+
+func TestIPAddr(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:ips"`
+
+		Addr     netip.Addr `ch:"type:IPv4"`
+		Addr6    netip.Addr
+		Nullable *netip.Addr  `ch:"type:Nullable(IPv4)"`
+		Addrs    []netip.Addr `ch:"type:Array(IPv4)"`
+		IP       net.IP       `ch:"type:IPv4"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	addr := netip.MustParseAddr("10.0.0.1")
+	src := &Model{
+		Addr:     addr,
+		Addr6:    netip.MustParseAddr("2001:db8::1"),
+		Nullable: &addr,
+		Addrs:    []netip.Addr{addr, netip.MustParseAddr("10.0.0.2")},
+		IP:       net.ParseIP("10.0.0.3").To4(),
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+}
+
+func TestUUID(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:uuids"`
+
+		ID       ch.UUID
+		Str      string   `ch:"type:UUID"`
+		Nullable *string  `ch:"type:Nullable(UUID)"`
+		Strs     []string `ch:"type:Array(UUID)"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	id, err := chschema.ParseUUID("00112233-4455-6677-8899-aabbccddeeff")
+	require.NoError(t, err)
+
+	str := "123e4567-e89b-12d3-a456-426614174000"
+	src := &Model{
+		ID:       id,
+		Str:      str,
+		Nullable: &str,
+		Strs:     []string{str, id.String()},
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+
+	var s string
+	err = db.QueryRow("SELECT toString(id) FROM uuids").Scan(&s)
+	require.NoError(t, err)
+	require.Equal(t, id.String(), s)
+}
+
+func TestJSON(t *testing.T) {
+	type Payload struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	type Model struct {
+		ch.CHModel `ch:"table:objects"`
+
+		ID      uint64
+		Payload Payload        `ch:"type:Object('json')"`
+		Attrs   map[string]any `ch:"type:Object('json')"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB(ch.WithQuerySettings(map[string]any{
+		"allow_experimental_object_type": 1,
+	}))
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	src := &Model{
+		ID:      1,
+		Payload: Payload{Name: "click", Count: 2},
+		Attrs:   map[string]any{"env": "prod"},
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+}
+
+func TestCancelAll(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := db.ExecContext(ctx, "SELECT sleepEachRow(1) FROM numbers(30)")
+		errc <- err
+	}()
+
+	time.Sleep(time.Second)
+	require.NoError(t, db.CancelAll(ctx))
+
+	select {
+	case err := <-errc:
+		require.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("query was not killed")
+	}
+}
+
+func TestBigInt(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:big_ints"`
+
+		Hash   *big.Int   `ch:"type:UInt128"`
+		Small  uint64     `ch:"type:UInt128"`
+		Signed *big.Int   `ch:"type:Nullable(Int256)"`
+		Hashes []*big.Int `ch:"type:Array(UInt256)"`
+		Counts []int64    `ch:"type:Array(Int128)"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	hash, _ := new(big.Int).SetString("340282366920938463463374607431768211455", 10)
+	src := &Model{
+		Hash:   hash,
+		Small:  42,
+		Hashes: []*big.Int{big.NewInt(1), hash},
+		Counts: []int64{-1, 2},
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, hash.String(), dest.Hash.String())
+	require.Equal(t, uint64(42), dest.Small)
+	require.Nil(t, dest.Signed)
+	require.Len(t, dest.Hashes, 2)
+	require.Equal(t, hash.String(), dest.Hashes[1].String())
+	require.Equal(t, []int64{-1, 2}, dest.Counts)
+
+	var n uint64
+	err = db.QueryRowContext(ctx, "SELECT toUInt128('340282366920938463463374607431768211455')").Scan(&n)
+	require.Error(t, err)
+}
+
+func TestGeo(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:geo"`
+
+		Point        ch.Point
+		Ring         ch.Ring
+		Polygon      ch.Polygon
+		MultiPolygon ch.MultiPolygon
+	}
+
+	ctx := context.Background()
+
+	db := chDB(ch.WithQuerySettings(map[string]any{
+		"allow_experimental_geo_types": 1,
+	}))
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	ring := ch.Ring{{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 10}, {X: 0, Y: 10}}
+	polygon := ch.Polygon{ring, {{X: 2, Y: 2}, {X: 4, Y: 2}, {X: 4, Y: 4}}}
+	src := &Model{
+		Point:        ch.Point{X: 1.5, Y: -2},
+		Ring:         ring,
+		Polygon:      polygon,
+		MultiPolygon: ch.MultiPolygon{polygon, {ring}},
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+}
+
+func TestEnum(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:enums"`
+
+		Kind     string   `ch:",enum:hello|world"`
+		Level    *string  `ch:",enum:debug=-1|info|error=1000"`
+		Statuses []string `ch:",enum:open|closed"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	level := "error"
+	src := &Model{
+		Kind:     "world",
+		Level:    &level,
+		Statuses: []string{"open", "closed"},
+	}
+	_, err = db.NewInsert().Model(src).Exec(ctx)
+	require.NoError(t, err)
+
+	dest := new(Model)
+	err = db.NewSelect().Model(dest).Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, src, dest)
+
+	_, err = db.NewInsert().Model(&Model{Kind: "foo"}).Exec(ctx)
+	require.Error(t, err)
+	var enumErr *chschema.EnumValueError
+	require.True(t, errors.As(err, &enumErr))
+}
+
+func TestLowCardinality(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:low_cardinality"`
+
+		Code *string   `ch:",lc"`
+		Num  uint32    `ch:",lc"`
+		Day  time.Time `ch:"type:LowCardinality(Date)"`
+		Hash string    `ch:"type:LowCardinality(FixedString(4))"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB(ch.WithQuerySettings(map[string]any{
+		"allow_suspicious_low_cardinality_types": 1,
+	}))
+	defer db.Close()
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Contains(t, query,
+		"code LowCardinality(Nullable(String)), num LowCardinality(UInt32)")
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	code := "foo"
+	day := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC).Local()
+	src := []Model{
+		{Code: &code, Num: 1, Day: day, Hash: "abcd"},
+		{Num: 2, Day: day, Hash: "ab"},
+		{Code: &code, Num: 1, Day: day, Hash: "abcd"},
+	}
+	_, err = db.NewInsert().Model(&src).Exec(ctx)
+	require.NoError(t, err)
+
+	var dest []Model
+	err = db.NewSelect().Model(&dest).Order("num", "hash").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Model{src[0], src[2], src[1]}, dest)
+}
+
+func TestNonFiniteFloats(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:floats"`
+
+		Value float64
+	}
+
+	ctx := context.Background()
+
+	db := chDB(ch.WithRejectNonFiniteFloats(true), ch.WithNonFiniteFloatsAsNull(true))
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	_, err = db.NewInsert().Model(&[]Model{{Value: 1}, {Value: math.NaN()}}).Exec(ctx)
+	var floatErr *chschema.NonFiniteFloatError
+	require.True(t, errors.As(err, &floatErr))
+	require.Equal(t, 1, floatErr.Row)
+
+	var m map[string]any
+	err = db.NewSelect().ColumnExpr("1.5 AS a, inf AS b, nan AS c").Scan(ctx, &m)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"a": 1.5, "b": nil, "c": nil}, m)
+}
@@ -0,0 +1,287 @@
+package ch
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const ddlPollInterval = 500 * time.Millisecond
+
+// DDLStatus is the status of a distributed DDL task, that is a query executed
+// with ON CLUSTER, as reported by system.distributed_ddl_queue.
+type DDLStatus struct {
+	Entry   string // task name in the queue, e.g. query-0000000042
+	Cluster string
+	Query   string
+	Hosts   []DDLHostStatus
+
+	// Remaining is the number of hosts that have not reported the status yet.
+	// It is only set by ExecDDL.
+	Remaining int
+}
+
+// DDLHostStatus is the status of a distributed DDL task on a host.
+type DDLHostStatus struct {
+	Host          string
+	Port          uint16
+	Status        string // Inactive, Active, Finished, Removing, or Unknown
+	ExceptionCode int32
+	ExceptionText string
+}
+
+func (s *DDLHostStatus) addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
+
+// Finished reports whether the host has executed the task, successfully or not.
+func (s *DDLHostStatus) Finished() bool {
+	return s.Status == "Finished" || s.Status == "Removing"
+}
+
+// Failed reports whether the task has failed on the host.
+func (s *DDLHostStatus) Failed() bool {
+	return s.ExceptionCode != 0
+}
+
+// Done reports whether all hosts have executed the task.
+func (s *DDLStatus) Done() bool {
+	if s.Remaining > 0 {
+		return false
+	}
+	for i := range s.Hosts {
+		if !s.Hosts[i].Finished() {
+			return false
+		}
+	}
+	return true
+}
+
+// Err returns a *DDLError when the task has failed on some hosts.
+func (s *DDLStatus) Err() error {
+	var failed []DDLHostStatus
+	for _, host := range s.Hosts {
+		if host.Failed() {
+			failed = append(failed, host)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &DDLError{
+		Entry:    s.Entry,
+		NumHosts: len(s.Hosts) + s.Remaining,
+		Failed:   failed,
+	}
+}
+
+// DDLError is returned when a distributed DDL task has failed on some hosts.
+type DDLError struct {
+	Entry    string
+	NumHosts int             // number of hosts in the cluster
+	Failed   []DDLHostStatus // hosts that have failed to execute the task
+}
+
+func (err *DDLError) Error() string {
+	var b strings.Builder
+	b.WriteString("ch: DDL task ")
+	if err.Entry != "" {
+		b.WriteString(err.Entry)
+		b.WriteString(" ")
+	}
+	fmt.Fprintf(&b, "failed on %d of %d hosts:", len(err.Failed), err.NumHosts)
+	for i, host := range err.Failed {
+		if i > 0 {
+			b.WriteString(";")
+		}
+		fmt.Fprintf(&b, " %s: %s", host.addr(), host.ExceptionText)
+	}
+	return b.String()
+}
+
+// ExecDDL executes the distributed DDL query, that is a query with ON CLUSTER,
+// and returns the status of the task on every host as reported by the query
+// itself, for example:
+//
+//	status, err := db.ExecDDL(ctx, "DROP TABLE events ON CLUSTER ?", ch.Ident("events"))
+//
+// The server waits for the hosts for up to distributed_ddl_task_timeout.
+// Hosts that have not executed the task by then are counted in Remaining, so
+// use Done to check whether all hosts have executed the task. The returned
+// error is a *DDLError when the task has failed on some hosts.
+func (db *DB) ExecDDL(ctx context.Context, query string, args ...any) (*DDLStatus, error) {
+	ctx = ContextWithQuerySettings(ctx, map[string]any{
+		// Report failed and timed out hosts in the result instead of an error.
+		"distributed_ddl_output_mode": "never_throw",
+	})
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := new(DDLStatus)
+	for rows.Next() {
+		columns, err := rows.Columns()
+		if err != nil {
+			return nil, err
+		}
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+
+		host, remaining := ddlHostStatusFromRow(columns, values)
+		status.Hosts = append(status.Hosts, host)
+		status.Remaining = remaining
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return status, status.Err()
+}
+
+// ddlHostStatusFromRow returns the host status from a row of the ON CLUSTER
+// query result with the host, port, status, error, and num_hosts_remaining
+// columns. The status is the exception code or NULL when the host has timed out.
+func ddlHostStatusFromRow(columns []string, values []any) (DDLHostStatus, int) {
+	host := DDLHostStatus{Status: "Finished"}
+	var remaining int
+	for i, col := range columns {
+		switch v := indirectValue(values[i]); col {
+		case "host":
+			host.Host, _ = v.(string)
+		case "port":
+			n, _ := ddlInt(v)
+			host.Port = uint16(n)
+		case "status":
+			n, ok := ddlInt(v)
+			if !ok {
+				host.Status = "Unknown"
+			}
+			host.ExceptionCode = int32(n)
+		case "error":
+			host.ExceptionText, _ = v.(string)
+		case "num_hosts_remaining":
+			n, _ := ddlInt(v)
+			remaining = int(n)
+		}
+	}
+	return host, remaining
+}
+
+func indirectValue(v any) any {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		return rv.Elem().Interface()
+	}
+	return v
+}
+
+func ddlInt(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// FindDDLTask returns the name of the latest distributed DDL task created
+// at or after since with the query that contains substr, for example:
+//
+//	since := time.Now()
+//	_, err := db.NewCreateTable().Model((*Event)(nil)).OnCluster("events").Exec(ctx)
+//	entry, err := db.FindDDLTask(ctx, "events", since)
+//	status, err := db.WaitDDL(ctx, entry)
+//
+// The queue is populated by the server so the query is formatted by ClickHouse,
+// for example, with backticks instead of double quotes. Tasks with the same
+// query created by other clients at the same time are not told apart, so prefer
+// ExecDDL unless the query returns before the task is executed, for example,
+// with distributed_ddl_task_timeout set to 0.
+func (db *DB) FindDDLTask(ctx context.Context, substr string, since time.Time) (string, error) {
+	var entry string
+	if err := db.NewSelect().
+		ColumnExpr("entry").
+		TableExpr("system.distributed_ddl_queue").
+		Where("query_create_time >= ?", since.Truncate(time.Second)).
+		Where("position(query, ?) > 0", substr).
+		OrderExpr("entry DESC").
+		Limit(1).
+		Scan(ctx, &entry); err != nil {
+		return "", err
+	}
+	return entry, nil
+}
+
+// DDLStatus returns the status of the distributed DDL task on every host.
+func (db *DB) DDLStatus(ctx context.Context, entry string) (*DDLStatus, error) {
+	var rows []struct {
+		Entry   string
+		Cluster string
+		Query   string
+		DDLHostStatus
+	}
+	if err := db.NewSelect().
+		ColumnExpr("entry, cluster, query").
+		ColumnExpr("ifNull(host, '') AS host").
+		ColumnExpr("toUInt16(ifNull(port, 0)) AS port").
+		ColumnExpr("ifNull(toString(status), '') AS status").
+		ColumnExpr("toInt32(ifNull(exception_code, 0)) AS exception_code").
+		ColumnExpr("ifNull(exception_text, '') AS exception_text").
+		TableExpr("system.distributed_ddl_queue").
+		Where("entry = ?", entry).
+		OrderExpr("host, port").
+		Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("ch: DDL task %s not found", entry)
+	}
+
+	status := &DDLStatus{
+		Entry:   rows[0].Entry,
+		Cluster: rows[0].Cluster,
+		Query:   rows[0].Query,
+		Hosts:   make([]DDLHostStatus, len(rows)),
+	}
+	for i := range rows {
+		status.Hosts[i] = rows[i].DDLHostStatus
+	}
+	return status, nil
+}
+
+// WaitDDL polls system.distributed_ddl_queue until all hosts have executed
+// the distributed DDL task and returns the status and the status error.
+// When ctx is done, it returns the last status with hosts that have not
+// executed the task yet, for example, inactive replicas.
+func (db *DB) WaitDDL(ctx context.Context, entry string) (*DDLStatus, error) {
+	for {
+		status, err := db.DDLStatus(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		if status.Done() {
+			return status, status.Err()
+		}
+
+		timer := time.NewTimer(ddlPollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return status, ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ch_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+func TestDDLStatus(t *testing.T) {
+	status := &ch.DDLStatus{
+		Entry: "query-0000000042",
+		Hosts: []ch.DDLHostStatus{
+			{Host: "ch1", Port: 9000, Status: "Finished"},
+			{Host: "ch2", Port: 9000, Status: "Inactive"},
+		},
+	}
+	require.False(t, status.Done())
+	require.NoError(t, status.Err())
+
+	status.Hosts[1].Status = "Finished"
+	status.Hosts[1].ExceptionCode = 57
+	status.Hosts[1].ExceptionText = "Code: 57. DB::Exception: Table already exists."
+	require.True(t, status.Done())
+
+	err := status.Err()
+	require.EqualError(t, err, "ch: DDL task query-0000000042 failed on 1 of 2 hosts: "+
+		"ch2:9000: Code: 57. DB::Exception: Table already exists.")
+
+	var ddlErr *ch.DDLError
+	require.True(t, errors.As(err, &ddlErr))
+	require.Equal(t, "ch2", ddlErr.Failed[0].Host)
+}
+
+func TestExecDDL(t *testing.T) {
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeQueryConn(
+				chfake.Column{Name: "host", CHType: "String", Values: []string{"ch1", "ch2"}},
+				chfake.Column{Name: "port", CHType: "UInt16", Values: []uint16{9000, 9000}},
+				chfake.Column{Name: "status", CHType: "Nullable(Int64)", Values: []*int64{new(int64), ptr(int64(57))}},
+				chfake.Column{Name: "error", CHType: "Nullable(String)", Values: []*string{nil, ptr("Table already exists.")}},
+				chfake.Column{Name: "num_hosts_remaining", CHType: "UInt64", Values: []uint64{2, 1}},
+				chfake.Column{Name: "num_hosts_active", CHType: "UInt64", Values: []uint64{0, 0}},
+			), nil
+		}),
+	)
+	defer db.Close()
+
+	status, err := db.ExecDDL(context.Background(), "DROP TABLE events ON CLUSTER ?", ch.Ident("events"))
+	require.EqualError(t, err, "ch: DDL task failed on 1 of 3 hosts: ch2:9000: Table already exists.")
+	require.Equal(t, []ch.DDLHostStatus{
+		{Host: "ch1", Port: 9000, Status: "Finished"},
+		{Host: "ch2", Port: 9000, Status: "Finished", ExceptionCode: 57, ExceptionText: "Table already exists."},
+	}, status.Hosts)
+	require.Equal(t, 1, status.Remaining)
+	require.False(t, status.Done())
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
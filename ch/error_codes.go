@@ -0,0 +1,133 @@
+package ch
+
+import (
+	"errors"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+// ClickHouse error codes returned in Error.Code.
+//
+// https://github.com/ClickHouse/ClickHouse/blob/master/src/Common/ErrorCodes.cpp
+const (
+	CodeCannotParseText             int32 = 6
+	CodeDuplicateColumn             int32 = 15
+	CodeNoSuchColumnInTable         int32 = 16
+	CodeCannotParseInputAssertion   int32 = 27
+	CodeCannotParseDate             int32 = 38
+	CodeCannotParseDateTime         int32 = 41
+	CodeIllegalColumn               int32 = 44
+	CodeUnknownIdentifier           int32 = 47
+	CodeTypeMismatch                int32 = 53
+	CodeTableAlreadyExists          int32 = 57
+	CodeUnknownTable                int32 = 60
+	CodeSyntaxError                 int32 = 62
+	CodeArgumentOutOfBound          int32 = 69
+	CodeCannotConvertType           int32 = 70
+	CodeCannotParseNumber           int32 = 72
+	CodeUnknownDatabase             int32 = 81
+	CodeUnknownSetting              int32 = 115
+	CodeIncorrectData               int32 = 117
+	CodeTooLargeStringSize          int32 = 131
+	CodeTimeoutExceeded             int32 = 159
+	CodeReadonly                    int32 = 164
+	CodeUnknownUser                 int32 = 192
+	CodeTooManySimultaneousQueries  int32 = 202
+	CodeSocketTimeout               int32 = 209
+	CodeNetworkError                int32 = 210
+	CodeMemoryLimitExceeded         int32 = 241
+	CodeTableIsReadOnly             int32 = 242
+	CodeTooManyParts                int32 = 252
+	CodeValueIsOutOfRangeOfDataType int32 = 321
+	CodeQueryWasCancelled           int32 = 394
+	CodeDecimalOverflow             int32 = 407
+	CodeViolatedConstraint          int32 = 469
+	CodeAuthenticationFailed        int32 = 516
+	CodeUnknownElementOfEnum        int32 = 691
+)
+
+// ErrorCode returns the code of the ClickHouse exception in the err chain
+// or 0 if err is not a server exception.
+func ErrorCode(err error) int32 {
+	var exc *Error
+	if errors.As(err, &exc) {
+		return exc.Code
+	}
+	return 0
+}
+
+// IsErrorCode reports whether err or one of the nested exceptions is
+// a ClickHouse exception with one of the codes.
+func IsErrorCode(err error, codes ...int32) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		exc, ok := err.(*Error)
+		if !ok {
+			continue
+		}
+		for _, code := range codes {
+			if exc.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsTableNotExist reports whether err is returned because a table does not exist.
+func IsTableNotExist(err error) bool {
+	return IsErrorCode(err, CodeUnknownTable)
+}
+
+// IsDatabaseNotExist reports whether err is returned because a database does not exist.
+func IsDatabaseNotExist(err error) bool {
+	return IsErrorCode(err, CodeUnknownDatabase)
+}
+
+// IsTableAlreadyExists reports whether err is returned because a table already exists.
+func IsTableAlreadyExists(err error) bool {
+	return IsErrorCode(err, CodeTableAlreadyExists)
+}
+
+// IsMemoryLimitExceeded reports whether the query exceeded a memory limit.
+func IsMemoryLimitExceeded(err error) bool {
+	return IsErrorCode(err, CodeMemoryLimitExceeded)
+}
+
+// IsReadonly reports whether the query was rejected because the user is in
+// readonly mode or the table is read-only, e.g. a replica that lost ZooKeeper.
+func IsReadonly(err error) bool {
+	return IsErrorCode(err, CodeReadonly, CodeTableIsReadOnly)
+}
+
+// IsTimeout reports whether the query exceeded a server-side time limit.
+func IsTimeout(err error) bool {
+	return IsErrorCode(err, CodeTimeoutExceeded, CodeSocketTimeout)
+}
+
+// IsValueError reports whether an insert was rejected because of an invalid
+// value, for example, a value that violates a constraint or does not fit
+// the column type, rather than because of the query or the connection.
+// Type mismatches, for example, a Go type that can't be converted to the column
+// type, are not value errors, because they reject every row.
+func IsValueError(err error) bool {
+	var enumErr *chschema.EnumValueError
+	var floatErr *chschema.NonFiniteFloatError
+	var sizeErr *chschema.FixedStringSizeError
+	if errors.As(err, &enumErr) || errors.As(err, &floatErr) || errors.As(err, &sizeErr) {
+		return true
+	}
+	return IsErrorCode(err,
+		CodeCannotParseText,
+		CodeCannotParseInputAssertion,
+		CodeCannotParseDate,
+		CodeCannotParseDateTime,
+		CodeArgumentOutOfBound,
+		CodeCannotParseNumber,
+		CodeIncorrectData,
+		CodeTooLargeStringSize,
+		CodeValueIsOutOfRangeOfDataType,
+		CodeDecimalOverflow,
+		CodeViolatedConstraint,
+		CodeUnknownElementOfEnum,
+	)
+}
@@ -0,0 +1,267 @@
+package ch_test
+
+import (
+	"bytes"
+	"io"
+	"net"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+// fakeServerConn returns a connection to a fake server that accepts the handshake
+// and replies to pings. Before replying to the first ping, it waits for ready.
+// After the pings, it closes the connection on the next packet, like servers
+// that close idle connections.
+func fakeServerConn(pings int, ready <-chan struct{}) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+
+		rd := chproto.NewReader(server)
+		wr := chproto.NewWriter(server)
+		if err := chfake.Handshake(rd, wr); err != nil {
+			return
+		}
+
+		for i := 0; i < pings; i++ {
+			if packet, err := rd.Uvarint(); err != nil || packet != chproto.ClientPing {
+				return
+			}
+			if i == 0 && ready != nil {
+				<-ready
+			}
+			wr.Uvarint(chproto.ServerPong)
+			if err := wr.Flush(); err != nil {
+				return
+			}
+		}
+
+		// Read the next packet and close the connection without replying.
+		_, _ = server.Read(make([]byte, 64<<10))
+	}()
+	return chfake.NoDeadlineConn{Conn: client}
+}
+
+// fakeQueryConn returns a connection to a fake server that accepts the handshake
+// and replies to the first query with a data block with the columns. Use it
+// with compression disabled.
+func fakeQueryConn(columns ...chfake.Column) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+
+		rd := chproto.NewReader(server)
+		wr := chproto.NewWriter(server)
+		if err := chfake.Handshake(rd, wr); err != nil {
+			return
+		}
+
+		// Read the query and the empty data block that ends it.
+		if _, err := server.Read(make([]byte, 64<<10)); err != nil {
+			return
+		}
+
+		if err := chfake.WriteBlock(wr, columns); err != nil {
+			return
+		}
+		wr.Uvarint(chproto.ServerEndOfStream)
+		if err := wr.Flush(); err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, server)
+	}()
+	return chfake.NoDeadlineConn{Conn: client}
+}
+
+// fakeInsertConn returns a connection to a fake server that accepts the
+// handshake and replies to every insert with the table schema returned by
+// schema. Blocks are rejected with the exception code returned by reject
+// unless it is nil or returns zero. Use it with compression disabled.
+func fakeInsertConn(schema func() []chfake.Column, reject func(numRow int) int32) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+
+		rd := chproto.NewReader(server)
+		wr := chproto.NewWriter(server)
+		if err := chfake.Handshake(rd, wr); err != nil {
+			return
+		}
+
+		// Replies are written by another goroutine, because net.Pipe is not
+		// buffered and the client may write while the server replies.
+		replies := make(chan []byte, 10)
+		defer close(replies)
+		go func() {
+			for b := range replies {
+				if _, err := server.Write(b); err != nil {
+					return
+				}
+			}
+		}()
+
+		buf := make([]byte, 64<<10)
+		for {
+			if _, err := server.Read(buf); err != nil {
+				return
+			}
+
+			var reply bytes.Buffer
+			out := chproto.NewWriter(&reply)
+			switch buf[0] {
+			case chproto.ClientQuery:
+				if err := chfake.WriteBlock(out, schema()); err != nil {
+					return
+				}
+			case chproto.ClientData:
+				// The number of rows follows the packet type, the table name,
+				// the block info, and the number of columns.
+				if reject != nil {
+					if code := reject(int(buf[11])); code != 0 {
+						chfake.WriteException(out, code)
+						break
+					}
+				}
+				out.Uvarint(chproto.ServerEndOfStream)
+			}
+			if err := out.Flush(); err != nil {
+				return
+			}
+			replies <- reply.Bytes()
+		}
+	}()
+	return chfake.NoDeadlineConn{Conn: client}
+}
+
+// fakeExceptionConn returns a connection to a fake server that accepts the
+// handshake and replies to every query with an exception with the code.
+// The query ids are sent to ids.
+func fakeExceptionConn(code int32, ids chan<- string) net.Conn {
+	return fakeNestedExceptionConn(ids, code)
+}
+
+// fakeNestedExceptionConn is like fakeExceptionConn, but the exception has
+// the nested exceptions with the rest of the codes, for example, the error
+// from the remote shard.
+func fakeNestedExceptionConn(ids chan<- string, code int32, nested ...int32) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+
+		rd := chproto.NewReader(server)
+		wr := chproto.NewWriter(server)
+		if err := chfake.Handshake(rd, wr); err != nil {
+			return
+		}
+
+		buf := make([]byte, 64<<10)
+		for {
+			n, err := server.Read(buf)
+			if err != nil {
+				return
+			}
+			// The packet type and the length of the short query id are
+			// encoded as single byte varints.
+			if n < 2 || buf[0] != chproto.ClientQuery || int(buf[1]) > n-2 {
+				continue
+			}
+			ids <- string(buf[2 : 2+buf[1]])
+
+			chfake.WriteException(wr, code, nested...)
+			if err := wr.Flush(); err != nil {
+				return
+			}
+		}
+	}()
+	return chfake.NoDeadlineConn{Conn: client}
+}
+
+// fakeExecConn returns a connection to a fake server that accepts the handshake
+// and replies to every query with the end of the stream. The query packets are
+// sent to queries.
+func fakeExecConn(queries chan<- []byte) net.Conn {
+	return fakeHangConn(queries, "")
+}
+
+// fakeHangConn is like fakeExecConn, but it never replies to the query that
+// contains hang and to the queries after it.
+func fakeHangConn(queries chan<- []byte, hang string) net.Conn {
+	client, server := net.Pipe()
+	go serveFakeExec(server, queries, hang)
+	return chfake.NoDeadlineConn{Conn: client}
+}
+
+// serveFakeExec serves the fake server of fakeHangConn on the connection.
+func serveFakeExec(server net.Conn, queries chan<- []byte, hang string) {
+	defer server.Close()
+
+	rd := chproto.NewReader(server)
+	wr := chproto.NewWriter(server)
+	if err := chfake.Handshake(rd, wr); err != nil {
+		return
+	}
+
+	// Replies are written by another goroutine, because net.Pipe is not
+	// buffered and large queries are read in several chunks.
+	replies := make(chan struct{}, 10)
+	defer close(replies)
+	go func() {
+		for range replies {
+			wr.Uvarint(chproto.ServerEndOfStream)
+			if err := wr.Flush(); err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 64<<10)
+	var hung bool
+	for {
+		n, err := server.Read(buf)
+		if err != nil {
+			return
+		}
+		if hung || buf[0] != chproto.ClientQuery {
+			continue
+		}
+		queries <- append([]byte(nil), buf[:n]...)
+
+		if hang != "" && bytes.Contains(buf[:n], []byte(hang)) {
+			hung = true // keep reading, e.g. the cancel packet
+			continue
+		}
+		replies <- struct{}{}
+	}
+}
+
+// fakeBlockingConn returns a connection to a fake server that accepts the
+// handshake and notifies received about the query. It does not reply and
+// closes the connection when release is closed.
+func fakeBlockingConn(received chan<- struct{}, release <-chan struct{}) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+
+		rd := chproto.NewReader(server)
+		wr := chproto.NewWriter(server)
+		if err := chfake.Handshake(rd, wr); err != nil {
+			return
+		}
+
+		if _, err := server.Read(make([]byte, 64<<10)); err != nil {
+			return
+		}
+		received <- struct{}{}
+		<-release
+	}()
+	return chfake.NoDeadlineConn{Conn: client}
+}
+
+// hostConn reports addr as the remote address.
+type hostConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c hostConn) RemoteAddr() net.Addr { return c.addr }
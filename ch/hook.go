@@ -51,6 +51,14 @@ func (db *DB) AddQueryHook(hook QueryHook) {
 	db.queryHooks = append(db.queryHooks, hook)
 }
 
+// WithQueryHook returns a copy of the DB with the query hook added.
+// Unlike AddQueryHook, it does not change the hooks of the DB.
+func (db *DB) WithQueryHook(hook QueryHook) *DB {
+	clone := db.clone()
+	clone.AddQueryHook(hook)
+	return clone
+}
+
 func (db *DB) beforeQuery(
 	ctx context.Context,
 	iquery Query,
@@ -58,6 +66,7 @@ func (db *DB) beforeQuery(
 	params []any,
 	model Model,
 ) (context.Context, *QueryEvent) {
+	ctx = contextWithQueryInfo(ctx)
 	if len(db.queryHooks) == 0 {
 		return ctx, nil
 	}
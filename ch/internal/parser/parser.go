@@ -82,6 +82,80 @@ func (p *Parser) ReadSep(sep byte) ([]byte, bool) {
 	return b, true
 }
 
+// ReadPlaceholder reads until the next ? placeholder skipping string literals,
+// quoted identifiers, and comments that may contain literal question marks.
+func (p *Parser) ReadPlaceholder() ([]byte, bool) {
+	start := p.i
+	for p.i < len(p.b) {
+		switch c := p.b[p.i]; c {
+		case '?':
+			b := p.b[start:p.i]
+			p.i++
+			return b, true
+		case '\'', '"', '`':
+			p.skipQuoted(c)
+		case '-':
+			if p.peekNext() == '-' {
+				p.skipLineComment()
+			} else {
+				p.i++
+			}
+		case '/':
+			if p.peekNext() == '*' {
+				p.skipBlockComment()
+			} else {
+				p.i++
+			}
+		default:
+			p.i++
+		}
+	}
+	return p.b[start:], false
+}
+
+func (p *Parser) peekNext() byte {
+	if p.i+1 < len(p.b) {
+		return p.b[p.i+1]
+	}
+	return 0
+}
+
+// skipQuoted skips the quoted string handling backslash escapes and doubled quotes.
+func (p *Parser) skipQuoted(quote byte) {
+	p.i++
+	for p.i < len(p.b) {
+		switch p.b[p.i] {
+		case '\\':
+			p.i += 2
+		case quote:
+			p.i++
+			if p.Peek() != quote {
+				return
+			}
+			p.i++
+		default:
+			p.i++
+		}
+	}
+	p.i = len(p.b)
+}
+
+func (p *Parser) skipLineComment() {
+	if ind := bytes.IndexByte(p.b[p.i:], '\n'); ind != -1 {
+		p.i += ind + 1
+		return
+	}
+	p.i = len(p.b)
+}
+
+func (p *Parser) skipBlockComment() {
+	if ind := bytes.Index(p.b[p.i+2:], []byte("*/")); ind != -1 {
+		p.i += 2 + ind + 2
+		return
+	}
+	p.i = len(p.b)
+}
+
 func (p *Parser) ReadIdentifier() (string, bool) {
 	if p.i < len(p.b) && p.b[p.i] == '(' {
 		s := p.i + 1
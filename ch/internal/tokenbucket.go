@@ -0,0 +1,41 @@
+package internal
+
+import "time"
+
+// TokenBucket holds up to one second of tokens. It is not safe for concurrent use.
+type TokenBucket struct {
+	Rate float64 // tokens per second, zero means unlimited
+
+	tokens float64 // negative when taken in advance
+	last   time.Time
+}
+
+// Take takes n tokens and returns how long to wait until they are available.
+func (b *TokenBucket) Take(now time.Time, n int) time.Duration {
+	if b.Rate <= 0 {
+		return 0
+	}
+
+	if b.last.IsZero() {
+		b.tokens = b.Rate
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * b.Rate
+		if b.tokens > b.Rate {
+			b.tokens = b.Rate
+		}
+	}
+	b.last = now
+
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.Rate * float64(time.Second))
+}
+
+// Return returns n tokens that were taken, but not used.
+func (b *TokenBucket) Return(n int) {
+	if b.Rate > 0 {
+		b.tokens += float64(n)
+	}
+}
@@ -0,0 +1,40 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/internal"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	b := internal.TokenBucket{Rate: 10}
+	require.Zero(t, b.Take(now, 10), "the bucket starts full")
+	require.Equal(t, 100*time.Millisecond, b.Take(now, 1))
+	require.Equal(t, time.Second, b.Take(now, 9))
+
+	// Tokens taken in advance are paid back over time.
+	now = now.Add(time.Second)
+	require.Equal(t, 100*time.Millisecond, b.Take(now, 1))
+
+	b.Return(1)
+	require.Zero(t, b.Take(now, 0))
+
+	// The bucket holds up to one second of tokens.
+	now = now.Add(time.Hour)
+	require.Zero(t, b.Take(now, 10))
+	require.Equal(t, 500*time.Millisecond, b.Take(now, 5))
+
+	// Blocks larger than the rate are allowed, but wait longer.
+	b = internal.TokenBucket{Rate: 10}
+	require.Equal(t, 2*time.Second, b.Take(now, 30))
+
+	unlimited := internal.TokenBucket{}
+	require.Zero(t, unlimited.Take(now, 1000))
+	unlimited.Return(1000)
+	require.Zero(t, unlimited.Take(now, 1000))
+}
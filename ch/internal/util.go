@@ -3,14 +3,17 @@ package internal
 import (
 	"context"
 	"log"
+	"math"
 	"math/rand"
 	"os"
 	"reflect"
 	"time"
+
+	"github.com/uptrace/go-clickhouse/internal/chlog"
 )
 
 var (
-	Logger     = log.New(os.Stderr, "ch: ", log.LstdFlags|log.Lshortfile)
+	Logger     = chlog.Logger
 	Warn       = log.New(os.Stderr, "WARN: ch: ", log.LstdFlags|log.Lshortfile)
 	Deprecated = log.New(os.Stderr, "DEPRECATED: ch: ", log.LstdFlags|log.Lshortfile)
 )
@@ -79,6 +82,17 @@ func MakeSliceNextElemFunc(v reflect.Value) func() reflect.Value {
 	}
 }
 
+// DeadlineSeconds returns the number of whole seconds from now until
+// the deadline rounded up, because max_execution_time can't be less
+// than a second. It returns 1 when the deadline has passed.
+func DeadlineSeconds(deadline, now time.Time) int64 {
+	secs := int64(math.Ceil(deadline.Sub(now).Seconds()))
+	if secs < 1 {
+		return 1
+	}
+	return secs
+}
+
 func RetryBackoff(retry int, minBackoff, maxBackoff time.Duration) time.Duration {
 	if retry < 0 {
 		panic("not reached")
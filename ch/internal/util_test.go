@@ -0,0 +1,32 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch/internal"
+)
+
+func TestDeadlineSeconds(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		timeout time.Duration
+		wanted  int64
+	}{
+		{time.Millisecond, 1},
+		{999 * time.Millisecond, 1},
+		{time.Second, 1},
+		{time.Second + time.Nanosecond, 2},
+		{1500 * time.Millisecond, 2},
+		{time.Minute, 60},
+		{0, 1},
+		{-time.Hour, 1},
+	}
+	for _, test := range tests {
+		got := internal.DeadlineSeconds(now.Add(test.timeout), now)
+		require.Equal(t, test.wanted, got, test.timeout)
+	}
+}
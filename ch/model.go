@@ -81,6 +81,12 @@ type TableModel interface {
 	Block(fields []*chschema.Field) *chschema.Block
 }
 
+// blockRangeModel is implemented by models that can be inserted in several blocks.
+type blockRangeModel interface {
+	numRow() int
+	blockRange(fields []*chschema.Field, start, end int) *chschema.Block
+}
+
 func newTableModel(db *DB, value any) (TableModel, error) {
 	if value, ok := value.(TableModel); ok {
 		return value, nil
@@ -47,10 +47,19 @@ func (m *sliceTableModel) ScanBlock(block *chschema.Block) error {
 }
 
 func (m *sliceTableModel) Block(fields []*chschema.Field) *chschema.Block {
-	sliceLen := m.slice.Len()
-	block := chschema.NewBlock(m.table, len(fields), sliceLen)
+	return m.blockRange(fields, 0, m.slice.Len())
+}
+
+var _ blockRangeModel = (*sliceTableModel)(nil)
+
+func (m *sliceTableModel) numRow() int {
+	return m.slice.Len()
+}
+
+func (m *sliceTableModel) blockRange(fields []*chschema.Field, start, end int) *chschema.Block {
+	block := chschema.NewBlock(m.table, len(fields), end-start)
 
-	if sliceLen == 0 {
+	if start == end {
 		return block
 	}
 
@@ -58,7 +67,7 @@ func (m *sliceTableModel) Block(fields []*chschema.Field) *chschema.Block {
 		_ = block.ColumnForField(field)
 	}
 
-	for i := 0; i < sliceLen; i++ {
+	for i := start; i < end; i++ {
 		elem := indirect(m.slice.Index(i))
 		for _, col := range block.Columns {
 			col.AppendValue(col.Field.Value(elem))
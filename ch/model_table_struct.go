@@ -60,17 +60,55 @@ func (m *structTableModel) ScanBlock(block *chschema.Block) error {
 	return scanRow(m.db, m.table, m.strct, block, 0)
 }
 
+// UnknownColumnPolicy is the policy for result columns that are not present
+// in the scanned model.
+type UnknownColumnPolicy int
+
+const (
+	// UnknownColumnsError fails the scan with *chschema.UnknownColumnError.
+	UnknownColumnsError UnknownColumnPolicy = iota
+	// UnknownColumnsIgnore skips the columns.
+	UnknownColumnsIgnore
+	// UnknownColumnsCollect stores the values in the model field with the extra
+	// option, e.g. Extra map[string]any `ch:",extra"`, keyed by column name.
+	// Models without the field fail the scan with *chschema.UnknownColumnError.
+	UnknownColumnsCollect
+)
+
+// unknownColumn handles the column of the block that does not have
+// a model field according to the policy.
+func unknownColumn(
+	db *DB, table *chschema.Table, strct reflect.Value, col *chschema.Column, value func() any,
+) error {
+	switch db.cfg.UnknownColumns {
+	case UnknownColumnsIgnore:
+		return nil
+	case UnknownColumnsCollect:
+		if table.ExtraField != nil {
+			extra := table.ExtraField.Value(strct)
+			if extra.IsNil() {
+				extra.Set(reflect.MakeMap(extra.Type()))
+			}
+			extra.SetMapIndex(reflect.ValueOf(col.Name), reflect.ValueOf(value()))
+			return nil
+		}
+	}
+	return &chschema.UnknownColumnError{
+		Table:  table,
+		Column: col.Name,
+	}
+}
+
 func scanRow(
 	db *DB, table *chschema.Table, strct reflect.Value, block *chschema.Block, row int,
 ) error {
 	for _, col := range block.Columns {
-		field := table.FieldMap[col.Name]
+		field, _ := table.LookupField(col.Name)
 		if field == nil {
-			if !db.flags.Has(discardUnknownColumnsFlag) {
-				return &chschema.UnknownColumnError{
-					Table:  table,
-					Column: col.Name,
-				}
+			if err := unknownColumn(db, table, strct, col, func() any {
+				return col.Index(row)
+			}); err != nil {
+				return err
 			}
 			continue
 		}
@@ -85,13 +123,12 @@ func scanRow(
 
 func scanColumns(db *DB, table *chschema.Table, strct reflect.Value, block *chschema.Block) error {
 	for _, col := range block.Columns {
-		field := table.FieldMap[col.Name]
+		field, _ := table.LookupField(col.Name)
 		if field == nil {
-			if !db.flags.Has(discardUnknownColumnsFlag) {
-				return &chschema.UnknownColumnError{
-					Table:  table,
-					Column: col.Name,
-				}
+			if err := unknownColumn(db, table, strct, col, func() any {
+				return appendExtraColumn(table, strct, col)
+			}); err != nil {
+				return err
 			}
 			continue
 		}
@@ -102,6 +139,29 @@ func scanColumns(db *DB, table *chschema.Table, strct reflect.Value, block *chsc
 	return nil
 }
 
+// appendExtraColumn appends the column values to the values collected
+// from the previous blocks.
+func appendExtraColumn(table *chschema.Table, strct reflect.Value, col *chschema.Column) any {
+	values := reflect.ValueOf(col.Value())
+	if table.ExtraField == nil {
+		return values.Interface()
+	}
+
+	extra := table.ExtraField.Value(strct)
+	if extra.IsNil() {
+		return values.Interface()
+	}
+	prev := extra.MapIndex(reflect.ValueOf(col.Name))
+	if !prev.IsValid() {
+		return values.Interface()
+	}
+	prev = reflect.ValueOf(prev.Interface())
+	if prev.Type() != values.Type() {
+		return values.Interface()
+	}
+	return reflect.AppendSlice(prev, values).Interface()
+}
+
 func (m *structTableModel) Block(fields []*chschema.Field) *chschema.Block {
 	block := chschema.NewBlock(m.table, len(fields), 1)
 
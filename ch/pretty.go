@@ -0,0 +1,129 @@
+package ch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+// PrettyPrint reads the remaining rows and writes them to w as an aligned table
+// with the column names and types in the header, like the Pretty format of
+// clickhouse-client. It is intended for debugging and REPL-like tools.
+func (rs *Rows) PrettyPrint(w io.Writer) error {
+	var rows [][]prettyCell
+	for rs.Next() {
+		values, err := rs.Values()
+		if err != nil {
+			return err
+		}
+		row := make([]prettyCell, len(values))
+		for i, v := range values {
+			row[i] = newPrettyCell(v)
+		}
+		rows = append(rows, row)
+	}
+	if err := rs.Err(); err != nil {
+		return err
+	}
+	return writePrettyTable(w, rs.block.Columns, rows)
+}
+
+type prettyCell struct {
+	s       string
+	numeric bool
+}
+
+var prettyEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+func newPrettyCell(v any) prettyCell {
+	switch v := v.(type) {
+	case nil:
+		return prettyCell{s: "NULL"}
+	case string:
+		return prettyCell{s: prettyEscaper.Replace(v)}
+	case []byte:
+		return prettyCell{s: prettyEscaper.Replace(string(v))}
+	case time.Time:
+		if v.Nanosecond() != 0 {
+			return prettyCell{s: v.Format("2006-01-02 15:04:05.999999999")}
+		}
+		return prettyCell{s: v.Format("2006-01-02 15:04:05")}
+	case int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64, chschema.Decimal:
+		return prettyCell{s: fmt.Sprint(v), numeric: true}
+	default:
+		return prettyCell{s: prettyEscaper.Replace(fmt.Sprint(v))}
+	}
+}
+
+func writePrettyTable(w io.Writer, columns []*chschema.Column, rows [][]prettyCell) error {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	widths := make([]int, len(columns))
+	for i, col := range columns {
+		widths[i] = utf8.RuneCountInString(col.Name)
+		if n := utf8.RuneCountInString(col.Type); n > widths[i] {
+			widths[i] = n
+		}
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := utf8.RuneCountInString(cell.s); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+
+	writeSep := func() {
+		for _, width := range widths {
+			bw.WriteByte('+')
+			bw.WriteString(strings.Repeat("-", width+2))
+		}
+		bw.WriteString("+\n")
+	}
+	writeRow := func(cells []prettyCell) {
+		for i, cell := range cells {
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell.s))
+			bw.WriteString("| ")
+			if cell.numeric {
+				bw.WriteString(pad)
+				bw.WriteString(cell.s)
+			} else {
+				bw.WriteString(cell.s)
+				bw.WriteString(pad)
+			}
+			bw.WriteByte(' ')
+		}
+		bw.WriteString("|\n")
+	}
+
+	names := make([]prettyCell, len(columns))
+	types := make([]prettyCell, len(columns))
+	for i, col := range columns {
+		names[i] = prettyCell{s: col.Name}
+		types[i] = prettyCell{s: col.Type}
+	}
+
+	writeSep()
+	writeRow(names)
+	writeRow(types)
+	writeSep()
+	if len(rows) > 0 {
+		for _, row := range rows {
+			writeRow(row)
+		}
+		writeSep()
+	}
+
+	return bw.Flush()
+}
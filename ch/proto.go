@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chpool"
 	"github.com/uptrace/go-clickhouse/ch/chproto"
 	"github.com/uptrace/go-clickhouse/ch/chschema"
+	"github.com/uptrace/go-clickhouse/ch/internal"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -29,6 +31,9 @@ type blockIter struct {
 	db *DB
 	cn *chpool.Conn
 
+	totals   *chschema.Block
+	extremes *chschema.Block
+
 	stickyErr error
 }
 
@@ -47,7 +52,11 @@ func (it *blockIter) Close() error {
 }
 
 func (it *blockIter) close() {
-	it.db.releaseConn(it.cn, it.stickyErr)
+	it.release(it.stickyErr)
+}
+
+func (it *blockIter) release(err error) {
+	it.db.releaseConn(it.cn, err)
 	it.cn = nil
 }
 
@@ -62,6 +71,10 @@ func (it *blockIter) Next(ctx context.Context, block *chschema.Block) bool {
 
 	ok, err := it.read(ctx, block)
 	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			it.cancel(block, ctxErr, err == ctxErr)
+			return false
+		}
 		it.stickyErr = err
 		it.close()
 		return false
@@ -74,9 +87,49 @@ func (it *blockIter) Next(ctx context.Context, block *chschema.Block) bool {
 	return true
 }
 
+// cancel asks the server to cancel the query after ctx is done.
+// When the stream was interrupted between packets, it also drains the remaining
+// packets so the connection can be reused. Otherwise, the connection is closed.
+func (it *blockIter) cancel(block *chschema.Block, ctxErr error, drain bool) {
+	it.stickyErr = ctxErr
+
+	err := it.cn.WithWriter(context.Background(), it.db.cfg.WriteTimeout, writeCancel)
+	if err == nil && drain {
+		err = it.drain(block)
+	} else if err == nil {
+		err = ctxErr
+	}
+	it.release(err)
+}
+
+func (it *blockIter) drain(block *chschema.Block) error {
+	ctx := context.Background()
+	for {
+		ok, err := it.read(ctx, block)
+		if err != nil {
+			// The server reports the cancellation with an exception
+			// that ends the stream.
+			var exc *Error
+			if errors.As(err, &exc) {
+				return nil
+			}
+			return err
+		}
+		if !ok {
+			return nil
+		}
+	}
+}
+
 func (it *blockIter) read(ctx context.Context, block *chschema.Block) (bool, error) {
 	rd := it.cn.Reader(ctx, it.db.cfg.ReadTimeout)
 	for {
+		// Stop on a packet boundary so the query can be canceled
+		// without losing the connection.
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+
 		packet, err := rd.Uvarint()
 		if err != nil {
 			return false, err
@@ -90,6 +143,16 @@ func (it *blockIter) read(ctx context.Context, block *chschema.Block) (bool, err
 			return true, nil
 		case chproto.ServerException:
 			return false, readException(rd)
+		case chproto.ServerTotals:
+			it.totals = chschema.NewBlock(block.Table, 0, 0)
+			if err := it.db.readBlock(rd, it.totals, true); err != nil {
+				return false, err
+			}
+		case chproto.ServerExtremes:
+			it.extremes = chschema.NewBlock(block.Table, 0, 0)
+			if err := it.db.readBlock(rd, it.extremes, true); err != nil {
+				return false, err
+			}
 		case chproto.ServerProgress:
 			if err := readProgress(it.cn, rd); err != nil {
 				return false, err
@@ -102,33 +165,41 @@ func (it *blockIter) read(ctx context.Context, block *chschema.Block) (bool, err
 			if err := readServerTableColumns(rd); err != nil {
 				return false, err
 			}
-		case chproto.ServerProfileEvents:
-			block := new(chschema.Block)
-			if err := it.db.readBlock(rd, block, false); err != nil {
-				return false, err
-			}
 		case chproto.ServerEndOfStream:
 			return false, nil
 		default:
-			return false, fmt.Errorf("ch: blockIter.Next: unexpected packet: %d", packet)
+			if ok, err := it.db.skipPacket(rd, packet); ok {
+				if err != nil {
+					return false, err
+				}
+				continue
+			}
+			return false, newUnexpectedPacketError("blockIter.Next", it.cn, packet)
 		}
 	}
 }
 
 func (db *DB) hello(ctx context.Context, cn *chpool.Conn) error {
-	err := cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
+	creds, err := db.credentials(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = cn.WithWriter(ctx, db.cfg.WriteTimeout, func(wr *chproto.Writer) {
 		wr.WriteByte(chproto.ClientHello)
 		writeClientInfo(wr)
 
 		wr.String(db.cfg.Database)
-		wr.String(db.cfg.User)
-		wr.String(db.cfg.Password)
+		wr.String(creds.User)
+		wr.String(creds.Password)
 	})
 	if err != nil {
 		return err
 	}
 
 	return cn.WithReader(ctx, db.cfg.ReadTimeout, func(rd *chproto.Reader) error {
+		rd.SetChecksumVerification(!db.cfg.SkipChecksumVerification)
+
 		packet, err := rd.Uvarint()
 		if err != nil {
 			return err
@@ -139,7 +210,7 @@ func (db *DB) hello(ctx context.Context, cn *chpool.Conn) error {
 		case chproto.ServerException:
 			return readException(rd)
 		default:
-			return fmt.Errorf("ch: hello: unexpected packet: %d", packet)
+			return newUnexpectedPacketError("hello", cn, packet)
 		}
 	})
 }
@@ -229,7 +300,7 @@ func writePing(wr *chproto.Writer) {
 	wr.WriteByte(chproto.ClientPing)
 }
 
-func readPong(rd *chproto.Reader) error {
+func readPong(cn *chpool.Conn, rd *chproto.Reader) error {
 	for {
 		packet, err := rd.Uvarint()
 		if err != nil {
@@ -244,14 +315,22 @@ func readPong(rd *chproto.Reader) error {
 		case chproto.ServerEndOfStream:
 			return nil
 		default:
-			return fmt.Errorf("ch: readPong: unexpected packet: %d", packet)
+			return newUnexpectedPacketError("readPong", cn, packet)
 		}
 	}
 }
 
 func (db *DB) writeQuery(ctx context.Context, cn *chpool.Conn, wr *chproto.Writer, query string) {
+	var queryID string
+	host := cn.RemoteAddr().String()
+	if info := queryInfoFromContext(ctx); info != nil {
+		queryID = info.nextID()
+		info.host = host
+	}
+	db.queries.add(cn, queryID, host)
+
 	wr.WriteByte(chproto.ClientQuery)
-	wr.String("") // query id
+	wr.String(queryID)
 
 	// TODO: use QuerySecondary - https://github.com/ClickHouse/ClickHouse/blob/master/dbms/src/Client/Connection.cpp#L388-L404
 	wr.WriteByte(chproto.QueryInitial)
@@ -297,13 +376,13 @@ func (db *DB) writeQuery(ctx context.Context, cn *chpool.Conn, wr *chproto.Write
 		wr.Uvarint(0) // number_of_current_replica
 	}
 
-	db.writeSettings(cn, wr)
+	db.writeSettings(ctx, cn, wr)
 
 	if cn.ServerInfo.Revision >= chproto.DBMS_MIN_REVISION_WITH_INTERSERVER_SECRET {
 		wr.String("")
 	}
 	wr.Uvarint(2) // state complete
-	wr.Bool(db.cfg.Compression)
+	wr.Bool(db.cfg.Compression.Enabled())
 	wr.String(query)
 }
 
@@ -314,36 +393,68 @@ func reverseBytes(b []byte) []byte {
 	return b
 }
 
-func (db *DB) writeSettings(cn *chpool.Conn, wr *chproto.Writer) {
-	for key, value := range db.cfg.QuerySettings {
-		wr.String(key)
+func (db *DB) writeSettings(ctx context.Context, cn *chpool.Conn, wr *chproto.Writer) {
+	ctxSettings := querySettings(ctx)
 
-		if cn.ServerInfo.Revision > chproto.DBMS_MIN_REVISION_WITH_SETTINGS_SERIALIZED_AS_STRINGS {
-			wr.Bool(true) // is_important
-			wr.String(fmt.Sprint(value))
-			continue
+	for key, value := range db.cfg.QuerySettings {
+		if _, ok := ctxSettings[key]; !ok {
+			writeSetting(cn, wr, key, value)
 		}
+	}
+	for key, value := range ctxSettings {
+		writeSetting(cn, wr, key, value)
+	}
 
-		switch value := value.(type) {
-		case string:
-			wr.String(value)
-		case int:
-			wr.Uvarint(uint64(value))
-		case int64:
-			wr.Uvarint(uint64(value))
-		case uint64:
-			wr.Uvarint(value)
-		case bool:
-			wr.Bool(value)
-		default:
-			panic(fmt.Errorf("%s setting has unsupported type: %T", key, value))
+	if deadline, ok := ctx.Deadline(); ok && db.cfg.ContextDeadline {
+		const key = "max_execution_time"
+		_, ok1 := db.cfg.QuerySettings[key]
+		_, ok2 := ctxSettings[key]
+		if !ok1 && !ok2 {
+			writeSetting(cn, wr, key, internal.DeadlineSeconds(deadline, time.Now()))
+			if mode := db.cfg.TimeoutOverflowMode; mode != "" {
+				writeSetting(cn, wr, "timeout_overflow_mode", string(mode))
+			}
 		}
+	}
 
+	if db.cfg.Compression == chproto.CompressionZSTD {
+		// Ask the server to compress blocks it sends using the same method.
+		const key = "network_compression_method"
+		_, ok1 := db.cfg.QuerySettings[key]
+		_, ok2 := ctxSettings[key]
+		if !ok1 && !ok2 {
+			writeSetting(cn, wr, key, "ZSTD")
+		}
 	}
 
 	wr.String("") // end of settings
 }
 
+func writeSetting(cn *chpool.Conn, wr *chproto.Writer, key string, value any) {
+	wr.String(key)
+
+	if cn.ServerInfo.Revision >= chproto.DBMS_MIN_REVISION_WITH_SETTINGS_SERIALIZED_AS_STRINGS {
+		wr.Uvarint(chproto.SettingFlagImportant)
+		wr.String(fmt.Sprint(value))
+		return
+	}
+
+	switch value := value.(type) {
+	case string:
+		wr.String(value)
+	case int:
+		wr.Uvarint(uint64(value))
+	case int64:
+		wr.Uvarint(uint64(value))
+	case uint64:
+		wr.Uvarint(value)
+	case bool:
+		wr.Bool(value)
+	default:
+		panic(fmt.Errorf("%s setting has unsupported type: %T", key, value))
+	}
+}
+
 var emptyBlock chschema.Block
 
 func (db *DB) writeBlock(ctx context.Context, wr *chproto.Writer, block *chschema.Block) {
@@ -353,6 +464,9 @@ func (db *DB) writeBlock(ctx context.Context, wr *chproto.Writer, block *chschem
 	wr.WriteByte(chproto.ClientData)
 	wr.String("")
 
+	wr.SetCompressionLevel(db.cfg.CompressionLevel)
+	wr.SetCompressionBlockSize(db.cfg.CompressionBlockSize)
+	wr.SetStrictChecksums(db.cfg.StrictChecksums)
 	wr.WithCompression(db.cfg.Compression, func() error {
 		writeBlockInfo(wr)
 		return block.WriteTo(wr)
@@ -369,7 +483,7 @@ func writeBlockInfo(wr *chproto.Writer) {
 	wr.Uvarint(0)
 }
 
-func (db *DB) readSampleBlock(rd *chproto.Reader) (*chschema.Block, error) {
+func (db *DB) readSampleBlock(cn *chpool.Conn, rd *chproto.Reader) (*chschema.Block, error) {
 	for {
 		packet, err := rd.Uvarint()
 		if err != nil {
@@ -390,7 +504,13 @@ func (db *DB) readSampleBlock(rd *chproto.Reader) (*chschema.Block, error) {
 		case chproto.ServerException:
 			return nil, readException(rd)
 		default:
-			return nil, fmt.Errorf("ch: readSampleBlock: unexpected packet: %d", packet)
+			if ok, err := db.skipPacket(rd, packet); ok {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, newUnexpectedPacketError("readSampleBlock", cn, packet)
 		}
 	}
 }
@@ -428,15 +548,16 @@ func (db *DB) readDataBlocks(cn *chpool.Conn, rd *chproto.Reader) (*result, erro
 			if err := readServerTableColumns(rd); err != nil {
 				return nil, err
 			}
-		case chproto.ServerProfileEvents:
-			block := new(chschema.Block)
-			if err := db.readBlock(rd, block, false); err != nil {
-				return nil, err
-			}
 		case chproto.ServerEndOfStream:
 			return res, nil
 		default:
-			return nil, fmt.Errorf("ch: readDataBlocks: unexpected packet: %d", packet)
+			if ok, err := db.skipPacket(rd, packet); ok {
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+			return nil, newUnexpectedPacketError("readDataBlocks", cn, packet)
 		}
 	}
 }
@@ -469,16 +590,46 @@ func readPacket(cn *chpool.Conn, rd *chproto.Reader) (*result, error) {
 	case chproto.ServerEndOfStream:
 		return res, nil
 	default:
-		return nil, fmt.Errorf("ch: readPacket: unexpected packet: %d", packet)
+		return nil, newUnexpectedPacketError("readPacket", cn, packet)
 	}
 }
 
+// skipPacket consumes packets that carry no data for the client, but that
+// newer server revisions may send in the middle of a response. It returns
+// false when the packet is unknown and the stream can't be resynced.
+func (db *DB) skipPacket(rd *chproto.Reader, packet uint64) (bool, error) {
+	switch packet {
+	case chproto.ServerLog, chproto.ServerProfileEvents:
+		block := new(chschema.Block)
+		return true, db.readBlock(rd, block, false)
+	case chproto.ServerPartUUIDs:
+		return true, readPartUUIDs(rd)
+	default:
+		return false, nil
+	}
+}
+
+func readPartUUIDs(rd *chproto.Reader) error {
+	n, err := rd.Uvarint()
+	if err != nil {
+		return err
+	}
+
+	var uuid [16]byte
+	for i := 0; i < int(n); i++ {
+		if err := rd.UUID(uuid[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (db *DB) readBlock(rd *chproto.Reader, block *chschema.Block, compressible bool) error {
 	if _, err := rd.String(); err != nil {
 		return err
 	}
 
-	return rd.WithCompression(compressible && db.cfg.Compression, func() error {
+	return rd.WithCompression(compressible && db.cfg.Compression.Enabled(), func() error {
 		if err := readBlockInfo(rd); err != nil {
 			return err
 		}
@@ -513,6 +664,11 @@ func (db *DB) readBlock(rd *chproto.Reader, block *chschema.Block, compressible
 			}
 
 			col := block.Column(colName, colType)
+			if db.cfg.NonFiniteFloatsAsNull && chschema.IsFloatType(colType) {
+				if _, ok := col.Columnar.(*chschema.NonFiniteNullColumn); !ok {
+					col.Columnar = &chschema.NonFiniteNullColumn{Columnar: col.Columnar}
+				}
+			}
 			if err := col.ReadFrom(rd, int(numRow)); err != nil {
 				return err
 			}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 	"github.com/uptrace/go-clickhouse/ch/internal"
@@ -28,6 +30,9 @@ type baseQuery struct {
 	tables         []chschema.QueryWithArgs
 	columns        []chschema.QueryWithArgs
 	settings       []chschema.QueryWithArgs
+	timeout        time.Duration
+	cluster        string
+	identFolder    func(string) string
 
 	flags internal.Flag
 }
@@ -67,6 +72,16 @@ func (q *baseQuery) GetTableName() string {
 	return ""
 }
 
+// formatQuery returns the query formatted with the formatter
+// or the error message when the query is invalid.
+func formatQuery(fmter chschema.Formatter, q chschema.QueryAppender) string {
+	b, err := q.AppendQuery(fmter, nil)
+	if err != nil {
+		return err.Error()
+	}
+	return internal.String(b)
+}
+
 func (q *baseQuery) setConn(db *DB) {
 	q.db = db
 }
@@ -117,6 +132,8 @@ func (q *baseQuery) query(ctx context.Context, model Model, query string) (*resu
 	if err := blocks.Err(); err != nil {
 		return nil, err
 	}
+	res.totals = blocks.totals
+	res.extremes = blocks.extremes
 
 	if model, ok := model.(AfterScanRowHook); ok {
 		if err := model.AfterScanRow(ctx); err != nil {
@@ -132,10 +149,16 @@ func (q *baseQuery) exec(
 	iquery Query,
 	query string,
 ) (sql.Result, error) {
+	ctx, cancel := q.db.withQueryTimeout(ctx, q.timeout)
+	defer cancel()
+
 	ctx, event := q.db.beforeQuery(ctx, iquery, query, nil, q.tableModel)
 	res, err := q.db.exec(ctx, query)
 	q.db.afterQuery(ctx, event, res, err)
-	return res, err
+	if err != nil {
+		return nil, q.db.queryError(ctx, iquery, query, nil, err)
+	}
+	return res, nil
 }
 
 //------------------------------------------------------------------------------
@@ -144,21 +167,45 @@ func (q *baseQuery) AppendNamedArg(fmter chschema.Formatter, b []byte, name stri
 	return b, false
 }
 
-func appendColumns(b []byte, table Safe, fields []*chschema.Field) []byte {
+func appendColumns(fmter chschema.Formatter, b []byte, fields []*chschema.Field) []byte {
 	for i, f := range fields {
 		if i > 0 {
 			b = append(b, ", "...)
 		}
-
-		if len(table) > 0 {
-			b = append(b, table...)
-			b = append(b, '.')
-		}
-		b = append(b, f.Column...)
+		b = f.AppendColumn(fmter, b)
 	}
 	return b
 }
 
+// withIdentFolder returns the formatter that folds identifiers using
+// the func configured with IdentFolder.
+func (q *baseQuery) withIdentFolder(fmter chschema.Formatter) chschema.Formatter {
+	if q.identFolder == nil {
+		return fmter
+	}
+	return fmter.WithIdentFolder(q.identFolder)
+}
+
+// tableName, tableAlias, and fieldColumn are query args that append model
+// identifiers using the query formatter, which can fold their case.
+type tableName struct{ table *chschema.Table }
+
+func (n tableName) AppendQuery(fmter chschema.Formatter, b []byte) ([]byte, error) {
+	return n.table.AppendName(fmter, b), nil
+}
+
+type tableAlias struct{ table *chschema.Table }
+
+func (a tableAlias) AppendQuery(fmter chschema.Formatter, b []byte) ([]byte, error) {
+	return a.table.AppendAlias(fmter, b), nil
+}
+
+type fieldColumn struct{ field *chschema.Field }
+
+func (c fieldColumn) AppendQuery(fmter chschema.Formatter, b []byte) ([]byte, error) {
+	return c.field.AppendColumn(fmter, b), nil
+}
+
 func formatterWithModel(
 	fmter chschema.Formatter, model chschema.NamedArgAppender,
 ) chschema.Formatter {
@@ -202,10 +249,10 @@ func (q *baseQuery) _appendTables(
 				return nil, err
 			}
 		} else {
-			b = fmter.AppendQuery(b, string(q.table.CHName))
+			b = q.table.AppendName(fmter, b)
 			if withAlias && q.table.CHAlias != q.table.CHName {
 				b = append(b, " AS "...)
-				b = append(b, q.table.CHAlias...)
+				b = q.table.AppendAlias(fmter, b)
 			}
 		}
 	}
@@ -241,10 +288,10 @@ func (q *baseQuery) _appendFirstTable(
 	}
 
 	if q.table != nil {
-		b = fmter.AppendQuery(b, string(q.table.CHName))
+		b = q.table.AppendName(fmter, b)
 		if withAlias {
 			b = append(b, " AS "...)
-			b = append(b, q.table.CHAlias...)
+			b = q.table.AppendAlias(fmter, b)
 		}
 		return b, nil
 	}
@@ -340,10 +387,59 @@ func (q *baseQuery) getFields() ([]*chschema.Field, error) {
 	return fields, nil
 }
 
+// appendOnCluster appends ON CLUSTER with the query cluster or the cluster
+// configured for the DB. See DB.Cluster.
+func (q *baseQuery) appendOnCluster(fmter chschema.Formatter, b []byte) []byte {
+	cluster := q.cluster
+	if cluster == "" {
+		cluster = q.db.cachedCluster()
+	}
+	if cluster == "" {
+		return b
+	}
+	b = append(b, " ON CLUSTER "...)
+	return fmter.AppendIdent(b, cluster)
+}
+
+// resolveCluster reads the cluster name of the DB before the query is formatted.
+func (q *baseQuery) resolveCluster(ctx context.Context) error {
+	if q.cluster != "" {
+		return nil
+	}
+	_, err := q.db.Cluster(ctx)
+	return err
+}
+
 func (q *baseQuery) appendSettings(fmter chschema.Formatter, b []byte) (_ []byte, err error) {
-	if len(q.settings) > 0 {
+	return appendSettings(fmter, b, q.settings)
+}
+
+// appendSettingsWithDefaults is like appendSettings, but also appends the model
+// default settings that are not overridden by the query.
+func (q *baseQuery) appendSettingsWithDefaults(
+	fmter chschema.Formatter, b []byte,
+) (_ []byte, err error) {
+	if q.table == nil || len(q.table.CHSettings) == 0 {
+		return q.appendSettings(fmter, b)
+	}
+
+	settings := make([]chschema.QueryWithArgs, 0, len(q.table.CHSettings)+len(q.settings))
+	for _, s := range q.table.CHSettings {
+		if !hasSetting(q.settings, settingName(s)) {
+			settings = append(settings, chschema.SafeQuery(s, nil))
+		}
+	}
+	settings = append(settings, q.settings...)
+
+	return appendSettings(fmter, b, settings)
+}
+
+func appendSettings(
+	fmter chschema.Formatter, b []byte, settings []chschema.QueryWithArgs,
+) (_ []byte, err error) {
+	if len(settings) > 0 {
 		b = append(b, " SETTINGS "...)
-		for i, opt := range q.settings {
+		for i, opt := range settings {
 			if i > 0 {
 				b = append(b, ", "...)
 			}
@@ -356,6 +452,22 @@ func (q *baseQuery) appendSettings(fmter chschema.Formatter, b []byte) (_ []byte
 	return b, nil
 }
 
+func hasSetting(settings []chschema.QueryWithArgs, name string) bool {
+	for _, s := range settings {
+		if settingName(s.Query) == name {
+			return true
+		}
+	}
+	return false
+}
+
+func settingName(s string) string {
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
 //------------------------------------------------------------------------------
 
 type WhereQuery struct {
@@ -413,6 +525,35 @@ func (q *whereBaseQuery) appendWhere(fmter chschema.Formatter, b []byte) (_ []by
 	return b, nil
 }
 
+// appendWhereWithFilter appends the where conditions AND the filter.
+func (q *whereBaseQuery) appendWhereWithFilter(
+	fmter chschema.Formatter, b []byte, filter chschema.QueryWithArgs,
+) (_ []byte, err error) {
+	if filter.IsZero() {
+		return q.appendWhere(fmter, b)
+	}
+
+	b = append(b, " WHERE "...)
+
+	if len(q.where) > 0 {
+		b = append(b, '(')
+		b, err = appendWhere(fmter, b, q.where)
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, ") AND "...)
+	}
+
+	b = append(b, '(')
+	b, err = filter.AppendQuery(fmter, b)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b, ')')
+
+	return b, nil
+}
+
 func appendWhere(
 	fmter chschema.Formatter, b []byte, where []chschema.QueryWithSep,
 ) (_ []byte, err error) {
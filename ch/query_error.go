@@ -0,0 +1,192 @@
+package ch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// QueryError wraps errors returned by queries with the information required
+// to find the query in the server logs and system.query_log.
+type QueryError struct {
+	Query     string // formatted query, see WithErrorQueryLength and WithErrorQueryRedactor
+	Args      []any  // args of raw queries, see WithErrorQueryArgs
+	Operation string // for example, SELECT or INSERT
+	Table     string // table of the query builder, empty for raw queries
+	QueryID   string
+	Host      string // address of the server that executed the query
+	Err       error
+}
+
+func (err *QueryError) Error() string {
+	s := fmt.Sprintf("%s (", err.Err)
+	if err.Operation != "" {
+		s += "operation=" + err.Operation + " "
+	}
+	if err.Table != "" {
+		s += "table=" + err.Table + " "
+	}
+	s += fmt.Sprintf("query_id=%s host=%s)", err.QueryID, err.Host)
+	if err.Query != "" {
+		s += ": " + err.Query
+	}
+	return s
+}
+
+func (err *QueryError) Unwrap() error {
+	return err.Err
+}
+
+// WithErrorQueryLength limits the length of the query included in QueryError.
+// Longer queries are truncated and zero omits the query. Default is 4096.
+func WithErrorQueryLength(n int) Option {
+	return func(db *DB) {
+		db.cfg.ErrorQueryLength = n
+	}
+}
+
+// WithErrorQueryRedactor configures a func that removes sensitive data,
+// for example, string literals, from the query included in QueryError.
+// The query is redacted before it is truncated and the args are omitted.
+func WithErrorQueryRedactor(fn func(query string) string) Option {
+	return func(db *DB) {
+		db.cfg.ErrorQueryRedactor = fn
+	}
+}
+
+// WithErrorQueryArgs includes the args of raw queries in QueryError.
+// The args are not truncated like the query, so they are omitted by default
+// and when the query is redacted.
+func WithErrorQueryArgs(on bool) Option {
+	return func(db *DB) {
+		db.cfg.ErrorQueryArgs = on
+	}
+}
+
+func (db *DB) queryError(
+	ctx context.Context, iquery Query, query string, args []any, err error,
+) error {
+	if err == nil {
+		return nil
+	}
+
+	info := queryInfoFromContext(ctx)
+	if info == nil {
+		return err
+	}
+
+	queryErr := &QueryError{
+		QueryID: info.id,
+		Host:    info.host,
+		Err:     err,
+	}
+	if iquery != nil {
+		queryErr.Operation = iquery.Operation()
+		queryErr.Table = iquery.GetTableName()
+	} else {
+		queryErr.Operation = queryOperation(query)
+	}
+
+	if db.cfg.ErrorQueryRedactor != nil {
+		query = db.cfg.ErrorQueryRedactor(query)
+	} else if db.cfg.ErrorQueryArgs {
+		queryErr.Args = args
+	}
+	if n := db.cfg.ErrorQueryLength; len(query) > n {
+		if n > 0 {
+			// Don't split multi-byte characters.
+			for n > 0 && !utf8.RuneStart(query[n]) {
+				n--
+			}
+			query = query[:n] + "..."
+		} else {
+			query = ""
+		}
+	}
+
+	queryErr.Query = query
+	return queryErr
+}
+
+//------------------------------------------------------------------------------
+
+type queryIDCtxKey struct{}
+
+// ContextWithQueryID returns a copy of ctx with the query id that is sent
+// with every query executed using that context, so use a new context for
+// each query. By default a random query id is generated for each query.
+// Retries are sent with the attempt number as a suffix, for example,
+// "my-id-1", and additional queries executed by helpers such as ScanAndCount
+// use the query id with a suffix, for example, "my-id-count".
+func ContextWithQueryID(ctx context.Context, queryID string) context.Context {
+	return context.WithValue(ctx, queryIDCtxKey{}, queryID)
+}
+
+// contextWithDerivedQueryID adds the suffix to the query id set by the user
+// so the server does not reject concurrent queries with the same id.
+func contextWithDerivedQueryID(ctx context.Context, suffix string) context.Context {
+	id, _ := ctx.Value(queryIDCtxKey{}).(string)
+	if id == "" {
+		return ctx
+	}
+	return ContextWithQueryID(ctx, id+"-"+suffix)
+}
+
+type queryInfoCtxKey struct{}
+
+// queryInfo is filled while the query is executed.
+type queryInfo struct {
+	baseID string
+	id     string
+	host   string
+	sent   int // number of times the query was sent
+}
+
+// nextID returns the id for the next attempt to send the query. The server
+// rejects a query with the id of a query that is still running, for example,
+// after a timeout, so retries get a suffix with the attempt number.
+func (info *queryInfo) nextID() string {
+	if info.sent > 0 {
+		info.id = info.baseID + "-" + strconv.Itoa(info.sent)
+	}
+	info.sent++
+	return info.id
+}
+
+func contextWithQueryInfo(ctx context.Context) context.Context {
+	id, _ := ctx.Value(queryIDCtxKey{}).(string)
+	if id == "" {
+		id = newQueryID()
+	}
+	return context.WithValue(ctx, queryInfoCtxKey{}, &queryInfo{baseID: id, id: id})
+}
+
+func queryInfoFromContext(ctx context.Context) *queryInfo {
+	info, _ := ctx.Value(queryInfoCtxKey{}).(*queryInfo)
+	return info
+}
+
+// newQueryID returns a random UUID.
+func newQueryID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"reflect"
+	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 	"github.com/uptrace/go-clickhouse/ch/internal"
@@ -11,8 +14,29 @@ import (
 
 type InsertQuery struct {
 	whereBaseQuery
+
+	beforeAppend []BeforeAppendModelFunc
+	blockSize    int
+	dedupToken   string
+	deadLetter   DeadLetterFunc
+
+	asyncInsert bool
+	asyncWait   bool
+	replace     bool
+	allTenants  bool
 }
 
+// BeforeAppendModelFunc is called for each row before it is appended to
+// the insert block. row is the index of the row in the model slice and
+// strct is a pointer to the row struct. The func may modify the row or
+// return an error to reject the whole insert.
+type BeforeAppendModelFunc func(ctx context.Context, row int, strct any) error
+
+// DeadLetterFunc is called for each row that was rejected because of an
+// invalid value. row is the index of the row in the model slice, strct is
+// a pointer to the row struct, and err is the error returned for the row.
+type DeadLetterFunc func(ctx context.Context, row int, strct any, err error)
+
 var _ Query = (*InsertQuery)(nil)
 
 func NewInsertQuery(db *DB) *InsertQuery {
@@ -25,6 +49,9 @@ func NewInsertQuery(db *DB) *InsertQuery {
 	}
 }
 
+// Model sets the struct or slice of structs to insert. The query lists
+// the model columns explicitly, for example, INSERT INTO t ("id", "name"),
+// so table columns without model fields are filled using the server defaults.
 func (q *InsertQuery) Model(model any) *InsertQuery {
 	q.setTableModel(model)
 	return q
@@ -49,11 +76,93 @@ func (q *InsertQuery) ModelTableExpr(query string, args ...any) *InsertQuery {
 	return q
 }
 
+// AllTenants disables tenant scoping for the query. See ContextWithTenant.
+func (q *InsertQuery) AllTenants() *InsertQuery {
+	q.allTenants = true
+	return q
+}
+
 func (q *InsertQuery) Setting(query string, args ...any) *InsertQuery {
 	q.settings = append(q.settings, chschema.SafeQuery(query, args))
 	return q
 }
 
+// Timeout sets the query timeout overriding DefaultQueryTimeout.
+// Negative timeout disables DefaultQueryTimeout for the query.
+func (q *InsertQuery) Timeout(timeout time.Duration) *InsertQuery {
+	q.timeout = timeout
+	return q
+}
+
+// IdentFolder configures a func that folds the case of identifiers generated
+// for the query overriding WithIdentFolder, for example, strings.ToLower.
+func (q *InsertQuery) IdentFolder(fn func(string) string) *InsertQuery {
+	q.identFolder = fn
+	return q
+}
+
+// DeduplicationToken sets insert_deduplication_token so the server skips the
+// insert when the data with the same token was already inserted. It makes
+// inserts safe to retry, so inserts with a token are retried according to
+// the retry policy like SELECT queries.
+//
+// Deduplication works for Replicated*MergeTree tables and for *MergeTree tables
+// with non_replicated_deduplication_window > 0. The server keeps a limited number
+// of recent tokens (replicated_deduplication_window) so retries must happen soon
+// after the original insert. With BlockSize each block gets the token with the
+// block index suffix, e.g. token_0, token_1.
+func (q *InsertQuery) DeduplicationToken(token string) *InsertQuery {
+	q.dedupToken = token
+	return q
+}
+
+// AsyncInsert makes the server buffer the insert and write it together with
+// other inserts, which is more efficient for frequent small inserts.
+// With wait the query returns after the buffer is flushed to the table
+// so errors are reported. Otherwise the query returns as soon as the data
+// is buffered and RowsAffected reports 0 because the rows are not inserted yet.
+func (q *InsertQuery) AsyncInsert(wait bool) *InsertQuery {
+	q.asyncInsert = true
+	q.asyncWait = wait
+	return q
+}
+
+// BlockSize splits a slice model into blocks of at most n rows. Each block is
+// inserted with a separate INSERT query so the blocks before a failed block are
+// stored by the server and the returned *InsertError tells where to resume.
+func (q *InsertQuery) BlockSize(n int) *InsertQuery {
+	q.blockSize = n
+	return q
+}
+
+// DeadLetter enables the salvage mode for slice models. When a block is
+// rejected because of an invalid value (see IsValueError), the block is split
+// in halves that are inserted separately until the rejected rows are isolated.
+// The rejected rows are passed to fn and the rest of the rows are inserted.
+// Other errors are returned as *InsertError.
+//
+// Isolating k rejected rows in a block of n rows takes about 2*k*log2(n)
+// extra inserts, so the mode is intended for rare bad rows. Use BlockSize
+// to limit the cost. RowsAffected reports only the inserted rows.
+func (q *InsertQuery) DeadLetter(fn DeadLetterFunc) *InsertQuery {
+	q.deadLetter = fn
+	return q
+}
+
+// Replace inserts the rows as new versions of the rows with the same sorting key.
+// It requires a ReplacingMergeTree table and a model field with the version
+// option, for example, `ch:",version"`, which is set to the current time
+// for every row so the inserted rows replace the existing ones. time.Time
+// versions should use DateTime64(9) so inserts in the same second are ordered.
+// int64 and uint64 versions are set to Unix time in nanoseconds.
+//
+// The server removes the replaced rows during merges, so queries that must not
+// see them should use SelectQuery.Final or aggregate using argMax.
+func (q *InsertQuery) Replace() *InsertQuery {
+	q.replace = true
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *InsertQuery) Column(columns ...string) *InsertQuery {
@@ -90,6 +199,14 @@ func (q *InsertQuery) WhereGroup(sep string, fn func(*WhereQuery)) *InsertQuery
 	return q
 }
 
+// BeforeAppendModel adds a func that validates or normalizes each row before
+// it is encoded. The funcs are called after the model's own
+// BeforeAppendModelHook, if any. Errors are returned as *RowError.
+func (q *InsertQuery) BeforeAppendModel(fn BeforeAppendModelFunc) *InsertQuery {
+	q.beforeAppend = append(q.beforeAppend, fn)
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *InsertQuery) Operation() string {
@@ -98,10 +215,21 @@ func (q *InsertQuery) Operation() string {
 
 var _ chschema.QueryAppender = (*InsertQuery)(nil)
 
+// String returns the query with the arguments interpolated.
+func (q *InsertQuery) String() string {
+	return formatQuery(q.db.fmter, q)
+}
+
+// RedactedString is like String, but replaces values of the arguments with ?.
+func (q *InsertQuery) RedactedString() string {
+	return formatQuery(q.db.fmter.WithRedactedArgs(), q)
+}
+
 func (q *InsertQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []byte, err error) {
 	if q.err != nil {
 		return nil, q.err
 	}
+	fmter = q.withIdentFolder(fmter)
 
 	b = append(b, "INSERT INTO "...)
 	b, err = q.appendInsertTable(fmter, b)
@@ -115,7 +243,7 @@ func (q *InsertQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []byte,
 	}
 	if len(fields) > 0 {
 		b = append(b, " ("...)
-		b = appendColumns(b, "", fields)
+		b = appendColumns(fmter, b, fields)
 		b = append(b, ")"...)
 	}
 
@@ -146,7 +274,7 @@ func (q *InsertQuery) appendValues(
 		return nil, err
 	}
 	if len(fields) > 0 {
-		b = appendColumns(b, "", fields)
+		b = appendColumns(fmter, b, fields)
 	} else {
 		b = append(b, "*"...)
 	}
@@ -175,7 +303,7 @@ func (q *InsertQuery) appendInsertTable(fmter chschema.Formatter, b []byte) ([]b
 	}
 
 	if q.table != nil {
-		return fmter.AppendQuery(b, string(q.table.CHInsertName)), nil
+		return q.table.AppendInsertName(fmter, b), nil
 	}
 	if len(q.tables) > 0 {
 		return q.tables[0].AppendQuery(fmter, b)
@@ -191,20 +319,198 @@ func (q *InsertQuery) Exec(ctx context.Context) (sql.Result, error) {
 	}
 	query := internal.String(queryBytes)
 
+	if err := q.beforeAppendModel(ctx); err != nil {
+		return nil, err
+	}
+	if err := q.setTenant(ctx); err != nil {
+		return nil, err
+	}
+	if q.replace {
+		if err := q.setVersion(); err != nil {
+			return nil, err
+		}
+	}
+
+	if q.asyncInsert {
+		wait := 0
+		if q.asyncWait {
+			wait = 1
+		}
+		ctx = ContextWithQuerySettings(ctx, map[string]any{
+			"async_insert":          1,
+			"wait_for_async_insert": wait,
+		})
+	}
+
+	ctx, cancel := q.db.withQueryTimeout(ctx, q.timeout)
+	defer cancel()
+
 	ctx, evt := q.db.beforeQuery(ctx, q, query, nil, q.tableModel)
 	var res *result
 
 	if q.tableModel != nil {
-		fields, err := q.getFields()
+		var fields []*chschema.Field
+		fields, err = q.getFields()
 		if err != nil {
 			return nil, err
 		}
-		res, err = q.db.insert(ctx, q.tableModel, query, fields)
+		opts := insertOptions{
+			blockSize:  q.blockSize,
+			dedupToken: q.dedupToken,
+		}
+		if q.deadLetter != nil {
+			opts.deadLetter = func(row int, err error) {
+				q.deadLetter(ctx, row, q.rowStruct(row), err)
+			}
+		}
+		res, err = q.db.insert(ctx, q.tableModel, query, fields, opts)
+	} else if q.dedupToken != "" {
+		execCtx := ContextWithQuerySettings(ctx, map[string]any{
+			"insert_deduplication_token": q.dedupToken,
+		})
+		res, err = q.db.execRetry(execCtx, query, true)
 	} else {
 		res, err = q.db.exec(ctx, query)
 	}
 
+	if res != nil && q.asyncInsert && !q.asyncWait {
+		// The rows are buffered by the server and inserted later.
+		res.affected = 0
+	}
+
 	q.db.afterQuery(ctx, evt, res, err)
+	if err != nil {
+		return nil, q.db.queryError(ctx, q, query, nil, err)
+	}
+
+	return res, nil
+}
+
+func (q *InsertQuery) beforeAppendModel(ctx context.Context) error {
+	if q.tableModel == nil {
+		return nil
+	}
+	if len(q.beforeAppend) == 0 && !q.tableModel.Table().HasBeforeAppendModelHook() {
+		return nil
+	}
+
+	return q.forEachRow(func(row int, strct reflect.Value) error {
+		return q.callBeforeAppend(ctx, row, strct)
+	})
+}
 
-	return res, err
+// forEachRow calls fn with a pointer to each row struct of the model.
+func (q *InsertQuery) forEachRow(fn func(row int, strct reflect.Value) error) error {
+	switch model := q.tableModel.(type) {
+	case *structTableModel:
+		return fn(0, model.strct.Addr())
+	case *sliceTableModel:
+		sliceLen := model.slice.Len()
+		for i := 0; i < sliceLen; i++ {
+			if err := fn(i, sliceElemAddr(model.slice, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rowStruct returns a pointer to the row struct of the slice model.
+func (q *InsertQuery) rowStruct(row int) any {
+	if model, ok := q.tableModel.(*sliceTableModel); ok {
+		return sliceElemAddr(model.slice, row).Interface()
+	}
+	return nil
+}
+
+func sliceElemAddr(slice reflect.Value, i int) reflect.Value {
+	elem := slice.Index(i)
+	if elem.Kind() == reflect.Interface {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Ptr {
+		elem = elem.Addr()
+	}
+	return elem
+}
+
+func (q *InsertQuery) setVersion() error {
+	if q.table == nil || q.table.VersionField == nil {
+		return errors.New("ch: Replace requires a model field with the version option")
+	}
+	if q.table.IsColumnar() {
+		return errors.New("ch: Replace does not support columnar models")
+	}
+
+	now := time.Now()
+	field := q.table.VersionField
+	return q.forEachRow(func(row int, strct reflect.Value) error {
+		v := field.Value(strct.Elem())
+		switch v.Kind() {
+		case reflect.Int64:
+			v.SetInt(now.UnixNano())
+		case reflect.Uint64:
+			v.SetUint(uint64(now.UnixNano()))
+		default:
+			v.Set(reflect.ValueOf(now))
+		}
+		return nil
+	})
+}
+
+// setTenant sets the tenant field of rows with the zero value to the tenant
+// from the context and rejects rows of other tenants.
+func (q *InsertQuery) setTenant(ctx context.Context) error {
+	if q.allTenants || q.tableModel == nil || q.table == nil || q.table.TenantField == nil {
+		return nil
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTenantRequired, q.table)
+	}
+	if q.table.IsColumnar() {
+		return errors.New("ch: tenant scoping does not support columnar models, use AllTenants")
+	}
+
+	field := q.table.TenantField
+	tenant, err := tenantValue(tenantID, field.Field.Type)
+	if err != nil {
+		return err
+	}
+	return q.forEachRow(func(row int, strct reflect.Value) error {
+		v := field.Value(strct.Elem())
+		if v.IsZero() {
+			v.Set(tenant)
+			return nil
+		}
+		if v.Interface() != tenant.Interface() {
+			return &RowError{Row: row, Err: fmt.Errorf(
+				"ch: row of tenant %v can't be inserted by tenant %v", v.Interface(), tenantID)}
+		}
+		return nil
+	})
+}
+
+func tenantValue(tenantID any, typ reflect.Type) (reflect.Value, error) {
+	v := reflect.ValueOf(tenantID)
+	// Don't convert integers to strings as runes.
+	if !v.Type().ConvertibleTo(typ) || (typ.Kind() == reflect.String) != (v.Kind() == reflect.String) {
+		return reflect.Value{}, fmt.Errorf("ch: can't use tenant %v (%T) as %s", tenantID, tenantID, typ)
+	}
+	return v.Convert(typ), nil
+}
+
+func (q *InsertQuery) callBeforeAppend(ctx context.Context, row int, v reflect.Value) error {
+	if hook, ok := v.Interface().(BeforeAppendModelHook); ok {
+		if err := hook.BeforeAppendModel(ctx, q); err != nil {
+			return &RowError{Row: row, Err: err}
+		}
+	}
+	for _, fn := range q.beforeAppend {
+		if err := fn(ctx, row, v.Interface()); err != nil {
+			return &RowError{Row: row, Err: err}
+		}
+	}
+	return nil
 }
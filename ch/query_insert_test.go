@@ -0,0 +1,475 @@
+package ch_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+func TestInsertBeforeAppendModel(t *testing.T) {
+	type Model struct {
+		Name string
+	}
+
+	db := ch.Connect(ch.WithDSN("clickhouse://localhost:9000/default?sslmode=disable"))
+	defer db.Close()
+
+	errEmptyName := errors.New("empty name")
+	models := []Model{{Name: " foo "}, {Name: ""}}
+
+	_, err := db.NewInsert().
+		Model(&models).
+		BeforeAppendModel(func(ctx context.Context, row int, strct any) error {
+			model := strct.(*Model)
+			model.Name = strings.TrimSpace(model.Name)
+			if model.Name == "" {
+				return errEmptyName
+			}
+			return nil
+		}).
+		Exec(context.Background())
+	require.Error(t, err)
+	require.ErrorIs(t, err, errEmptyName)
+
+	var rowErr *ch.RowError
+	require.True(t, errors.As(err, &rowErr))
+	require.Equal(t, 1, rowErr.Row)
+	require.Equal(t, "foo", models[0].Name)
+}
+
+type hookModel struct {
+	Name  string
+	Upper string
+}
+
+var _ ch.BeforeAppendModelHook = (*hookModel)(nil)
+
+func (m *hookModel) BeforeAppendModel(ctx context.Context, query ch.Query) error {
+	if m.Name == "" {
+		return errors.New("empty name")
+	}
+	m.Upper = strings.ToUpper(m.Name)
+	return nil
+}
+
+func TestInsertBeforeAppendModelHook(t *testing.T) {
+	db := ch.Connect(ch.WithDSN("clickhouse://localhost:9000/default?sslmode=disable"))
+	defer db.Close()
+
+	models := []*hookModel{{Name: "foo"}, {Name: "bar"}, {}}
+	_, err := db.NewInsert().Model(&models).Exec(context.Background())
+	require.Error(t, err)
+
+	var rowErr *ch.RowError
+	require.True(t, errors.As(err, &rowErr))
+	require.Equal(t, 2, rowErr.Row)
+	require.Equal(t, "FOO", models[0].Upper)
+	require.Equal(t, "BAR", models[1].Upper)
+}
+
+func TestInsertBlockSize(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS insert_blocks")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE insert_blocks (
+			n UInt64,
+			CONSTRAINT small CHECK n < 4
+		) ENGINE = MergeTree ORDER BY n`)
+	require.NoError(t, err)
+
+	type Model struct {
+		ch.CHModel `ch:"table:insert_blocks"`
+
+		N uint64
+	}
+
+	var models []Model
+	for i := 0; i < 6; i++ {
+		models = append(models, Model{N: uint64(i)})
+	}
+
+	_, err = db.NewInsert().Model(&models).BlockSize(2).Exec(ctx)
+	require.Error(t, err)
+
+	var insertErr *ch.InsertError
+	require.True(t, errors.As(err, &insertErr))
+	require.Equal(t, 2, insertErr.Block)
+	require.Equal(t, 4, insertErr.AcceptedRows)
+
+	var count int
+	err = db.NewSelect().Model((*Model)(nil)).ColumnExpr("count()").Scan(ctx, &count)
+	require.NoError(t, err)
+	require.Equal(t, 4, count)
+}
+
+func TestInsertDeadLetterRejected(t *testing.T) {
+	type Model struct {
+		N float64
+	}
+
+	db := ch.Connect(
+		ch.WithDSN("clickhouse://localhost:9000/default?sslmode=disable"),
+		ch.WithRejectNonFiniteFloats(true))
+	defer db.Close()
+
+	// All rows are rejected by the client so the server is never contacted.
+	models := []Model{{N: math.NaN()}, {N: math.Inf(1)}, {N: math.Inf(-1)}}
+
+	var rows []int
+	res, err := db.NewInsert().
+		Model(&models).
+		DeadLetter(func(ctx context.Context, row int, strct any, err error) {
+			require.Same(t, &models[row], strct)
+			require.True(t, ch.IsValueError(err))
+			rows = append(rows, row)
+		}).
+		Exec(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2}, rows)
+
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+}
+
+func TestInsertRateLimit(t *testing.T) {
+	type Model struct {
+		N uint64
+	}
+
+	db := ch.Connect(
+		ch.WithDSN("clickhouse://localhost:9000/default?sslmode=disable"),
+		ch.WithInsertRateLimit(1, 0))
+	defer db.Close()
+
+	// The block waits for 3 seconds so the insert fails before the server is contacted.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	models := []Model{{N: 1}, {N: 2}, {N: 3}, {N: 4}}
+	start := time.Now()
+	_, err := db.NewInsert().Model(&models).Exec(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestInsertRateLimitBytes(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events"`
+
+		Name string
+	}
+
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithInsertRateLimit(0, 10),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeInsertConn(func() []chfake.Column {
+				return []chfake.Column{{Name: "name", CHType: "String"}}
+			}, nil), nil
+		}),
+	)
+	defer db.Close()
+
+	// The size of the block is only known after it is written, so the first
+	// insert is not limited.
+	models := []Model{{Name: strings.Repeat("x", 100)}}
+	_, err := db.NewInsert().Model(&models).Exec(context.Background())
+	require.NoError(t, err)
+
+	// The next insert waits for the bytes written by the first one.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = db.NewInsert().Model(&models).Exec(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestInsertRateLimitDeadLetter(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events"`
+
+		N uint64
+	}
+
+	db := ch.Connect(
+		ch.WithCompression(false),
+		ch.WithInsertRateLimit(4, 0),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return fakeInsertConn(func() []chfake.Column {
+				return []chfake.Column{{Name: "n", CHType: "UInt64"}}
+			}, func(numRow int) int32 {
+				// One of the rows is rejected.
+				if numRow > 1 {
+					return ch.CodeCannotParseText
+				}
+				return 0
+			}), nil
+		}),
+	)
+	defer db.Close()
+
+	models := []Model{{N: 1}, {N: 2}, {N: 3}, {N: 4}}
+	var rejected []uint64
+	start := time.Now()
+	res, err := db.NewInsert().
+		Model(&models).
+		DeadLetter(func(ctx context.Context, row int, strct any, err error) {
+			rejected = append(rejected, strct.(*Model).N)
+		}).
+		Exec(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, rejected)
+
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+
+	// The rows are limited once: the halves of the rejected block
+	// do not wait for the tokens again.
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+}
+
+func TestInsertDeadLetter(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS insert_dead_letter")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE insert_dead_letter (
+			n UInt64,
+			CONSTRAINT not_bad CHECK n NOT IN (2, 5, 6)
+		) ENGINE = MergeTree ORDER BY n`)
+	require.NoError(t, err)
+
+	type Model struct {
+		ch.CHModel `ch:"table:insert_dead_letter"`
+
+		N uint64
+	}
+
+	var models []Model
+	for i := 0; i < 8; i++ {
+		models = append(models, Model{N: uint64(i)})
+	}
+
+	var rejected []uint64
+	res, err := db.NewInsert().
+		Model(&models).
+		BlockSize(4).
+		DeadLetter(func(ctx context.Context, row int, strct any, err error) {
+			require.True(t, ch.IsErrorCode(err, ch.CodeViolatedConstraint))
+			rejected = append(rejected, strct.(*Model).N)
+		}).
+		Exec(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 5, 6}, rejected)
+
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), n)
+
+	var count int
+	err = db.NewSelect().Model((*Model)(nil)).ColumnExpr("count()").Scan(ctx, &count)
+	require.NoError(t, err)
+	require.Equal(t, 5, count)
+}
+
+func TestInsertModelColumns(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events"`
+
+		ID   uint64
+		Name string
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewInsert().Model(new(Model)).String()
+	require.Equal(t, `INSERT INTO "events" ("id", "name") VALUES`, query)
+
+	query = db.NewInsert().Model(new(Model)).ExcludeColumn("name").String()
+	require.Equal(t, `INSERT INTO "events" ("id") VALUES`, query)
+}
+
+func TestInsertDefaultColumns(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS insert_defaults")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE insert_defaults (
+			id UInt64,
+			version UInt32 DEFAULT 1,
+			label String DEFAULT concat('event-', toString(id))
+		) ENGINE = MergeTree ORDER BY id`)
+	require.NoError(t, err)
+
+	type Model struct {
+		ch.CHModel `ch:"table:insert_defaults"`
+
+		ID uint64
+	}
+
+	models := []Model{{ID: 1}, {ID: 2}}
+	_, err = db.NewInsert().Model(&models).Exec(ctx)
+	require.NoError(t, err)
+
+	type Row struct {
+		ch.CHModel `ch:"table:insert_defaults"`
+
+		ID      uint64
+		Version uint32
+		Label   string
+	}
+
+	var rows []Row
+	err = db.NewSelect().Model(&rows).Order("id").Scan(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []Row{
+		{ID: 1, Version: 1, Label: "event-1"},
+		{ID: 2, Version: 1, Label: "event-2"},
+	}, rows)
+}
+
+func TestInsertReplace(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:users"`
+
+		ID      uint64 `ch:",pk"`
+		Name    string
+		Version uint64 `ch:",version"`
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Equal(t,
+		`CREATE TABLE "users" (id UInt64, name String, version UInt64) `+
+			`Engine = ReplacingMergeTree("version") ORDER BY (id)`, query)
+
+	type NoVersion struct {
+		ch.CHModel `ch:"table:users"`
+
+		ID uint64
+	}
+
+	_, err := db.NewInsert().Model(&NoVersion{ID: 1}).Replace().Exec(context.Background())
+	require.EqualError(t, err, "ch: Replace requires a model field with the version option")
+}
+
+func TestInsertReplaceExec(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:insert_replace"`
+
+		ID        uint64 `ch:",pk"`
+		Name      string
+		UpdatedAt time.Time `ch:"type:DateTime64(9),version"`
+	}
+
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	err := db.ResetModel(ctx, (*Model)(nil))
+	require.NoError(t, err)
+
+	for _, name := range []string{"foo", "bar"} {
+		model := &Model{ID: 1, Name: name}
+		_, err = db.NewInsert().Model(model).Replace().Exec(ctx)
+		require.NoError(t, err)
+		require.False(t, model.UpdatedAt.IsZero())
+	}
+
+	var models []Model
+	err = db.NewSelect().Model(&models).Final().Scan(ctx)
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	require.Equal(t, "bar", models[0].Name)
+}
+
+func TestInsertDeduplicationToken(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS insert_dedup")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, `
+		CREATE TABLE insert_dedup (n UInt64)
+		ENGINE = MergeTree ORDER BY n
+		SETTINGS non_replicated_deduplication_window = 100`)
+	require.NoError(t, err)
+
+	type Model struct {
+		ch.CHModel `ch:"table:insert_dedup"`
+
+		N uint64
+	}
+
+	models := []Model{{N: 1}, {N: 2}, {N: 3}}
+	for i := 0; i < 2; i++ {
+		_, err = db.NewInsert().Model(&models).DeduplicationToken("batch-1").Exec(ctx)
+		require.NoError(t, err)
+	}
+
+	var count int
+	err = db.NewSelect().Model((*Model)(nil)).ColumnExpr("count()").Scan(ctx, &count)
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+}
+
+func TestInsertAsync(t *testing.T) {
+	ctx := context.Background()
+
+	db := chDB()
+	defer db.Close()
+
+	_, err := db.ExecContext(ctx, "DROP TABLE IF EXISTS insert_async")
+	require.NoError(t, err)
+	_, err = db.ExecContext(ctx, "CREATE TABLE insert_async (n UInt64) ENGINE = MergeTree ORDER BY n")
+	require.NoError(t, err)
+
+	type Model struct {
+		ch.CHModel `ch:"table:insert_async"`
+
+		N uint64
+	}
+
+	models := []Model{{N: 1}, {N: 2}}
+
+	res, err := db.NewInsert().Model(&models).AsyncInsert(true).Exec(ctx)
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	res, err = db.NewInsert().Model(&models).AsyncInsert(false).Exec(ctx)
+	require.NoError(t, err)
+	n, err = res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), n)
+}
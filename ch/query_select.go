@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 	"github.com/uptrace/go-clickhouse/ch/internal"
@@ -17,13 +20,16 @@ type SelectQuery struct {
 
 	sample     chschema.QueryWithArgs
 	distinctOn []chschema.QueryWithArgs
+	modifiers  []chschema.QueryWithArgs // * APPLY, EXCEPT, and REPLACE
 	joins      []joinQuery
 	group      []chschema.QueryWithArgs
+	withTotals bool
 	having     []chschema.QueryWithArgs
 	order      []chschema.QueryWithArgs
 	limit      int
 	offset     int
 	final      bool
+	allTenants bool
 }
 
 var _ Query = (*SelectQuery)(nil)
@@ -72,7 +78,21 @@ func (q *SelectQuery) WithAlias(name, query string, args ...any) *SelectQuery {
 	return q
 }
 
+// With adds the subquery as the common table expression that can be used as
+// a table, for example, `WITH "signups" AS (SELECT ...) SELECT ... FROM "signups"`.
+// Subqueries are formatted with the args of the query and select queries are
+// scoped to the tenant of the query context. Adding a subquery with the same
+// name replaces the previous one.
 func (q *SelectQuery) With(name string, subq chschema.QueryAppender) *SelectQuery {
+	for i := range q.with {
+		with := &q.with[i]
+		if with.name == name {
+			with.query = subq
+			with.cte = true
+			return q
+		}
+	}
+
 	q.with = append(q.with, withQuery{
 		name:  name,
 		query: subq,
@@ -105,13 +125,63 @@ func (q *SelectQuery) TableExpr(query string, args ...any) *SelectQuery {
 	return q
 }
 
+// TableSubquery adds the select query as a table expression, for example,
+// `SELECT ... FROM (SELECT ...) AS "sub"`. The subquery is formatted with
+// the args of the query and is scoped to the tenant of the query context,
+// like select queries passed to TableExpr as args. Empty alias omits the AS
+// clause.
+func (q *SelectQuery) TableSubquery(subq *SelectQuery, alias string) *SelectQuery {
+	if alias == "" {
+		q.addTable(chschema.SafeQuery("(?)", []any{subq}))
+	} else {
+		q.addTable(chschema.SafeQuery("(?) AS ?", []any{subq, chschema.Ident(alias)}))
+	}
+	return q
+}
+
 func (q *SelectQuery) ModelTableExpr(query string, args ...any) *SelectQuery {
 	q.modelTableName = chschema.SafeQuery(query, args)
 	return q
 }
 
-func (q *SelectQuery) Sample(query string, args ...any) *SelectQuery {
-	q.sample = chschema.SafeQuery(query, args)
+// Sample adds the SAMPLE clause to read a sample of a MergeTree table created
+// with SAMPLE BY, for example:
+//
+//	q.Sample(0.1)                    // SAMPLE 0.1, i.e. 10% of the data
+//	q.Sample(10000000)               // SAMPLE 10000000, i.e. at least 10M rows
+//	q.Sample("1/10 OFFSET 1/2")      // SAMPLE 1/10 OFFSET 1/2
+//	q.Sample("? OFFSET ?", 0.1, 0.5) // SAMPLE 0.1 OFFSET 0.5
+//
+// Floats must be in (0, 1] and integers must be positive.
+func (q *SelectQuery) Sample(sample any, args ...any) *SelectQuery {
+	if query, ok := sample.(string); ok {
+		q.sample = chschema.SafeQuery(query, args)
+		return q
+	}
+
+	v := reflect.ValueOf(sample)
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); !(f > 0 && f <= 1) {
+			q.setErr(fmt.Errorf("ch: Sample(%v): ratio must be in (0, 1]", sample))
+			return q
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() <= 0 {
+			q.setErr(fmt.Errorf("ch: Sample(%v): number of rows must be positive", sample))
+			return q
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() == 0 {
+			q.setErr(fmt.Errorf("ch: Sample(%v): number of rows must be positive", sample))
+			return q
+		}
+	default:
+		q.setErr(fmt.Errorf("ch: Sample(unsupported %T)", sample))
+		return q
+	}
+
+	q.sample = chschema.SafeQuery("?", []any{sample})
 	return q
 }
 
@@ -134,8 +204,95 @@ func (q *SelectQuery) ExcludeColumn(columns ...string) *SelectQuery {
 	return q
 }
 
+// ColumnsExcept selects all columns except the columns using
+// the * EXCEPT modifier, for example, `SELECT * EXCEPT ("secret")`.
+//
+// Column modifiers replace the model columns and are applied
+// in the order they are added. Columns added with Column and
+// ColumnExpr are selected after the modified *.
+func (q *SelectQuery) ColumnsExcept(columns ...string) *SelectQuery {
+	if len(columns) == 0 {
+		return q
+	}
+
+	args := make([]any, len(columns))
+	for i, column := range columns {
+		args[i] = chschema.Ident(column)
+	}
+	query := "EXCEPT (" + strings.Repeat("?, ", len(columns)-1) + "?)"
+	q.modifiers = append(q.modifiers, chschema.SafeQuery(query, args))
+	return q
+}
+
+// ColumnsReplace replaces the column with the expression using
+// the * REPLACE modifier, for example, `SELECT * REPLACE (price * 2 AS "price")`.
+func (q *SelectQuery) ColumnsReplace(column, query string, args ...any) *SelectQuery {
+	q.modifiers = append(q.modifiers, chschema.SafeQuery("REPLACE (? AS ?)", []any{
+		chschema.SafeQuery(query, args), chschema.Ident(column),
+	}))
+	return q
+}
+
+// ColumnsApply applies the function to all columns using the * APPLY modifier,
+// for example, `SELECT * APPLY(sum)`.
+func (q *SelectQuery) ColumnsApply(fn string, args ...any) *SelectQuery {
+	q.modifiers = append(q.modifiers, chschema.SafeQuery("APPLY(?)", []any{
+		chschema.SafeQuery(fn, args),
+	}))
+	return q
+}
+
 //------------------------------------------------------------------------------
 
+// JoinType is the type of JOIN including the strictness, for example,
+// LeftJoin.Any() for LEFT ANY JOIN.
+type JoinType string
+
+const (
+	InnerJoin JoinType = "INNER JOIN"
+	LeftJoin  JoinType = "LEFT JOIN"
+	RightJoin JoinType = "RIGHT JOIN"
+	FullJoin  JoinType = "FULL JOIN"
+	CrossJoin JoinType = "CROSS JOIN"
+)
+
+// Any returns the join type with the ANY strictness, for example, LEFT ANY JOIN.
+func (t JoinType) Any() JoinType {
+	return t.strictness("ANY")
+}
+
+// All returns the join type with the ALL strictness, for example, LEFT ALL JOIN.
+func (t JoinType) All() JoinType {
+	return t.strictness("ALL")
+}
+
+// Asof returns the join type with the ASOF strictness, for example, LEFT ASOF JOIN.
+// ASOF joins require the closest match condition in JoinOn or the last column
+// in JoinUsing.
+func (t JoinType) Asof() JoinType {
+	return t.strictness("ASOF")
+}
+
+// Semi returns the join type with the SEMI strictness, for example, LEFT SEMI JOIN.
+func (t JoinType) Semi() JoinType {
+	return t.strictness("SEMI")
+}
+
+// Anti returns the join type with the ANTI strictness, for example, LEFT ANTI JOIN.
+func (t JoinType) Anti() JoinType {
+	return t.strictness("ANTI")
+}
+
+func (t JoinType) strictness(s string) JoinType {
+	return JoinType(strings.TrimSuffix(string(t), "JOIN") + s + " JOIN")
+}
+
+// Global returns the GLOBAL join type that sends the right table to all
+// servers of a distributed query.
+func (t JoinType) Global() JoinType {
+	return "GLOBAL " + t
+}
+
 func (q *SelectQuery) Join(join string, args ...any) *SelectQuery {
 	q.joins = append(q.joins, joinQuery{
 		join: chschema.SafeQuery(join, args),
@@ -143,6 +300,40 @@ func (q *SelectQuery) Join(join string, args ...any) *SelectQuery {
 	return q
 }
 
+// JoinModel joins the table of the model using the model alias, for example,
+// `LEFT ANY JOIN "users" AS "user"`. The model is a pointer to a struct or to
+// a slice of structs and it is not scanned. Use JoinOn or JoinUsing to add
+// the join condition.
+func (q *SelectQuery) JoinModel(join JoinType, model any) *SelectQuery {
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		q.setErr(fmt.Errorf("ch: JoinModel(unsupported %T)", model))
+		return q
+	}
+
+	table := chschema.TableForType(typ)
+	if table.CHAlias == table.CHName {
+		return q.Join("? ?", chschema.Safe(join), tableName{table})
+	}
+	return q.Join("? ? AS ?", chschema.Safe(join), tableName{table}, tableAlias{table})
+}
+
+// JoinUsing adds the USING clause with the columns to the last join.
+func (q *SelectQuery) JoinUsing(columns ...string) *SelectQuery {
+	if len(q.joins) == 0 {
+		q.err = errors.New("ch: query has no joins")
+		return q
+	}
+	j := &q.joins[len(q.joins)-1]
+	for _, column := range columns {
+		j.using = append(j.using, chschema.Ident(column))
+	}
+	return q
+}
+
 func (q *SelectQuery) JoinOn(cond string, args ...any) *SelectQuery {
 	return q.joinOn(cond, args, " AND ")
 }
@@ -192,6 +383,13 @@ func (q *SelectQuery) GroupExpr(group string, args ...any) *SelectQuery {
 	return q
 }
 
+// WithTotals adds WITH TOTALS modifier to GROUP BY. Use ScanTotals
+// to retrieve the totals row.
+func (q *SelectQuery) WithTotals() *SelectQuery {
+	q.withTotals = true
+	return q
+}
+
 func (q *SelectQuery) Having(having string, args ...any) *SelectQuery {
 	q.having = append(q.having, chschema.SafeQuery(having, args))
 	return q
@@ -232,6 +430,24 @@ func (q *SelectQuery) OrderExpr(order string, args ...any) *SelectQuery {
 	return q
 }
 
+// Latest selects the last n rows according to the model primary key, i.e. it adds
+// ORDER BY pk1 DESC, pk2 DESC LIMIT n. Sorting by the table sorting key lets
+// ClickHouse read data in order instead of doing a full sort.
+func (q *SelectQuery) Latest(n int) *SelectQuery {
+	if q.table == nil || len(q.table.PKs) == 0 {
+		q.setErr(errors.New("ch: Latest requires a model with pk fields"))
+		return q
+	}
+
+	for _, pk := range q.table.PKs {
+		q.order = append(q.order, chschema.SafeQuery("?.? DESC", []any{
+			tableAlias{q.table}, fieldColumn{pk},
+		}))
+	}
+	q.limit = n
+	return q
+}
+
 func (q *SelectQuery) Limit(limit int) *SelectQuery {
 	q.limit = limit
 	return q
@@ -247,31 +463,71 @@ func (q *SelectQuery) Final() *SelectQuery {
 	return q
 }
 
+// AllTenants disables tenant scoping for the query. See ContextWithTenant.
+func (q *SelectQuery) AllTenants() *SelectQuery {
+	q.allTenants = true
+	return q
+}
+
 func (q *SelectQuery) Setting(query string, args ...any) *SelectQuery {
 	q.settings = append(q.settings, chschema.SafeQuery(query, args))
 	return q
 }
 
+// Timeout sets the query timeout overriding DefaultQueryTimeout.
+// Negative timeout disables DefaultQueryTimeout for the query.
+func (q *SelectQuery) Timeout(timeout time.Duration) *SelectQuery {
+	q.timeout = timeout
+	return q
+}
+
+// IdentFolder configures a func that folds the case of identifiers generated
+// for the query overriding WithIdentFolder, for example, strings.ToLower.
+func (q *SelectQuery) IdentFolder(fn func(string) string) *SelectQuery {
+	q.identFolder = fn
+	return q
+}
+
 //------------------------------------------------------------------------------
 
+// String returns the query with the arguments interpolated.
 func (q *SelectQuery) String() string {
-	b, err := q.AppendQuery(q.db.fmter, nil)
-	if err != nil {
-		return err.Error()
-	}
-	return internal.String(b)
+	return formatQuery(q.db.fmter, q)
+}
+
+// RedactedString is like String, but replaces values of the arguments with ?.
+func (q *SelectQuery) RedactedString() string {
+	return formatQuery(q.db.fmter.WithRedactedArgs(), q)
 }
 
 func (q *SelectQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []byte, err error) {
 	return q.appendQuery(formatterWithModel(fmter, q), b, false)
 }
 
+// tenantFilterFunc returns the tenant filter of the select query.
+type tenantFilterFunc func(q *SelectQuery) (chschema.QueryWithArgs, error)
+
+// tenantFilterKey is the formatter value key of the tenantFilterFunc that
+// scopes the query and the select queries nested in it to the tenant.
+type tenantFilterKey struct{}
+
+// appendQuery appends the query. Queries formatted without the tenant filter,
+// for example, with String, are not scoped to the tenant.
 func (q *SelectQuery) appendQuery(
 	fmter chschema.Formatter, b []byte, count bool,
 ) (_ []byte, err error) {
 	if q.err != nil {
 		return nil, q.err
 	}
+	fmter = q.withIdentFolder(fmter)
+
+	var tenant chschema.QueryWithArgs
+	if tenantFilter, _ := fmter.Value(tenantFilterKey{}).(tenantFilterFunc); tenantFilter != nil {
+		tenant, err = tenantFilter(q)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	cteCount := count && (len(q.group) > 0 || len(q.distinctOn) > 0)
 	if cteCount {
@@ -315,6 +571,9 @@ func (q *SelectQuery) appendQuery(
 		if err != nil {
 			return nil, err
 		}
+		if q.final {
+			b = append(b, " FINAL"...)
+		}
 	}
 	if !q.sample.IsZero() {
 		b = append(b, " SAMPLE "...)
@@ -325,14 +584,13 @@ func (q *SelectQuery) appendQuery(
 	}
 
 	for _, j := range q.joins {
-		b = append(b, ' ')
 		b, err = j.AppendQuery(fmter, b)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	b, err = q.appendWhere(fmter, b)
+	b, err = q.appendWhereWithFilter(fmter, b, tenant)
 	if err != nil {
 		return nil, err
 	}
@@ -348,6 +606,9 @@ func (q *SelectQuery) appendQuery(
 				return nil, err
 			}
 		}
+		if q.withTotals && !count {
+			b = append(b, " WITH TOTALS"...)
+		}
 	}
 
 	if len(q.having) > 0 {
@@ -387,16 +648,13 @@ func (q *SelectQuery) appendQuery(
 			b = append(b, " OFFSET "...)
 			b = strconv.AppendInt(b, int64(q.offset), 10)
 		}
-		if q.final {
-			b = append(b, " FINAL"...)
-		}
 	} else if cteCount {
 		b = append(b, `) SELECT `...)
 		b = append(b, "count()"...)
 		b = append(b, ` FROM "_count_wrapper"`...)
 	}
 
-	b, err = q.appendSettings(fmter, b)
+	b, err = q.appendSettingsWithDefaults(fmter, b)
 	if err != nil {
 		return nil, err
 	}
@@ -412,7 +670,7 @@ func (q *SelectQuery) appendWith(fmter chschema.Formatter, b []byte) (_ []byte,
 		}
 
 		if with.cte {
-			b = chschema.AppendIdent(b, with.name)
+			b = fmter.AppendIdent(b, with.name)
 			b = append(b, " AS "...)
 			b = append(b, "("...)
 		}
@@ -426,7 +684,7 @@ func (q *SelectQuery) appendWith(fmter chschema.Formatter, b []byte) (_ []byte,
 			b = append(b, ")"...)
 		} else {
 			b = append(b, " AS "...)
-			b = chschema.AppendIdent(b, with.name)
+			b = fmter.AppendIdent(b, with.name)
 		}
 	}
 	b = append(b, ' ')
@@ -434,6 +692,21 @@ func (q *SelectQuery) appendWith(fmter chschema.Formatter, b []byte) (_ []byte,
 }
 
 func (q *SelectQuery) appendColumns(fmter chschema.Formatter, b []byte) (_ []byte, err error) {
+	if len(q.modifiers) > 0 {
+		b = append(b, '*')
+		for _, modifier := range q.modifiers {
+			b = append(b, ' ')
+			b, err = modifier.AppendQuery(fmter, b)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(q.columns) == 0 {
+			return b, nil
+		}
+		b = append(b, ", "...)
+	}
+
 	switch {
 	case q.columns != nil:
 		for i, f := range q.columns {
@@ -446,23 +719,23 @@ func (q *SelectQuery) appendColumns(fmter chschema.Formatter, b []byte) (_ []byt
 			}
 		}
 	case q.table != nil:
-		b = appendTableColumns(b, q.table.CHAlias, q.table.Fields)
+		b = appendTableColumns(fmter, b, q.table, q.table.Fields)
 	default:
 		b = append(b, '*')
 	}
 	return b, nil
 }
 
-func appendTableColumns(b []byte, table chschema.Safe, fields []*chschema.Field) []byte {
+func appendTableColumns(
+	fmter chschema.Formatter, b []byte, table *chschema.Table, fields []*chschema.Field,
+) []byte {
 	for i, f := range fields {
 		if i > 0 {
 			b = append(b, ", "...)
 		}
-		if len(table) > 0 {
-			b = append(b, table...)
-			b = append(b, '.')
-		}
-		b = append(b, f.Column...)
+		b = table.AppendAlias(fmter, b)
+		b = append(b, '.')
+		b = f.AppendColumn(fmter, b)
 	}
 	return b
 }
@@ -475,40 +748,136 @@ func (q *SelectQuery) ScanColumns(ctx context.Context, values ...any) error {
 	return q.scan(ctx, true, values...)
 }
 
+// ScanTotals is like Scan, but also scans the totals row of a
+// GROUP BY ... WITH TOTALS query into totals, for example:
+//
+//	var stats []Stat
+//	var total Stat
+//	err := db.NewSelect().Model(&stats).Group("project").ScanTotals(ctx, &total)
+//
+// Totals are left untouched when the server does not send them.
+func (q *SelectQuery) ScanTotals(ctx context.Context, totals any, values ...any) error {
+	q.withTotals = true
+
+	res, err := q._scan(ctx, false, values...)
+	if err != nil {
+		return err
+	}
+	if res.totals == nil || res.totals.NumRow == 0 {
+		return nil
+	}
+
+	model, err := newModel(q.db, totals)
+	if err != nil {
+		return err
+	}
+	return model.ScanBlock(res.totals)
+}
+
+// ScanExtremes is like Scan, but also enables the extremes setting and
+// scans the minimum and maximum values of the result columns into extremes,
+// which must be a pointer to a slice, for example:
+//
+//	var stats []Stat
+//	var extremes []Stat // [min, max]
+//	err := db.NewSelect().Model(&stats).ScanExtremes(ctx, &extremes)
+//
+// Extremes are left untouched when the server does not send them.
+func (q *SelectQuery) ScanExtremes(ctx context.Context, extremes any, values ...any) error {
+	if !hasSetting(q.settings, "extremes") {
+		q.Setting("extremes = 1")
+	}
+
+	res, err := q._scan(ctx, false, values...)
+	if err != nil {
+		return err
+	}
+	if res.extremes == nil || res.extremes.NumRow == 0 {
+		return nil
+	}
+
+	model, err := newModel(q.db, extremes)
+	if err != nil {
+		return err
+	}
+	if _, ok := model.(*scanModel); ok {
+		return fmt.Errorf("ch: ScanExtremes(non-slice %T)", extremes)
+	}
+	return model.ScanBlock(res.extremes)
+}
+
 func (q *SelectQuery) scan(ctx context.Context, columnar bool, values ...any) error {
+	_, err := q._scan(ctx, columnar, values...)
+	return err
+}
+
+func (q *SelectQuery) _scan(ctx context.Context, columnar bool, values ...any) (*result, error) {
 	if q.err != nil {
-		return q.err
+		return nil, q.err
 	}
 
 	model, err := q.newModel(values...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if columnar {
 		model.(interface{ SetColumnar(bool) }).SetColumnar(true)
 	}
 
-	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
+	res, err := q.run(ctx, model, false)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if !columnar && useQueryRowModel(model) {
+		if res.affected == 0 {
+			return nil, sql.ErrNoRows
+		}
+	}
+
+	return res, nil
+}
+
+// run executes the query scanning the result into the model. Queries derived
+// from q, for example, by Count, use the same settings, formatter, and hooks.
+func (q *SelectQuery) run(ctx context.Context, model Model, count bool) (*result, error) {
+	fmter := q.db.fmter.WithValue(tenantFilterKey{}, tenantFilterFunc(
+		func(q *SelectQuery) (chschema.QueryWithArgs, error) {
+			return q.tenantFilter(ctx)
+		}))
+	queryBytes, err := q.appendQuery(
+		formatterWithModel(fmter, q), q.db.makeQueryBytes(), count)
+	if err != nil {
+		return nil, err
 	}
 	query := internal.String(queryBytes)
 
+	ctx, cancel := q.db.withQueryTimeout(ctx, q.timeout)
+	defer cancel()
+
 	ctx, evt := q.db.beforeQuery(ctx, q, query, nil, model)
 	res, err := q.query(ctx, model, query)
 	q.db.afterQuery(ctx, evt, res, err)
 	if err != nil {
-		return err
+		return nil, q.db.queryError(ctx, q, query, nil, err)
 	}
+	return res, nil
+}
 
-	if !columnar && useQueryRowModel(model) {
-		if res.affected == 0 {
-			return sql.ErrNoRows
-		}
+func (q *SelectQuery) tenantFilter(ctx context.Context) (chschema.QueryWithArgs, error) {
+	if q.allTenants || q.table == nil || q.table.TenantField == nil {
+		return chschema.QueryWithArgs{}, nil
+	}
+
+	tenantID, ok := TenantFromContext(ctx)
+	if !ok {
+		return chschema.QueryWithArgs{}, fmt.Errorf("%w: %s", ErrTenantRequired, q.table)
 	}
 
-	return nil
+	return chschema.SafeQuery("?.? = ?", []any{
+		tableAlias{q.table}, fieldColumn{q.table.TenantField}, tenantID,
+	}), nil
 }
 
 func useQueryRowModel(model Model) bool {
@@ -524,15 +893,15 @@ func (q *SelectQuery) Count(ctx context.Context) (int, error) {
 		return 0, q.err
 	}
 
-	queryBytes, err := q.appendQuery(q.db.fmter, nil, true)
+	var count uint
+	res, err := q.run(ctx, scan(&count), true)
 	if err != nil {
 		return 0, err
 	}
-	query := internal.String(queryBytes)
-
-	var count uint
-	err = q.db.QueryRowContext(ctx, query).Scan(&count)
-	return int(count), err
+	if res.affected == 0 {
+		return 0, sql.ErrNoRows
+	}
+	return int(count), nil
 }
 
 // SelectAndCount runs Select and Count in two goroutines,
@@ -567,7 +936,7 @@ func (q *SelectQuery) ScanAndCount(
 	go func() {
 		defer wg.Done()
 		var err error
-		count, err = q.Count(ctx)
+		count, err = q.Count(contextWithDerivedQueryID(ctx, "count"))
 		if err != nil {
 			mu.Lock()
 			if firstErr == nil {
@@ -584,8 +953,9 @@ func (q *SelectQuery) ScanAndCount(
 //------------------------------------------------------------------------------
 
 type joinQuery struct {
-	join chschema.QueryWithArgs
-	on   []chschema.QueryWithSep
+	join  chschema.QueryWithArgs
+	on    []chschema.QueryWithSep
+	using []chschema.Ident
 }
 
 func (j *joinQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []byte, err error) {
@@ -612,5 +982,16 @@ func (j *joinQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []byte, e
 		}
 	}
 
+	if len(j.using) > 0 {
+		b = append(b, " USING ("...)
+		for i, column := range j.using {
+			if i > 0 {
+				b = append(b, ", "...)
+			}
+			b = fmter.AppendIdent(b, string(column))
+		}
+		b = append(b, ')')
+	}
+
 	return b, nil
 }
@@ -3,6 +3,7 @@ package ch
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 	"github.com/uptrace/go-clickhouse/ch/internal"
@@ -89,6 +90,27 @@ func (q *CreateTableQuery) Setting(query string, args ...any) *CreateTableQuery
 	return q
 }
 
+// OnCluster executes the query on all nodes of the cluster using ON CLUSTER.
+// It overrides the cluster configured with WithCluster or WithClusterMacro.
+func (q *CreateTableQuery) OnCluster(cluster string) *CreateTableQuery {
+	q.cluster = cluster
+	return q
+}
+
+// Timeout sets the query timeout overriding DefaultQueryTimeout.
+// Negative timeout disables DefaultQueryTimeout for the query.
+func (q *CreateTableQuery) Timeout(timeout time.Duration) *CreateTableQuery {
+	q.timeout = timeout
+	return q
+}
+
+// IdentFolder configures a func that folds the case of identifiers generated
+// for the query overriding WithIdentFolder, for example, strings.ToLower.
+func (q *CreateTableQuery) IdentFolder(fn func(string) string) *CreateTableQuery {
+	q.identFolder = fn
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *CreateTableQuery) Operation() string {
@@ -97,10 +119,21 @@ func (q *CreateTableQuery) Operation() string {
 
 var _ chschema.QueryAppender = (*CreateTableQuery)(nil)
 
+// String returns the query with the arguments interpolated.
+func (q *CreateTableQuery) String() string {
+	return formatQuery(q.db.fmter, q)
+}
+
+// RedactedString is like String, but replaces values of the arguments with ?.
+func (q *CreateTableQuery) RedactedString() string {
+	return formatQuery(q.db.fmter.WithRedactedArgs(), q)
+}
+
 func (q *CreateTableQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []byte, err error) {
 	if q.err != nil {
 		return nil, q.err
 	}
+	fmter = q.withIdentFolder(fmter)
 	if q.table == nil {
 		return nil, errNilModel
 	}
@@ -115,14 +148,24 @@ func (q *CreateTableQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []
 		return nil, err
 	}
 
+	b = q.appendOnCluster(fmter, b)
+
 	b = append(b, " ("...)
 
 	for i, field := range q.table.Fields {
+		if field.Nested != nil {
+			// Flattened fields are created with the Nested column.
+			if i > 0 && q.table.Fields[i-1].Nested == field.Nested {
+				continue
+			}
+			field = field.Nested
+		}
+
 		if i > 0 {
 			b = append(b, ", "...)
 		}
 
-		b = append(b, field.CHName...)
+		b = append(b, fmter.FoldIdent(field.CHName)...)
 		b = append(b, " "...)
 		b = append(b, field.CHType...)
 		if field.NotNull {
@@ -155,6 +198,10 @@ func (q *CreateTableQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []
 		}
 	} else if q.table.CHEngine != "" {
 		b = append(b, q.table.CHEngine...)
+	} else if q.table.VersionField != nil {
+		b = append(b, "ReplacingMergeTree("...)
+		b = q.table.VersionField.AppendColumn(fmter, b)
+		b = append(b, ")"...)
 	} else {
 		b = append(b, "MergeTree()"...)
 	}
@@ -177,7 +224,7 @@ func (q *CreateTableQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []
 			if i > 0 {
 				b = append(b, ", "...)
 			}
-			b = append(b, pk.CHName...)
+			b = append(b, fmter.FoldIdent(pk.CHName)...)
 		}
 		b = append(b, ')')
 	} else if q.table.CHEngine == "" {
@@ -213,6 +260,10 @@ func (q *CreateTableQuery) appendPartition(fmter chschema.Formatter, b []byte) (
 }
 
 func (q *CreateTableQuery) Exec(ctx context.Context) (sql.Result, error) {
+	if err := q.resolveCluster(ctx); err != nil {
+		return nil, err
+	}
+
 	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
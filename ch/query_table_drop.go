@@ -3,6 +3,7 @@ package ch
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 	"github.com/uptrace/go-clickhouse/ch/internal"
@@ -56,16 +57,48 @@ func (q *DropTableQuery) IfExists() *DropTableQuery {
 	return q
 }
 
+// OnCluster executes the query on all nodes of the cluster using ON CLUSTER.
+// It overrides the cluster configured with WithCluster or WithClusterMacro.
+func (q *DropTableQuery) OnCluster(cluster string) *DropTableQuery {
+	q.cluster = cluster
+	return q
+}
+
+// Timeout sets the query timeout overriding DefaultQueryTimeout.
+// Negative timeout disables DefaultQueryTimeout for the query.
+func (q *DropTableQuery) Timeout(timeout time.Duration) *DropTableQuery {
+	q.timeout = timeout
+	return q
+}
+
+// IdentFolder configures a func that folds the case of identifiers generated
+// for the query overriding WithIdentFolder, for example, strings.ToLower.
+func (q *DropTableQuery) IdentFolder(fn func(string) string) *DropTableQuery {
+	q.identFolder = fn
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *DropTableQuery) Operation() string {
 	return "DROP TABLE"
 }
 
+// String returns the query with the arguments interpolated.
+func (q *DropTableQuery) String() string {
+	return formatQuery(q.db.fmter, q)
+}
+
+// RedactedString is like String, but replaces values of the arguments with ?.
+func (q *DropTableQuery) RedactedString() string {
+	return formatQuery(q.db.fmter.WithRedactedArgs(), q)
+}
+
 func (q *DropTableQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []byte, err error) {
 	if q.err != nil {
 		return nil, q.err
 	}
+	fmter = q.withIdentFolder(fmter)
 
 	b = append(b, "DROP TABLE "...)
 	if q.ifExists {
@@ -77,12 +110,16 @@ func (q *DropTableQuery) AppendQuery(fmter chschema.Formatter, b []byte) (_ []by
 		return nil, err
 	}
 
-	return b, nil
+	return q.appendOnCluster(fmter, b), nil
 }
 
 //------------------------------------------------------------------------------
 
 func (q *DropTableQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	if err := q.resolveCluster(ctx); err != nil {
+		return nil, err
+	}
+
 	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
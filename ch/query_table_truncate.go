@@ -3,6 +3,7 @@ package ch
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 	"github.com/uptrace/go-clickhouse/ch/internal"
@@ -51,18 +52,50 @@ func (q *TruncateTableQuery) IfExists() *TruncateTableQuery {
 	return q
 }
 
+// OnCluster executes the query on all nodes of the cluster using ON CLUSTER.
+// It overrides the cluster configured with WithCluster or WithClusterMacro.
+func (q *TruncateTableQuery) OnCluster(cluster string) *TruncateTableQuery {
+	q.cluster = cluster
+	return q
+}
+
+// Timeout sets the query timeout overriding DefaultQueryTimeout.
+// Negative timeout disables DefaultQueryTimeout for the query.
+func (q *TruncateTableQuery) Timeout(timeout time.Duration) *TruncateTableQuery {
+	q.timeout = timeout
+	return q
+}
+
+// IdentFolder configures a func that folds the case of identifiers generated
+// for the query overriding WithIdentFolder, for example, strings.ToLower.
+func (q *TruncateTableQuery) IdentFolder(fn func(string) string) *TruncateTableQuery {
+	q.identFolder = fn
+	return q
+}
+
 //------------------------------------------------------------------------------
 
 func (q *TruncateTableQuery) Operation() string {
 	return "TRUNCATE TABLE"
 }
 
+// String returns the query with the arguments interpolated.
+func (q *TruncateTableQuery) String() string {
+	return formatQuery(q.db.fmter, q)
+}
+
+// RedactedString is like String, but replaces values of the arguments with ?.
+func (q *TruncateTableQuery) RedactedString() string {
+	return formatQuery(q.db.fmter.WithRedactedArgs(), q)
+}
+
 func (q *TruncateTableQuery) AppendQuery(
 	fmter chschema.Formatter, b []byte,
 ) (_ []byte, err error) {
 	if q.err != nil {
 		return nil, q.err
 	}
+	fmter = q.withIdentFolder(fmter)
 
 	b = append(b, "TRUNCATE TABLE "...)
 	if q.ifExists {
@@ -74,12 +107,16 @@ func (q *TruncateTableQuery) AppendQuery(
 		return nil, err
 	}
 
-	return b, nil
+	return q.appendOnCluster(fmter, b), nil
 }
 
 //------------------------------------------------------------------------------
 
 func (q *TruncateTableQuery) Exec(ctx context.Context, dest ...any) (sql.Result, error) {
+	if err := q.resolveCluster(ctx); err != nil {
+		return nil, err
+	}
+
 	queryBytes, err := q.AppendQuery(q.db.fmter, q.db.makeQueryBytes())
 	if err != nil {
 		return nil, err
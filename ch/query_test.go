@@ -1,12 +1,15 @@
 package ch_test
 
 import (
+	"context"
 	"fmt"
 	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/bradleyjkemp/cupaloy"
+	"github.com/stretchr/testify/require"
+
 	"github.com/uptrace/go-clickhouse/ch"
 	"github.com/uptrace/go-clickhouse/ch/chschema"
 )
@@ -97,3 +100,406 @@ func TestQuery(t *testing.T) {
 		})
 	}
 }
+
+func TestModelSettings(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events,setting:final=1,setting:max_threads=4"`
+
+		ID uint64
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().Model((*Model)(nil)).String()
+	require.Equal(t,
+		`SELECT "model"."id" FROM "events" AS "model" SETTINGS final = 1, max_threads = 4`, query)
+
+	query = db.NewSelect().Model((*Model)(nil)).Setting("max_threads = ?", 8).String()
+	require.Equal(t,
+		`SELECT "model"."id" FROM "events" AS "model" SETTINGS final = 1, max_threads = 8`, query)
+}
+
+func TestSelectJoin(t *testing.T) {
+	type User struct {
+		ch.CHModel `ch:"table:users,alias:u"`
+
+		ID   uint64
+		Name string
+	}
+	type Event struct {
+		ch.CHModel `ch:"table:events,alias:e"`
+
+		UserID uint64
+		Time   time.Time
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().
+		Model((*Event)(nil)).
+		ColumnExpr("u.name").
+		JoinModel(ch.LeftJoin.Any(), (*User)(nil)).
+		JoinOn("u.id = e.user_id").
+		String()
+	require.Equal(t, `SELECT u.name FROM "events" AS "e" `+
+		`LEFT ANY JOIN "users" AS "u" ON (u.id = e.user_id)`, query)
+
+	query = db.NewSelect().
+		Model((*Event)(nil)).
+		JoinModel(ch.InnerJoin.Asof().Global(), &[]User{}).
+		JoinUsing("user_id", "time").
+		String()
+	require.Equal(t, `SELECT "e"."user_id", "e"."time" FROM "events" AS "e" `+
+		`GLOBAL INNER ASOF JOIN "users" AS "u" USING ("user_id", "time")`, query)
+
+	_, err := db.NewSelect().
+		Model((*Event)(nil)).
+		JoinModel(ch.LeftJoin, "users").
+		AppendQuery(db.Formatter(), nil)
+	require.EqualError(t, err, "ch: JoinModel(unsupported string)")
+
+	_, err = db.NewSelect().Model((*Event)(nil)).JoinUsing("id").AppendQuery(db.Formatter(), nil)
+	require.EqualError(t, err, "ch: query has no joins")
+}
+
+func TestSelectWith(t *testing.T) {
+	db := ch.Connect()
+	defer db.Close()
+
+	signups := db.NewSelect().
+		TableExpr("events").
+		ColumnExpr("user_id").
+		Where("name = ?", "signup").
+		Where("time >= ?since")
+	query := db.NewSelect().
+		With("signups", signups).
+		With("buyers", ch.SafeQuery("SELECT user_id FROM events WHERE name = ?", "buy")).
+		TableExpr("signups").
+		ColumnExpr("count()").
+		Where("user_id IN (SELECT user_id FROM buyers)").
+		String()
+	require.Equal(t, `WITH "signups" AS (SELECT user_id FROM events `+
+		`WHERE (name = 'signup') AND (time >= '2022-01-01')), `+
+		`"buyers" AS (SELECT user_id FROM events WHERE name = 'buy') `+
+		`SELECT count() FROM signups WHERE (user_id IN (SELECT user_id FROM buyers))`,
+		db.Formatter().WithNamedArg("since", "2022-01-01").FormatQuery(query))
+
+	query = db.NewSelect().
+		With("q", db.NewSelect().TableExpr("a")).
+		With("q", db.NewSelect().TableExpr("b")).
+		TableExpr("q").
+		String()
+	require.Equal(t, `WITH "q" AS (SELECT * FROM b) SELECT * FROM q`, query)
+}
+
+func TestSelectTableSubquery(t *testing.T) {
+	db := ch.Connect()
+	defer db.Close()
+
+	sub := db.NewSelect().
+		TableExpr("events").
+		ColumnExpr("user_id, count() AS n").
+		Where("name = ?", "click").
+		Group("user_id")
+
+	query := db.NewSelect().
+		TableSubquery(sub, "sub").
+		ColumnExpr("avg(n)").
+		Where("n > ?", 1).
+		String()
+	require.Equal(t, `SELECT avg(n) FROM (SELECT user_id, count() AS n FROM events `+
+		`WHERE (name = 'click') GROUP BY "user_id") AS "sub" WHERE (n > 1)`, query)
+
+	require.Equal(t, query, db.NewSelect().
+		TableExpr(`(?) AS "sub"`, sub).
+		ColumnExpr("avg(n)").
+		Where("n > ?", 1).
+		String())
+
+	query = db.NewSelect().TableSubquery(db.NewSelect().TableExpr("a"), "").String()
+	require.Equal(t, `SELECT * FROM (SELECT * FROM a)`, query)
+}
+
+func TestNestedQuery(t *testing.T) {
+	type Item struct {
+		Name  string
+		Count uint64
+	}
+	type Model struct {
+		ch.CHModel `ch:"table:orders"`
+
+		ID    uint64
+		Items []Item `ch:",nested"`
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Equal(t,
+		`CREATE TABLE "orders" (id UInt64, items Nested("name" String, "count" UInt64)) `+
+			`Engine = MergeTree() ORDER BY tuple()`, query)
+
+	query = db.NewSelect().Model((*Model)(nil)).String()
+	require.Equal(t,
+		`SELECT "model"."id", "model"."items.name", "model"."items.count" `+
+			`FROM "orders" AS "model"`, query)
+}
+
+func TestEnumTag(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events"`
+
+		Kind     string   `ch:",enum:hello|world"`
+		Level    *string  `ch:",enum:debug=-1|info|error=1000"`
+		Statuses []string `ch:",enum:open|it's closed=10"`
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewCreateTable().Model((*Model)(nil)).String()
+	require.Equal(t,
+		`CREATE TABLE "events" (kind Enum8('hello' = 1, 'world' = 2), `+
+			`level Nullable(Enum16('debug' = -1, 'info' = 0, 'error' = 1000)), `+
+			`statuses Array(Enum8('open' = 1, 'it\'s closed' = 10))) `+
+			`Engine = MergeTree() ORDER BY tuple()`, query)
+}
+
+func TestSelectColumnModifiers(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:orders"`
+
+		ID     uint64
+		Price  float64
+		Secret string
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().Model((*Model)(nil)).
+		ColumnsExcept("secret", "id").
+		ColumnsReplace("price", "price * ?", 2).
+		String()
+	require.Equal(t,
+		`SELECT * EXCEPT ("secret", "id") REPLACE (price * 2 AS "price") `+
+			`FROM "orders" AS "model"`, query)
+
+	query = db.NewSelect().Model((*Model)(nil)).
+		ColumnsExcept("secret").
+		ColumnsApply("sum").
+		ColumnExpr("count() AS n").
+		String()
+	require.Equal(t,
+		`SELECT * EXCEPT ("secret") APPLY(sum), count() AS n FROM "orders" AS "model"`, query)
+}
+
+func TestSelectLatest(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events,alias:e"`
+
+		Time   time.Time `ch:",pk"`
+		ID     uint64    `ch:",pk"`
+		String string
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().Model((*Model)(nil)).Column("string").Latest(10).String()
+	require.Equal(t,
+		`SELECT "string" FROM "events" AS "e" ORDER BY "e"."time" DESC, "e"."id" DESC LIMIT 10`, query)
+
+	err := db.NewSelect().Table("events").Latest(10).Scan(context.Background())
+	require.EqualError(t, err, "ch: Latest requires a model with pk fields")
+}
+
+func TestSelectSample(t *testing.T) {
+	type Event struct {
+		ch.CHModel `ch:"table:events,alias:e"`
+
+		Name string
+	}
+
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().
+		Model((*Event)(nil)).
+		Final().
+		Sample(0.1).
+		Join("ARRAY JOIN tags AS tag").
+		Where("tag = ?", "foo").
+		String()
+	require.Equal(t, `SELECT "e"."name" FROM "events" AS "e" FINAL SAMPLE 0.1 `+
+		`ARRAY JOIN tags AS tag WHERE (tag = 'foo')`, query)
+
+	query = db.NewSelect().Model((*Event)(nil)).Sample(10000000).String()
+	require.Equal(t, `SELECT "e"."name" FROM "events" AS "e" SAMPLE 10000000`, query)
+
+	query = db.NewSelect().Model((*Event)(nil)).Sample("1/10 OFFSET 1/2").String()
+	require.Equal(t, `SELECT "e"."name" FROM "events" AS "e" SAMPLE 1/10 OFFSET 1/2`, query)
+
+	_, err := db.NewSelect().Model((*Event)(nil)).Sample(1.5).AppendQuery(db.Formatter(), nil)
+	require.EqualError(t, err, "ch: Sample(1.5): ratio must be in (0, 1]")
+
+	_, err = db.NewSelect().Model((*Event)(nil)).Sample(0).AppendQuery(db.Formatter(), nil)
+	require.EqualError(t, err, "ch: Sample(0): number of rows must be positive")
+}
+
+func TestSelectFinal(t *testing.T) {
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().Table("events").Where("id = 1").Limit(1).Final().String()
+	require.Equal(t, `SELECT * FROM "events" FINAL WHERE (id = 1) LIMIT 1`, query)
+}
+
+func TestSelectRunCount(t *testing.T) {
+	type Event struct {
+		ch.CHModel `ch:"table:events,alias:e"`
+
+		TenantID uint64 `ch:",tenant"`
+		Name     string
+	}
+
+	db := ch.Connect(ch.WithMaxRetries(0))
+	defer db.Close()
+
+	hook := new(captureQueryHook)
+	db.AddQueryHook(hook)
+
+	ctx := ch.ContextWithTenant(context.Background(), 42)
+
+	tests := []struct {
+		query func() *ch.SelectQuery
+		scan  string
+		count string
+	}{
+		{
+			func() *ch.SelectQuery {
+				return db.NewSelect().
+					Model((*Event)(nil)).
+					Final().
+					Where("name = ?", "foo").
+					Order("name").
+					Limit(10).
+					Offset(20).
+					Setting("max_threads = 1")
+			},
+			`SELECT "e"."tenant_id", "e"."name" FROM "events" AS "e" FINAL ` +
+				`WHERE ((name = 'foo')) AND ("e"."tenant_id" = 42) ` +
+				`ORDER BY "name" LIMIT 10 OFFSET 20 SETTINGS max_threads = 1`,
+			`SELECT count() FROM "events" AS "e" FINAL ` +
+				`WHERE ((name = 'foo')) AND ("e"."tenant_id" = 42) SETTINGS max_threads = 1`,
+		},
+		{
+			func() *ch.SelectQuery {
+				return db.NewSelect().
+					Model((*Event)(nil)).
+					Column("name").
+					Group("name").
+					Having("count() > 1").
+					WithTotals()
+			},
+			`SELECT "name" FROM "events" AS "e" WHERE ("e"."tenant_id" = 42) ` +
+				`GROUP BY "name" WITH TOTALS HAVING (count() > 1)`,
+			`WITH "_count_wrapper" AS (SELECT "name" FROM "events" AS "e" ` +
+				`WHERE ("e"."tenant_id" = 42) GROUP BY "name" HAVING (count() > 1)) ` +
+				`SELECT count() FROM "_count_wrapper"`,
+		},
+		{
+			func() *ch.SelectQuery {
+				return db.NewSelect().
+					With("names", db.NewSelect().Model((*Event)(nil)).Column("name")).
+					TableExpr("names").
+					AllTenants()
+			},
+			`WITH "names" AS (SELECT "name" FROM "events" AS "e" ` +
+				`WHERE ("e"."tenant_id" = 42)) SELECT * FROM names`,
+			`WITH "names" AS (SELECT "name" FROM "events" AS "e" ` +
+				`WHERE ("e"."tenant_id" = 42)) SELECT count() FROM names`,
+		},
+	}
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			_ = test.query().Scan(ctx)
+			require.Equal(t, test.scan, hook.query)
+
+			_, _ = test.query().Count(ctx)
+			require.Equal(t, test.count, hook.query)
+		})
+	}
+
+	// Count is scoped to the tenant like Scan.
+	_, err := db.NewSelect().Model((*Event)(nil)).Count(context.Background())
+	require.ErrorIs(t, err, ch.ErrTenantRequired)
+}
+
+func TestSelectWithTotals(t *testing.T) {
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().
+		ColumnExpr("project, count()").
+		Table("events").
+		Group("project").
+		WithTotals().
+		Having("count() > 1").
+		String()
+	require.Equal(t,
+		`SELECT project, count() FROM "events" GROUP BY "project" WITH TOTALS HAVING (count() > 1)`,
+		query)
+}
+
+func TestQueryRedactedString(t *testing.T) {
+	db := ch.Connect()
+	defer db.Close()
+
+	q := db.NewSelect().
+		Table("events").
+		Where("? = ?", ch.Ident("name"), "secret").
+		Where("id IN (?)", ch.In([]uint64{1, 2}))
+	require.Equal(t,
+		`SELECT * FROM "events" WHERE ("name" = 'secret') AND (id IN (1, 2))`, q.String())
+	require.Equal(t,
+		`SELECT * FROM "events" WHERE ("name" = ?) AND (id IN (?, ?))`, q.RedactedString())
+
+	drop := db.NewDropTable().Table("events").IfExists()
+	require.Equal(t, `DROP TABLE IF EXISTS "events"`, drop.String())
+}
+
+func TestOnCluster(t *testing.T) {
+	type Model struct {
+		ch.CHModel `ch:"table:events"`
+
+		ID uint64
+	}
+
+	db := ch.Connect(ch.WithCluster("main"))
+	defer db.Close()
+
+	query := db.NewCreateTable().Model((*Model)(nil)).IfNotExists().String()
+	require.Equal(t,
+		`CREATE TABLE IF NOT EXISTS "events" ON CLUSTER "main" (id UInt64) `+
+			`Engine = MergeTree() ORDER BY tuple()`, query)
+
+	query = db.NewDropTable().Model((*Model)(nil)).OnCluster("other").String()
+	require.Equal(t, `DROP TABLE "events" ON CLUSTER "other"`, query)
+
+	query = db.NewTruncateTable().Table("events").IfExists().String()
+	require.Equal(t, `TRUNCATE TABLE IF EXISTS "events" ON CLUSTER "main"`, query)
+
+	cluster, err := db.Cluster(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "main", cluster)
+
+	local := ch.Connect()
+	defer local.Close()
+
+	query = local.NewDropTable().Table("events").String()
+	require.Equal(t, `DROP TABLE "events"`, query)
+}
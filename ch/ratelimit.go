@@ -0,0 +1,64 @@
+package ch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/uptrace/go-clickhouse/ch/internal"
+)
+
+// insertLimiter limits the rate of inserted rows and bytes using token buckets
+// that hold up to one second of tokens. Blocks larger than that are allowed,
+// but wait until the buckets have enough tokens, so a spiky producer is spread
+// over time instead of sending many blocks at once. The size of a block is
+// known only after it is written, so the bytes are taken after the write and
+// the next block waits for them.
+type insertLimiter struct {
+	mu    sync.Mutex
+	rows  internal.TokenBucket
+	bytes internal.TokenBucket
+}
+
+func newInsertLimiter(rowsPerSec, bytesPerSec int) *insertLimiter {
+	return &insertLimiter{
+		rows:  internal.TokenBucket{Rate: float64(rowsPerSec)},
+		bytes: internal.TokenBucket{Rate: float64(bytesPerSec)},
+	}
+}
+
+// wait blocks until a block with numRow rows can be inserted or ctx is done.
+func (l *insertLimiter) wait(ctx context.Context, numRow int) error {
+	l.mu.Lock()
+	now := time.Now()
+	delay := l.rows.Take(now, numRow)
+	if d := l.bytes.Take(now, 0); d > delay {
+		delay = d
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// The block is not inserted so the tokens are returned.
+		l.mu.Lock()
+		l.rows.Return(numRow)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// takeBytes takes the number of uncompressed bytes of a written block.
+func (l *insertLimiter) takeBytes(numByte int64) {
+	l.mu.Lock()
+	_ = l.bytes.Take(time.Now(), int(numByte))
+	l.mu.Unlock()
+}
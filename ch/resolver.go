@@ -0,0 +1,81 @@
+package ch
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// addrResolver periodically resolves the host and returns the resolved
+// addresses in a round-robin fashion.
+type addrResolver struct {
+	addr     string
+	host     string
+	port     string
+	interval time.Duration
+
+	next uint32 // atomic
+
+	mu         sync.Mutex
+	addrs      []string
+	resolvedAt time.Time
+}
+
+func newAddrResolver(addr string, interval time.Duration) *addrResolver {
+	r := &addrResolver{
+		addr:     addr,
+		interval: interval,
+	}
+	if host, port, err := net.SplitHostPort(addr); err == nil && net.ParseIP(host) == nil {
+		r.host = host
+		r.port = port
+	}
+	return r
+}
+
+// Addr returns the next address to dial.
+func (r *addrResolver) Addr(ctx context.Context) (string, error) {
+	if r.host == "" {
+		return r.addr, nil
+	}
+
+	addrs, err := r.resolve(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	n := atomic.AddUint32(&r.next, 1)
+	return addrs[int(n-1)%len(addrs)], nil
+}
+
+func (r *addrResolver) resolve(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.addrs) > 0 && time.Since(r.resolvedAt) < r.interval {
+		return r.addrs, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, r.host)
+	if err != nil || len(ips) == 0 {
+		if len(r.addrs) > 0 {
+			// Keep using the stale addresses until the DNS is back.
+			return r.addrs, nil
+		}
+		if err == nil {
+			err = &net.DNSError{Err: "no such host", Name: r.host, IsNotFound: true}
+		}
+		return nil, err
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip.String(), r.port)
+	}
+
+	r.addrs = addrs
+	r.resolvedAt = time.Now()
+	return addrs, nil
+}
@@ -0,0 +1,203 @@
+package ch
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/uptrace/go-clickhouse/ch/chpool"
+	"github.com/uptrace/go-clickhouse/ch/internal"
+)
+
+// RetryPolicy decides whether a failed query is retried. It is only consulted
+// for idempotent operations: SELECT-like queries. Inserts and other statements
+// are never retried because they may have been applied by the server.
+type RetryPolicy interface {
+	// Retry reports whether the query that failed with err should be retried
+	// and how long to wait before the retry. Attempt starts at 0.
+	Retry(attempt int, err error) (time.Duration, bool)
+}
+
+// DefaultRetryableCodes are ClickHouse error codes retried by BackoffRetryPolicy
+// when RetryableCodes is nil.
+var DefaultRetryableCodes = []int32{
+	CodeTimeoutExceeded,
+	CodeTooManySimultaneousQueries,
+	CodeSocketTimeout,
+	CodeNetworkError,
+	CodeMemoryLimitExceeded,
+}
+
+// BackoffRetryPolicy retries queries with an exponential backoff.
+type BackoffRetryPolicy struct {
+	// MaxRetries is the maximum number of retries. Zero disables retries.
+	MaxRetries int
+	// MinBackoff is the backoff before the first retry. It is doubled
+	// on each retry up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Jitter randomizes the backoff between MinBackoff and the computed value.
+	Jitter bool
+	// RetryableCodes are ClickHouse error codes that are retried.
+	// Nil means DefaultRetryableCodes.
+	RetryableCodes []int32
+}
+
+var _ RetryPolicy = (*BackoffRetryPolicy)(nil)
+
+func (p *BackoffRetryPolicy) Retry(attempt int, err error) (time.Duration, bool) {
+	if attempt >= p.MaxRetries || !p.retryable(err) {
+		return 0, false
+	}
+	return p.backoff(attempt), true
+}
+
+func (p *BackoffRetryPolicy) retryable(err error) bool {
+	switch err {
+	case driver.ErrBadConn:
+		return true
+	case nil, context.Canceled, context.DeadlineExceeded:
+		return false
+	}
+
+	if err, ok := err.(*Error); ok {
+		codes := p.RetryableCodes
+		if codes == nil {
+			codes = DefaultRetryableCodes
+		}
+		for _, code := range codes {
+			if err.Code == code {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (p *BackoffRetryPolicy) backoff(attempt int) time.Duration {
+	if p.Jitter {
+		return internal.RetryBackoff(attempt, p.MinBackoff, p.MaxBackoff)
+	}
+	if p.MinBackoff <= 0 {
+		return 0
+	}
+
+	d := p.MinBackoff << uint(attempt)
+	if d > p.MaxBackoff || d < p.MinBackoff {
+		d = p.MaxBackoff
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// WithRetryPolicy configures the policy used to retry failed idempotent queries.
+// It overrides WithMaxRetries, WithMinRetryBackoff, and WithMaxRetryBackoff.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(db *DB) {
+		db.cfg.RetryPolicy = policy
+	}
+}
+
+func (db *DB) retryPolicy() RetryPolicy {
+	if db.cfg.RetryPolicy != nil {
+		return db.cfg.RetryPolicy
+	}
+	return &BackoffRetryPolicy{
+		MaxRetries: db.cfg.MaxRetries,
+		MinBackoff: db.cfg.MinRetryBackoff,
+		MaxBackoff: db.cfg.MaxRetryBackoff,
+		Jitter:     true,
+	}
+}
+
+// withRetry calls fn until it succeeds or the retry policy gives up.
+// Non-idempotent operations are executed once.
+func (db *DB) withRetry(ctx context.Context, idempotent bool, fn func() error) error {
+	err := fn()
+	if !idempotent {
+		return err
+	}
+
+	policy := db.retryPolicy()
+	for attempt := 0; err != nil; attempt++ {
+		backoff, ok := policy.Retry(attempt, err)
+		if !ok {
+			break
+		}
+		if err := internal.Sleep(ctx, backoff); err != nil {
+			return err
+		}
+		err = fn()
+	}
+	return err
+}
+
+// isIdempotentQuery reports whether the query only reads data.
+func isIdempotentQuery(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	i := strings.IndexAny(query, " \t\r\n(")
+	if i == -1 {
+		i = len(query)
+	}
+	switch strings.ToUpper(query[:i]) {
+	case "SELECT", "WITH", "SHOW", "DESCRIBE", "DESC", "EXISTS", "EXPLAIN":
+		return true
+	}
+	return false
+}
+
+//------------------------------------------------------------------------------
+
+const (
+	connectRetryMinBackoff = 250 * time.Millisecond
+	connectRetryMaxBackoff = 5 * time.Second
+)
+
+// connectRetry is like connect, but retries connection errors
+// for up to ConnectRetryTimeout.
+func (db *DB) connectRetry(ctx context.Context) (*chpool.Conn, error) {
+	cn, err := db.connect(ctx)
+	if err == nil || db.cfg.ConnectRetryTimeout <= 0 {
+		return cn, err
+	}
+
+	deadline := time.Now().Add(db.cfg.ConnectRetryTimeout)
+	for attempt := 0; isConnectRetryable(err); attempt++ {
+		backoff := internal.RetryBackoff(attempt, connectRetryMinBackoff, connectRetryMaxBackoff)
+		if time.Now().Add(backoff).After(deadline) {
+			break
+		}
+		if err := internal.Sleep(ctx, backoff); err != nil {
+			return nil, err
+		}
+
+		cn, err = db.connect(ctx)
+		if err == nil {
+			return cn, nil
+		}
+	}
+	return nil, err
+}
+
+// isConnectRetryable reports whether err is returned by a server that is
+// not ready to accept connections yet.
+func isConnectRetryable(err error) bool {
+	switch err {
+	case context.Canceled, context.DeadlineExceeded, chpool.ErrClosed, chpool.ErrPoolTimeout:
+		return false
+	}
+	if IsErrorCode(err, CodeSocketTimeout, CodeNetworkError, CodeTimeoutExceeded) {
+		return true
+	}
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || isStaleConnError(err)
+}
@@ -0,0 +1,72 @@
+package ch_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestSchemaDump(t *testing.T) {
+	type Span struct {
+		ch.CHModel `ch:"table:spans,partition:toDate(time)"`
+
+		ID   uint64 `ch:",pk"`
+		Name string `ch:",lc"`
+	}
+
+	schema, err := ch.DumpSchema((*Span)(nil))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	_, err = schema.WriteTo(&buf)
+	require.NoError(t, err)
+
+	loaded, err := chschema.ReadSchema(&buf)
+	require.NoError(t, err)
+	require.Empty(t, schema.Diff(loaded))
+
+	loaded.Tables[0].Columns[1].Type = "String"
+	loaded.Tables[0].Columns = append(loaded.Tables[0].Columns, &chschema.ColumnSchema{
+		Name: "kind",
+		Type: "String",
+	})
+	require.Equal(t, []string{
+		"table spans: column name changed from String to LowCardinality(String)",
+		"table spans: column kind removed",
+	}, schema.Diff(loaded))
+}
+
+func TestRegisterModels(t *testing.T) {
+	type Trace struct {
+		ch.CHModel `ch:"table:registry_traces"`
+
+		TraceID uint64 `ch:",pk"`
+		Name    *string
+	}
+
+	tables, err := ch.RegisterModels((*Trace)(nil))
+	require.NoError(t, err)
+	require.Len(t, tables, 1)
+
+	table := chschema.TableByName("registry_traces")
+	require.Same(t, tables[0], table)
+	require.Contains(t, chschema.RegisteredTables(), table)
+
+	schema := chschema.NewSchema([]*chschema.Table{table})
+	require.Equal(t, []*chschema.ColumnSchema{
+		{Name: "trace_id", Type: "UInt64", GoName: "TraceID", GoType: "uint64", PK: true},
+		{Name: "name", Type: "Nullable(String)", GoName: "Name", GoType: "*string"},
+	}, schema.Tables[0].Columns)
+
+	// Go names are not compared.
+	other := chschema.NewSchema([]*chschema.Table{table})
+	other.Tables[0].Columns[1].GoName = "Title"
+	require.Empty(t, schema.Diff(other))
+
+	_, err = ch.RegisterModels("registry_traces")
+	require.EqualError(t, err, "ch: RegisterModels(unsupported string)")
+}
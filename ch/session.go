@@ -0,0 +1,115 @@
+package ch
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/uptrace/go-clickhouse/ch/chpool"
+)
+
+// ErrSessionClosed is returned when a Session is used after Close or after
+// its connection was broken.
+var ErrSessionClosed = errors.New("ch: session is closed")
+
+// Session is a DB handle pinned to a single connection. ClickHouse keeps
+// temporary tables and session settings per native connection so queries
+// that rely on them must use the same Session.
+//
+// A Session is safe for concurrent use, but queries are executed one at a
+// time. Rows returned by the session must be closed before the next query
+// can start.
+type Session struct {
+	*DB
+	sess *session
+}
+
+type session struct {
+	sem chan struct{} // serializes use of cn
+
+	mu     sync.Mutex
+	cn     *chpool.Conn
+	err    error
+	closed bool
+}
+
+// Session reserves a connection from the pool and returns a handle that
+// executes all queries on that connection. The session must be closed to
+// return the connection to the pool.
+func (db *DB) Session(ctx context.Context) (*Session, error) {
+	if db.session != nil {
+		return nil, errors.New("ch: nested sessions are not supported")
+	}
+
+	cn, err := db.connectRetry(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &session{
+		sem: make(chan struct{}, 1),
+		cn:  cn,
+	}
+
+	clone := db.clone()
+	clone.session = sess
+
+	return &Session{
+		DB:   clone,
+		sess: sess,
+	}, nil
+}
+
+// Close returns the connection to the pool. It does not close the DB.
+func (s *Session) Close() error {
+	// Wait for the running query, if any, to release the conn.
+	s.sess.sem <- struct{}{}
+	defer func() { <-s.sess.sem }()
+
+	s.sess.mu.Lock()
+	defer s.sess.mu.Unlock()
+
+	if s.sess.closed {
+		return nil
+	}
+	s.sess.closed = true
+
+	if s.sess.err != nil || s.sess.cn.Closed() {
+		s.DB.pool.Remove(s.sess.cn, s.sess.err)
+	} else {
+		s.DB.pool.Put(s.sess.cn)
+	}
+	return nil
+}
+
+func (s *session) getConn(ctx context.Context) (*chpool.Conn, error) {
+	select {
+	case s.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed || s.err != nil {
+		<-s.sem
+		return nil, ErrSessionClosed
+	}
+	return s.cn, nil
+}
+
+func (s *session) releaseConn(cn *chpool.Conn, err error) {
+	// Server exceptions end the query cleanly so the conn stays usable.
+	var chErr *Error
+	if err != nil && !errors.As(err, &chErr) {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+	} else if cn.Closed() {
+		s.mu.Lock()
+		s.err = ErrSessionClosed
+		s.mu.Unlock()
+	}
+	<-s.sem
+}
@@ -0,0 +1,138 @@
+package ch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/uptrace/go-clickhouse/ch/chpool"
+	"github.com/uptrace/go-clickhouse/ch/internal"
+)
+
+type settingsCtxKey struct{}
+
+// ContextWithQuerySettings returns a copy of ctx with settings that are sent
+// along with queries executed using that context, for example:
+//
+//	ctx = ch.ContextWithQuerySettings(ctx, map[string]any{"mutations_sync": 2})
+//	_, err := db.ExecContext(ctx, "ALTER TABLE ... DELETE WHERE ...")
+//
+// The settings override Config.QuerySettings and settings from the parent context.
+func ContextWithQuerySettings(ctx context.Context, settings map[string]any) context.Context {
+	if parent := querySettings(ctx); len(parent) > 0 {
+		merged := make(map[string]any, len(parent)+len(settings))
+		for k, v := range parent {
+			merged[k] = v
+		}
+		for k, v := range settings {
+			merged[k] = v
+		}
+		settings = merged
+	}
+	return context.WithValue(ctx, settingsCtxKey{}, settings)
+}
+
+func querySettings(ctx context.Context) map[string]any {
+	settings, _ := ctx.Value(settingsCtxKey{}).(map[string]any)
+	return settings
+}
+
+// settingsDiff runs logSettingsDiff once in the background. The query is
+// canceled when the DB is closed.
+type settingsDiff struct {
+	once   sync.Once
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newSettingsDiff() *settingsDiff {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &settingsDiff{ctx: ctx, cancel: cancel}
+}
+
+func (d *settingsDiff) start(db *DB) {
+	d.once.Do(func() {
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			db.logSettingsDiff(d.ctx)
+		}()
+	})
+}
+
+// stop cancels the query. Blocks that are being read are not interrupted
+// by ctx, so call wait after the connections are closed.
+func (d *settingsDiff) stop() {
+	d.once.Do(func() {}) // don't start after the DB is closed
+	d.cancel()
+}
+
+func (d *settingsDiff) wait() {
+	d.wg.Wait()
+}
+
+// logSettingsDiff logs QuerySettings that differ from the server settings.
+func (db *DB) logSettingsDiff(ctx context.Context) {
+	names := make([]string, 0, len(db.cfg.QuerySettings))
+	for name := range db.cfg.QuerySettings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Select the server settings without the client settings.
+	newcfg := *db.cfg
+	newcfg.QuerySettings = nil
+	clone := db.clone()
+	clone.cfg = &newcfg
+
+	var settings []struct {
+		Name  string
+		Value string
+	}
+	if err := clone.NewSelect().
+		ColumnExpr("name, value").
+		TableExpr("system.settings").
+		Where("name IN (?)", In(names)).
+		Scan(ctx, &settings); err != nil {
+		if ctx.Err() == nil && !errors.Is(err, chpool.ErrClosed) {
+			internal.Logger.Printf("can't select server settings: %s", err)
+		}
+		return
+	}
+
+	server := make(map[string]string, len(settings))
+	for _, setting := range settings {
+		server[setting.Name] = setting.Value
+	}
+
+	var diffs []string
+	for _, name := range names {
+		value := formatSettingValue(db.cfg.QuerySettings[name])
+		serverValue, ok := server[name]
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s=%s (unknown setting)", name, value))
+		case value != serverValue:
+			diffs = append(diffs, fmt.Sprintf("%s=%s (server: %s)", name, value, serverValue))
+		}
+	}
+	if len(diffs) > 0 {
+		internal.Logger.Printf("QuerySettings differ from server settings: %s",
+			strings.Join(diffs, ", "))
+	}
+}
+
+// formatSettingValue formats the value the way system.settings does.
+func formatSettingValue(value any) string {
+	if b, ok := value.(bool); ok {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprint(value)
+}
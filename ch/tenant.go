@@ -0,0 +1,33 @@
+package ch
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrTenantRequired is returned when a query on a tenant-scoped model
+// is executed without a tenant in the context.
+var ErrTenantRequired = errors.New("ch: query requires a tenant")
+
+type tenantCtxKey struct{}
+
+// ContextWithTenant returns a copy of ctx that carries the tenant id.
+//
+// Select queries on models that have a field with the tenant option, for example,
+// `ch:",tenant"`, are filtered by that field using the tenant id from the
+// context. Inserts of such models set the field of rows with the zero value
+// to the tenant id and reject rows of other tenants. Queries without a tenant
+// fail with ErrTenantRequired unless AllTenants is used.
+//
+// Only the Select and Insert query builders are scoped. Queries executed with
+// ExecContext or QueryContext, for example, ALTER TABLE ... DELETE mutations,
+// must filter by the tenant explicitly.
+func ContextWithTenant(ctx context.Context, tenantID any) context.Context {
+	return context.WithValue(ctx, tenantCtxKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant id set with ContextWithTenant.
+func TenantFromContext(ctx context.Context) (any, bool) {
+	tenantID := ctx.Value(tenantCtxKey{})
+	return tenantID, tenantID != nil
+}
@@ -0,0 +1,185 @@
+package ch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+type captureQueryHook struct {
+	query string
+}
+
+func (h *captureQueryHook) BeforeQuery(ctx context.Context, evt *ch.QueryEvent) context.Context {
+	h.query = evt.Query
+	return ctx
+}
+
+func (h *captureQueryHook) AfterQuery(ctx context.Context, evt *ch.QueryEvent) {}
+
+func TestTenantScope(t *testing.T) {
+	type Event struct {
+		ch.CHModel `ch:"table:events,alias:e"`
+
+		TenantID uint64 `ch:",tenant"`
+		Name     string
+	}
+
+	db := ch.Connect(ch.WithMaxRetries(0))
+	defer db.Close()
+
+	hook := new(captureQueryHook)
+	db.AddQueryHook(hook)
+
+	var events []Event
+
+	err := db.NewSelect().Model(&events).Where("name = ?", "foo").Scan(context.Background())
+	require.ErrorIs(t, err, ch.ErrTenantRequired)
+
+	ctx := ch.ContextWithTenant(context.Background(), 42)
+	_ = db.NewSelect().Model(&events).Where("name = ?", "foo").WhereOr("name = ?", "bar").Scan(ctx)
+	require.Equal(t,
+		`SELECT "e"."tenant_id", "e"."name" FROM "events" AS "e" `+
+			`WHERE ((name = 'foo') OR (name = 'bar')) AND ("e"."tenant_id" = 42)`,
+		hook.query)
+
+	_ = db.NewSelect().Model(&events).AllTenants().Scan(context.Background())
+	require.Equal(t, `SELECT "e"."tenant_id", "e"."name" FROM "events" AS "e"`, hook.query)
+
+	// Subqueries in the WITH clause are scoped to the tenant too.
+	_ = db.NewSelect().
+		With("foo", db.NewSelect().Model((*Event)(nil)).Where("name = ?", "foo")).
+		TableExpr("foo").
+		Scan(ctx)
+	require.Equal(t,
+		`WITH "foo" AS (SELECT "e"."tenant_id", "e"."name" FROM "events" AS "e" `+
+			`WHERE ((name = 'foo')) AND ("e"."tenant_id" = 42)) SELECT * FROM foo`,
+		hook.query)
+
+	_ = db.NewSelect().
+		TableSubquery(db.NewSelect().Model((*Event)(nil)), "foo").
+		Scan(ctx)
+	require.Equal(t,
+		`SELECT * FROM (SELECT "e"."tenant_id", "e"."name" FROM "events" AS "e" `+
+			`WHERE ("e"."tenant_id" = 42)) AS "foo"`,
+		hook.query)
+
+	_ = db.NewSelect().
+		TableExpr("(?) AS foo", db.NewSelect().Model((*Event)(nil))).
+		Where("name IN (?)", db.NewSelect().Model((*Event)(nil)).Column("name")).
+		Scan(ctx)
+	require.Equal(t,
+		`SELECT * FROM (SELECT "e"."tenant_id", "e"."name" FROM "events" AS "e" `+
+			`WHERE ("e"."tenant_id" = 42)) AS foo WHERE (name IN (SELECT "name" `+
+			`FROM "events" AS "e" WHERE ("e"."tenant_id" = 42)))`,
+		hook.query)
+
+	err = db.NewSelect().
+		With("foo", db.NewSelect().Model((*Event)(nil))).
+		TableExpr("foo").
+		Scan(context.Background())
+	require.ErrorIs(t, err, ch.ErrTenantRequired)
+}
+
+type tenantQueryHook struct {
+	mu      sync.Mutex
+	queries map[any]string
+}
+
+func (h *tenantQueryHook) BeforeQuery(ctx context.Context, evt *ch.QueryEvent) context.Context {
+	tenantID, _ := ch.TenantFromContext(ctx)
+	h.mu.Lock()
+	h.queries[tenantID] = evt.Query
+	h.mu.Unlock()
+	return ctx
+}
+
+func (h *tenantQueryHook) AfterQuery(ctx context.Context, evt *ch.QueryEvent) {}
+
+func TestTenantScopeConcurrent(t *testing.T) {
+	type Event struct {
+		ch.CHModel `ch:"table:events,alias:e"`
+
+		TenantID uint64 `ch:",tenant"`
+	}
+
+	db := ch.Connect(
+		ch.WithMaxRetries(0),
+		ch.WithDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errors.New("dial failed")
+		}),
+	)
+	defer db.Close()
+
+	hook := &tenantQueryHook{queries: make(map[any]string)}
+	db.AddQueryHook(hook)
+
+	// The same query is executed for different tenants at the same time.
+	q := db.NewSelect().TableSubquery(db.NewSelect().Model((*Event)(nil)), "sub")
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 10; i++ {
+		wg.Add(1)
+		go func(tenantID int) {
+			defer wg.Done()
+			_ = q.Scan(ch.ContextWithTenant(context.Background(), tenantID))
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, hook.queries, 10)
+	for tenantID, query := range hook.queries {
+		require.Contains(t, query, fmt.Sprintf(`("e"."tenant_id" = %d)`, tenantID))
+	}
+}
+
+func TestTenantScopeInsert(t *testing.T) {
+	type Event struct {
+		ch.CHModel `ch:"table:events"`
+
+		TenantID uint64 `ch:",tenant"`
+		Name     string
+	}
+
+	errDial := errors.New("dial failed")
+	db := ch.Connect(ch.WithMaxRetries(0), ch.WithDialer(
+		func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, errDial
+		}))
+	defer db.Close()
+
+	ctx := ch.ContextWithTenant(context.Background(), 42)
+
+	_, err := db.NewInsert().Model(&Event{Name: "foo"}).Exec(context.Background())
+	require.ErrorIs(t, err, ch.ErrTenantRequired)
+
+	// Rows without a tenant are assigned to the tenant from the context.
+	events := []Event{{Name: "foo"}, {TenantID: 42, Name: "bar"}}
+	_, err = db.NewInsert().Model(&events).Exec(ctx)
+	require.ErrorIs(t, err, errDial)
+	require.Equal(t, uint64(42), events[0].TenantID)
+	require.Equal(t, uint64(42), events[1].TenantID)
+
+	events = []Event{{Name: "foo"}, {TenantID: 7, Name: "bar"}}
+	_, err = db.NewInsert().Model(&events).Exec(ctx)
+	var rowErr *ch.RowError
+	require.True(t, errors.As(err, &rowErr), err)
+	require.Equal(t, 1, rowErr.Row)
+	require.EqualError(t, rowErr.Err, "ch: row of tenant 7 can't be inserted by tenant 42")
+
+	// AllTenants inserts the rows as is.
+	events = []Event{{Name: "foo"}, {TenantID: 7, Name: "bar"}}
+	_, err = db.NewInsert().Model(&events).AllTenants().Exec(context.Background())
+	require.ErrorIs(t, err, errDial)
+	require.Equal(t, uint64(0), events[0].TenantID)
+
+	_, err = db.NewInsert().Model(&Event{}).Exec(ch.ContextWithTenant(context.Background(), "acme"))
+	require.EqualError(t, err, "ch: can't use tenant acme (string) as uint64")
+}
@@ -0,0 +1,122 @@
+package ch
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+const day = 24 * time.Hour
+
+// ToStartOfInterval returns a toStartOfInterval(expr, INTERVAL n unit, tz)
+// expression that groups rows into buckets of the given interval in the time
+// zone of loc, for example:
+//
+//	db.NewSelect().
+//		ColumnExpr("? AS bucket", ch.ToStartOfInterval("time", 5*time.Minute, time.UTC)).
+//		ColumnExpr("count()").
+//		Group("bucket")
+//
+// expr is included in the query as is. The interval is expressed in the largest
+// unit that divides it evenly, from days down to nanoseconds. Nil loc omits
+// the time zone so the time zone of the column is used. Use StartOfInterval
+// with times in loc to compute the same buckets in Go.
+//
+// Non-positive intervals and time.Local, which has no IANA name, are reported
+// as errors when the query is formatted.
+func ToStartOfInterval(expr string, interval time.Duration, loc *time.Location) chschema.QueryAppender {
+	return &intervalBucket{
+		expr:     expr,
+		interval: interval,
+		loc:      loc,
+	}
+}
+
+type intervalBucket struct {
+	expr     string
+	interval time.Duration
+	loc      *time.Location
+}
+
+var _ chschema.QueryAppender = (*intervalBucket)(nil)
+
+func (b *intervalBucket) AppendQuery(fmter chschema.Formatter, bb []byte) ([]byte, error) {
+	if b.interval <= 0 {
+		return nil, fmt.Errorf("ch: ToStartOfInterval got non-positive interval %s", b.interval)
+	}
+	if b.loc == time.Local {
+		return nil, errors.New("ch: ToStartOfInterval requires a named location, got Local")
+	}
+
+	n, unit := intervalUnit(b.interval)
+	bb = append(bb, "toStartOfInterval("...)
+	bb = append(bb, b.expr...)
+	bb = append(bb, ", INTERVAL "...)
+	bb = strconv.AppendInt(bb, n, 10)
+	bb = append(bb, ' ')
+	bb = append(bb, unit...)
+	if b.loc != nil {
+		bb = append(bb, ", "...)
+		bb = chschema.AppendString(bb, b.loc.String())
+	}
+	bb = append(bb, ')')
+	return bb, nil
+}
+
+// StartOfInterval returns the start of the interval tm belongs to. It matches
+// the buckets produced by ToStartOfInterval as long as tm has the same location
+// as the ClickHouse column: intervals shorter than a day are aligned to the
+// Unix epoch and day intervals are aligned to the epoch date in tm's location.
+func StartOfInterval(tm time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return tm
+	}
+
+	if interval%day == 0 {
+		days := int64(interval / day)
+		year, month, dayOfMonth := tm.Date()
+		date := time.Date(year, month, dayOfMonth, 0, 0, 0, 0, time.UTC)
+		dayNum := floorDiv(date.Unix(), int64(day/time.Second))
+		dayNum -= floorMod(dayNum, days)
+		date = time.Unix(dayNum*int64(day/time.Second), 0).UTC()
+		return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, tm.Location())
+	}
+
+	nsec := tm.UnixNano()
+	nsec -= floorMod(nsec, int64(interval))
+	return time.Unix(0, nsec).In(tm.Location())
+}
+
+func intervalUnit(d time.Duration) (int64, string) {
+	switch {
+	case d%day == 0:
+		return int64(d / day), "DAY"
+	case d%time.Hour == 0:
+		return int64(d / time.Hour), "HOUR"
+	case d%time.Minute == 0:
+		return int64(d / time.Minute), "MINUTE"
+	case d%time.Second == 0:
+		return int64(d / time.Second), "SECOND"
+	case d%time.Millisecond == 0:
+		return int64(d / time.Millisecond), "MILLISECOND"
+	case d%time.Microsecond == 0:
+		return int64(d / time.Microsecond), "MICROSECOND"
+	default:
+		return int64(d), "NANOSECOND"
+	}
+}
+
+func floorDiv(a, b int64) int64 {
+	return (a - floorMod(a, b)) / b
+}
+
+func floorMod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
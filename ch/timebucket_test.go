@@ -0,0 +1,64 @@
+package ch_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+func TestToStartOfInterval(t *testing.T) {
+	db := ch.Connect()
+	defer db.Close()
+
+	query := db.NewSelect().
+		ColumnExpr("? AS bucket", ch.ToStartOfInterval("time", 90*time.Minute, nil)).
+		TableExpr("spans").
+		String()
+	require.Equal(t,
+		"SELECT toStartOfInterval(time, INTERVAL 90 MINUTE) AS bucket FROM spans", query)
+
+	query = db.NewSelect().
+		ColumnExpr("?", ch.ToStartOfInterval("time", 2*24*time.Hour, time.UTC)).
+		String()
+	require.Equal(t, "SELECT toStartOfInterval(time, INTERVAL 2 DAY, 'UTC')", query)
+
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	query = db.NewSelect().
+		ColumnExpr("?", ch.ToStartOfInterval("time", time.Hour, loc)).
+		String()
+	require.Equal(t, "SELECT toStartOfInterval(time, INTERVAL 1 HOUR, 'Europe/Berlin')", query)
+
+	for _, bucket := range []chschema.QueryAppender{
+		ch.ToStartOfInterval("time", 0, nil),
+		ch.ToStartOfInterval("time", -time.Minute, time.UTC),
+		ch.ToStartOfInterval("time", time.Minute, time.Local),
+	} {
+		_, err := bucket.AppendQuery(chschema.NewFormatter(), nil)
+		require.Error(t, err)
+	}
+}
+
+func TestStartOfInterval(t *testing.T) {
+	tm := time.Date(2022, 6, 15, 13, 47, 12, 500, time.UTC)
+
+	require.Equal(t,
+		time.Date(2022, 6, 15, 13, 45, 0, 0, time.UTC),
+		ch.StartOfInterval(tm, 5*time.Minute))
+	require.Equal(t,
+		time.Date(2022, 6, 15, 12, 0, 0, 0, time.UTC),
+		ch.StartOfInterval(tm, 3*time.Hour))
+	// 2022-06-15 is day 19158 since the epoch and 19152 is a multiple of 7.
+	require.Equal(t,
+		time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC),
+		ch.StartOfInterval(tm, 7*24*time.Hour))
+
+	loc := time.FixedZone("UTC+3", 3*3600)
+	require.Equal(t,
+		time.Date(2022, 6, 15, 0, 0, 0, 0, loc),
+		ch.StartOfInterval(tm.In(loc), 24*time.Hour))
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/fatih/color"
@@ -38,6 +39,29 @@ func WithWriter(w io.Writer) Option {
 	}
 }
 
+// WithPartitionPruning configures the hook to run EXPLAIN ESTIMATE for
+// SELECT queries and to log a warning when a query reads more than maxParts
+// parts or maxRows rows from a table. Zero disables the corresponding check.
+// It is intended to catch missing partition filters during development.
+//
+// EXPLAIN runs in the background with a new context, so it doesn't inherit
+// the query id, settings, and tenant of the query. Queries of sessions are not
+// checked, because they may use temporary tables of the session.
+func WithPartitionPruning(maxParts, maxRows uint64) Option {
+	return func(h *QueryHook) {
+		h.maxParts = maxParts
+		h.maxRows = maxRows
+	}
+}
+
+// WithExplainDB sets the DB that runs EXPLAIN ESTIMATE for WithPartitionPruning.
+// By default, it is the DB of the query.
+func WithExplainDB(db *ch.DB) Option {
+	return func(h *QueryHook) {
+		h.explainDB = db
+	}
+}
+
 // FromEnv configures the hook using the environment variable value.
 // For example, WithEnv("CHDEBUG"):
 //    - CHDEBUG=0 - disables the hook.
@@ -59,6 +83,12 @@ type QueryHook struct {
 	enabled bool
 	verbose bool
 	writer  io.Writer
+
+	maxParts  uint64
+	maxRows   uint64
+	explainDB *ch.DB
+
+	mu sync.Mutex // serializes writes from the EXPLAIN goroutines
 }
 
 var _ ch.QueryHook = (*QueryHook)(nil)
@@ -83,6 +113,11 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *ch.QueryEvent) {
 		return
 	}
 
+	if ctx.Value(explainCtxKey{}) != nil {
+		return
+	}
+	h.checkPartitionPruning(event)
+
 	if !h.verbose {
 		switch event.Err {
 		case nil, sql.ErrNoRows:
@@ -109,6 +144,12 @@ func (h *QueryHook) AfterQuery(ctx context.Context, event *ch.QueryEvent) {
 		)
 	}
 
+	h.println(args...)
+}
+
+func (h *QueryHook) println(args ...any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	fmt.Fprintln(h.writer, args...)
 }
 
@@ -131,3 +172,76 @@ func operationColor(operation string) *color.Color {
 		return color.New(color.BgWhite, color.FgHiBlack)
 	}
 }
+
+//------------------------------------------------------------------------------
+
+type explainCtxKey struct{}
+
+type estimate struct {
+	database string
+	table    string
+	parts    uint64
+	rows     uint64
+	marks    uint64
+}
+
+func (h *QueryHook) checkPartitionPruning(event *ch.QueryEvent) {
+	if h.maxParts == 0 && h.maxRows == 0 {
+		return
+	}
+	if event.Err != nil || event.DB == nil || event.DB.InSession() ||
+		event.Operation() != "SELECT" {
+		return
+	}
+
+	db := h.explainDB
+	if db == nil {
+		db = event.DB
+	}
+	// The caller may still be reading the rows, so EXPLAIN can't wait
+	// for the hook to return.
+	go h.explain(db, event.Query)
+}
+
+func (h *QueryHook) explain(db *ch.DB, query string) {
+	estimates, err := explainEstimate(db, query)
+	if err != nil {
+		h.println("[ch] EXPLAIN ESTIMATE failed:", err)
+		return
+	}
+
+	for _, est := range estimates {
+		if (h.maxParts > 0 && est.parts > h.maxParts) ||
+			(h.maxRows > 0 && est.rows > h.maxRows) {
+			h.println(
+				"[ch]",
+				color.New(color.BgYellow, color.FgHiBlack).Sprint(" PARTITION PRUNING "),
+				fmt.Sprintf(" %s.%s: parts=%d rows=%d marks=%d ",
+					est.database, est.table, est.parts, est.rows, est.marks),
+				query,
+			)
+		}
+	}
+}
+
+func explainEstimate(db *ch.DB, query string) ([]estimate, error) {
+	ctx := context.WithValue(context.Background(), explainCtxKey{}, struct{}{})
+
+	rows, err := db.QueryContext(ctx, "EXPLAIN ESTIMATE "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var estimates []estimate
+	for rows.Next() {
+		var est estimate
+		if err := rows.Scan(
+			&est.database, &est.table, &est.parts, &est.rows, &est.marks,
+		); err != nil {
+			return nil, err
+		}
+		estimates = append(estimates, est)
+	}
+	return estimates, rows.Err()
+}
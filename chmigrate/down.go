@@ -0,0 +1,160 @@
+package chmigrate
+
+import (
+	"regexp"
+	"strings"
+)
+
+type sqlMigrationConfig struct {
+	up       string
+	downStub bool
+}
+
+type SQLMigrationOption func(cfg *sqlMigrationConfig)
+
+// WithUpSQL sets the content of the up migration instead of the template.
+func WithUpSQL(query string) SQLMigrationOption {
+	return func(cfg *sqlMigrationConfig) {
+		cfg.up = query
+	}
+}
+
+// WithDownStub generates the down migration from the up migration. See DownSQL.
+func WithDownStub() SQLMigrationOption {
+	return func(cfg *sqlMigrationConfig) {
+		cfg.downStub = true
+	}
+}
+
+var (
+	createRE = regexp.MustCompile(`(?is)^CREATE\s+(TEMPORARY\s+)?` +
+		`(TABLE|VIEW|MATERIALIZED\s+VIEW|LIVE\s+VIEW|WINDOW\s+VIEW|DICTIONARY|DATABASE)\s+` +
+		"(IF\\s+NOT\\s+EXISTS\\s+)?([^\\s(]+)(\\s+ON\\s+CLUSTER\\s+[^\\s(]+)?")
+	renameRE = regexp.MustCompile(`(?is)^RENAME\s+(TABLE|DATABASE|DICTIONARY)\s+(.+?)` +
+		`(\s+ON\s+CLUSTER\s+\S+)?$`)
+	renamePairRE = regexp.MustCompile(`(?is)^(\S+)\s+TO\s+(\S+)$`)
+	alterRE      = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\S+)(\s+ON\s+CLUSTER\s+\S+)?\s+(ADD\s.+)$`)
+	alterAddRE   = regexp.MustCompile(`(?is)^ADD\s+(COLUMN|INDEX|PROJECTION)\s+` +
+		"(IF\\s+NOT\\s+EXISTS\\s+)?([^\\s(]+)")
+)
+
+// DownSQL returns a best-effort down migration for the up SQL migration.
+// CREATE statements are reverted with DROP, RENAME statements with the inverse
+// RENAME, and ALTER TABLE ... ADD COLUMN/INDEX/PROJECTION with the matching DROP.
+// Other statements are left as TODO comments that must be reverted by hand.
+// Statements are reverted in the reverse order.
+func DownSQL(up string) string {
+	stmts := splitSQL(up)
+
+	down := make([]string, 0, len(stmts))
+	for i := len(stmts) - 1; i >= 0; i-- {
+		down = append(down, revertStatement(stmts[i]))
+	}
+	return joinStatements(down)
+}
+
+// splitSQL splits the migration into statements and removes comments.
+func splitSQL(content string) []string {
+	var stmts []string
+	var lines []string
+
+	flush := func() {
+		stmt := strings.TrimSpace(strings.Join(lines, "\n"))
+		stmt = strings.TrimSpace(strings.TrimSuffix(stmt, ";"))
+		if stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		lines = lines[:0]
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "--migration:split" {
+			flush()
+			continue
+		}
+		if strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+
+	return stmts
+}
+
+func revertStatement(stmt string) string {
+	if m := createRE.FindStringSubmatch(stmt); m != nil {
+		kind := strings.ToUpper(strings.Join(strings.Fields(m[2]), " "))
+		switch kind {
+		case "MATERIALIZED VIEW", "LIVE VIEW", "WINDOW VIEW":
+			kind = "VIEW"
+		}
+		return "DROP " + kind + " IF EXISTS " + m[4] + m[5]
+	}
+
+	if m := renameRE.FindStringSubmatch(stmt); m != nil {
+		pairs := strings.Split(m[2], ",")
+		inverse := make([]string, 0, len(pairs))
+		for _, pair := range pairs {
+			p := renamePairRE.FindStringSubmatch(strings.TrimSpace(pair))
+			if p == nil {
+				return todoStatement(stmt)
+			}
+			inverse = append(inverse, p[2]+" TO "+p[1])
+		}
+		return "RENAME " + strings.ToUpper(m[1]) + " " + strings.Join(inverse, ", ") + m[3]
+	}
+
+	if m := alterRE.FindStringSubmatch(stmt); m != nil {
+		actions := splitTopLevel(m[3])
+		drops := make([]string, 0, len(actions))
+		for i := len(actions) - 1; i >= 0; i-- {
+			a := alterAddRE.FindStringSubmatch(strings.TrimSpace(actions[i]))
+			if a == nil {
+				return todoStatement(stmt)
+			}
+			drops = append(drops, "DROP "+strings.ToUpper(a[1])+" IF EXISTS "+a[3])
+		}
+		return "ALTER TABLE " + m[1] + m[2] + " " + strings.Join(drops, ", ")
+	}
+
+	return todoStatement(stmt)
+}
+
+func todoStatement(stmt string) string {
+	lines := strings.Split(stmt, "\n")
+	for i, line := range lines {
+		lines[i] = "-- " + line
+	}
+	return "-- TODO: revert the statement\n" + strings.Join(lines, "\n") + "\nSELECT 1"
+}
+
+// splitTopLevel splits s by commas that are not inside parentheses or quotes.
+func splitTopLevel(s string) []string {
+	var parts []string
+	var depth int
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
@@ -0,0 +1,111 @@
+package chmigrate_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chmigrate"
+)
+
+func TestDownSQL(t *testing.T) {
+	const split = "\n\n--migration:split\n\n"
+
+	tests := []struct {
+		name string
+		up   string
+		down string
+	}{
+		{
+			name: "create table",
+			up:   "CREATE TABLE events (id UInt64) ENGINE = MergeTree ORDER BY id;",
+			down: "DROP TABLE IF EXISTS events\n",
+		},
+		{
+			name: "create if not exists on cluster",
+			up:   "create table if not exists db.events on cluster prod (id UInt64)",
+			down: "DROP TABLE IF EXISTS db.events on cluster prod\n",
+		},
+		{
+			name: "views and dictionaries",
+			up: "CREATE MATERIALIZED VIEW events_mv TO events AS SELECT 1\n" +
+				"--migration:split\n" +
+				"CREATE  LIVE   VIEW events_lv AS SELECT 1\n" +
+				"--migration:split\n" +
+				"CREATE DICTIONARY names (id UInt64) PRIMARY KEY id\n" +
+				"--migration:split\n" +
+				"CREATE TEMPORARY TABLE tmp (id UInt64)\n" +
+				"--migration:split\n" +
+				"CREATE DATABASE analytics",
+			down: "DROP DATABASE IF EXISTS analytics" + split +
+				"DROP TABLE IF EXISTS tmp" + split +
+				"DROP DICTIONARY IF EXISTS names" + split +
+				"DROP VIEW IF EXISTS events_lv" + split +
+				"DROP VIEW IF EXISTS events_mv\n",
+		},
+		{
+			name: "rename",
+			up:   "RENAME TABLE a TO b, c TO d ON CLUSTER prod",
+			down: "RENAME TABLE b TO a, d TO c ON CLUSTER prod\n",
+		},
+		{
+			name: "alter add",
+			up: "ALTER TABLE events ON CLUSTER prod ADD COLUMN tags Array(String) DEFAULT [], " +
+				"ADD INDEX IF NOT EXISTS idx_name (name, kind) TYPE bloom_filter GRANULARITY 1, " +
+				"ADD PROJECTION by_name (SELECT * ORDER BY name)",
+			down: "ALTER TABLE events ON CLUSTER prod DROP PROJECTION IF EXISTS by_name, " +
+				"DROP INDEX IF EXISTS idx_name, DROP COLUMN IF EXISTS tags\n",
+		},
+		{
+			name: "alter with other actions",
+			up:   "ALTER TABLE events ADD COLUMN name String, MODIFY TTL time + INTERVAL 1 DAY",
+			down: "-- TODO: revert the statement\n" +
+				"-- ALTER TABLE events ADD COLUMN name String, MODIFY TTL time + INTERVAL 1 DAY\n" +
+				"SELECT 1\n",
+		},
+		{
+			name: "unknown statement",
+			up:   "INSERT INTO events\nSELECT 1",
+			down: "-- TODO: revert the statement\n-- INSERT INTO events\n-- SELECT 1\nSELECT 1\n",
+		},
+		{
+			name: "comments and order",
+			up: "-- create the tables\n" +
+				"CREATE TABLE a (id UInt64)\n" +
+				"--migration:split\n" +
+				"\n" +
+				"CREATE TABLE b (id UInt64);\n",
+			down: "DROP TABLE IF EXISTS b" + split + "DROP TABLE IF EXISTS a\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.down, chmigrate.DownSQL(test.up))
+		})
+	}
+}
+
+func TestCreateSQLMigrationsDownStub(t *testing.T) {
+	dir := t.TempDir()
+	db := ch.Connect()
+	defer db.Close()
+
+	migrations := chmigrate.NewMigrations(chmigrate.WithMigrationsDirectory(dir))
+	m := chmigrate.NewMigrator(db, migrations)
+
+	files, err := m.CreateSQLMigrations(context.Background(), "events",
+		chmigrate.WithUpSQL("CREATE TABLE events (id UInt64)"),
+		chmigrate.WithDownStub())
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	require.True(t, strings.HasSuffix(files[0].Name, "_events.up.sql"), files[0].Name)
+	require.True(t, strings.HasSuffix(files[1].Name, "_events.down.sql"), files[1].Name)
+
+	down, err := os.ReadFile(files[1].Path)
+	require.NoError(t, err)
+	require.Equal(t, "DROP TABLE IF EXISTS events\n", string(down))
+}
@@ -0,0 +1,277 @@
+package chmigrate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+// CreateSQLMigrationsFromModels compares the models with the tables in the database
+// and creates up and down SQL migration files with the CREATE TABLE and ALTER TABLE
+// statements required to make the database match the models. Without models,
+// it uses all models used so far by the process.
+//
+// Only columns are compared so changes of engines or sorting keys must be
+// added manually. Types are compared ignoring LowCardinality and the timezone
+// of DateTime columns when the model does not specify one. It returns nil files
+// when the database already matches the models.
+func (m *Migrator) CreateSQLMigrationsFromModels(
+	ctx context.Context, name string, models ...any,
+) ([]*MigrationFile, error) {
+	tables, err := modelTables(models)
+	if err != nil {
+		return nil, err
+	}
+
+	live, err := m.selectSchema(ctx, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	up, down, err := m.diffStatements(tables, live)
+	if err != nil {
+		return nil, err
+	}
+	if len(up) == 0 {
+		return nil, nil
+	}
+
+	name, err = m.genMigrationName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	upFile, err := m.writeSQL(name+".up.sql", joinStatements(up))
+	if err != nil {
+		return nil, err
+	}
+
+	downFile, err := m.writeSQL(name+".down.sql", joinStatements(down))
+	if err != nil {
+		return nil, err
+	}
+
+	return []*MigrationFile{upFile, downFile}, nil
+}
+
+func modelTables(models []any) ([]*chschema.Table, error) {
+	if len(models) == 0 {
+		return chschema.RegisteredTables(), nil
+	}
+
+	tables := make([]*chschema.Table, 0, len(models))
+	for _, model := range models {
+		typ := reflect.TypeOf(model)
+		for typ != nil && typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ == nil || typ.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("chmigrate: unsupported model %T", model)
+		}
+		tables = append(tables, chschema.TableForType(typ))
+	}
+	return tables, nil
+}
+
+// selectSchema selects columns of the tables that exist in the current database.
+func (m *Migrator) selectSchema(
+	ctx context.Context, tables []*chschema.Table,
+) (*chschema.Schema, error) {
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, table.Name)
+	}
+
+	schema := new(chschema.Schema)
+	if len(names) == 0 {
+		return schema, nil
+	}
+
+	var columns []struct {
+		Table             string
+		Name              string
+		Type              string
+		DefaultKind       string
+		DefaultExpression string
+	}
+	if err := m.db.NewSelect().
+		ColumnExpr("table, name, type, default_kind, default_expression").
+		Model(&columns).
+		ModelTableExpr("system.columns").
+		Where("database = currentDatabase()").
+		Where("table IN (?)", ch.In(names)).
+		OrderExpr("table, position").
+		Scan(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, col := range columns {
+		table := schema.Table(col.Table)
+		if table == nil {
+			table = &chschema.TableSchema{Name: col.Table}
+			schema.Tables = append(schema.Tables, table)
+		}
+		kind := col.DefaultKind
+		if kind == "DEFAULT" {
+			kind = ""
+		}
+		table.Columns = append(table.Columns, &chschema.ColumnSchema{
+			Name:        col.Name,
+			Type:        col.Type,
+			Default:     col.DefaultExpression,
+			DefaultKind: kind,
+		})
+	}
+	return schema, nil
+}
+
+// diffStatements returns statements that migrate the live schema to the tables
+// and statements that revert the changes.
+func (m *Migrator) diffStatements(
+	tables []*chschema.Table, live *chschema.Schema,
+) (up, down []string, _ error) {
+	for _, table := range tables {
+		model := chschema.NewSchema([]*chschema.Table{table}).Tables[0]
+
+		liveTable := live.Table(table.Name)
+		if liveTable == nil {
+			query, err := m.db.NewCreateTable().
+				Model(reflect.New(table.Type).Interface()).
+				AppendQuery(m.db.Formatter(), nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			up = append(up, string(query))
+			down = append(down, m.db.FormatQuery("DROP TABLE ?", ch.Ident(table.Name)))
+			continue
+		}
+
+		for _, col := range model.Columns {
+			liveCol := liveColumn(liveTable, col.Name)
+			switch {
+			case liveCol == nil:
+				up = append(up, m.alterColumn(table.Name, "ADD COLUMN", col))
+				down = append(down, m.dropColumn(table.Name, col.Name))
+			case !columnsEqual(col, liveCol):
+				up = append(up, m.alterColumn(table.Name, "MODIFY COLUMN", col))
+				down = append(down, m.alterColumn(table.Name, "MODIFY COLUMN", liveCol))
+			}
+		}
+		for _, liveCol := range liveTable.Columns {
+			if liveColumn(model, liveCol.Name) == nil {
+				up = append(up, m.dropColumn(table.Name, liveCol.Name))
+				down = append(down, m.alterColumn(table.Name, "ADD COLUMN", liveCol))
+			}
+		}
+	}
+
+	// Revert changes in the reverse order.
+	for i, j := 0, len(down)-1; i < j; i, j = i+1, j-1 {
+		down[i], down[j] = down[j], down[i]
+	}
+
+	return up, down, nil
+}
+
+func (m *Migrator) alterColumn(table, action string, col *chschema.ColumnSchema) string {
+	query := m.db.FormatQuery("ALTER TABLE ? ? ? ?",
+		ch.Ident(table), ch.Safe(action), ch.Ident(col.Name), ch.Safe(col.Type))
+	if col.DefaultKind != "" {
+		query += " " + col.DefaultKind
+	} else if col.Default != "" {
+		query += " DEFAULT"
+	}
+	if col.Default != "" {
+		query += " " + col.Default
+	}
+	return query
+}
+
+func (m *Migrator) dropColumn(table, column string) string {
+	return m.db.FormatQuery("ALTER TABLE ? DROP COLUMN ?", ch.Ident(table), ch.Ident(column))
+}
+
+func liveColumn(table *chschema.TableSchema, name string) *chschema.ColumnSchema {
+	for _, col := range table.Columns {
+		if col.Name == name {
+			return col
+		}
+	}
+	return nil
+}
+
+// columnsEqual reports whether the model column matches the live column
+// read from system.columns, which uses the canonical form of types and defaults.
+func columnsEqual(model, live *chschema.ColumnSchema) bool {
+	return typesEqual(model.Type, live.Type) &&
+		model.DefaultKind == live.DefaultKind &&
+		normalizeDefault(model.Default) == normalizeDefault(live.Default)
+}
+
+var (
+	dateTimeTZRE   = regexp.MustCompile(`DateTime\('[^']*'\)`)
+	dateTime64TZRE = regexp.MustCompile(`DateTime64\((\d+),'[^']*'\)`)
+	now64RE        = regexp.MustCompile(`(?i)\bnow64\(\d*\)`)
+)
+
+// typesEqual compares the types ignoring spaces and LowCardinality. The timezone
+// of the live type is ignored when the model type does not specify one, because
+// such columns use the timezone of the server.
+func typesEqual(model, live string) bool {
+	model, live = canonicalType(model), canonicalType(live)
+	if model == live {
+		return true
+	}
+	live = dateTimeTZRE.ReplaceAllString(live, "DateTime")
+	live = dateTime64TZRE.ReplaceAllString(live, "DateTime64($1)")
+	return model == live
+}
+
+func canonicalType(s string) string {
+	s = strings.Join(strings.Fields(s), "")
+	const lc = "LowCardinality("
+	for {
+		i := strings.Index(s, lc)
+		if i == -1 {
+			return s
+		}
+		end := matchingParen(s, i+len(lc)-1)
+		if end == -1 {
+			return s
+		}
+		s = s[:i] + s[i+len(lc):end] + s[end+1:]
+	}
+}
+
+// matchingParen returns the index of the paren that closes the one at i or -1.
+func matchingParen(s string, i int) int {
+	var depth int
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// normalizeDefault removes spaces from the default expression and replaces
+// now64 with now, because both are used as the default of DateTime64 columns.
+func normalizeDefault(s string) string {
+	s = strings.Join(strings.Fields(s), "")
+	return now64RE.ReplaceAllString(s, "now()")
+}
+
+func joinStatements(queries []string) string {
+	return strings.Join(queries, "\n\n--migration:split\n\n") + "\n"
+}
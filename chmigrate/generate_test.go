@@ -0,0 +1,80 @@
+package chmigrate_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chmigrate"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+func TestCreateSQLMigrationsFromModels(t *testing.T) {
+	type Event struct {
+		ch.CHModel `ch:"table:gen_events"`
+
+		ID        uint64
+		Name      string    `ch:",lc"`
+		CreatedAt time.Time `ch:",default:now()"`
+		UpdatedAt time.Time `ch:",type:DateTime64(3),default:now64(3)"`
+		Total     uint64    `ch:",default:id * 2"`
+		Count     uint32
+	}
+
+	srv := &chfake.Server{Reply: func(query string) chfake.Reply {
+		if !strings.Contains(query, "system.columns") {
+			return chfake.Reply{}
+		}
+		return chfake.Reply{Columns: []chfake.Column{
+			{Name: "table", CHType: "String", Values: []string{
+				"gen_events", "gen_events", "gen_events", "gen_events",
+				"gen_events", "gen_events",
+			}},
+			{Name: "name", CHType: "String", Values: []string{
+				"id", "name", "created_at", "updated_at", "total", "count",
+			}},
+			{Name: "type", CHType: "String", Values: []string{
+				"UInt64", "String", "DateTime('UTC')", "DateTime64(3, 'UTC')",
+				"UInt64", "UInt64",
+			}},
+			{Name: "default_kind", CHType: "String", Values: []string{
+				"", "", "DEFAULT", "DEFAULT", "MATERIALIZED", "",
+			}},
+			{Name: "default_expression", CHType: "String", Values: []string{
+				"", "", "now()", "now()", "id * 2", "",
+			}},
+		}}
+	}}
+	db := ch.Connect(ch.WithCompression(false), ch.WithDialer(srv.Dial))
+	defer db.Close()
+
+	migrations := chmigrate.NewMigrations(chmigrate.WithMigrationsDirectory(t.TempDir()))
+	m := chmigrate.NewMigrator(db, migrations)
+
+	files, err := m.CreateSQLMigrationsFromModels(context.Background(), "events", (*Event)(nil))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	up, err := os.ReadFile(files[0].Path)
+	require.NoError(t, err)
+	require.Equal(t, `ALTER TABLE "gen_events" MODIFY COLUMN "total" UInt64 DEFAULT id * 2
+
+--migration:split
+
+ALTER TABLE "gen_events" MODIFY COLUMN "count" UInt32
+`, string(up))
+
+	down, err := os.ReadFile(files[1].Path)
+	require.NoError(t, err)
+	require.Equal(t, `ALTER TABLE "gen_events" MODIFY COLUMN "count" UInt64
+
+--migration:split
+
+ALTER TABLE "gen_events" MODIFY COLUMN "total" UInt64 MATERIALIZED id * 2
+`, string(down))
+}
@@ -0,0 +1,68 @@
+package chmigrate
+
+import (
+	"context"
+	"sort"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+// MigrationHistory is the state of migrations as recorded in the migrations table.
+type MigrationHistory struct {
+	// Groups are applied migration groups in descending order.
+	Groups []*MigrationGroup
+	// Pending are unapplied migrations in ascending order.
+	Pending MigrationSlice
+}
+
+// LastGroup returns the last applied migration group or nil.
+func (h *MigrationHistory) LastGroup() *MigrationGroup {
+	if len(h.Groups) == 0 {
+		return nil
+	}
+	return h.Groups[0]
+}
+
+// History returns applied migration groups and pending migrations.
+func (m *Migrator) History(ctx context.Context) (*MigrationHistory, error) {
+	migrations, err := m.MigrationsWithStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history := new(MigrationHistory)
+	groups := make(map[int64]*MigrationGroup)
+
+	// Applied migrations are in descending order.
+	for _, migration := range migrations.Applied() {
+		group, ok := groups[migration.GroupID]
+		if !ok {
+			group = &MigrationGroup{ID: migration.GroupID}
+			groups[group.ID] = group
+			history.Groups = append(history.Groups, group)
+		}
+		group.Migrations = append(group.Migrations, migration)
+	}
+	sort.Slice(history.Groups, func(i, j int) bool {
+		return history.Groups[i].ID > history.Groups[j].ID
+	})
+
+	history.Pending = migrations.Unapplied()
+	return history, nil
+}
+
+// NewHistoryQuery returns a query that selects applied migrations from the
+// migrations table into ms. Add conditions, ordering, and limits as needed, e.g.
+//
+//	var ms chmigrate.MigrationSlice
+//	err := migrator.NewHistoryQuery(&ms).
+//		Where("group_id = ?", groupID).
+//		Order("migrated_at DESC").
+//		Scan(ctx)
+func (m *Migrator) NewHistoryQuery(ms *MigrationSlice) *ch.SelectQuery {
+	return m.db.NewSelect().
+		Model(ms).
+		ModelTableExpr(m.table).
+		Final().
+		Where("sign = 1")
+}
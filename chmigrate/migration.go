@@ -20,7 +20,10 @@ type Migration struct {
 	Comment    string `ch:"-"`
 	GroupID    int64
 	MigratedAt time.Time
-	Sign       int8
+	// Duration is how long the up migration took. It is only known
+	// when migrations are marked applied on success.
+	Duration time.Duration
+	Sign     int8
 
 	Up   MigrationFunc `ch:"-"`
 	Down MigrationFunc `ch:"-"`
@@ -34,19 +37,44 @@ func (m *Migration) IsApplied() bool {
 	return !m.MigratedAt.IsZero()
 }
 
+func (m *Migration) Status() MigrationStatus {
+	if m.IsApplied() {
+		return MigrationApplied
+	}
+	return MigrationPending
+}
+
+type MigrationStatus string
+
+const (
+	MigrationApplied MigrationStatus = "applied"
+	MigrationPending MigrationStatus = "pending"
+)
+
 type MigrationFunc func(ctx context.Context, db *ch.DB) error
 
 func NewSQLMigrationFunc(fsys fs.FS, name string) MigrationFunc {
 	return func(ctx context.Context, db *ch.DB) error {
-		f, err := fsys.Open(name)
+		content, err := fs.ReadFile(fsys, name)
 		if err != nil {
 			return err
 		}
 
-		scanner := bufio.NewScanner(f)
-		var queries []string
+		if data, ok := ctx.Value(templateDataKey{}).(map[string]any); ok {
+			data, err = withClusterData(ctx, db, data)
+			if err != nil {
+				return err
+			}
+			content, err = renderTemplate(name, content, data)
+			if err != nil {
+				return err
+			}
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		var queries []sqlQuery
 
-		var query []byte
+		var query sqlQuery
 		for scanner.Scan() {
 			b := scanner.Bytes()
 
@@ -54,26 +82,36 @@ func NewSQLMigrationFunc(fsys fs.FS, name string) MigrationFunc {
 			if bytes.HasPrefix(b, []byte(prefix)) {
 				b = b[len(prefix):]
 				if bytes.Equal(b, []byte("split")) {
-					queries = append(queries, string(query))
-					query = query[:0]
+					queries = append(queries, query)
+					query = sqlQuery{}
+					continue
+				}
+				if bytes.HasPrefix(b, []byte("settings ")) {
+					if err := query.parseSettings(string(b[len("settings "):])); err != nil {
+						return err
+					}
 					continue
 				}
 				return fmt.Errorf("ch: unknown directive: %q", b)
 			}
 
-			query = append(query, b...)
-			query = append(query, '\n')
+			query.query = append(query.query, b...)
+			query.query = append(query.query, '\n')
 		}
 
-		if len(query) > 0 {
-			queries = append(queries, string(query))
+		if len(query.query) > 0 {
+			queries = append(queries, query)
 		}
 		if err := scanner.Err(); err != nil {
 			return err
 		}
 
 		for _, q := range queries {
-			_, err = db.ExecContext(ctx, q)
+			queryCtx := ctx
+			if len(q.settings) > 0 {
+				queryCtx = ch.ContextWithQuerySettings(ctx, q.settings)
+			}
+			_, err = db.ExecContext(queryCtx, string(q.query))
 			if err != nil {
 				return err
 			}
@@ -83,6 +121,40 @@ func NewSQLMigrationFunc(fsys fs.FS, name string) MigrationFunc {
 	}
 }
 
+// sqlQuery is a statement from a SQL migration file. Settings are specified with
+// a directive inside the statement, for example:
+//
+//	--migration:settings mutations_sync=2, replication_alter_partitions_sync=2
+type sqlQuery struct {
+	query    []byte
+	settings map[string]any
+}
+
+func (q *sqlQuery) parseSettings(s string) error {
+	for _, setting := range strings.Split(s, ",") {
+		setting = strings.TrimSpace(setting)
+		if setting == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(setting, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("ch: invalid setting: %q", setting)
+		}
+
+		if q.settings == nil {
+			q.settings = make(map[string]any)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		q.settings[key] = value
+	}
+	return nil
+}
+
 const goTemplate = `package %s
 
 import (
@@ -199,6 +271,26 @@ func (g *MigrationGroup) IsZero() bool {
 	return g.ID == 0 && len(g.Migrations) == 0
 }
 
+// MigratedAt returns the time when the first migration in the group was applied.
+func (g *MigrationGroup) MigratedAt() time.Time {
+	var tm time.Time
+	for i := range g.Migrations {
+		if at := g.Migrations[i].MigratedAt; tm.IsZero() || at.Before(tm) {
+			tm = at
+		}
+	}
+	return tm
+}
+
+// Duration returns the total duration of migrations in the group.
+func (g *MigrationGroup) Duration() time.Duration {
+	var d time.Duration
+	for i := range g.Migrations {
+		d += g.Migrations[i].Duration
+	}
+	return d
+}
+
 func (g *MigrationGroup) String() string {
 	if g.IsZero() {
 		return "nil"
@@ -44,6 +44,9 @@ type Migrator struct {
 	table                string
 	locksTable           string
 	markAppliedOnSuccess bool
+	replicationWait      *replicationWait
+	logger               Logger
+	templateData         map[string]any
 }
 
 func NewMigrator(db *ch.DB, migrations *Migrations, opts ...MigratorOption) *Migrator {
@@ -86,6 +89,7 @@ func (m *Migrator) migrationsWithStatus(ctx context.Context) (MigrationSlice, in
 		if m2, ok := appliedMap[m1.Name]; ok {
 			m1.GroupID = m2.GroupID
 			m1.MigratedAt = m2.MigratedAt
+			m1.Duration = m2.Duration
 		}
 	}
 
@@ -100,6 +104,14 @@ func (m *Migrator) Init(ctx context.Context) error {
 		Exec(ctx); err != nil {
 		return err
 	}
+	// Tables created by older versions don't have the duration column.
+	if _, err := m.db.ExecContext(
+		ctx,
+		"ALTER TABLE ? ADD COLUMN IF NOT EXISTS duration Int64",
+		ch.Safe(m.table),
+	); err != nil {
+		return err
+	}
 	if _, err := m.db.NewCreateTable().
 		Model((*migrationLock)(nil)).
 		ModelTableExpr(m.locksTable).
@@ -141,6 +153,11 @@ func (m *Migrator) Migrate(ctx context.Context, opts ...MigrationOption) (*Migra
 	}
 	defer m.Unlock(ctx) //nolint:errcheck
 
+	return m.migrate(ctx, cfg)
+}
+
+// migrate runs unapplied migrations. The caller must hold the lock.
+func (m *Migrator) migrate(ctx context.Context, cfg *migrationConfig) (*MigrationGroup, error) {
 	migrations, lastGroupID, err := m.migrationsWithStatus(ctx)
 	if err != nil {
 		return nil, err
@@ -169,11 +186,13 @@ func (m *Migrator) Migrate(ctx context.Context, opts ...MigrationOption) (*Migra
 			applied.Migrations = append(applied.Migrations, *migration)
 		}
 
+		start := time.Now()
 		if !cfg.nop && migration.Up != nil {
-			if err := migration.Up(ctx, m.db); err != nil {
+			if err := m.runMigration(ctx, migration.Up); err != nil {
 				return applied, err
 			}
 		}
+		migration.Duration = time.Since(start)
 
 		if m.markAppliedOnSuccess {
 			if err := m.MarkApplied(ctx, migration); err != nil {
@@ -224,7 +243,7 @@ func (m *Migrator) Rollback(ctx context.Context, opts ...MigrationOption) (*Migr
 		}
 
 		if !cfg.nop && migration.Down != nil {
-			if err := migration.Down(ctx, m.db); err != nil {
+			if err := m.runMigration(ctx, migration.Down); err != nil {
 				return unapplied, err
 			}
 		}
@@ -242,6 +261,33 @@ func (m *Migrator) Rollback(ctx context.Context, opts ...MigrationOption) (*Migr
 	return lastGroup, nil
 }
 
+// migrationContext returns the context used to run migrations.
+func (m *Migrator) migrationContext(ctx context.Context) context.Context {
+	if m.templateData != nil {
+		ctx = context.WithValue(ctx, templateDataKey{}, m.templateData)
+	}
+	if m.replicationWait != nil {
+		ctx = ch.ContextWithQuerySettings(ctx, map[string]any{
+			"alter_sync":                        2,
+			"replication_alter_partitions_sync": 2,
+		})
+	}
+	return ctx
+}
+
+func (m *Migrator) runMigration(ctx context.Context, fn MigrationFunc) error {
+	ctx = m.migrationContext(ctx)
+	if m.replicationWait == nil {
+		return fn(ctx, m.db)
+	}
+
+	hook := &replicationHook{db: m.db, wait: m.replicationWait}
+	if err := fn(ctx, m.db.WithQueryHook(hook)); err != nil {
+		return err
+	}
+	return hook.Err()
+}
+
 type goMigrationConfig struct {
 	packageName string
 }
@@ -287,18 +333,32 @@ func (m *Migrator) CreateGoMigration(
 }
 
 // CreateSQLMigrations creates an up and down SQL migration files.
-func (m *Migrator) CreateSQLMigrations(ctx context.Context, name string) ([]*MigrationFile, error) {
+func (m *Migrator) CreateSQLMigrations(
+	ctx context.Context, name string, opts ...SQLMigrationOption,
+) ([]*MigrationFile, error) {
+	cfg := &sqlMigrationConfig{
+		up: sqlTemplate,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
 	name, err := m.genMigrationName(name)
 	if err != nil {
 		return nil, err
 	}
 
-	up, err := m.createSQL(ctx, name+".up.sql")
+	downContent := sqlTemplate
+	if cfg.downStub {
+		downContent = DownSQL(cfg.up)
+	}
+
+	up, err := m.writeSQL(name+".up.sql", cfg.up)
 	if err != nil {
 		return nil, err
 	}
 
-	down, err := m.createSQL(ctx, name+".down.sql")
+	down, err := m.writeSQL(name+".down.sql", downContent)
 	if err != nil {
 		return nil, err
 	}
@@ -306,17 +366,17 @@ func (m *Migrator) CreateSQLMigrations(ctx context.Context, name string) ([]*Mig
 	return []*MigrationFile{up, down}, nil
 }
 
-func (m *Migrator) createSQL(ctx context.Context, fname string) (*MigrationFile, error) {
+func (m *Migrator) writeSQL(fname, content string) (*MigrationFile, error) {
 	fpath := filepath.Join(m.migrations.getDirectory(), fname)
 
-	if err := ioutil.WriteFile(fpath, []byte(sqlTemplate), 0o644); err != nil {
+	if err := ioutil.WriteFile(fpath, []byte(content), 0o644); err != nil {
 		return nil, err
 	}
 
 	mf := &MigrationFile{
 		Name:    fname,
 		Path:    fpath,
-		Content: goTemplate,
+		Content: content,
 	}
 	return mf, nil
 }
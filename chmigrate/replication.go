@@ -0,0 +1,139 @@
+package chmigrate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+// WithReplicationWait makes the migrator wait for replicas to apply the DDL
+// before a migration is reported as applied. Migration queries are executed
+// with alter_sync=2 and replication_alter_partitions_sync=2, and after each
+// ALTER, CREATE, TRUNCATE, or OPTIMIZE TABLE statement the migrator polls
+// system.replication_queue until it has no entries for the table created
+// since the statement started or the timeout expires.
+func WithReplicationWait(timeout time.Duration) MigratorOption {
+	return func(m *Migrator) {
+		m.replicationWait = &replicationWait{
+			timeout:  timeout,
+			interval: 500 * time.Millisecond,
+		}
+	}
+}
+
+type replicationWait struct {
+	timeout  time.Duration
+	interval time.Duration
+}
+
+var ddlTableRE = regexp.MustCompile(`(?is)^(?:\s*--[^\n]*\n)*\s*` +
+	`(?:ALTER\s+TABLE|CREATE\s+(?:OR\s+REPLACE\s+)?TABLE(?:\s+IF\s+NOT\s+EXISTS)?|` +
+	`TRUNCATE(?:\s+TABLE)?(?:\s+IF\s+EXISTS)?|OPTIMIZE\s+TABLE)\s+` +
+	"(?:(\"[^\"]+\"|`[^`]+`|\\w+)\\.)?(\"[^\"]+\"|`[^`]+`|\\w+)")
+
+// ddlTable returns the database and the table changed by the DDL statement.
+// The database is empty when the statement uses the current database.
+func ddlTable(query string) (database, table string, ok bool) {
+	m := ddlTableRE.FindStringSubmatch(query)
+	if m == nil {
+		return "", "", false
+	}
+	return unquoteIdent(m[1]), unquoteIdent(m[2]), true
+}
+
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '`') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// replicationHook waits for replication after each DDL statement of a migration.
+// Hooks can't fail queries so the first error is returned after the migration.
+type replicationHook struct {
+	db   *ch.DB
+	wait *replicationWait
+
+	mu  sync.Mutex
+	err error
+}
+
+var _ ch.QueryHook = (*replicationHook)(nil)
+
+func (h *replicationHook) BeforeQuery(ctx context.Context, evt *ch.QueryEvent) context.Context {
+	return ctx
+}
+
+func (h *replicationHook) AfterQuery(ctx context.Context, evt *ch.QueryEvent) {
+	if evt.Err != nil {
+		return
+	}
+	database, table, ok := ddlTable(evt.Query)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return
+	}
+	h.err = h.waitForReplication(ctx, database, table, evt.StartTime)
+}
+
+func (h *replicationHook) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// waitForReplication waits until the replication queue has no entries
+// for the table that were created after the start time of the statement.
+func (h *replicationHook) waitForReplication(
+	ctx context.Context, database, table string, startTime time.Time,
+) error {
+	// create_time has the precision of a second.
+	startTime = startTime.Truncate(time.Second)
+
+	deadline := time.Now().Add(h.wait.timeout)
+	for {
+		q := h.db.NewSelect().
+			ColumnExpr("count()").
+			TableExpr("system.replication_queue")
+		if database != "" {
+			q = q.Where("database = ?", database)
+		} else {
+			q = q.Where("database = currentDatabase()")
+		}
+
+		var count uint64
+		if err := q.
+			Where("table = ?", table).
+			Where("create_time >= ?", startTime).
+			Scan(ctx, &count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf(
+				"chmigrate: replication queue of %s still has %d entries after %s",
+				strings.TrimPrefix(database+"."+table, "."), count, h.wait.timeout)
+		}
+
+		timer := time.NewTimer(h.wait.interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
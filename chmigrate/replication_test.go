@@ -0,0 +1,87 @@
+package chmigrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chmigrate"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+func newReplicationMigrator(
+	t *testing.T, count uint64, timeout time.Duration,
+) (*chmigrate.Migrator, *chfake.Server) {
+	srv := &chfake.Server{Reply: func(query string) chfake.Reply {
+		switch {
+		case strings.HasPrefix(query, "INSERT"):
+			return chfake.Reply{Columns: migrationColumns}
+		case strings.Contains(query, "system.replication_queue"):
+			return chfake.Reply{Columns: []chfake.Column{
+				{Name: "count()", CHType: "UInt64", Values: []uint64{count}},
+			}}
+		}
+		return chfake.Reply{}
+	}}
+	db := ch.Connect(ch.WithCompression(false), ch.WithDialer(srv.Dial))
+	t.Cleanup(func() { db.Close() })
+
+	migrations := chmigrate.NewMigrations()
+	migrations.Add(chmigrate.Migration{
+		Name: migrationName,
+		Up: func(ctx context.Context, db *ch.DB) error {
+			for _, query := range []string{
+				`ALTER TABLE analytics."events" ADD COLUMN x UInt8`,
+				"SELECT 1",
+				"CREATE TABLE IF NOT EXISTS users (id UInt64) Engine = MergeTree() ORDER BY id",
+			} {
+				if _, err := db.ExecContext(ctx, query); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+	return chmigrate.NewMigrator(db, migrations, chmigrate.WithReplicationWait(timeout)), srv
+}
+
+func TestReplicationWait(t *testing.T) {
+	m, srv := newReplicationMigrator(t, 0, time.Second)
+
+	_, err := m.Migrate(context.Background())
+	require.NoError(t, err, srv.Queries())
+
+	// The queue of each table is checked right after the DDL.
+	var queries []string
+	for _, query := range srv.Queries() {
+		if strings.Contains(query, "ch_migration") {
+			continue
+		}
+		queries = append(queries, query)
+	}
+	require.Len(t, queries, 5, queries)
+	require.True(t, strings.HasPrefix(queries[0], "ALTER TABLE"), queries[0])
+	require.Contains(t, queries[1], "system.replication_queue")
+	require.Contains(t, queries[1], "database = 'analytics'")
+	require.Contains(t, queries[1], "table = 'events'")
+	require.Contains(t, queries[1], "create_time >= toDateTime(")
+	require.Equal(t, "SELECT 1", queries[2])
+	require.True(t, strings.HasPrefix(queries[3], "CREATE TABLE"), queries[3])
+	require.Contains(t, queries[4], "database = currentDatabase()")
+	require.Contains(t, queries[4], "table = 'users'")
+}
+
+func TestReplicationWaitTimeout(t *testing.T) {
+	m, srv := newReplicationMigrator(t, 3, 0)
+
+	_, err := m.Migrate(context.Background())
+	require.EqualError(t, err,
+		"chmigrate: replication queue of analytics.events still has 3 entries after 0s")
+
+	// The migration stops waiting after the first error.
+	require.False(t, hasQuery(srv.Queries(), "table = 'users'"))
+}
@@ -0,0 +1,174 @@
+package chmigrate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/internal/chlog"
+)
+
+// Logger is used by RunOnce to report what the instance is doing.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
+// WithLogger sets the logger used by RunOnce. By default messages are logged
+// with the logger used by the ch package.
+func WithLogger(logger Logger) MigratorOption {
+	return func(m *Migrator) {
+		m.logger = logger
+	}
+}
+
+func (m *Migrator) logf(format string, v ...any) {
+	if m.logger != nil {
+		m.logger.Printf(format, v...)
+	} else {
+		chlog.Logger.Printf("chmigrate: "+format, v...)
+	}
+}
+
+// RunOnceResult describes what RunOnce did.
+type RunOnceResult struct {
+	// Group contains the migrations applied by this instance. It is empty
+	// when the migrations were applied by another instance.
+	Group *MigrationGroup
+	// Leader is true when this instance acquired the lock and ran migrations.
+	Leader bool
+	// Waited is the time spent waiting for another instance to release the lock.
+	Waited time.Duration
+}
+
+// RunOnceOption configures RunOnce.
+type RunOnceOption func(cfg *runOnceConfig)
+
+type runOnceConfig struct {
+	pollInterval time.Duration
+	migrateOpts  []MigrationOption
+}
+
+// WithPollInterval sets how often RunOnce checks whether the instance that
+// holds the lock has finished. The default is 1 second.
+func WithPollInterval(d time.Duration) RunOnceOption {
+	return func(cfg *runOnceConfig) {
+		cfg.pollInterval = d
+	}
+}
+
+// WithMigrationOptions sets options passed to Migrate.
+func WithMigrationOptions(opts ...MigrationOption) RunOnceOption {
+	return func(cfg *runOnceConfig) {
+		cfg.migrateOpts = append(cfg.migrateOpts, opts...)
+	}
+}
+
+// RunOnce applies pending migrations so that any number of identical instances
+// can call it at the same time during a deploy. Exactly one instance acquires
+// the lock and runs the migrations while the others wait for the lock to be
+// released and return without running anything.
+//
+// An instance that dies while holding the lock leaves the table locked, in which
+// case the other instances wait until ctx is done. Use Unlock to remove a stale
+// lock.
+func (m *Migrator) RunOnce(ctx context.Context, opts ...RunOnceOption) (*RunOnceResult, error) {
+	cfg := &runOnceConfig{
+		pollInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	if err := m.Init(ctx); err != nil {
+		return nil, err
+	}
+
+	res := new(RunOnceResult)
+	start := time.Now()
+	waiting := false
+
+	for {
+		pending, err := m.pendingMigrations(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(pending) == 0 {
+			res.Group = new(MigrationGroup)
+			if waiting {
+				res.Waited = time.Since(start)
+				m.logf("migrations were applied by another instance (waited %s)",
+					res.Waited.Round(time.Millisecond))
+			} else {
+				m.logf("database is up to date")
+			}
+			return res, nil
+		}
+
+		locked, err := m.tryLock(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			break
+		}
+
+		if !waiting {
+			waiting = true
+			m.logf("another instance is applying migrations, waiting")
+		}
+
+		timer := time.NewTimer(cfg.pollInterval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("chmigrate: waiting for the lock: %w", ctx.Err())
+		}
+	}
+	defer m.Unlock(ctx) //nolint:errcheck
+
+	if waiting {
+		res.Waited = time.Since(start)
+	}
+	res.Leader = true
+
+	m.logf("acquired the lock, applying migrations")
+	group, err := m.migrate(ctx, newMigrationConfig(cfg.migrateOpts))
+	res.Group = group
+	if err != nil {
+		return res, err
+	}
+
+	if group.IsZero() {
+		m.logf("database is up to date")
+	} else {
+		m.logf("applied %s", group)
+	}
+	return res, nil
+}
+
+func (m *Migrator) pendingMigrations(ctx context.Context) (MigrationSlice, error) {
+	migrations, _, err := m.migrationsWithStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return migrations.Unapplied(), nil
+}
+
+// tryLock acquires the lock and reports whether it was already held.
+func (m *Migrator) tryLock(ctx context.Context) (bool, error) {
+	err := m.Lock(ctx)
+	if err == nil {
+		return true, nil
+	}
+
+	// The lock column already exists. Older servers return ILLEGAL_COLUMN.
+	if ch.IsErrorCode(err, ch.CodeDuplicateColumn, ch.CodeIllegalColumn) {
+		return false, nil
+	}
+	return false, err
+}
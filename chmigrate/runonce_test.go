@@ -0,0 +1,153 @@
+package chmigrate_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chmigrate"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+const migrationName = "20220101000000"
+
+// migrationColumns is the schema of the migrations table sent for inserts.
+var migrationColumns = []chfake.Column{
+	{Name: "name", CHType: "String"},
+	{Name: "group_id", CHType: "Int64"},
+	{Name: "migrated_at", CHType: "DateTime"},
+	{Name: "duration", CHType: "Int64"},
+	{Name: "sign", CHType: "Int8"},
+}
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Printf(format string, v ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func newTestMigrator(
+	t *testing.T, reply func(query string) chfake.Reply, opts ...chmigrate.MigratorOption,
+) (*chmigrate.Migrator, *chfake.Server, *int32) {
+	srv := &chfake.Server{Reply: reply}
+	db := ch.Connect(ch.WithCompression(false), ch.WithDialer(srv.Dial))
+	t.Cleanup(func() { db.Close() })
+
+	var ups int32
+	migrations := chmigrate.NewMigrations()
+	migrations.Add(chmigrate.Migration{
+		Name: migrationName,
+		Up: func(ctx context.Context, db *ch.DB) error {
+			atomic.AddInt32(&ups, 1)
+			return nil
+		},
+	})
+	return chmigrate.NewMigrator(db, migrations, opts...), srv, &ups
+}
+
+func hasQuery(queries []string, substr string) bool {
+	for _, query := range queries {
+		if strings.Contains(query, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunOnceLeader(t *testing.T) {
+	logger := new(testLogger)
+	m, srv, ups := newTestMigrator(t, func(query string) chfake.Reply {
+		if strings.HasPrefix(query, "INSERT") {
+			return chfake.Reply{Columns: migrationColumns}
+		}
+		return chfake.Reply{}
+	}, chmigrate.WithLogger(logger))
+
+	res, err := m.RunOnce(context.Background())
+	require.NoError(t, err)
+	require.True(t, res.Leader)
+	require.Zero(t, res.Waited)
+	require.Len(t, res.Group.Migrations, 1)
+	require.Equal(t, migrationName, res.Group.Migrations[0].Name)
+	require.Equal(t, int32(1), atomic.LoadInt32(ups))
+
+	queries := srv.Queries()
+	require.True(t, hasQuery(queries, "ADD COLUMN lock Int8"), queries)
+	require.True(t, hasQuery(queries, "INSERT INTO ch_migrations"), queries)
+	require.True(t, hasQuery(queries, "DROP COLUMN lock"), queries)
+	require.Equal(t, []string{
+		"acquired the lock, applying migrations",
+		"applied " + res.Group.String(),
+	}, logger.messages)
+}
+
+func TestRunOnceWaitsForLock(t *testing.T) {
+	for _, code := range []int32{ch.CodeDuplicateColumn, ch.CodeIllegalColumn} {
+		t.Run(fmt.Sprint(code), func(t *testing.T) {
+			var selects int32
+			logger := new(testLogger)
+			m, srv, ups := newTestMigrator(t, func(query string) chfake.Reply {
+				switch {
+				case strings.Contains(query, "ADD COLUMN lock"):
+					return chfake.Reply{Code: code}
+				case strings.HasPrefix(query, "SELECT"):
+					// The other instance applies the migration after the first poll.
+					if atomic.AddInt32(&selects, 1) == 1 {
+						return chfake.Reply{}
+					}
+					return chfake.Reply{Columns: []chfake.Column{
+						{Name: "name", CHType: "String", Values: []string{migrationName}},
+						{Name: "group_id", CHType: "Int64", Values: []int64{1}},
+						{Name: "migrated_at", CHType: "DateTime", Values: []time.Time{time.Now()}},
+					}}
+				}
+				return chfake.Reply{}
+			}, chmigrate.WithLogger(logger))
+
+			res, err := m.RunOnce(context.Background(), chmigrate.WithPollInterval(time.Millisecond))
+			require.NoError(t, err)
+			require.False(t, res.Leader)
+			require.True(t, res.Group.IsZero())
+			require.NotZero(t, res.Waited)
+			require.Zero(t, atomic.LoadInt32(ups))
+			require.False(t, hasQuery(srv.Queries(), "DROP COLUMN lock"))
+			require.Len(t, logger.messages, 2)
+			require.Equal(t, "another instance is applying migrations, waiting", logger.messages[0])
+			require.True(t, strings.HasPrefix(logger.messages[1],
+				"migrations were applied by another instance"), logger.messages[1])
+		})
+	}
+}
+
+func TestRunOnceLockError(t *testing.T) {
+	m, srv, ups := newTestMigrator(t, func(query string) chfake.Reply {
+		if strings.Contains(query, "ADD COLUMN lock") {
+			return chfake.Reply{Code: ch.CodeUnknownTable}
+		}
+		return chfake.Reply{}
+	}, chmigrate.WithLogger(new(testLogger)))
+
+	_, err := m.RunOnce(context.Background(), chmigrate.WithPollInterval(time.Millisecond))
+	require.True(t, ch.IsErrorCode(err, ch.CodeUnknownTable), err)
+	require.Zero(t, atomic.LoadInt32(ups))
+
+	var locks int
+	for _, query := range srv.Queries() {
+		if strings.Contains(query, "ADD COLUMN lock") {
+			locks++
+		}
+	}
+	require.Equal(t, 1, locks)
+}
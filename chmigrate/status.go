@@ -0,0 +1,114 @@
+package chmigrate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MigrationMissing is the status of a migration that is applied in
+// the database, but is not registered in Migrations.
+const MigrationMissing MigrationStatus = "missing"
+
+// StatusReport is a machine-readable migrations status, for example, to fail
+// CI builds when a target environment has pending or missing migrations.
+type StatusReport struct {
+	Migrations  []MigrationReport `json:"migrations"`
+	Pending     []string          `json:"pending"`
+	Missing     []string          `json:"missing"`
+	LastGroupID int64             `json:"last_group_id"`
+}
+
+type MigrationReport struct {
+	Name       string          `json:"name"`
+	Comment    string          `json:"comment,omitempty"`
+	Status     MigrationStatus `json:"status"`
+	GroupID    int64           `json:"group_id,omitempty"`
+	MigratedAt *time.Time      `json:"migrated_at,omitempty"`
+	DurationMs int64           `json:"duration_ms,omitempty"`
+}
+
+// Status returns the status of registered migrations and migrations
+// that are applied in the database, but are not registered.
+func (m *Migrator) Status(ctx context.Context) (*StatusReport, error) {
+	migrations, lastGroupID, err := m.migrationsWithStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.selectAppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &StatusReport{
+		Migrations:  make([]MigrationReport, 0, len(migrations)),
+		Pending:     make([]string, 0),
+		Missing:     make([]string, 0),
+		LastGroupID: lastGroupID,
+	}
+
+	for i := range migrations {
+		migration := &migrations[i]
+		report.Migrations = append(report.Migrations, newMigrationReport(migration, migration.Status()))
+		if !migration.IsApplied() {
+			report.Pending = append(report.Pending, migration.Name)
+		}
+	}
+
+	registered := migrationMap(migrations)
+	sortAsc(applied)
+	for i := range applied {
+		migration := &applied[i]
+		if _, ok := registered[migration.Name]; ok {
+			continue
+		}
+		report.Migrations = append(report.Migrations, newMigrationReport(migration, MigrationMissing))
+		report.Missing = append(report.Missing, migration.Name)
+	}
+
+	return report, nil
+}
+
+func newMigrationReport(migration *Migration, status MigrationStatus) MigrationReport {
+	r := MigrationReport{
+		Name:       migration.Name,
+		Comment:    migration.Comment,
+		Status:     status,
+		GroupID:    migration.GroupID,
+		DurationMs: migration.Duration.Milliseconds(),
+	}
+	if migration.IsApplied() {
+		tm := migration.MigratedAt
+		r.MigratedAt = &tm
+	}
+	return r
+}
+
+// IsUpToDate reports whether there are no pending or missing migrations.
+func (r *StatusReport) IsUpToDate() bool {
+	return len(r.Pending) == 0 && len(r.Missing) == 0
+}
+
+// Err returns an error that lists pending and missing migrations or nil.
+func (r *StatusReport) Err() error {
+	if r.IsUpToDate() {
+		return nil
+	}
+
+	var parts []string
+	if len(r.Pending) > 0 {
+		parts = append(parts, fmt.Sprintf("pending migrations: %s", strings.Join(r.Pending, ", ")))
+	}
+	if len(r.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing migrations: %s", strings.Join(r.Missing, ", ")))
+	}
+	return fmt.Errorf("chmigrate: database is not up to date (%s)", strings.Join(parts, "; "))
+}
+
+// JSON returns the report as indented JSON.
+func (r *StatusReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
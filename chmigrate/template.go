@@ -0,0 +1,68 @@
+package chmigrate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"text/template"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+// WithTemplateData renders SQL migrations as Go text/template templates
+// with the data before executing them, for example:
+//
+//	CREATE TABLE {{.Database}}.events ON CLUSTER {{.Cluster}} (...)
+//	ENGINE = ReplicatedMergeTree
+//	SETTINGS storage_policy = '{{.StoragePolicy}}'
+//
+// Referencing a key that is missing from the data is an error. Without this
+// option SQL migrations are executed as is.
+//
+// Unless the data has the OnCluster key, it is set to " ON CLUSTER name" when
+// the DB is configured with ch.WithCluster or ch.WithClusterMacro and to ""
+// otherwise, so the same migration works with single nodes and clusters:
+//
+//	CREATE TABLE events{{.OnCluster}} (...)
+func WithTemplateData(data map[string]any) MigratorOption {
+	return func(m *Migrator) {
+		m.templateData = data
+	}
+}
+
+type templateDataKey struct{}
+
+func renderTemplate(name string, content []byte, data map[string]any) ([]byte, error) {
+	tpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("chmigrate: parsing %s: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("chmigrate: rendering %s: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// withClusterData returns a copy of the data with the OnCluster key.
+func withClusterData(ctx context.Context, db *ch.DB, data map[string]any) (map[string]any, error) {
+	if _, ok := data["OnCluster"]; ok {
+		return data, nil
+	}
+
+	cluster, err := db.Cluster(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	clone := make(map[string]any, len(data)+1)
+	for k, v := range data {
+		clone[k] = v
+	}
+	clone["OnCluster"] = ""
+	if cluster != "" {
+		clone["OnCluster"] = " ON CLUSTER " + db.Formatter().FormatQuery("?", ch.Ident(cluster))
+	}
+	return clone, nil
+}
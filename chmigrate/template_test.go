@@ -0,0 +1,119 @@
+package chmigrate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chmigrate"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+func TestTemplateData(t *testing.T) {
+	const upFile = migrationName + "_events.up.sql"
+
+	tests := []struct {
+		name    string
+		sql     string
+		data    map[string]any
+		opts    []ch.Option
+		wanted  string // rendered query
+		wantErr string
+	}{
+		{
+			name:   "without data",
+			sql:    "CREATE TABLE {{.Database}}.events (id UInt64)",
+			wanted: "CREATE TABLE {{.Database}}.events (id UInt64)",
+		},
+		{
+			name:   "single node",
+			sql:    "CREATE TABLE {{.Database}}.events{{.OnCluster}} (id UInt64)",
+			data:   map[string]any{"Database": "analytics"},
+			wanted: "CREATE TABLE analytics.events (id UInt64)",
+		},
+		{
+			name:   "cluster",
+			sql:    "CREATE TABLE events{{.OnCluster}} (id UInt64)",
+			data:   map[string]any{},
+			opts:   []ch.Option{ch.WithCluster("prod")},
+			wanted: `CREATE TABLE events ON CLUSTER "prod" (id UInt64)`,
+		},
+		{
+			name:   "cluster macro",
+			sql:    "CREATE TABLE events{{.OnCluster}} (id UInt64)",
+			data:   map[string]any{},
+			opts:   []ch.Option{ch.WithClusterMacro("cluster")},
+			wanted: `CREATE TABLE events ON CLUSTER "staging" (id UInt64)`,
+		},
+		{
+			name:   "explicit OnCluster",
+			sql:    "CREATE TABLE events{{.OnCluster}} (id UInt64)",
+			data:   map[string]any{"OnCluster": " ON CLUSTER other"},
+			opts:   []ch.Option{ch.WithCluster("prod")},
+			wanted: "CREATE TABLE events ON CLUSTER other (id UInt64)",
+		},
+		{
+			name:    "missing key",
+			sql:     "CREATE TABLE {{.Database}}.events (id UInt64)",
+			data:    map[string]any{},
+			wantErr: "chmigrate: rendering " + upFile + ":",
+		},
+		{
+			name:    "parse error",
+			sql:     "CREATE TABLE {{.Database.events (id UInt64)",
+			data:    map[string]any{"Database": "analytics"},
+			wantErr: "chmigrate: parsing " + upFile + ":",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv := &chfake.Server{Reply: func(query string) chfake.Reply {
+				switch {
+				case strings.HasPrefix(query, "INSERT"):
+					return chfake.Reply{Columns: migrationColumns}
+				case strings.Contains(query, "system.macros"):
+					return chfake.Reply{Columns: []chfake.Column{
+						{Name: "substitution", CHType: "String", Values: []string{"staging"}},
+					}}
+				}
+				return chfake.Reply{}
+			}}
+			opts := append([]ch.Option{ch.WithCompression(false), ch.WithDialer(srv.Dial)},
+				test.opts...)
+			db := ch.Connect(opts...)
+			defer db.Close()
+
+			migrations := chmigrate.NewMigrations()
+			require.NoError(t, migrations.Discover(fstest.MapFS{
+				upFile: &fstest.MapFile{Data: []byte(test.sql)},
+			}))
+
+			var migratorOpts []chmigrate.MigratorOption
+			if test.data != nil {
+				migratorOpts = append(migratorOpts, chmigrate.WithTemplateData(test.data))
+			}
+			m := chmigrate.NewMigrator(db, migrations, migratorOpts...)
+
+			_, err := m.Migrate(context.Background())
+			if test.wantErr != "" {
+				require.Error(t, err)
+				require.True(t, strings.HasPrefix(err.Error(), test.wantErr), err.Error())
+				require.False(t, hasQuery(srv.Queries(), "events"))
+				return
+			}
+			require.NoError(t, err)
+
+			var rendered []string
+			for _, query := range srv.Queries() {
+				if strings.Contains(query, "events") {
+					rendered = append(rendered, strings.TrimSpace(query))
+				}
+			}
+			require.Equal(t, []string{test.wanted}, rendered)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package chmirror mirrors inserts to a secondary ClickHouse cluster,
+// for example, while migrating data to a new cluster.
+package chmirror
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+type Option func(m *Mirror)
+
+// WithSecondaryErrorHandler sets a func that is called when an insert into the
+// secondary DB fails. Secondary errors are never returned to the caller.
+func WithSecondaryErrorHandler(fn func(ctx context.Context, err error)) Option {
+	return func(m *Mirror) {
+		m.onSecondaryError = fn
+	}
+}
+
+// WithSkipSecondaryOnPrimaryError configures the mirror to not write into the
+// secondary DB when the insert into the primary DB fails.
+func WithSkipSecondaryOnPrimaryError(on bool) Option {
+	return func(m *Mirror) {
+		m.skipOnPrimaryError = on
+	}
+}
+
+// Stats contains accumulated insert stats for both DBs.
+type Stats struct {
+	Primary   TargetStats
+	Secondary TargetStats
+}
+
+type TargetStats struct {
+	Inserts uint64 // number of insert queries
+	Errors  uint64 // number of failed insert queries
+	Rows    uint64 // number of successfully inserted rows
+}
+
+// Mirror writes every insert into the primary DB and then into the secondary DB.
+// The result and the error of the primary insert are returned to the caller.
+//
+// Inserts are executed one after another so the model is never accessed
+// concurrently, for example, by BeforeAppendModel hooks.
+type Mirror struct {
+	primary   *ch.DB
+	secondary *ch.DB
+
+	onSecondaryError   func(ctx context.Context, err error)
+	skipOnPrimaryError bool
+
+	primaryStats   targetStats
+	secondaryStats targetStats
+}
+
+func New(primary, secondary *ch.DB, opts ...Option) *Mirror {
+	m := &Mirror{
+		primary:   primary,
+		secondary: secondary,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *Mirror) Primary() *ch.DB {
+	return m.primary
+}
+
+func (m *Mirror) Secondary() *ch.DB {
+	return m.secondary
+}
+
+// Insert inserts the model into both DBs. The optional apply funcs are
+// used to customize the insert query for each DB, for example, to set the table.
+func (m *Mirror) Insert(
+	ctx context.Context, model any, apply ...func(*ch.InsertQuery) *ch.InsertQuery,
+) (sql.Result, error) {
+	res, err := m.insert(ctx, m.primary, &m.primaryStats, model, apply)
+	if err != nil && m.skipOnPrimaryError {
+		return res, err
+	}
+
+	if _, err := m.insert(ctx, m.secondary, &m.secondaryStats, model, apply); err != nil {
+		if m.onSecondaryError != nil {
+			m.onSecondaryError(ctx, err)
+		}
+	}
+
+	return res, err
+}
+
+func (m *Mirror) insert(
+	ctx context.Context,
+	db *ch.DB,
+	stats *targetStats,
+	model any,
+	apply []func(*ch.InsertQuery) *ch.InsertQuery,
+) (sql.Result, error) {
+	q := db.NewInsert().Model(model)
+	for _, fn := range apply {
+		q = fn(q)
+	}
+
+	atomic.AddUint64(&stats.inserts, 1)
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		atomic.AddUint64(&stats.errors, 1)
+		return res, err
+	}
+
+	if n, err := res.RowsAffected(); err == nil {
+		atomic.AddUint64(&stats.rows, uint64(n))
+	}
+	return res, nil
+}
+
+func (m *Mirror) Stats() Stats {
+	return Stats{
+		Primary:   m.primaryStats.load(),
+		Secondary: m.secondaryStats.load(),
+	}
+}
+
+type targetStats struct {
+	inserts uint64
+	errors  uint64
+	rows    uint64
+}
+
+func (s *targetStats) load() TargetStats {
+	return TargetStats{
+		Inserts: atomic.LoadUint64(&s.inserts),
+		Errors:  atomic.LoadUint64(&s.errors),
+		Rows:    atomic.LoadUint64(&s.rows),
+	}
+}
@@ -0,0 +1,155 @@
+package chmirror_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chmirror"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+type Event struct {
+	ch.CHModel `ch:"table:events"`
+
+	ID   uint64
+	Name string
+}
+
+// eventServer returns a fake server that accepts inserts of events
+// or fails them with the exception code.
+func eventServer(code int32) *chfake.Server {
+	return &chfake.Server{Reply: func(query string) chfake.Reply {
+		return chfake.Reply{Code: code, Columns: []chfake.Column{
+			{Name: "id", CHType: "UInt64"},
+			{Name: "name", CHType: "String"},
+		}}
+	}}
+}
+
+var errDial = errors.New("dial failed")
+
+func failingDialer(ctx context.Context, network, addr string) (net.Conn, error) {
+	return nil, errDial
+}
+
+func connect(t *testing.T, dialer func(context.Context, string, string) (net.Conn, error)) *ch.DB {
+	db := ch.Connect(ch.WithCompression(false), ch.WithMaxRetries(0), ch.WithDialer(dialer))
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMirror(t *testing.T) {
+	primary, secondary := eventServer(0), eventServer(0)
+	var secondaryErrs []error
+	m := chmirror.New(connect(t, primary.Dial), connect(t, secondary.Dial),
+		chmirror.WithSecondaryErrorHandler(func(ctx context.Context, err error) {
+			secondaryErrs = append(secondaryErrs, err)
+		}))
+
+	events := []Event{{ID: 1, Name: "foo"}, {ID: 2, Name: "bar"}}
+	res, err := m.Insert(context.Background(), &events, func(q *ch.InsertQuery) *ch.InsertQuery {
+		return q.ModelTableExpr("events_v2")
+	})
+	require.NoError(t, err)
+	n, err := res.RowsAffected()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	require.Empty(t, secondaryErrs)
+
+	for _, srv := range []*chfake.Server{primary, secondary} {
+		require.Equal(t, []string{
+			`INSERT INTO events_v2 ("id", "name") VALUES`,
+		}, srv.Queries())
+	}
+
+	target := chmirror.TargetStats{Inserts: 1, Rows: 2}
+	require.Equal(t, chmirror.Stats{Primary: target, Secondary: target}, m.Stats())
+}
+
+func TestMirrorErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		primaryErr       bool
+		secondaryErr     bool
+		skipOnPrimaryErr bool
+		wanted           chmirror.Stats
+	}{
+		{
+			name:         "secondary error",
+			secondaryErr: true,
+			wanted: chmirror.Stats{
+				Primary:   chmirror.TargetStats{Inserts: 1, Rows: 1},
+				Secondary: chmirror.TargetStats{Inserts: 1, Errors: 1},
+			},
+		},
+		{
+			name:       "primary error",
+			primaryErr: true,
+			wanted: chmirror.Stats{
+				Primary:   chmirror.TargetStats{Inserts: 1, Errors: 1},
+				Secondary: chmirror.TargetStats{Inserts: 1, Rows: 1},
+			},
+		},
+		{
+			name:             "skip secondary on primary error",
+			primaryErr:       true,
+			skipOnPrimaryErr: true,
+			wanted: chmirror.Stats{
+				Primary: chmirror.TargetStats{Inserts: 1, Errors: 1},
+			},
+		},
+		{
+			name:         "both errors",
+			primaryErr:   true,
+			secondaryErr: true,
+			wanted: chmirror.Stats{
+				Primary:   chmirror.TargetStats{Inserts: 1, Errors: 1},
+				Secondary: chmirror.TargetStats{Inserts: 1, Errors: 1},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var code int32
+			if test.primaryErr {
+				code = ch.CodeTableIsReadOnly
+			}
+			primary, secondary := eventServer(code), eventServer(0)
+			primaryDB := connect(t, primary.Dial)
+			secondaryDB := connect(t, secondary.Dial)
+			if test.secondaryErr {
+				secondaryDB = connect(t, failingDialer)
+			}
+
+			var secondaryErrs []error
+			m := chmirror.New(primaryDB, secondaryDB,
+				chmirror.WithSkipSecondaryOnPrimaryError(test.skipOnPrimaryErr),
+				chmirror.WithSecondaryErrorHandler(func(ctx context.Context, err error) {
+					secondaryErrs = append(secondaryErrs, err)
+				}))
+
+			_, err := m.Insert(context.Background(), &Event{ID: 1, Name: "foo"})
+			if test.primaryErr {
+				// The caller only sees the primary error.
+				require.True(t, ch.IsReadonly(err), err)
+				require.False(t, errors.Is(err, errDial), err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if test.secondaryErr {
+				require.Len(t, secondaryErrs, 1)
+				require.ErrorIs(t, secondaryErrs[0], errDial)
+			} else {
+				require.Empty(t, secondaryErrs)
+			}
+
+			require.Equal(t, test.wanted, m.Stats())
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package chpprof labels query execution for CPU profiles and execution traces
+// so the time spent by the client is attributed to the query shapes:
+//
+//	db.AddQueryHook(chpprof.NewQueryHook())
+//
+// Queries are labeled with the operation and the query fingerprint, which is
+// the query with literals replaced by ?. Use `go tool pprof -tagfocus` to
+// filter profiles by the labels.
+//
+// Labels cover the time until AfterQuery returns. For QueryContext the hook
+// runs before the rows are read, so reading the rows is not labeled.
+package chpprof
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"runtime/trace"
+	"strings"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+const (
+	OperationLabel   = "ch.operation"
+	FingerprintLabel = "ch.query"
+)
+
+type Option func(h *QueryHook)
+
+// WithMaxFingerprintLen limits the length of the fingerprint label. Default is 256.
+func WithMaxFingerprintLen(n int) Option {
+	return func(h *QueryHook) {
+		h.maxLen = n
+	}
+}
+
+// WithTraceRegions enables runtime/trace regions around queries.
+// Regions are only recorded when tracing is enabled.
+func WithTraceRegions(on bool) Option {
+	return func(h *QueryHook) {
+		h.traceRegions = on
+	}
+}
+
+type QueryHook struct {
+	maxLen       int
+	traceRegions bool
+}
+
+var _ ch.QueryHook = (*QueryHook)(nil)
+
+func NewQueryHook(opts ...Option) *QueryHook {
+	h := &QueryHook{
+		maxLen:       256,
+		traceRegions: true,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type stashKey struct{}
+
+type stash struct {
+	parent context.Context // ctx passed to the query
+	region *trace.Region
+}
+
+func (h *QueryHook) BeforeQuery(ctx context.Context, evt *ch.QueryEvent) context.Context {
+	fingerprint := Fingerprint(evt.Query)
+	if len(fingerprint) > h.maxLen {
+		fingerprint = fingerprint[:h.maxLen]
+	}
+
+	st := &stash{parent: ctx}
+	if evt.Stash == nil {
+		evt.Stash = make(map[any]any)
+	}
+	evt.Stash[stashKey{}] = st
+
+	ctx = pprof.WithLabels(ctx, pprof.Labels(
+		OperationLabel, evt.Operation(),
+		FingerprintLabel, fingerprint,
+	))
+	pprof.SetGoroutineLabels(ctx)
+
+	if h.traceRegions && trace.IsEnabled() {
+		st.region = trace.StartRegion(ctx, evt.Operation()+" "+fingerprint)
+	}
+	return ctx
+}
+
+func (h *QueryHook) AfterQuery(ctx context.Context, evt *ch.QueryEvent) {
+	st, ok := evt.Stash[stashKey{}].(*stash)
+	if !ok {
+		return
+	}
+	if st.region != nil {
+		st.region.End()
+	}
+	// Reset the goroutine labels to the labels of the ctx passed to the query,
+	// which are usually the labels set with pprof.Do by the caller.
+	pprof.SetGoroutineLabels(st.parent)
+}
+
+//------------------------------------------------------------------------------
+
+// Fingerprint returns the query with string and number literals replaced by ?,
+// lists of placeholders collapsed into a single ?, and spaces collapsed.
+func Fingerprint(query string) string {
+	b := make([]byte, 0, len(query))
+
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			// Skip the string literal, including escaped quotes.
+			for i++; i < len(query); i++ {
+				if query[i] == '\\' {
+					i++
+					continue
+				}
+				if query[i] == '\'' {
+					if i+1 < len(query) && query[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b = appendPlaceholder(b)
+		case isDigit(c) && (len(b) == 0 || !isIdentChar(b[len(b)-1])):
+			for i+1 < len(query) && (isIdentChar(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b = appendPlaceholder(b)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if len(b) > 0 && b[len(b)-1] != ' ' {
+				b = append(b, ' ')
+			}
+		default:
+			b = append(b, c)
+		}
+	}
+
+	return strings.TrimRight(string(b), " ")
+}
+
+// appendPlaceholder appends ? unless it continues a list of placeholders.
+func appendPlaceholder(b []byte) []byte {
+	switch {
+	case bytes.HasSuffix(b, []byte("?, ")):
+		return b[:len(b)-2]
+	case bytes.HasSuffix(b, []byte("?,")):
+		return b[:len(b)-1]
+	}
+	return append(b, '?')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return isDigit(c) || c == '_' || c == '"' || c == '`' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
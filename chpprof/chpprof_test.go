@@ -0,0 +1,82 @@
+package chpprof_test
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chpprof"
+)
+
+func TestQueryHook(t *testing.T) {
+	hook := chpprof.NewQueryHook()
+	evt := &ch.QueryEvent{Query: "SELECT 1"}
+
+	ctx := hook.BeforeQuery(context.Background(), evt)
+	op, _ := pprof.Label(ctx, chpprof.OperationLabel)
+	require.Equal(t, "SELECT", op)
+	fingerprint, _ := pprof.Label(ctx, chpprof.FingerprintLabel)
+	require.Equal(t, "SELECT ?", fingerprint)
+
+	hook.AfterQuery(ctx, evt)
+}
+
+func TestQueryHookRestoresGoroutineLabels(t *testing.T) {
+	defer pprof.SetGoroutineLabels(context.Background())
+
+	pprof.Do(context.Background(), pprof.Labels("caller", "test"), func(ctx context.Context) {
+		hook := chpprof.NewQueryHook()
+		evt := &ch.QueryEvent{Query: "SELECT 1"}
+
+		queryCtx := hook.BeforeQuery(ctx, evt)
+		dump := goroutineLabels(t)
+		require.Contains(t, dump, `"caller":"test"`)
+		require.Contains(t, dump, `"ch.query":"SELECT ?"`)
+
+		hook.AfterQuery(queryCtx, evt)
+		dump = goroutineLabels(t)
+		require.Contains(t, dump, `"caller":"test"`)
+		require.NotContains(t, dump, `"ch.query"`)
+	})
+}
+
+// goroutineLabels returns the labels of the goroutines.
+func goroutineLabels(t *testing.T) string {
+	var buf bytes.Buffer
+	require.NoError(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+
+	var labels []byte
+	for _, line := range bytes.Split(buf.Bytes(), []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("# labels:")) {
+			labels = append(labels, line...)
+		}
+	}
+	return string(labels)
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query       string
+		fingerprint string
+	}{
+		{
+			`SELECT "id" FROM "events" WHERE (id = 123) AND (name = 'it''s')`,
+			`SELECT "id" FROM "events" WHERE (id = ?) AND (name = ?)`,
+		},
+		{
+			"SELECT *\n  FROM t1\n  WHERE id IN (1, 2, 3) LIMIT 10",
+			"SELECT * FROM t1 WHERE id IN (?) LIMIT ?",
+		},
+		{
+			`SELECT toUInt8(x), 'a\'b' FROM t WHERE f = -1.5e3`,
+			`SELECT toUInt8(x), ? FROM t WHERE f = -?`,
+		},
+	}
+	for _, test := range tests {
+		require.Equal(t, test.fingerprint, chpprof.Fingerprint(test.query))
+	}
+}
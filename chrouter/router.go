@@ -0,0 +1,144 @@
+// Package chrouter routes queries to different ClickHouse clusters by table,
+// for example, to keep recent data on a hot cluster and archives on a cold one.
+package chrouter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/go-clickhouse/ch"
+)
+
+type Option func(r *Router)
+
+// WithTables routes queries on the tables to the db.
+func WithTables(db *ch.DB, tables ...string) Option {
+	return func(r *Router) {
+		for _, table := range tables {
+			r.tables[table] = db
+		}
+	}
+}
+
+// WithModels routes queries on the tables of the models to the db.
+// Models are pointers to structs, for example, (*Event)(nil).
+func WithModels(db *ch.DB, models ...any) Option {
+	return func(r *Router) {
+		for _, model := range models {
+			table := tableName(r.def, model)
+			if table == "" {
+				panic(fmt.Errorf("chrouter: can't get the table of %T", model))
+			}
+			r.tables[table] = db
+		}
+	}
+}
+
+// Router maps tables to DBs. Queries on tables without a route use the default DB.
+//
+// Queries are created on the DB of the model so the builder API is the same:
+//
+//	event := new(Event)
+//	err := router.NewSelect(event).Where("id = ?", id).Scan(ctx)
+type Router struct {
+	def    *ch.DB
+	tables map[string]*ch.DB
+}
+
+// New returns a Router that uses def for unrouted tables.
+func New(def *ch.DB, opts ...Option) *Router {
+	r := &Router{
+		def:    def,
+		tables: make(map[string]*ch.DB),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Default returns the DB used for unrouted tables.
+func (r *Router) Default() *ch.DB {
+	return r.def
+}
+
+// Table returns the DB for the table.
+func (r *Router) Table(table string) *ch.DB {
+	if db, ok := r.tables[table]; ok {
+		return db
+	}
+	return r.def
+}
+
+// DB returns the DB for the table of the model.
+func (r *Router) DB(model any) *ch.DB {
+	return r.Table(tableName(r.def, model))
+}
+
+// DBs returns the default DB and the routed DBs without duplicates.
+func (r *Router) DBs() []*ch.DB {
+	dbs := []*ch.DB{r.def}
+	seen := map[*ch.DB]bool{r.def: true}
+	for _, db := range r.tables {
+		if !seen[db] {
+			seen[db] = true
+			dbs = append(dbs, db)
+		}
+	}
+	return dbs
+}
+
+func (r *Router) NewSelect(model any) *ch.SelectQuery {
+	return r.DB(model).NewSelect().Model(model)
+}
+
+func (r *Router) NewInsert(model any) *ch.InsertQuery {
+	return r.DB(model).NewInsert().Model(model)
+}
+
+func (r *Router) NewCreateTable(model any) *ch.CreateTableQuery {
+	return r.DB(model).NewCreateTable().Model(model)
+}
+
+func (r *Router) NewDropTable(model any) *ch.DropTableQuery {
+	return r.DB(model).NewDropTable().Model(model)
+}
+
+func (r *Router) NewTruncateTable(model any) *ch.TruncateTableQuery {
+	return r.DB(model).NewTruncateTable().Model(model)
+}
+
+// ResetModel drops and creates the tables of the models on their DBs.
+func (r *Router) ResetModel(ctx context.Context, models ...any) error {
+	for _, model := range models {
+		if err := r.DB(model).ResetModel(ctx, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping pings all DBs.
+func (r *Router) Ping(ctx context.Context) error {
+	for _, db := range r.DBs() {
+		if err := db.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes all DBs and returns the first error.
+func (r *Router) Close() error {
+	var firstErr error
+	for _, db := range r.DBs() {
+		if err := db.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func tableName(db *ch.DB, model any) string {
+	return db.NewSelect().Model(model).GetTableName()
+}
@@ -0,0 +1,127 @@
+package chrouter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chrouter"
+)
+
+type Span struct {
+	ch.CHModel `ch:"table:spans"`
+
+	ID uint64
+}
+
+type Event struct {
+	ch.CHModel `ch:"table:events"`
+
+	Name string
+}
+
+type ArchivedEvent struct {
+	ch.CHModel `ch:"table:events_archive"`
+
+	Name string
+}
+
+func TestRouter(t *testing.T) {
+	def := ch.Connect()
+	hot := ch.Connect()
+	cold := ch.Connect()
+
+	router := chrouter.New(def,
+		chrouter.WithTables(hot, "logs", "metrics"),
+		chrouter.WithModels(hot, (*Event)(nil)),
+		chrouter.WithModels(cold, (*ArchivedEvent)(nil)),
+	)
+	defer router.Close()
+
+	tests := []struct {
+		name  string
+		model any
+		table string
+		db    *ch.DB
+	}{
+		{"unrouted model", (*Span)(nil), "spans", def},
+		{"routed model", (*Event)(nil), "events", hot},
+		{"model value", new(Event), "events", hot},
+		{"model slice", new([]Event), "events", hot},
+		{"other cluster", (*ArchivedEvent)(nil), "events_archive", cold},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Same(t, test.db, router.DB(test.model))
+			require.Same(t, test.db, router.Table(test.table))
+
+			q := router.NewSelect(test.model)
+			require.Same(t, test.db, q.DB())
+			require.Equal(t, test.table, q.GetTableName())
+
+			require.Same(t, test.db, router.NewInsert(test.model).DB())
+			require.Same(t, test.db, router.NewCreateTable(test.model).DB())
+			require.Same(t, test.db, router.NewDropTable(test.model).DB())
+			require.Same(t, test.db, router.NewTruncateTable(test.model).DB())
+		})
+	}
+
+	require.Same(t, hot, router.Table("logs"))
+	require.Same(t, hot, router.Table("metrics"))
+	require.Same(t, def, router.Table("unknown"))
+	require.Same(t, def, router.Default())
+}
+
+func TestRouterDBs(t *testing.T) {
+	def := ch.Connect()
+	hot := ch.Connect()
+
+	tests := []struct {
+		name   string
+		opts   []chrouter.Option
+		wanted []*ch.DB
+	}{
+		{"default only", nil, []*ch.DB{def}},
+		{"routed to default", []chrouter.Option{chrouter.WithTables(def, "spans")}, []*ch.DB{def}},
+		{"deduplicated", []chrouter.Option{
+			chrouter.WithTables(hot, "logs", "metrics"),
+			chrouter.WithModels(hot, (*Event)(nil)),
+		}, []*ch.DB{def, hot}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := chrouter.New(def, test.opts...)
+			require.Equal(t, test.wanted, router.DBs())
+		})
+	}
+
+	require.NoError(t, chrouter.New(def, chrouter.WithTables(hot, "logs")).Close())
+}
+
+func TestRouterLastRouteWins(t *testing.T) {
+	hot := ch.Connect()
+	cold := ch.Connect()
+
+	router := chrouter.New(ch.Connect(),
+		chrouter.WithTables(hot, "events"),
+		chrouter.WithModels(cold, (*Event)(nil)),
+	)
+	require.Same(t, cold, router.Table("events"))
+}
+
+func TestRouterPingError(t *testing.T) {
+	db := ch.Connect(ch.WithAddr("127.0.0.1:1"), ch.WithDialTimeout(100*time.Millisecond))
+	router := chrouter.New(db)
+	defer router.Close()
+
+	require.Error(t, router.Ping(context.Background()))
+}
+
+func TestWithModelsPanics(t *testing.T) {
+	require.Panics(t, func() {
+		chrouter.New(ch.Connect(), chrouter.WithModels(ch.Connect(), 42))
+	})
+}
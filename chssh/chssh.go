@@ -0,0 +1,390 @@
+// Package chssh dials ClickHouse servers through an SSH jump host, also known
+// as a bastion, for clusters that are only reachable from a private network:
+//
+//	dialer, err := chssh.NewDialer("bastion.example.com:22",
+//		chssh.WithUser("deploy"),
+//		chssh.WithPrivateKeyFile("/home/deploy/.ssh/id_ed25519"),
+//		chssh.WithKnownHostsFile("/home/deploy/.ssh/known_hosts"),
+//	)
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer dialer.Close()
+//
+//	db := ch.Connect(ch.WithDialer(dialer.DialContext))
+package chssh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+type Option func(d *Dialer) error
+
+// WithUser sets the user name on the jump host.
+func WithUser(user string) Option {
+	return func(d *Dialer) error {
+		d.cfg.User = user
+		return nil
+	}
+}
+
+// WithPrivateKey authenticates on the jump host using the PEM encoded private key.
+func WithPrivateKey(pemBytes []byte) Option {
+	return func(d *Dialer) error {
+		signer, err := ssh.ParsePrivateKey(pemBytes)
+		if err != nil {
+			return fmt.Errorf("chssh: can't parse private key: %w", err)
+		}
+		d.cfg.Auth = append(d.cfg.Auth, ssh.PublicKeys(signer))
+		return nil
+	}
+}
+
+// WithPrivateKeyFile authenticates on the jump host using the private key file.
+func WithPrivateKeyFile(path string) Option {
+	return func(d *Dialer) error {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("chssh: can't read private key: %w", err)
+		}
+		return WithPrivateKey(b)(d)
+	}
+}
+
+// WithPassword authenticates on the jump host using the password.
+func WithPassword(password string) Option {
+	return func(d *Dialer) error {
+		d.cfg.Auth = append(d.cfg.Auth, ssh.Password(password))
+		return nil
+	}
+}
+
+// WithKnownHostsFile verifies the jump host key using the known_hosts file.
+func WithKnownHostsFile(path string) Option {
+	return func(d *Dialer) error {
+		callback, err := knownhosts.New(path)
+		if err != nil {
+			return fmt.Errorf("chssh: can't read known hosts: %w", err)
+		}
+		d.cfg.HostKeyCallback = callback
+		return nil
+	}
+}
+
+// WithHostKeyCallback sets the func that verifies the jump host key.
+func WithHostKeyCallback(callback ssh.HostKeyCallback) Option {
+	return func(d *Dialer) error {
+		d.cfg.HostKeyCallback = callback
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout for connecting to the jump host. Default is 10 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(d *Dialer) error {
+		d.cfg.Timeout = timeout
+		return nil
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Dialer opens connections through the jump host. The SSH connection
+// is established on the first dial and re-established when it is closed.
+type Dialer struct {
+	addr string
+	cfg  ssh.ClientConfig
+
+	mu      sync.Mutex
+	client  *ssh.Client
+	dialing *sshDial // nil unless the SSH connection is being established
+	closed  bool
+}
+
+// sshDial is the SSH connection that is being established. Concurrent dials
+// wait for the same connection.
+type sshDial struct {
+	done   chan struct{}
+	client *ssh.Client
+	err    error
+}
+
+// NewDialer returns a Dialer for the jump host addr, for example,
+// "bastion.example.com:22". The host key must be verified using
+// WithKnownHostsFile or WithHostKeyCallback.
+func NewDialer(addr string, opts ...Option) (*Dialer, error) {
+	d := &Dialer{
+		addr: addr,
+		cfg: ssh.ClientConfig{
+			User:    os.Getenv("USER"),
+			Timeout: 10 * time.Second,
+		},
+	}
+	for _, opt := range opts {
+		if err := opt(d); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.cfg.HostKeyCallback == nil {
+		return nil, errors.New("chssh: host key callback is required")
+	}
+	if len(d.cfg.Auth) == 0 {
+		return nil, errors.New("chssh: private key or password is required")
+	}
+	return d, nil
+}
+
+// DialContext connects to addr from the jump host. It can be used
+// with ch.WithDialer. SSH channels don't support deadlines, so they are
+// emulated: a read or write that doesn't finish before its deadline
+// closes the connection.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, err := d.sshClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	ch := make(chan result, 1)
+
+	// ssh.Client.Dial does not accept a context.
+	go func() {
+		conn, err := client.Dial(network, addr)
+		ch <- result{conn: conn, err: err}
+	}()
+
+	select {
+	case res := <-ch:
+		if res.err != nil {
+			return nil, fmt.Errorf("chssh: can't dial %s via %s: %w", addr, d.addr, res.err)
+		}
+		return newDeadlineConn(res.conn), nil
+	case <-ctx.Done():
+		go func() {
+			if res := <-ch; res.conn != nil {
+				_ = res.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+var errClosed = errors.New("chssh: dialer is closed")
+
+// sshClient returns the SSH connection to the jump host. The connection is
+// established in the background without holding the lock, so callers whose
+// ctx is done and Close don't wait for a slow jump host.
+func (d *Dialer) sshClient(ctx context.Context) (*ssh.Client, error) {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil, errClosed
+	}
+	if d.client != nil {
+		client := d.client
+		d.mu.Unlock()
+		return client, nil
+	}
+	dial := d.dialing
+	if dial == nil {
+		dial = &sshDial{done: make(chan struct{})}
+		d.dialing = dial
+		go d.dialSSH(dial)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-dial.done:
+		return dial.client, dial.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (d *Dialer) dialSSH(dial *sshDial) {
+	client, err := d.connect()
+
+	d.mu.Lock()
+	d.dialing = nil
+	if err == nil && d.closed {
+		_ = client.Close()
+		client, err = nil, errClosed
+	}
+	if err == nil {
+		d.client = client
+		go d.watch(client)
+	}
+	dial.client, dial.err = client, err
+	d.mu.Unlock()
+
+	close(dial.done)
+}
+
+func (d *Dialer) connect() (*ssh.Client, error) {
+	netDialer := &net.Dialer{Timeout: d.cfg.Timeout}
+	conn, err := netDialer.Dial("tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.cfg.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(d.cfg.Timeout))
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, d.addr, &d.cfg)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("chssh: handshake with %s failed: %w", d.addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// watch forgets the client when the SSH connection is closed, so the next
+// dial establishes a new one.
+func (d *Dialer) watch(client *ssh.Client) {
+	_ = client.Wait()
+
+	d.mu.Lock()
+	if d.client == client {
+		d.client = nil
+	}
+	d.mu.Unlock()
+}
+
+// Close closes the SSH connection. Connections opened through
+// the jump host are closed as well.
+func (d *Dialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.closed = true
+	if d.client == nil {
+		return nil
+	}
+	err := d.client.Close()
+	d.client = nil
+	return err
+}
+
+//------------------------------------------------------------------------------
+
+// deadlineConn emulates deadlines for SSH channels, which fail to set them.
+// Reads and writes that are pending when their deadline expires are
+// interrupted by closing the connection.
+type deadlineConn struct {
+	net.Conn
+
+	mu       sync.Mutex
+	rd, wr   connDeadline
+	timedOut bool // the connection was closed by a deadline
+}
+
+type connDeadline struct {
+	t       time.Time
+	timer   *time.Timer
+	pending int // number of pending reads or writes
+}
+
+func newDeadlineConn(conn net.Conn) *deadlineConn {
+	return &deadlineConn{Conn: conn}
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if err := c.begin(&c.rd); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	return n, c.end(&c.rd, err)
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	if err := c.begin(&c.wr); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(b)
+	return n, c.end(&c.wr, err)
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error {
+	c.setDeadline(&c.rd, t)
+	c.setDeadline(&c.wr, t)
+	return nil
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.setDeadline(&c.rd, t)
+	return nil
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.setDeadline(&c.wr, t)
+	return nil
+}
+
+func (c *deadlineConn) setDeadline(d *connDeadline, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.t = t
+	if !t.IsZero() {
+		d.timer = time.AfterFunc(time.Until(t), func() { c.expire(d) })
+	}
+}
+
+// expire closes the connection if a read or write is pending after the deadline.
+func (c *deadlineConn) expire(d *connDeadline) {
+	c.mu.Lock()
+	// The deadline could be extended after the timer fired.
+	if d.pending == 0 || !d.expired() {
+		c.mu.Unlock()
+		return
+	}
+	c.timedOut = true
+	c.mu.Unlock()
+
+	_ = c.Conn.Close()
+}
+
+func (c *deadlineConn) begin(d *connDeadline) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timedOut || d.expired() {
+		return os.ErrDeadlineExceeded
+	}
+	d.pending++
+	return nil
+}
+
+func (c *deadlineConn) end(d *connDeadline, err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	d.pending--
+	if err != nil && c.timedOut {
+		return os.ErrDeadlineExceeded
+	}
+	return err
+}
+
+func (d *connDeadline) expired() bool {
+	return !d.t.IsZero() && !time.Now().Before(d.t)
+}
@@ -0,0 +1,279 @@
+package chssh_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/uptrace/go-clickhouse/ch"
+	"github.com/uptrace/go-clickhouse/chssh"
+	"github.com/uptrace/go-clickhouse/internal/chfake"
+)
+
+// sshServer is a jump host that forwards direct-tcpip channels.
+type sshServer struct {
+	ln      net.Listener
+	cfg     *ssh.ServerConfig
+	hostKey ssh.PublicKey
+	ready   <-chan struct{} // closed when the server may start handshakes
+	conns   int32           // accepted connections
+}
+
+func newSSHServer(t *testing.T, ready <-chan struct{}) *sshServer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	srv := &sshServer{ln: ln, cfg: cfg, hostKey: signer.PublicKey(), ready: ready}
+	go srv.serve()
+	return srv
+}
+
+func (s *sshServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		atomic.AddInt32(&s.conns, 1)
+		go s.serveConn(conn)
+	}
+}
+
+func (s *sshServer) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	if s.ready != nil {
+		<-s.ready
+	}
+	_, chans, reqs, err := ssh.NewServerConn(conn, s.cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		var target struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		if newChan.ChannelType() != "direct-tcpip" ||
+			ssh.Unmarshal(newChan.ExtraData(), &target) != nil {
+			_ = newChan.Reject(ssh.UnknownChannelType, "unsupported channel")
+			continue
+		}
+
+		addr := net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port)))
+		targetConn, err := net.Dial("tcp", addr)
+		if err != nil {
+			_ = newChan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, chanReqs, err := newChan.Accept()
+		if err != nil {
+			targetConn.Close()
+			continue
+		}
+		go ssh.DiscardRequests(chanReqs)
+		go func() {
+			defer channel.Close()
+			defer targetConn.Close()
+			go func() { _, _ = io.Copy(targetConn, channel) }()
+			_, _ = io.Copy(channel, targetConn)
+		}()
+	}
+}
+
+func (s *sshServer) dialer(t *testing.T, opts ...chssh.Option) *chssh.Dialer {
+	opts = append([]chssh.Option{
+		chssh.WithUser("deploy"),
+		chssh.WithPassword("secret"),
+		chssh.WithHostKeyCallback(ssh.FixedHostKey(s.hostKey)),
+	}, opts...)
+	dialer, err := chssh.NewDialer(s.ln.Addr().String(), opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { dialer.Close() })
+	return dialer
+}
+
+// echoServer returns the address of a server that echoes the received data.
+func echoServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestDialerSharesConnection(t *testing.T) {
+	srv := newSSHServer(t, nil)
+	dialer := srv.dialer(t)
+	addr := echoServer(t)
+
+	var wg sync.WaitGroup
+	errc := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			conn, err := dialer.DialContext(context.Background(), "tcp", addr)
+			if err != nil {
+				errc <- err
+				return
+			}
+			defer conn.Close()
+
+			if _, err := conn.Write([]byte("ping")); err != nil {
+				errc <- err
+				return
+			}
+			b := make([]byte, 4)
+			if _, err := io.ReadFull(conn, b); err != nil {
+				errc <- err
+				return
+			}
+			if string(b) != "ping" {
+				errc <- io.ErrUnexpectedEOF
+			}
+		}()
+	}
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, int32(1), atomic.LoadInt32(&srv.conns))
+}
+
+func TestDialerSlowHandshake(t *testing.T) {
+	ready := make(chan struct{})
+	defer close(ready)
+
+	srv := newSSHServer(t, ready)
+	dialer := srv.dialer(t)
+	addr := echoServer(t)
+
+	// Callers don't wait for the jump host longer than their ctx allows.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := dialer.DialContext(ctx, "tcp", addr)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+
+	// Close does not wait for the handshake either.
+	start = time.Now()
+	require.NoError(t, dialer.Close())
+	require.Less(t, time.Since(start), time.Second)
+
+	_, err = dialer.DialContext(context.Background(), "tcp", addr)
+	require.EqualError(t, err, "chssh: dialer is closed")
+}
+
+func TestDialerTimeout(t *testing.T) {
+	ready := make(chan struct{})
+	defer close(ready)
+
+	srv := newSSHServer(t, ready)
+	dialer := srv.dialer(t, chssh.WithTimeout(50*time.Millisecond))
+
+	_, err := dialer.DialContext(context.Background(), "tcp", echoServer(t))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "handshake with")
+}
+
+func TestDialerQuery(t *testing.T) {
+	srv := newSSHServer(t, nil)
+	dialer := srv.dialer(t)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	fake := new(chfake.Server)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go fake.Serve(conn)
+		}
+	}()
+
+	db := ch.Connect(
+		ch.WithAddr(ln.Addr().String()),
+		ch.WithCompression(false),
+		ch.WithDialer(dialer.DialContext),
+	)
+	defer db.Close()
+
+	_, err = db.ExecContext(context.Background(), "SELECT 1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"SELECT 1"}, fake.Queries())
+}
+
+func TestDialerReadDeadline(t *testing.T) {
+	srv := newSSHServer(t, nil)
+	dialer := srv.dialer(t)
+
+	conn, err := dialer.DialContext(context.Background(), "tcp", echoServer(t))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The deadline is extended before it expires.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(20*time.Millisecond)))
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Hour)))
+	time.Sleep(50 * time.Millisecond)
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+	b := make([]byte, 4)
+	_, err = io.ReadFull(conn, b)
+	require.NoError(t, err)
+
+	// The pending read is interrupted.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	start := time.Now()
+	_, err = conn.Read(b)
+	require.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,26 @@
+module github.com/uptrace/go-clickhouse/chssh
+
+go 1.18
+
+replace github.com/uptrace/go-clickhouse => ./..
+
+require (
+	github.com/stretchr/testify v1.7.5
+	github.com/uptrace/go-clickhouse v0.2.8
+	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d
+)
+
+require (
+	github.com/codemodus/kace v0.5.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/jinzhu/inflection v1.0.0 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/kr/text v0.2.0 // indirect
+	github.com/pierrec/lz4/v4 v4.1.15 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.opentelemetry.io/otel v1.7.0 // indirect
+	go.opentelemetry.io/otel/trace v1.7.0 // indirect
+	golang.org/x/exp v0.0.0-20220613132600-b0d781184e0d // indirect
+	golang.org/x/sys v0.0.0-20220624220833-87e55d714810 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
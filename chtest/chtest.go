@@ -0,0 +1,462 @@
+// Package chtest compares ClickHouse result sets in tests, for example,
+// to check that a data pipeline still produces the expected results.
+//
+//	var got map[string]any
+//	err := db.NewSelect().TableExpr("daily_stats").Order("date").ScanColumns(ctx, &got)
+//	require.NoError(t, err)
+//
+//	chtest.AssertEqual(t, got, want,
+//		chtest.WithFloatEpsilon(1e-9),
+//		chtest.WithTimeTruncate(time.Second))
+package chtest
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+type config struct {
+	floatEpsilon  float64
+	timeTruncate  time.Duration
+	ignoreColumns map[string]struct{}
+	maxDiffs      int
+}
+
+type Option func(c *config)
+
+// WithFloatEpsilon sets the max absolute difference between equal floats.
+func WithFloatEpsilon(eps float64) Option {
+	return func(c *config) {
+		c.floatEpsilon = eps
+	}
+}
+
+// WithTimeTruncate truncates times to a multiple of d before comparing them,
+// for example, to compare DateTime64 columns with fixtures in seconds.
+func WithTimeTruncate(d time.Duration) Option {
+	return func(c *config) {
+		c.timeTruncate = d
+	}
+}
+
+// WithIgnoreColumns excludes the columns from the comparison,
+// for example, columns with generated ids or insert times.
+func WithIgnoreColumns(columns ...string) Option {
+	return func(c *config) {
+		for _, col := range columns {
+			c.ignoreColumns[col] = struct{}{}
+		}
+	}
+}
+
+// WithMaxDiffs sets the max number of reported differences per column.
+// The default is 10 and zero reports all differences.
+func WithMaxDiffs(n int) Option {
+	return func(c *config) {
+		c.maxDiffs = n
+	}
+}
+
+// TestingT is the subset of testing.TB used by AssertEqual.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// AssertEqual reports the diff of the result sets as a test error.
+// It returns true when the result sets are equal.
+func AssertEqual(t TestingT, got, want any, opts ...Option) bool {
+	t.Helper()
+
+	if diff := Diff(got, want, opts...); diff != "" {
+		t.Errorf("result sets are not equal (-got +want):\n%s", diff)
+		return false
+	}
+	return true
+}
+
+// Diff compares the result sets column by column and returns a readable diff
+// or an empty string when the result sets are equal.
+//
+// A result set is one of:
+//   - map[string]any with a slice of values per column, e.g. from ScanColumns;
+//   - []map[string]any with a map per row, e.g. from Scan or JSON fixtures;
+//   - a slice of structs or pointers to structs, e.g. a model.
+//
+// Values are compared by value and not by type, so int64(1) is equal to uint8(1)
+// and float64(1), and times are equal to strings in RFC 3339 or ClickHouse format.
+func Diff(got, want any, opts ...Option) string {
+	cfg := &config{
+		ignoreColumns: make(map[string]struct{}),
+		maxDiffs:      10,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	gotSet, err := newResultSet(got)
+	if err != nil {
+		return err.Error()
+	}
+	wantSet, err := newResultSet(want)
+	if err != nil {
+		return err.Error()
+	}
+
+	var b strings.Builder
+
+	if gotSet.numRow != wantSet.numRow {
+		fmt.Fprintf(&b, "rows: got %d, want %d\n", gotSet.numRow, wantSet.numRow)
+	}
+
+	for _, col := range wantSet.columns {
+		if _, ok := cfg.ignoreColumns[col]; ok {
+			continue
+		}
+		if _, ok := gotSet.values[col]; !ok {
+			fmt.Fprintf(&b, "column %q: missing\n", col)
+		}
+	}
+	for _, col := range gotSet.columns {
+		if _, ok := cfg.ignoreColumns[col]; ok {
+			continue
+		}
+		if _, ok := wantSet.values[col]; !ok {
+			fmt.Fprintf(&b, "column %q: unexpected\n", col)
+		}
+	}
+
+	numRow := gotSet.numRow
+	if wantSet.numRow < numRow {
+		numRow = wantSet.numRow
+	}
+
+	for _, col := range wantSet.columns {
+		if _, ok := cfg.ignoreColumns[col]; ok {
+			continue
+		}
+		gotValues, ok := gotSet.values[col]
+		if !ok {
+			continue
+		}
+		wantValues := wantSet.values[col]
+
+		var numDiff int
+		for i := 0; i < numRow; i++ {
+			gotValue, wantValue := gotValues[i], wantValues[i]
+			if cfg.equal(gotValue, wantValue) {
+				continue
+			}
+
+			numDiff++
+			if cfg.maxDiffs > 0 && numDiff > cfg.maxDiffs {
+				continue
+			}
+			fmt.Fprintf(&b, "column %q: row %d:\n\t- %s\n\t+ %s\n",
+				col, i, formatValue(gotValue), formatValue(wantValue))
+		}
+		if cfg.maxDiffs > 0 && numDiff > cfg.maxDiffs {
+			fmt.Fprintf(&b, "column %q: and %d more rows\n", col, numDiff-cfg.maxDiffs)
+		}
+	}
+
+	return b.String()
+}
+
+//------------------------------------------------------------------------------
+
+type resultSet struct {
+	columns []string
+	values  map[string][]any
+	numRow  int
+}
+
+func newResultSet(v any) (*resultSet, error) {
+	set := &resultSet{
+		values: make(map[string][]any),
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return set, nil
+	case map[string]any:
+		return set, set.addColumns(v)
+	case *map[string]any:
+		return set, set.addColumns(*v)
+	case []map[string]any:
+		set.addRows(v)
+		return set, nil
+	case *[]map[string]any:
+		set.addRows(*v)
+		return set, nil
+	}
+
+	slice := reflect.Indirect(reflect.ValueOf(v))
+	if slice.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("chtest: unsupported result set %T", v)
+	}
+
+	elemType := indirectType(slice.Type().Elem())
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("chtest: unsupported result set %T", v)
+	}
+
+	table := chschema.TableForType(elemType)
+	for _, field := range table.Fields {
+		set.columns = append(set.columns, field.CHName)
+	}
+
+	set.numRow = slice.Len()
+	for i := 0; i < slice.Len(); i++ {
+		strct := reflect.Indirect(slice.Index(i))
+		for _, field := range table.Fields {
+			var value any
+			if strct.IsValid() {
+				value = field.Value(strct).Interface()
+			}
+			set.values[field.CHName] = append(set.values[field.CHName], value)
+		}
+	}
+
+	return set, nil
+}
+
+func (set *resultSet) addColumns(m map[string]any) error {
+	set.columns = sortedKeys(m)
+	for i, col := range set.columns {
+		values := reflect.ValueOf(m[col])
+		if values.Kind() != reflect.Slice && values.Kind() != reflect.Array {
+			return fmt.Errorf("chtest: column %q is %T, not a slice", col, m[col])
+		}
+
+		if i == 0 {
+			set.numRow = values.Len()
+		} else if values.Len() != set.numRow {
+			return fmt.Errorf("chtest: column %q has %d rows, wanted %d",
+				col, values.Len(), set.numRow)
+		}
+
+		column := make([]any, values.Len())
+		for j := range column {
+			column[j] = values.Index(j).Interface()
+		}
+		set.values[col] = column
+	}
+	return nil
+}
+
+func (set *resultSet) addRows(rows []map[string]any) {
+	seen := make(map[string]struct{})
+	for _, row := range rows {
+		for col := range row {
+			if _, ok := seen[col]; !ok {
+				seen[col] = struct{}{}
+				set.columns = append(set.columns, col)
+			}
+		}
+	}
+	sort.Strings(set.columns)
+
+	set.numRow = len(rows)
+	for _, col := range set.columns {
+		column := make([]any, len(rows))
+		for i, row := range rows {
+			column[i] = row[col]
+		}
+		set.values[col] = column
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (cfg *config) equal(got, want any) bool {
+	v1 := indirect(reflect.ValueOf(got))
+	v2 := indirect(reflect.ValueOf(want))
+
+	if !v1.IsValid() || !v2.IsValid() {
+		return v1.IsValid() == v2.IsValid()
+	}
+
+	if v1.Type() == timeType || v2.Type() == timeType {
+		t1, ok1 := timeValue(v1)
+		t2, ok2 := timeValue(v2)
+		return ok1 && ok2 && cfg.truncate(t1).Equal(cfg.truncate(t2))
+	}
+
+	if isNumber(v1.Kind()) && isNumber(v2.Kind()) {
+		return cfg.equalNumbers(v1, v2)
+	}
+
+	switch v1.Kind() {
+	case reflect.Slice, reflect.Array:
+		if v2.Kind() != reflect.Slice && v2.Kind() != reflect.Array {
+			return false
+		}
+		if v1.Len() != v2.Len() {
+			return false
+		}
+		for i := 0; i < v1.Len(); i++ {
+			if !cfg.equal(v1.Index(i).Interface(), v2.Index(i).Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.Map:
+		if v2.Kind() != reflect.Map || v1.Len() != v2.Len() {
+			return false
+		}
+		iter := v1.MapRange()
+		for iter.Next() {
+			key := iter.Key()
+			if !key.Type().AssignableTo(v2.Type().Key()) {
+				return false
+			}
+			value := v2.MapIndex(key)
+			if !value.IsValid() || !cfg.equal(iter.Value().Interface(), value.Interface()) {
+				return false
+			}
+		}
+		return true
+	case reflect.String:
+		return v2.Kind() == reflect.String && v1.String() == v2.String()
+	}
+
+	return reflect.DeepEqual(v1.Interface(), v2.Interface())
+}
+
+func (cfg *config) equalNumbers(v1, v2 reflect.Value) bool {
+	if isFloat(v1.Kind()) || isFloat(v2.Kind()) {
+		f1, f2 := floatValue(v1), floatValue(v2)
+		if math.IsNaN(f1) || math.IsNaN(f2) {
+			return math.IsNaN(f1) && math.IsNaN(f2)
+		}
+		if f1 == f2 {
+			return true
+		}
+		return math.Abs(f1-f2) <= cfg.floatEpsilon
+	}
+
+	if isUint(v1.Kind()) && isUint(v2.Kind()) {
+		return v1.Uint() == v2.Uint()
+	}
+	if isUint(v1.Kind()) {
+		v1, v2 = v2, v1
+	}
+	if isUint(v2.Kind()) {
+		n := v1.Int()
+		return n >= 0 && uint64(n) == v2.Uint()
+	}
+	return v1.Int() == v2.Int()
+}
+
+func (cfg *config) truncate(tm time.Time) time.Time {
+	if cfg.timeTruncate > 0 {
+		return tm.Truncate(cfg.timeTruncate)
+	}
+	return tm
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+func timeValue(v reflect.Value) (time.Time, bool) {
+	if v.Type() == timeType {
+		return v.Interface().(time.Time), true
+	}
+	if v.Kind() != reflect.String {
+		return time.Time{}, false
+	}
+	for _, layout := range timeLayouts {
+		if tm, err := time.Parse(layout, v.String()); err == nil {
+			return tm, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func isNumber(kind reflect.Kind) bool {
+	return isInt(kind) || isUint(kind) || isFloat(kind)
+}
+
+func isInt(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUint(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func floatValue(v reflect.Value) float64 {
+	switch {
+	case isInt(v.Kind()):
+		return float64(v.Int())
+	case isUint(v.Kind()):
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func formatValue(v any) string {
+	switch v := indirect(reflect.ValueOf(v)); {
+	case !v.IsValid():
+		return "NULL"
+	case v.Type() == timeType:
+		return v.Interface().(time.Time).Format(time.RFC3339Nano)
+	case v.Kind() == reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	default:
+		return fmt.Sprintf("%v (%s)", v.Interface(), v.Type())
+	}
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+package chtest_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uptrace/go-clickhouse/chtest"
+)
+
+type Stat struct {
+	Date  time.Time
+	Name  string
+	Count uint64
+	Avg   float64
+}
+
+func TestDiff(t *testing.T) {
+	date := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	got := map[string]any{
+		"date":  []time.Time{date, date.Add(1500 * time.Millisecond)},
+		"name":  []string{"foo", "bar"},
+		"count": []uint64{1, 2},
+		"avg":   []float64{0.1 + 0.2, math.NaN()},
+	}
+	want := []map[string]any{
+		{"date": "2022-06-01T00:00:00Z", "name": "foo", "count": 1, "avg": 0.3},
+		{"date": "2022-06-01 00:00:01", "name": "bar", "count": int8(2), "avg": math.NaN()},
+	}
+
+	require.NotEqual(t, "", chtest.Diff(got, want))
+	require.Equal(t, "", chtest.Diff(got, want,
+		chtest.WithFloatEpsilon(1e-9),
+		chtest.WithTimeTruncate(time.Second)))
+
+	stats := []*Stat{
+		{Date: date, Name: "foo", Count: 1, Avg: 0.3},
+		{Date: date, Name: "baz", Count: 3, Avg: 0.3},
+	}
+	require.Equal(t, `column "count": row 1:
+	- 3 (uint64)
+	+ 2 (int8)
+column "name": row 1:
+	- "baz"
+	+ "bar"
+`, chtest.Diff(stats, want, chtest.WithIgnoreColumns("date", "avg")))
+
+	require.Equal(t, `rows: got 1, want 2
+column "extra": missing
+column "avg": unexpected
+`, chtest.Diff(stats[:1], []map[string]any{
+		{"date": date, "name": "foo", "count": 1, "extra": nil},
+		{"date": date},
+	}))
+}
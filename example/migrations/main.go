@@ -107,9 +107,25 @@ func newDBCommand(db *ch.DB, migrator *chmigrate.Migrator) *cli.Command {
 			{
 				Name:  "create_sql",
 				Usage: "create up and down SQL migrations",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "up",
+						Usage: "file with the up migration; the down migration is generated from it",
+					},
+				},
 				Action: func(c *cli.Context) error {
 					name := strings.Join(c.Args().Slice(), "_")
-					files, err := migrator.CreateSQLMigrations(c.Context, name)
+
+					var opts []chmigrate.SQLMigrationOption
+					if path := c.String("up"); path != "" {
+						up, err := os.ReadFile(path)
+						if err != nil {
+							return err
+						}
+						opts = append(opts, chmigrate.WithUpSQL(string(up)), chmigrate.WithDownStub())
+					}
+
+					files, err := migrator.CreateSQLMigrations(c.Context, name, opts...)
 					if err != nil {
 						return err
 					}
@@ -124,7 +140,26 @@ func newDBCommand(db *ch.DB, migrator *chmigrate.Migrator) *cli.Command {
 			{
 				Name:  "status",
 				Usage: "print migrations status",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "json",
+						Usage: "print status as JSON and fail when the database is not up to date",
+					},
+				},
 				Action: func(c *cli.Context) error {
+					if c.Bool("json") {
+						report, err := migrator.Status(c.Context)
+						if err != nil {
+							return err
+						}
+						b, err := report.JSON()
+						if err != nil {
+							return err
+						}
+						fmt.Println(string(b))
+						return report.Err()
+					}
+
 					ms, err := migrator.MigrationsWithStatus(c.Context)
 					if err != nil {
 						return err
@@ -8,6 +8,7 @@ require (
 	github.com/bradleyjkemp/cupaloy v2.3.0+incompatible
 	github.com/codemodus/kace v0.5.1
 	github.com/jinzhu/inflection v1.0.0
+	github.com/klauspost/compress v1.15.9
 	github.com/pierrec/lz4/v4 v4.1.15
 	github.com/stretchr/testify v1.7.5
 	github.com/uptrace/go-clickhouse/chdebug v0.2.8
@@ -0,0 +1,231 @@
+// Package chfake implements a fake ClickHouse server for tests. The server
+// speaks just enough of the native protocol to accept the handshake, queries,
+// and inserts. Use it with compression disabled.
+package chfake
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/uptrace/go-clickhouse/ch/chproto"
+	"github.com/uptrace/go-clickhouse/ch/chschema"
+)
+
+// Column is a column of a block sent by the fake server.
+type Column struct {
+	Name   string
+	CHType string
+	Values any
+}
+
+// Reply is the reply of the fake server to a query.
+type Reply struct {
+	Code    int32    // exception code, zero for a successful query
+	Columns []Column // data block sent before the end of the stream
+}
+
+// Server is a fake server that replies to queries with the replies returned
+// by the Reply func. Inserts are replied with the columns of the table schema.
+type Server struct {
+	Reply func(query string) Reply
+
+	mu      sync.Mutex
+	queries []string
+}
+
+// Dial connects to the server. It can be used with ch.WithDialer.
+func (s *Server) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go s.Serve(server)
+	return NoDeadlineConn{client}, nil
+}
+
+// Queries returns the queries received by the server.
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// Serve serves the client connection until it is closed.
+func (s *Server) Serve(conn net.Conn) {
+	defer conn.Close()
+
+	rd := chproto.NewReader(conn)
+	wr := chproto.NewWriter(conn)
+	if err := Handshake(rd, wr); err != nil {
+		return
+	}
+
+	// Replies are written by another goroutine, because net.Pipe is not
+	// buffered and clients send insert blocks before reading the reply.
+	replies := make(chan []byte, 10)
+	defer close(replies)
+	go func() {
+		for b := range replies {
+			if _, err := conn.Write(b); err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		if buf[0] != chproto.ClientQuery {
+			continue // insert blocks
+		}
+
+		query := QueryText(buf[:n])
+		s.mu.Lock()
+		s.queries = append(s.queries, query)
+		s.mu.Unlock()
+
+		var reply Reply
+		if s.Reply != nil {
+			reply = s.Reply(query)
+		}
+
+		var out bytes.Buffer
+		if err := WriteReply(chproto.NewWriter(&out), reply); err != nil {
+			return
+		}
+		replies <- out.Bytes()
+	}
+}
+
+// WriteReply writes the reply to a query.
+func WriteReply(wr *chproto.Writer, reply Reply) error {
+	if reply.Code != 0 {
+		WriteException(wr, reply.Code)
+		return wr.Flush()
+	}
+	if reply.Columns != nil {
+		if err := WriteBlock(wr, reply.Columns); err != nil {
+			return err
+		}
+	}
+	wr.Uvarint(chproto.ServerEndOfStream)
+	return wr.Flush()
+}
+
+// WriteException writes an exception with the code and the nested exceptions
+// with the rest of the codes, for example, the error from the remote shard.
+func WriteException(wr *chproto.Writer, code int32, nested ...int32) {
+	wr.Uvarint(chproto.ServerException)
+	writeExceptionBody(wr, code, nested)
+}
+
+func writeExceptionBody(wr *chproto.Writer, code int32, nested []int32) {
+	wr.Int32(code)
+	wr.String("DB::Exception")
+	wr.String(fmt.Sprintf("fake exception %d", code))
+	wr.String("")
+	wr.Bool(len(nested) > 0)
+	if len(nested) > 0 {
+		writeExceptionBody(wr, nested[0], nested[1:])
+	}
+}
+
+// WriteBlock writes a data block with the columns.
+func WriteBlock(wr *chproto.Writer, columns []Column) error {
+	wr.Uvarint(chproto.ServerData)
+	wr.String("")
+	wr.Uvarint(1) // block info
+	wr.Bool(false)
+	wr.Uvarint(2)
+	wr.Int32(-1)
+	wr.Uvarint(0)
+
+	cols := make([]chschema.Columnar, len(columns))
+	var numRow int
+	for i, col := range columns {
+		cols[i] = chschema.NewColumnFromCHType(col.CHType, 0)
+		if col.Values != nil {
+			cols[i].Set(col.Values)
+		}
+		numRow = cols[i].Len()
+	}
+
+	wr.Uvarint(uint64(len(columns)))
+	wr.Uvarint(uint64(numRow))
+	for i, col := range columns {
+		wr.String(col.Name)
+		wr.String(col.CHType)
+		if err := cols[i].WriteTo(wr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryText returns the query from the query packet. The query is the string
+// that starts with a keyword and is preceded by its length.
+func QueryText(b []byte) string {
+	i := -1
+	for _, keyword := range []string{"SELECT", "INSERT", "ALTER", "CREATE"} {
+		if j := bytes.Index(b, []byte(keyword)); j != -1 && (i == -1 || j < i) {
+			i = j
+		}
+	}
+	if i == -1 {
+		return ""
+	}
+	// Try the longer length first, because the last byte of a 2-byte
+	// length is also a valid 1-byte length.
+	for w := 2; w >= 1; w-- {
+		if w > i {
+			continue
+		}
+		size, n := binary.Uvarint(b[i-w:])
+		if n == w && i+int(size) <= len(b) {
+			return string(b[i : i+int(size)])
+		}
+	}
+	return string(b[i:])
+}
+
+// Handshake reads the client hello and replies with the server hello.
+func Handshake(rd *chproto.Reader, wr *chproto.Writer) error {
+	if _, err := rd.Uvarint(); err != nil { // ClientHello
+		return err
+	}
+	for _, fn := range []func() error{
+		func() error { _, err := rd.String(); return err }, // client name
+		func() error { _, err := rd.Uvarint(); return err },
+		func() error { _, err := rd.Uvarint(); return err },
+		func() error { _, err := rd.Uvarint(); return err }, // revision
+		func() error { _, err := rd.String(); return err },  // database
+		func() error { _, err := rd.String(); return err },  // user
+		func() error { _, err := rd.String(); return err },  // password
+	} {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+
+	wr.Uvarint(chproto.ServerHello)
+	wr.String("fake")
+	wr.Uvarint(23)
+	wr.Uvarint(8)
+	wr.Uvarint(chproto.DBMS_MIN_REVISION_WITH_CLIENT_INFO)
+	return wr.Flush()
+}
+
+// NoDeadlineConn ignores deadlines, because net.Pipe fails to set them after
+// the other end is closed while TCP connections return io.EOF on read.
+type NoDeadlineConn struct {
+	net.Conn
+}
+
+func (NoDeadlineConn) SetDeadline(time.Time) error      { return nil }
+func (NoDeadlineConn) SetReadDeadline(time.Time) error  { return nil }
+func (NoDeadlineConn) SetWriteDeadline(time.Time) error { return nil }
@@ -0,0 +1,10 @@
+// Package chlog contains the logger shared by the packages of the module
+// that can't import ch/internal.
+package chlog
+
+import (
+	"log"
+	"os"
+)
+
+var Logger = log.New(os.Stderr, "ch: ", log.LstdFlags|log.Lshortfile)